@@ -0,0 +1,156 @@
+// Command allocation-simulator evaluates a flag for a sample of contexts and
+// reports the variations each rule serves, with a uniformity check of the
+// flag's buckets.
+//
+// The flag is read from an environment configuration as served by
+// GET /v1/configs/{envKey}:
+//
+//	allocation-simulator -config env.json -flag checkout-v2 < contexts.ndjson
+//
+// or from a flag configuration file, with optional segments and salt:
+//
+//	allocation-simulator -flag-file flag.json -segments segments.json -salt s1 -contexts contexts.ndjson
+//
+// Contexts are newline-delimited JSON evaluation contexts.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/Sidd-007/feature-flag-platform/pkg/bucketing"
+	"github.com/Sidd-007/feature-flag-platform/pkg/hashing"
+	"github.com/Sidd-007/feature-flag-platform/pkg/operators"
+)
+
+// environmentConfig is the part of a published environment configuration a
+// simulation needs
+type environmentConfig struct {
+	Salt        string                              `json:"salt"`
+	HashVersion hashing.Version                     `json:"hash_version"`
+	Holdout     *bucketing.HoldoutConfig            `json:"holdout,omitempty"`
+	Flags       map[string]*bucketing.FlagConfig    `json:"flags"`
+	Segments    map[string]*bucketing.SegmentConfig `json:"segments"`
+}
+
+func main() {
+	configPath := flag.String("config", "", "environment configuration JSON file")
+	flagKey := flag.String("flag", "", "key of the flag to simulate, with -config")
+	flagPath := flag.String("flag-file", "", "flag configuration JSON file, instead of -config")
+	segmentsPath := flag.String("segments", "", "segment configurations JSON file keyed by segment key, with -flag-file")
+	salt := flag.String("salt", "", "environment salt, with -flag-file")
+	contextsPath := flag.String("contexts", "-", "NDJSON contexts file, - for standard input")
+	flag.Parse()
+
+	env, flagConfig, err := loadFlag(*configPath, *flagKey, *flagPath, *segmentsPath, *salt)
+	if err != nil {
+		log.Fatalf("Failed to load flag: %v", err)
+	}
+	if errs := compile(env); len(errs) > 0 {
+		log.Fatalf("Failed to compile configuration: %v", errs[0])
+	}
+
+	contexts := io.Reader(os.Stdin)
+	if *contextsPath != "-" {
+		file, err := os.Open(*contextsPath)
+		if err != nil {
+			log.Fatalf("Failed to open contexts: %v", err)
+		}
+		defer file.Close()
+		contexts = file
+	}
+
+	simulator := bucketing.NewSimulator(flagConfig, env.Flags, env.Segments, env.Salt)
+	if err := simulator.SimulateNDJSON(contexts); err != nil {
+		log.Fatalf("Failed to read contexts: %v", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(simulator.Report()); err != nil {
+		log.Fatalf("Failed to write report: %v", err)
+	}
+}
+
+// loadFlag loads the flag to simulate and the environment it is evaluated in
+func loadFlag(configPath, flagKey, flagPath, segmentsPath, salt string) (*environmentConfig, *bucketing.FlagConfig, error) {
+	switch {
+	case configPath != "" && flagPath != "":
+		return nil, nil, fmt.Errorf("-config and -flag-file are mutually exclusive")
+	case configPath != "":
+		if flagKey == "" {
+			return nil, nil, fmt.Errorf("-flag is required with -config")
+		}
+		env := &environmentConfig{}
+		if err := readJSON(configPath, env); err != nil {
+			return nil, nil, err
+		}
+		flagConfig, exists := env.Flags[flagKey]
+		if !exists || flagConfig == nil {
+			return nil, nil, fmt.Errorf("flag %s not found", flagKey)
+		}
+		return env, flagConfig, nil
+	case flagPath != "":
+		flagConfig := &bucketing.FlagConfig{}
+		if err := readJSON(flagPath, flagConfig); err != nil {
+			return nil, nil, err
+		}
+		env := &environmentConfig{
+			Salt:        salt,
+			HashVersion: flagConfig.HashVersion,
+			Flags:       map[string]*bucketing.FlagConfig{flagConfig.Key: flagConfig},
+		}
+		if segmentsPath != "" {
+			if err := readJSON(segmentsPath, &env.Segments); err != nil {
+				return nil, nil, err
+			}
+		}
+		return env, flagConfig, nil
+	default:
+		return nil, nil, fmt.Errorf("-config or -flag-file is required")
+	}
+}
+
+// compile prepares the environment's flags and segments as evaluators do
+func compile(env *environmentConfig) []error {
+	var errs []error
+	for key, flagConfig := range env.Flags {
+		if flagConfig == nil {
+			continue
+		}
+		if env.HashVersion != 0 {
+			flagConfig.HashVersion = env.HashVersion
+		}
+		if env.Holdout != nil && flagConfig.Experiment != nil {
+			flagConfig.Holdout = env.Holdout
+		}
+		if err := flagConfig.Compile(operators.SystemClock); err != nil {
+			errs = append(errs, fmt.Errorf("flag %s: %w", key, err))
+		}
+	}
+	for key, segment := range env.Segments {
+		if segment == nil {
+			continue
+		}
+		if err := segment.Compile(operators.SystemClock); err != nil {
+			errs = append(errs, fmt.Errorf("segment %s: %w", key, err))
+		}
+	}
+	return errs
+}
+
+// readJSON decodes a JSON file into target
+func readJSON(path string, target any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, target); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
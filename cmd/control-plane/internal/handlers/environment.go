@@ -11,6 +11,7 @@ import (
 
 	"github.com/Sidd-007/feature-flag-platform/cmd/control-plane/internal/repository"
 	"github.com/Sidd-007/feature-flag-platform/cmd/control-plane/internal/services"
+	"github.com/Sidd-007/feature-flag-platform/pkg/bucketing"
 )
 
 // EnvironmentHandler handles environment endpoints
@@ -147,3 +148,119 @@ func (h *EnvironmentHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// GetContextSchema handles GET /environments/{envId}/context-schema
+func (h *EnvironmentHandler) GetContextSchema(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "envId")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, "invalid_env_id", "Invalid environment ID")
+		return
+	}
+
+	schema, err := h.envService.GetContextSchema(r.Context(), id)
+	if err != nil {
+		if err.Error() == "environment not found" {
+			h.sendError(w, http.StatusNotFound, "not_found", err.Error())
+			return
+		}
+		h.sendError(w, http.StatusInternalServerError, "get_failed", err.Error())
+		return
+	}
+	h.sendJSON(w, http.StatusOK, schema)
+}
+
+// UpdateContextSchema handles PUT /environments/{envId}/context-schema
+func (h *EnvironmentHandler) UpdateContextSchema(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "envId")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, "invalid_env_id", "Invalid environment ID")
+		return
+	}
+
+	var schema bucketing.ContextSchema
+	if err := json.NewDecoder(r.Body).Decode(&schema); err != nil {
+		h.sendError(w, http.StatusBadRequest, "invalid_request", "Invalid JSON payload")
+		return
+	}
+
+	updated, err := h.envService.UpdateContextSchema(r.Context(), id, &schema)
+	if err != nil {
+		if err.Error() == "environment not found" {
+			h.sendError(w, http.StatusNotFound, "not_found", err.Error())
+			return
+		}
+		h.sendError(w, http.StatusBadRequest, "update_failed", err.Error())
+		return
+	}
+	h.sendJSON(w, http.StatusOK, updated)
+}
+
+// GetHoldout handles GET /environments/{envId}/holdout
+func (h *EnvironmentHandler) GetHoldout(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "envId")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, "invalid_env_id", "Invalid environment ID")
+		return
+	}
+
+	holdout, err := h.envService.GetHoldout(r.Context(), id)
+	if err != nil {
+		if err.Error() == "environment not found" || err.Error() == "holdout not found" {
+			h.sendError(w, http.StatusNotFound, "not_found", err.Error())
+			return
+		}
+		h.sendError(w, http.StatusInternalServerError, "get_failed", err.Error())
+		return
+	}
+	h.sendJSON(w, http.StatusOK, holdout)
+}
+
+// UpdateHoldout handles PUT /environments/{envId}/holdout
+func (h *EnvironmentHandler) UpdateHoldout(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "envId")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, "invalid_env_id", "Invalid environment ID")
+		return
+	}
+
+	var holdout bucketing.HoldoutConfig
+	if err := json.NewDecoder(r.Body).Decode(&holdout); err != nil {
+		h.sendError(w, http.StatusBadRequest, "invalid_request", "Invalid JSON payload")
+		return
+	}
+
+	updated, err := h.envService.UpdateHoldout(r.Context(), id, &holdout)
+	if err != nil {
+		if err.Error() == "environment not found" {
+			h.sendError(w, http.StatusNotFound, "not_found", err.Error())
+			return
+		}
+		h.sendError(w, http.StatusBadRequest, "update_failed", err.Error())
+		return
+	}
+	h.sendJSON(w, http.StatusOK, updated)
+}
+
+// DeleteHoldout handles DELETE /environments/{envId}/holdout
+func (h *EnvironmentHandler) DeleteHoldout(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "envId")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, "invalid_env_id", "Invalid environment ID")
+		return
+	}
+
+	if err := h.envService.DeleteHoldout(r.Context(), id); err != nil {
+		if err.Error() == "environment not found" {
+			h.sendError(w, http.StatusNotFound, "not_found", err.Error())
+			return
+		}
+		h.sendError(w, http.StatusBadRequest, "delete_failed", err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
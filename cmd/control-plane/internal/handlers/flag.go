@@ -2,8 +2,10 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
@@ -11,8 +13,13 @@ import (
 
 	"github.com/Sidd-007/feature-flag-platform/cmd/control-plane/internal/repository"
 	"github.com/Sidd-007/feature-flag-platform/cmd/control-plane/internal/services"
+	"github.com/Sidd-007/feature-flag-platform/pkg/bucketing"
 )
 
+// maxSimulationBody bounds the size of the contexts an allocation simulation
+// reads
+const maxSimulationBody = 32 << 20
+
 // FlagHandler handles flag endpoints
 type FlagHandler struct {
 	flagService *services.FlagService
@@ -73,6 +80,8 @@ func (h *FlagHandler) List(w http.ResponseWriter, r *http.Request) {
 			"type":          flag.Type,
 			"status":        flag.Status,
 			"enabled":       flag.Status == "active",
+			"on":            flag.On,
+			"off_variation": flag.OffVariation,
 			"default_value": flag.DefaultVariation,
 			"created_at":    flag.CreatedAt,
 			"updated_at":    flag.UpdatedAt,
@@ -156,9 +165,11 @@ func (h *FlagHandler) Update(w http.ResponseWriter, r *http.Request) {
 
 	// Prepare request with defaults from current
 	req := repository.UpdateFlagRequest{
-		Name:        current.Name,
-		Description: current.Description,
-		Status:      current.Status,
+		Name:         current.Name,
+		Description:  current.Description,
+		Status:       current.Status,
+		BucketBy:     current.BucketBy,
+		OffVariation: current.OffVariation,
 	}
 
 	if v, ok := raw["name"].(string); ok && v != "" {
@@ -170,6 +181,12 @@ func (h *FlagHandler) Update(w http.ResponseWriter, r *http.Request) {
 	if v, ok := raw["status"].(string); ok && v != "" {
 		req.Status = v
 	}
+	if v, ok := raw["bucket_by"].(string); ok {
+		req.BucketBy = v
+	}
+	if v, ok := raw["off_variation"].(string); ok {
+		req.OffVariation = v
+	}
 	if v, ok := raw["enabled"].(bool); ok {
 		if v {
 			req.Status = "active"
@@ -226,6 +243,15 @@ func (h *FlagHandler) Publish(w http.ResponseWriter, r *http.Request) {
 	// Publish the individual flag
 	publishedFlag, err := h.flagService.PublishFlag(r.Context(), envID, flagKey)
 	if err != nil {
+		var lintErr *services.RulesLintError
+		if errors.As(err, &lintErr) {
+			h.sendJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+				"error":   "lint_failed",
+				"message": err.Error(),
+				"lint":    lintErr.Report,
+			})
+			return
+		}
 		h.logger.Error().Err(err).Str("env_id", envID.String()).Str("flag_key", flagKey).Msg("Failed to publish flag")
 		h.sendError(w, http.StatusInternalServerError, "publish_failed", err.Error())
 		return
@@ -273,3 +299,353 @@ func (h *FlagHandler) Unpublish(w http.ResponseWriter, r *http.Request) {
 
 	h.sendJSON(w, http.StatusOK, response)
 }
+
+// Kill handles POST /orgs/{orgId}/projects/{projectId}/environments/{envId}/flags/{flagKey}/kill
+func (h *FlagHandler) Kill(w http.ResponseWriter, r *http.Request) {
+	h.setOn(w, r, false)
+}
+
+// Restore handles POST /orgs/{orgId}/projects/{projectId}/environments/{envId}/flags/{flagKey}/restore
+func (h *FlagHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	h.setOn(w, r, true)
+}
+
+// setOn turns the kill switch of the flag in the URL on or off
+func (h *FlagHandler) setOn(w http.ResponseWriter, r *http.Request, on bool) {
+	envIDStr := chi.URLParam(r, "envId")
+	envID, err := uuid.Parse(envIDStr)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, "invalid_env_id", "Invalid environment ID")
+		return
+	}
+	flagKey := chi.URLParam(r, "flagKey")
+
+	flag, err := h.flagService.SetFlagOn(r.Context(), envID, flagKey, on)
+	if err != nil {
+		if err.Error() == "flag not found" {
+			h.sendError(w, http.StatusNotFound, "not_found", err.Error())
+			return
+		}
+		h.sendError(w, http.StatusInternalServerError, "kill_switch_failed", err.Error())
+		return
+	}
+
+	h.sendJSON(w, http.StatusOK, flag)
+}
+
+// UpdateRules handles PUT /orgs/{orgId}/projects/{projectId}/environments/{envId}/flags/{flagKey}/rules
+func (h *FlagHandler) UpdateRules(w http.ResponseWriter, r *http.Request) {
+	envIDStr := chi.URLParam(r, "envId")
+	envID, err := uuid.Parse(envIDStr)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, "invalid_env_id", "Invalid environment ID")
+		return
+	}
+	flagKey := chi.URLParam(r, "flagKey")
+
+	var req struct {
+		Rules []bucketing.Rule `json:"rules"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, http.StatusBadRequest, "invalid_request", "Invalid JSON payload")
+		return
+	}
+
+	flag, report, err := h.flagService.UpdateRules(r.Context(), envID, flagKey, req.Rules)
+	if err != nil {
+		if err.Error() == "flag not found" {
+			h.sendError(w, http.StatusNotFound, "not_found", err.Error())
+			return
+		}
+		h.sendError(w, http.StatusBadRequest, "update_failed", err.Error())
+		return
+	}
+
+	h.sendJSON(w, http.StatusOK, map[string]interface{}{
+		"flag": flag,
+		"lint": report,
+	})
+}
+
+// ControlRamp handles POST /orgs/{orgId}/projects/{projectId}/environments/{envId}/flags/{flagKey}/rules/{ruleId}/ramp
+func (h *FlagHandler) ControlRamp(w http.ResponseWriter, r *http.Request) {
+	envIDStr := chi.URLParam(r, "envId")
+	envID, err := uuid.Parse(envIDStr)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, "invalid_env_id", "Invalid environment ID")
+		return
+	}
+	flagKey := chi.URLParam(r, "flagKey")
+	ruleID := chi.URLParam(r, "ruleId")
+
+	var req struct {
+		Action string `json:"action"` // pause, resume or abort
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, http.StatusBadRequest, "invalid_request", "Invalid JSON payload")
+		return
+	}
+
+	flag, err := h.flagService.ControlRamp(r.Context(), envID, flagKey, ruleID, req.Action)
+	if err != nil {
+		if err.Error() == "flag not found" || err.Error() == "rule not found" {
+			h.sendError(w, http.StatusNotFound, "not_found", err.Error())
+			return
+		}
+		if err.Error() == "failed to update flag rules" {
+			h.logger.Error().Err(err).Str("env_id", envID.String()).Str("flag_key", flagKey).Msg("Failed to update flag ramp")
+			h.sendError(w, http.StatusInternalServerError, "update_failed", err.Error())
+			return
+		}
+		h.sendError(w, http.StatusBadRequest, "invalid_ramp_action", err.Error())
+		return
+	}
+
+	h.sendJSON(w, http.StatusOK, flag)
+}
+
+// UpdatePrerequisites handles PUT /orgs/{orgId}/projects/{projectId}/environments/{envId}/flags/{flagKey}/prerequisites
+func (h *FlagHandler) UpdatePrerequisites(w http.ResponseWriter, r *http.Request) {
+	envIDStr := chi.URLParam(r, "envId")
+	envID, err := uuid.Parse(envIDStr)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, "invalid_env_id", "Invalid environment ID")
+		return
+	}
+	flagKey := chi.URLParam(r, "flagKey")
+
+	var req struct {
+		Prerequisites []bucketing.Prerequisite `json:"prerequisites"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, http.StatusBadRequest, "invalid_request", "Invalid JSON payload")
+		return
+	}
+
+	flag, err := h.flagService.UpdatePrerequisites(r.Context(), envID, flagKey, req.Prerequisites)
+	if err != nil {
+		var cycleErr *services.PrerequisiteCycleError
+		if errors.As(err, &cycleErr) {
+			h.sendJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+				"error":   "prerequisite_cycle",
+				"message": err.Error(),
+				"cycle":   cycleErr.Cycle,
+			})
+			return
+		}
+		if err.Error() == "flag not found" {
+			h.sendError(w, http.StatusNotFound, "not_found", err.Error())
+			return
+		}
+		h.sendError(w, http.StatusBadRequest, "update_failed", err.Error())
+		return
+	}
+
+	h.sendJSON(w, http.StatusOK, flag)
+}
+
+// ResetAssignments handles DELETE /orgs/{orgId}/projects/{projectId}/environments/{envId}/flags/{flagKey}/assignments
+func (h *FlagHandler) ResetAssignments(w http.ResponseWriter, r *http.Request) {
+	envIDStr := chi.URLParam(r, "envId")
+	envID, err := uuid.Parse(envIDStr)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, "invalid_env_id", "Invalid environment ID")
+		return
+	}
+	flagKey := chi.URLParam(r, "flagKey")
+
+	if err := h.flagService.ResetAssignments(r.Context(), envID, flagKey); err != nil {
+		if err.Error() == "flag not found" || err.Error() == "environment not found" {
+			h.sendError(w, http.StatusNotFound, "not_found", err.Error())
+			return
+		}
+		h.sendError(w, http.StatusInternalServerError, "reset_failed", err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetTargets handles GET /orgs/{orgId}/projects/{projectId}/environments/{envId}/flags/{flagKey}/targets
+func (h *FlagHandler) GetTargets(w http.ResponseWriter, r *http.Request) {
+	envIDStr := chi.URLParam(r, "envId")
+	envID, err := uuid.Parse(envIDStr)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, "invalid_env_id", "Invalid environment ID")
+		return
+	}
+	flagKey := chi.URLParam(r, "flagKey")
+
+	targets, err := h.flagService.GetTargets(r.Context(), envID, flagKey)
+	if err != nil {
+		if err.Error() == "flag not found" {
+			h.sendError(w, http.StatusNotFound, "not_found", err.Error())
+			return
+		}
+		h.sendError(w, http.StatusInternalServerError, "targets_failed", err.Error())
+		return
+	}
+
+	h.sendJSON(w, http.StatusOK, targets)
+}
+
+// UpdateTargets handles PATCH /orgs/{orgId}/projects/{projectId}/environments/{envId}/flags/{flagKey}/targets
+func (h *FlagHandler) UpdateTargets(w http.ResponseWriter, r *http.Request) {
+	envIDStr := chi.URLParam(r, "envId")
+	envID, err := uuid.Parse(envIDStr)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, "invalid_env_id", "Invalid environment ID")
+		return
+	}
+	flagKey := chi.URLParam(r, "flagKey")
+
+	var req repository.UpdateFlagTargetsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, http.StatusBadRequest, "invalid_request", "Invalid JSON payload")
+		return
+	}
+
+	targets, err := h.flagService.UpdateTargets(r.Context(), envID, flagKey, &req)
+	if err != nil {
+		if err.Error() == "flag not found" {
+			h.sendError(w, http.StatusNotFound, "not_found", err.Error())
+			return
+		}
+		h.sendError(w, http.StatusBadRequest, "update_failed", err.Error())
+		return
+	}
+
+	h.sendJSON(w, http.StatusOK, targets)
+}
+
+// PrerequisiteGraph handles GET /orgs/{orgId}/projects/{projectId}/environments/{envId}/prerequisite-graph
+func (h *FlagHandler) PrerequisiteGraph(w http.ResponseWriter, r *http.Request) {
+	envIDStr := chi.URLParam(r, "envId")
+	envID, err := uuid.Parse(envIDStr)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, "invalid_env_id", "Invalid environment ID")
+		return
+	}
+
+	graph, err := h.flagService.PrerequisiteGraph(r.Context(), envID)
+	if err != nil {
+		if err.Error() == "environment not found" {
+			h.sendError(w, http.StatusNotFound, "not_found", err.Error())
+			return
+		}
+		h.sendError(w, http.StatusInternalServerError, "graph_failed", err.Error())
+		return
+	}
+
+	h.sendJSON(w, http.StatusOK, graph)
+}
+
+// Lint handles POST /orgs/{orgId}/projects/{projectId}/environments/{envId}/flags/{flagKey}/lint
+func (h *FlagHandler) Lint(w http.ResponseWriter, r *http.Request) {
+	envIDStr := chi.URLParam(r, "envId")
+	envID, err := uuid.Parse(envIDStr)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, "invalid_env_id", "Invalid environment ID")
+		return
+	}
+	flagKey := chi.URLParam(r, "flagKey")
+
+	report, err := h.flagService.LintRules(r.Context(), envID, flagKey)
+	if err != nil {
+		if err.Error() == "flag not found" {
+			h.sendError(w, http.StatusNotFound, "not_found", err.Error())
+			return
+		}
+		h.sendError(w, http.StatusInternalServerError, "lint_failed", err.Error())
+		return
+	}
+
+	h.sendJSON(w, http.StatusOK, report)
+}
+
+// PreviewRules handles POST /orgs/{orgId}/projects/{projectId}/environments/{envId}/flags/{flagKey}/diff
+func (h *FlagHandler) PreviewRules(w http.ResponseWriter, r *http.Request) {
+	envIDStr := chi.URLParam(r, "envId")
+	envID, err := uuid.Parse(envIDStr)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, "invalid_env_id", "Invalid environment ID")
+		return
+	}
+	flagKey := chi.URLParam(r, "flagKey")
+
+	var req struct {
+		Rules []bucketing.Rule `json:"rules"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, http.StatusBadRequest, "invalid_request", "Invalid JSON payload")
+		return
+	}
+
+	diff, err := h.flagService.PreviewRules(r.Context(), envID, flagKey, req.Rules)
+	if err != nil {
+		if err.Error() == "flag not found" {
+			h.sendError(w, http.StatusNotFound, "not_found", err.Error())
+			return
+		}
+		h.sendError(w, http.StatusInternalServerError, "diff_failed", err.Error())
+		return
+	}
+
+	h.sendJSON(w, http.StatusOK, diff)
+}
+
+// Simulate handles POST /orgs/{orgId}/projects/{projectId}/environments/{envId}/flags/{flagKey}/simulate
+// with a newline-delimited JSON body of evaluation contexts
+func (h *FlagHandler) Simulate(w http.ResponseWriter, r *http.Request) {
+	envIDStr := chi.URLParam(r, "envId")
+	envID, err := uuid.Parse(envIDStr)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, "invalid_env_id", "Invalid environment ID")
+		return
+	}
+	flagKey := chi.URLParam(r, "flagKey")
+
+	body := http.MaxBytesReader(w, r.Body, maxSimulationBody)
+	report, err := h.flagService.SimulateAllocation(r.Context(), envID, flagKey, body)
+	if err != nil {
+		if err.Error() == "flag not found" || err.Error() == "environment not found" {
+			h.sendError(w, http.StatusNotFound, "not_found", err.Error())
+			return
+		}
+		if strings.HasPrefix(err.Error(), "invalid contexts") {
+			h.sendError(w, http.StatusBadRequest, "invalid_contexts", err.Error())
+			return
+		}
+		h.sendError(w, http.StatusInternalServerError, "simulation_failed", err.Error())
+		return
+	}
+
+	h.sendJSON(w, http.StatusOK, report)
+}
+
+// Compare handles GET /orgs/{orgId}/projects/{projectId}/environments/{envId}/flags/{flagKey}/compare?env={otherEnvId}
+func (h *FlagHandler) Compare(w http.ResponseWriter, r *http.Request) {
+	envIDStr := chi.URLParam(r, "envId")
+	envID, err := uuid.Parse(envIDStr)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, "invalid_env_id", "Invalid environment ID")
+		return
+	}
+	otherEnvID, err := uuid.Parse(r.URL.Query().Get("env"))
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, "invalid_env_id", "Invalid environment ID to compare with")
+		return
+	}
+	flagKey := chi.URLParam(r, "flagKey")
+
+	diff, err := h.flagService.CompareEnvironments(r.Context(), envID, flagKey, otherEnvID)
+	if err != nil {
+		if err.Error() == "flag not found" || err.Error() == "environment not found" {
+			h.sendError(w, http.StatusNotFound, "not_found", err.Error())
+			return
+		}
+		h.sendError(w, http.StatusInternalServerError, "diff_failed", err.Error())
+		return
+	}
+
+	h.sendJSON(w, http.StatusOK, diff)
+}
@@ -2,8 +2,10 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
@@ -94,7 +96,7 @@ func (h *SegmentHandler) Create(w http.ResponseWriter, r *http.Request) {
 		if err.Error() == "segment key already exists" ||
 			err.Error() == "segment key is required" ||
 			err.Error() == "segment name is required" ||
-			err.Error() == "invalid rules" {
+			strings.HasPrefix(err.Error(), "invalid rules") {
 			h.sendError(w, http.StatusBadRequest, err.Error())
 			return
 		}
@@ -152,7 +154,7 @@ func (h *SegmentHandler) Update(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		if err.Error() == "invalid rules" {
+		if strings.HasPrefix(err.Error(), "invalid rules") {
 			h.sendError(w, http.StatusBadRequest, err.Error())
 			return
 		}
@@ -181,6 +183,17 @@ func (h *SegmentHandler) Delete(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		var inUseErr *services.SegmentInUseError
+		if errors.As(err, &inUseErr) {
+			h.sendJSON(w, http.StatusConflict, map[string]interface{}{
+				"error":    "segment_in_use",
+				"message":  err.Error(),
+				"flags":    inUseErr.Flags,
+				"segments": inUseErr.Segments,
+			})
+			return
+		}
+
 		h.logger.Error().Err(err).Msg("Failed to delete segment")
 		h.sendError(w, http.StatusInternalServerError, "Failed to delete segment")
 		return
@@ -189,6 +202,64 @@ func (h *SegmentHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// GetTargets handles GET /segments/{segmentId}/targets
+func (h *SegmentHandler) GetTargets(w http.ResponseWriter, r *http.Request) {
+	segmentIDStr := chi.URLParam(r, "segmentId")
+	segmentID, err := uuid.Parse(segmentIDStr)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, "Invalid segment ID")
+		return
+	}
+
+	targets, err := h.segmentService.GetTargets(r.Context(), segmentID)
+	if err != nil {
+		if err.Error() == "segment not found" {
+			h.sendError(w, http.StatusNotFound, "Segment not found")
+			return
+		}
+
+		h.logger.Error().Err(err).Msg("Failed to get segment targets")
+		h.sendError(w, http.StatusInternalServerError, "Failed to get segment targets")
+		return
+	}
+
+	h.sendJSON(w, http.StatusOK, targets)
+}
+
+// UpdateTargets handles PATCH /segments/{segmentId}/targets
+func (h *SegmentHandler) UpdateTargets(w http.ResponseWriter, r *http.Request) {
+	segmentIDStr := chi.URLParam(r, "segmentId")
+	segmentID, err := uuid.Parse(segmentIDStr)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, "Invalid segment ID")
+		return
+	}
+
+	var req repository.UpdateSegmentTargetsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	targets, err := h.segmentService.UpdateTargets(r.Context(), segmentID, &req)
+	if err != nil {
+		if err.Error() == "segment not found" {
+			h.sendError(w, http.StatusNotFound, "Segment not found")
+			return
+		}
+		if err.Error() == "failed to update segment targets" {
+			h.logger.Error().Err(err).Msg("Failed to update segment targets")
+			h.sendError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		h.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.sendJSON(w, http.StatusOK, targets)
+}
+
 // sendJSON sends a JSON response
 func (h *SegmentHandler) sendJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
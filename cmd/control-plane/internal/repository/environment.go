@@ -8,6 +8,8 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/zerolog"
+
+	"github.com/Sidd-007/feature-flag-platform/pkg/hashing"
 )
 
 // Environment represents an environment record
@@ -21,6 +23,9 @@ type Environment struct {
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
 	Version   int       `json:"version" db:"version"`
+
+	// HashVersion is the bucketing hash version, fixed at creation
+	HashVersion hashing.Version `json:"hash_version" db:"hash_version"`
 }
 
 // CreateEnvironmentRequest input for creating an environment
@@ -30,6 +35,9 @@ type CreateEnvironmentRequest struct {
 	Key         string    `json:"key"`
 	Description string    `json:"description"` // not persisted currently
 	IsProd      bool      `json:"is_prod"`
+
+	// HashVersion defaults to hashing.DefaultHashVersion
+	HashVersion hashing.Version `json:"hash_version,omitempty"`
 }
 
 // UpdateEnvironmentRequest input for updating an environment
@@ -60,10 +68,12 @@ func (r *EnvironmentRepository) Create(ctx context.Context, req *CreateEnvironme
 		Name:      req.Name,
 		Key:       req.Key,
 		IsProd:    req.IsProd,
+
+		HashVersion: req.HashVersion,
 	}
 
-	query := `INSERT INTO environments (id, project_id, name, key, is_prod) VALUES ($1, $2, $3, $4, $5) RETURNING salt, created_at, updated_at, version`
-	if err := r.db.QueryRow(ctx, query, env.ID, env.ProjectID, env.Name, env.Key, env.IsProd).Scan(&env.Salt, &env.CreatedAt, &env.UpdatedAt, &env.Version); err != nil {
+	query := `INSERT INTO environments (id, project_id, name, key, is_prod, hash_version) VALUES ($1, $2, $3, $4, $5, $6) RETURNING salt, created_at, updated_at, version`
+	if err := r.db.QueryRow(ctx, query, env.ID, env.ProjectID, env.Name, env.Key, env.IsProd, env.HashVersion).Scan(&env.Salt, &env.CreatedAt, &env.UpdatedAt, &env.Version); err != nil {
 		r.logger.Error().Err(err).Msg("Failed to create environment")
 		return nil, err
 	}
@@ -73,8 +83,8 @@ func (r *EnvironmentRepository) Create(ctx context.Context, req *CreateEnvironme
 // GetByID fetches environment by id
 func (r *EnvironmentRepository) GetByID(ctx context.Context, id uuid.UUID) (*Environment, error) {
 	env := &Environment{}
-	query := `SELECT id, project_id, name, key, salt, is_prod, created_at, updated_at, version FROM environments WHERE id = $1`
-	if err := r.db.QueryRow(ctx, query, id).Scan(&env.ID, &env.ProjectID, &env.Name, &env.Key, &env.Salt, &env.IsProd, &env.CreatedAt, &env.UpdatedAt, &env.Version); err != nil {
+	query := `SELECT id, project_id, name, key, salt, is_prod, created_at, updated_at, version, hash_version FROM environments WHERE id = $1`
+	if err := r.db.QueryRow(ctx, query, id).Scan(&env.ID, &env.ProjectID, &env.Name, &env.Key, &env.Salt, &env.IsProd, &env.CreatedAt, &env.UpdatedAt, &env.Version, &env.HashVersion); err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, ErrNotFound
 		}
@@ -86,7 +96,7 @@ func (r *EnvironmentRepository) GetByID(ctx context.Context, id uuid.UUID) (*Env
 
 // List returns environments for a project (paginated)
 func (r *EnvironmentRepository) List(ctx context.Context, projectID uuid.UUID, limit, offset int) ([]*Environment, int, error) {
-	rows, err := r.db.Query(ctx, `SELECT id, project_id, name, key, salt, is_prod, created_at, updated_at, version FROM environments WHERE project_id = $1 ORDER BY created_at DESC LIMIT $2 OFFSET $3`, projectID, limit, offset)
+	rows, err := r.db.Query(ctx, `SELECT id, project_id, name, key, salt, is_prod, created_at, updated_at, version, hash_version FROM environments WHERE project_id = $1 ORDER BY created_at DESC LIMIT $2 OFFSET $3`, projectID, limit, offset)
 	if err != nil {
 		r.logger.Error().Err(err).Msg("Failed to list environments")
 		return nil, 0, err
@@ -96,7 +106,7 @@ func (r *EnvironmentRepository) List(ctx context.Context, projectID uuid.UUID, l
 	var envs []*Environment
 	for rows.Next() {
 		e := &Environment{}
-		if err := rows.Scan(&e.ID, &e.ProjectID, &e.Name, &e.Key, &e.Salt, &e.IsProd, &e.CreatedAt, &e.UpdatedAt, &e.Version); err != nil {
+		if err := rows.Scan(&e.ID, &e.ProjectID, &e.Name, &e.Key, &e.Salt, &e.IsProd, &e.CreatedAt, &e.UpdatedAt, &e.Version, &e.HashVersion); err != nil {
 			r.logger.Error().Err(err).Msg("Failed to scan environment")
 			return nil, 0, err
 		}
@@ -113,8 +123,8 @@ func (r *EnvironmentRepository) List(ctx context.Context, projectID uuid.UUID, l
 // Update modifies an environment
 func (r *EnvironmentRepository) Update(ctx context.Context, id uuid.UUID, req *UpdateEnvironmentRequest) (*Environment, error) {
 	env := &Environment{}
-	query := `UPDATE environments SET name = $2, is_prod = $3, updated_at = NOW(), version = version + 1 WHERE id = $1 RETURNING id, project_id, name, key, salt, is_prod, created_at, updated_at, version`
-	if err := r.db.QueryRow(ctx, query, id, req.Name, req.IsProd).Scan(&env.ID, &env.ProjectID, &env.Name, &env.Key, &env.Salt, &env.IsProd, &env.CreatedAt, &env.UpdatedAt, &env.Version); err != nil {
+	query := `UPDATE environments SET name = $2, is_prod = $3, updated_at = NOW(), version = version + 1 WHERE id = $1 RETURNING id, project_id, name, key, salt, is_prod, created_at, updated_at, version, hash_version`
+	if err := r.db.QueryRow(ctx, query, id, req.Name, req.IsProd).Scan(&env.ID, &env.ProjectID, &env.Name, &env.Key, &env.Salt, &env.IsProd, &env.CreatedAt, &env.UpdatedAt, &env.Version, &env.HashVersion); err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, ErrNotFound
 		}
@@ -154,17 +164,70 @@ func (r *EnvironmentRepository) IncrementVersion(ctx context.Context, id uuid.UU
 	return nil
 }
 
+// GetContextSchema returns the raw context schema of an environment
+func (r *EnvironmentRepository) GetContextSchema(ctx context.Context, id uuid.UUID) (any, error) {
+	var schema any
+	if err := r.db.QueryRow(ctx, `SELECT context_schema FROM environments WHERE id = $1`, id).Scan(&schema); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		r.logger.Error().Err(err).Msg("Failed to get context schema")
+		return nil, err
+	}
+	return schema, nil
+}
+
+// UpdateContextSchema replaces the context schema of an environment
+func (r *EnvironmentRepository) UpdateContextSchema(ctx context.Context, id uuid.UUID, schemaJSON []byte) error {
+	res, err := r.db.Exec(ctx, `UPDATE environments SET context_schema = $2, updated_at = NOW() WHERE id = $1`, id, schemaJSON)
+	if err != nil {
+		r.logger.Error().Err(err).Msg("Failed to update context schema")
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetHoldout returns the raw holdout of an environment, nil when it has none
+func (r *EnvironmentRepository) GetHoldout(ctx context.Context, id uuid.UUID) (any, error) {
+	var holdout any
+	if err := r.db.QueryRow(ctx, `SELECT holdout FROM environments WHERE id = $1`, id).Scan(&holdout); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		r.logger.Error().Err(err).Msg("Failed to get holdout")
+		return nil, err
+	}
+	return holdout, nil
+}
+
+// UpdateHoldout replaces the holdout of an environment. A nil holdout removes
+// it.
+func (r *EnvironmentRepository) UpdateHoldout(ctx context.Context, id uuid.UUID, holdoutJSON []byte) error {
+	res, err := r.db.Exec(ctx, `UPDATE environments SET holdout = $2, updated_at = NOW() WHERE id = $1`, id, holdoutJSON)
+	if err != nil {
+		r.logger.Error().Err(err).Msg("Failed to update holdout")
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
 // GetByKey retrieves an environment by its key
 func (r *EnvironmentRepository) GetByKey(ctx context.Context, key string) (*Environment, error) {
 	env := &Environment{}
 	query := `
-		SELECT id, project_id, name, key, salt, is_prod, created_at, updated_at, version
+		SELECT id, project_id, name, key, salt, is_prod, created_at, updated_at, version, hash_version
 		FROM environments 
 		WHERE key = $1`
 
 	err := r.db.QueryRow(ctx, query, key).Scan(
 		&env.ID, &env.ProjectID, &env.Name, &env.Key, &env.Salt,
-		&env.IsProd, &env.CreatedAt, &env.UpdatedAt, &env.Version,
+		&env.IsProd, &env.CreatedAt, &env.UpdatedAt, &env.Version, &env.HashVersion,
 	)
 
 	if err != nil {
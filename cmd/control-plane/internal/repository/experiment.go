@@ -11,14 +11,17 @@ import (
 
 // Experiment represents an A/B test experiment
 type Experiment struct {
-	ID          uuid.UUID `json:"id"`
-	EnvID       uuid.UUID `json:"env_id"`
-	FlagKey     string    `json:"flag_key"`
-	Name        string    `json:"name"`
-	Description string    `json:"description,omitempty"`
-	Status      string    `json:"status"` // draft, running, completed, archived
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID                uuid.UUID `json:"id"`
+	EnvID             uuid.UUID `json:"env_id"`
+	FlagKey           string    `json:"flag_key"`
+	Key               string    `json:"key"`
+	Name              string    `json:"name"`
+	Description       string    `json:"description,omitempty"`
+	Status            string    `json:"status"` // draft, running, completed, archived
+	TrafficAllocation float64   `json:"traffic_allocation"`
+	ExclusionGroup    string    `json:"exclusion_group,omitempty"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
 }
 
 // ExperimentRepository handles experiment persistence (placeholder)
@@ -50,3 +53,30 @@ func (r *ExperimentRepository) List(ctx context.Context, envID uuid.UUID) ([]*Ex
 	// Placeholder implementation
 	return []*Experiment{}, nil
 }
+
+// ListRunning returns the running experiments of an environment with the keys
+// of their flags, oldest first
+func (r *ExperimentRepository) ListRunning(ctx context.Context, envID uuid.UUID) ([]*Experiment, error) {
+	rows, err := r.db.Query(ctx, `SELECT e.id, e.env_id, f.key, e.key, e.name, COALESCE(e.description, ''), e.status,
+		COALESCE(e.traffic_allocation, 1.0)::float8, COALESCE(e.exclusion_group, ''), e.created_at, e.updated_at
+		FROM experiments e JOIN flags f ON f.id = e.flag_id
+		WHERE e.env_id = $1 AND e.status = 'running'
+		ORDER BY e.created_at, e.key`, envID)
+	if err != nil {
+		r.logger.Error().Err(err).Msg("Failed to list running experiments")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var experiments []*Experiment
+	for rows.Next() {
+		e := &Experiment{}
+		if err := rows.Scan(&e.ID, &e.EnvID, &e.FlagKey, &e.Key, &e.Name, &e.Description, &e.Status,
+			&e.TrafficAllocation, &e.ExclusionGroup, &e.CreatedAt, &e.UpdatedAt); err != nil {
+			r.logger.Error().Err(err).Msg("Failed to scan experiment")
+			return nil, err
+		}
+		experiments = append(experiments, e)
+	}
+	return experiments, rows.Err()
+}
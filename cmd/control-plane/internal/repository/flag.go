@@ -25,6 +25,11 @@ type Flag struct {
 	DefaultVariation string    `json:"default_variation" db:"default_variation"`
 	Variations       any       `json:"variations" db:"variations"`
 	RulesJSON        any       `json:"rules_json" db:"rules_json"`
+	PublishedRules   any       `json:"published_rules" db:"published_rules"` // rules evaluators are shipped; nil until first published
+	BucketBy         string    `json:"bucket_by" db:"bucket_by"`             // attribute users are bucketed by; empty for the user key
+	Prerequisites    any       `json:"prerequisites" db:"prerequisites"`
+	On               bool      `json:"on" db:"is_on"`                    // kill switch; a flag that is off serves its off variation
+	OffVariation     string    `json:"off_variation" db:"off_variation"` // variation served while off; empty for the default variation
 	CreatedAt        time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt        time.Time `json:"updated_at" db:"updated_at"`
 	Version          int       `json:"version" db:"version"`
@@ -39,13 +44,16 @@ type CreateFlagRequest struct {
 	Type         string    `json:"type"`
 	Enabled      bool      `json:"enabled"`
 	DefaultValue any       `json:"default_value"`
+	BucketBy     string    `json:"bucket_by"`
 }
 
 // UpdateFlagRequest input for updating a flag
 type UpdateFlagRequest struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Status      string `json:"status"`
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	Status       string `json:"status"`
+	BucketBy     string `json:"bucket_by"`
+	OffVariation string `json:"off_variation"`
 }
 
 // FlagRepository handles flag data access
@@ -105,15 +113,18 @@ func (r *FlagRepository) Create(ctx context.Context, req *CreateFlagRequest) (*F
 		variationsJSON = `[]`
 	}
 
-	query := `INSERT INTO flags (id, env_id, key, name, description, type, status, published, default_variation, variations, rules_json)
-		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9, $10::jsonb, '{}'::jsonb)
+	query := `INSERT INTO flags (id, env_id, key, name, description, type, status, published, default_variation, variations, rules_json, bucket_by)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9, $10::jsonb, '{}'::jsonb, $11)
 		RETURNING created_at, updated_at, version`
-	if err := r.db.QueryRow(ctx, query, flag.ID, flag.EnvID, flag.Key, flag.Name, flag.Description, flag.Type, status, false, defaultVariation, variationsJSON).Scan(&flag.CreatedAt, &flag.UpdatedAt, &flag.Version); err != nil {
+	if err := r.db.QueryRow(ctx, query, flag.ID, flag.EnvID, flag.Key, flag.Name, flag.Description, flag.Type, status, false, defaultVariation, variationsJSON, req.BucketBy).Scan(&flag.CreatedAt, &flag.UpdatedAt, &flag.Version); err != nil {
 		r.logger.Error().Err(err).Msg("Failed to create flag")
 		return nil, err
 	}
 	flag.Status = status
 	flag.DefaultVariation = defaultVariation
+	flag.BucketBy = req.BucketBy
+	flag.Prerequisites = []any{}
+	flag.On = true
 	flag.Published = false // Flags start unpublished
 	return flag, nil
 }
@@ -121,8 +132,8 @@ func (r *FlagRepository) Create(ctx context.Context, req *CreateFlagRequest) (*F
 // GetByID returns flag by ID
 func (r *FlagRepository) GetByID(ctx context.Context, id uuid.UUID) (*Flag, error) {
 	f := &Flag{}
-	q := `SELECT id, env_id, key, name, description, type, status, published, default_variation, variations, rules_json, created_at, updated_at, version FROM flags WHERE id=$1`
-	if err := r.db.QueryRow(ctx, q, id).Scan(&f.ID, &f.EnvID, &f.Key, &f.Name, &f.Description, &f.Type, &f.Status, &f.Published, &f.DefaultVariation, &f.Variations, &f.RulesJSON, &f.CreatedAt, &f.UpdatedAt, &f.Version); err != nil {
+	q := `SELECT id, env_id, key, name, description, type, status, published, default_variation, variations, rules_json, published_rules, bucket_by, prerequisites, is_on, off_variation, created_at, updated_at, version FROM flags WHERE id=$1`
+	if err := r.db.QueryRow(ctx, q, id).Scan(&f.ID, &f.EnvID, &f.Key, &f.Name, &f.Description, &f.Type, &f.Status, &f.Published, &f.DefaultVariation, &f.Variations, &f.RulesJSON, &f.PublishedRules, &f.BucketBy, &f.Prerequisites, &f.On, &f.OffVariation, &f.CreatedAt, &f.UpdatedAt, &f.Version); err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, ErrNotFound
 		}
@@ -135,8 +146,8 @@ func (r *FlagRepository) GetByID(ctx context.Context, id uuid.UUID) (*Flag, erro
 // GetByKey returns flag by env and key
 func (r *FlagRepository) GetByKey(ctx context.Context, envID uuid.UUID, key string) (*Flag, error) {
 	f := &Flag{}
-	q := `SELECT id, env_id, key, name, description, type, status, published, default_variation, variations, rules_json, created_at, updated_at, version FROM flags WHERE env_id=$1 AND key=$2`
-	if err := r.db.QueryRow(ctx, q, envID, key).Scan(&f.ID, &f.EnvID, &f.Key, &f.Name, &f.Description, &f.Type, &f.Status, &f.Published, &f.DefaultVariation, &f.Variations, &f.RulesJSON, &f.CreatedAt, &f.UpdatedAt, &f.Version); err != nil {
+	q := `SELECT id, env_id, key, name, description, type, status, published, default_variation, variations, rules_json, published_rules, bucket_by, prerequisites, is_on, off_variation, created_at, updated_at, version FROM flags WHERE env_id=$1 AND key=$2`
+	if err := r.db.QueryRow(ctx, q, envID, key).Scan(&f.ID, &f.EnvID, &f.Key, &f.Name, &f.Description, &f.Type, &f.Status, &f.Published, &f.DefaultVariation, &f.Variations, &f.RulesJSON, &f.PublishedRules, &f.BucketBy, &f.Prerequisites, &f.On, &f.OffVariation, &f.CreatedAt, &f.UpdatedAt, &f.Version); err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, ErrNotFound
 		}
@@ -148,7 +159,7 @@ func (r *FlagRepository) GetByKey(ctx context.Context, envID uuid.UUID, key stri
 
 // List returns flags for an environment
 func (r *FlagRepository) List(ctx context.Context, envID uuid.UUID, limit, offset int) ([]*Flag, int, error) {
-	rows, err := r.db.Query(ctx, `SELECT id, env_id, key, name, description, type, status, published, default_variation, variations, rules_json, created_at, updated_at, version FROM flags WHERE env_id=$1 ORDER BY created_at DESC LIMIT $2 OFFSET $3`, envID, limit, offset)
+	rows, err := r.db.Query(ctx, `SELECT id, env_id, key, name, description, type, status, published, default_variation, variations, rules_json, published_rules, bucket_by, prerequisites, is_on, off_variation, created_at, updated_at, version FROM flags WHERE env_id=$1 ORDER BY created_at DESC LIMIT $2 OFFSET $3`, envID, limit, offset)
 	if err != nil {
 		r.logger.Error().Err(err).Msg("Failed to list flags")
 		return nil, 0, err
@@ -157,7 +168,7 @@ func (r *FlagRepository) List(ctx context.Context, envID uuid.UUID, limit, offse
 	var flags []*Flag
 	for rows.Next() {
 		f := &Flag{}
-		if err := rows.Scan(&f.ID, &f.EnvID, &f.Key, &f.Name, &f.Description, &f.Type, &f.Status, &f.Published, &f.DefaultVariation, &f.Variations, &f.RulesJSON, &f.CreatedAt, &f.UpdatedAt, &f.Version); err != nil {
+		if err := rows.Scan(&f.ID, &f.EnvID, &f.Key, &f.Name, &f.Description, &f.Type, &f.Status, &f.Published, &f.DefaultVariation, &f.Variations, &f.RulesJSON, &f.PublishedRules, &f.BucketBy, &f.Prerequisites, &f.On, &f.OffVariation, &f.CreatedAt, &f.UpdatedAt, &f.Version); err != nil {
 			r.logger.Error().Err(err).Msg("Failed to scan flag")
 			return nil, 0, err
 		}
@@ -174,8 +185,8 @@ func (r *FlagRepository) List(ctx context.Context, envID uuid.UUID, limit, offse
 // Update updates a flag
 func (r *FlagRepository) Update(ctx context.Context, id uuid.UUID, req *UpdateFlagRequest) (*Flag, error) {
 	f := &Flag{}
-	q := `UPDATE flags SET name=$2, description=$3, status=$4, updated_at=NOW(), version = version + 1 WHERE id=$1 RETURNING id, env_id, key, name, description, type, status, published, default_variation, variations, rules_json, created_at, updated_at, version`
-	if err := r.db.QueryRow(ctx, q, id, req.Name, req.Description, req.Status).Scan(&f.ID, &f.EnvID, &f.Key, &f.Name, &f.Description, &f.Type, &f.Status, &f.Published, &f.DefaultVariation, &f.Variations, &f.RulesJSON, &f.CreatedAt, &f.UpdatedAt, &f.Version); err != nil {
+	q := `UPDATE flags SET name=$2, description=$3, status=$4, bucket_by=$5, off_variation=$6, updated_at=NOW(), version = version + 1 WHERE id=$1 RETURNING id, env_id, key, name, description, type, status, published, default_variation, variations, rules_json, published_rules, bucket_by, prerequisites, is_on, off_variation, created_at, updated_at, version`
+	if err := r.db.QueryRow(ctx, q, id, req.Name, req.Description, req.Status, req.BucketBy, req.OffVariation).Scan(&f.ID, &f.EnvID, &f.Key, &f.Name, &f.Description, &f.Type, &f.Status, &f.Published, &f.DefaultVariation, &f.Variations, &f.RulesJSON, &f.PublishedRules, &f.BucketBy, &f.Prerequisites, &f.On, &f.OffVariation, &f.CreatedAt, &f.UpdatedAt, &f.Version); err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, ErrNotFound
 		}
@@ -198,11 +209,55 @@ func (r *FlagRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	return nil
 }
 
+// UpdateRules replaces the rules of a flag
+func (r *FlagRepository) UpdateRules(ctx context.Context, id uuid.UUID, rulesJSON []byte) (*Flag, error) {
+	f := &Flag{}
+	q := `UPDATE flags SET rules_json=$2, updated_at=NOW(), version = version + 1 WHERE id=$1 RETURNING id, env_id, key, name, description, type, status, published, default_variation, variations, rules_json, published_rules, bucket_by, prerequisites, is_on, off_variation, created_at, updated_at, version`
+	if err := r.db.QueryRow(ctx, q, id, rulesJSON).Scan(&f.ID, &f.EnvID, &f.Key, &f.Name, &f.Description, &f.Type, &f.Status, &f.Published, &f.DefaultVariation, &f.Variations, &f.RulesJSON, &f.PublishedRules, &f.BucketBy, &f.Prerequisites, &f.On, &f.OffVariation, &f.CreatedAt, &f.UpdatedAt, &f.Version); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		r.logger.Error().Err(err).Msg("Failed to update flag rules")
+		return nil, err
+	}
+	return f, nil
+}
+
+// ControlRules replaces the rules of a flag for a control that takes effect
+// without publishing, such as a ramp pause. The published rules are replaced
+// too unless publishedRulesJSON is nil.
+func (r *FlagRepository) ControlRules(ctx context.Context, id uuid.UUID, rulesJSON, publishedRulesJSON []byte) (*Flag, error) {
+	f := &Flag{}
+	q := `UPDATE flags SET rules_json=$2, published_rules=COALESCE($3, published_rules), updated_at=NOW(), version = version + 1 WHERE id=$1 RETURNING id, env_id, key, name, description, type, status, published, default_variation, variations, rules_json, published_rules, bucket_by, prerequisites, is_on, off_variation, created_at, updated_at, version`
+	if err := r.db.QueryRow(ctx, q, id, rulesJSON, publishedRulesJSON).Scan(&f.ID, &f.EnvID, &f.Key, &f.Name, &f.Description, &f.Type, &f.Status, &f.Published, &f.DefaultVariation, &f.Variations, &f.RulesJSON, &f.PublishedRules, &f.BucketBy, &f.Prerequisites, &f.On, &f.OffVariation, &f.CreatedAt, &f.UpdatedAt, &f.Version); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		r.logger.Error().Err(err).Msg("Failed to update flag rules")
+		return nil, err
+	}
+	return f, nil
+}
+
+// UpdatePrerequisites replaces the prerequisites of a flag
+func (r *FlagRepository) UpdatePrerequisites(ctx context.Context, id uuid.UUID, prerequisitesJSON []byte) (*Flag, error) {
+	f := &Flag{}
+	q := `UPDATE flags SET prerequisites=$2, updated_at=NOW(), version = version + 1 WHERE id=$1 RETURNING id, env_id, key, name, description, type, status, published, default_variation, variations, rules_json, published_rules, bucket_by, prerequisites, is_on, off_variation, created_at, updated_at, version`
+	if err := r.db.QueryRow(ctx, q, id, prerequisitesJSON).Scan(&f.ID, &f.EnvID, &f.Key, &f.Name, &f.Description, &f.Type, &f.Status, &f.Published, &f.DefaultVariation, &f.Variations, &f.RulesJSON, &f.PublishedRules, &f.BucketBy, &f.Prerequisites, &f.On, &f.OffVariation, &f.CreatedAt, &f.UpdatedAt, &f.Version); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		r.logger.Error().Err(err).Msg("Failed to update flag prerequisites")
+		return nil, err
+	}
+	return f, nil
+}
+
 // SetPublished sets the published status of a flag
 func (r *FlagRepository) SetPublished(ctx context.Context, id uuid.UUID, published bool) (*Flag, error) {
 	f := &Flag{}
-	q := `UPDATE flags SET published=$2, updated_at=NOW(), version = version + 1 WHERE id=$1 RETURNING id, env_id, key, name, description, type, status, published, default_variation, variations, rules_json, created_at, updated_at, version`
-	if err := r.db.QueryRow(ctx, q, id, published).Scan(&f.ID, &f.EnvID, &f.Key, &f.Name, &f.Description, &f.Type, &f.Status, &f.Published, &f.DefaultVariation, &f.Variations, &f.RulesJSON, &f.CreatedAt, &f.UpdatedAt, &f.Version); err != nil {
+	q := `UPDATE flags SET published=$2, updated_at=NOW(), version = version + 1 WHERE id=$1 RETURNING id, env_id, key, name, description, type, status, published, default_variation, variations, rules_json, published_rules, bucket_by, prerequisites, is_on, off_variation, created_at, updated_at, version`
+	if err := r.db.QueryRow(ctx, q, id, published).Scan(&f.ID, &f.EnvID, &f.Key, &f.Name, &f.Description, &f.Type, &f.Status, &f.Published, &f.DefaultVariation, &f.Variations, &f.RulesJSON, &f.PublishedRules, &f.BucketBy, &f.Prerequisites, &f.On, &f.OffVariation, &f.CreatedAt, &f.UpdatedAt, &f.Version); err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, ErrNotFound
 		}
@@ -211,3 +266,31 @@ func (r *FlagRepository) SetPublished(ctx context.Context, id uuid.UUID, publish
 	}
 	return f, nil
 }
+
+// Publish marks a flag published with the rules evaluators are shipped
+func (r *FlagRepository) Publish(ctx context.Context, id uuid.UUID, rulesJSON []byte) (*Flag, error) {
+	f := &Flag{}
+	q := `UPDATE flags SET published=true, published_rules=$2, updated_at=NOW(), version = version + 1 WHERE id=$1 RETURNING id, env_id, key, name, description, type, status, published, default_variation, variations, rules_json, published_rules, bucket_by, prerequisites, is_on, off_variation, created_at, updated_at, version`
+	if err := r.db.QueryRow(ctx, q, id, rulesJSON).Scan(&f.ID, &f.EnvID, &f.Key, &f.Name, &f.Description, &f.Type, &f.Status, &f.Published, &f.DefaultVariation, &f.Variations, &f.RulesJSON, &f.PublishedRules, &f.BucketBy, &f.Prerequisites, &f.On, &f.OffVariation, &f.CreatedAt, &f.UpdatedAt, &f.Version); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		r.logger.Error().Err(err).Msg("Failed to publish flag")
+		return nil, err
+	}
+	return f, nil
+}
+
+// SetOn turns a flag's kill switch on or off
+func (r *FlagRepository) SetOn(ctx context.Context, id uuid.UUID, on bool) (*Flag, error) {
+	f := &Flag{}
+	q := `UPDATE flags SET is_on=$2, updated_at=NOW(), version = version + 1 WHERE id=$1 RETURNING id, env_id, key, name, description, type, status, published, default_variation, variations, rules_json, published_rules, bucket_by, prerequisites, is_on, off_variation, created_at, updated_at, version`
+	if err := r.db.QueryRow(ctx, q, id, on).Scan(&f.ID, &f.EnvID, &f.Key, &f.Name, &f.Description, &f.Type, &f.Status, &f.Published, &f.DefaultVariation, &f.Variations, &f.RulesJSON, &f.PublishedRules, &f.BucketBy, &f.Prerequisites, &f.On, &f.OffVariation, &f.CreatedAt, &f.UpdatedAt, &f.Version); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		r.logger.Error().Err(err).Msg("Failed to set flag kill switch")
+		return nil, err
+	}
+	return f, nil
+}
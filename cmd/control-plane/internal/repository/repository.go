@@ -13,6 +13,7 @@ type Repositories struct {
 	Flag         *FlagRepository
 	Segment      *SegmentRepository
 	Experiment   *ExperimentRepository
+	Target       *TargetRepository
 	User         *UserRepository
 	APIToken     *APITokenRepository
 	AuditLog     *AuditLogRepository
@@ -27,6 +28,7 @@ func New(db *pgxpool.Pool, logger zerolog.Logger) *Repositories {
 		Flag:         NewFlagRepository(db, logger),
 		Segment:      NewSegmentRepository(db, logger),
 		Experiment:   NewExperimentRepository(db, logger),
+		Target:       NewTargetRepository(db, logger),
 		User:         NewUserRepository(db, logger),
 		APIToken:     NewAPITokenRepository(db, logger),
 		AuditLog:     NewAuditLogRepository(db, logger),
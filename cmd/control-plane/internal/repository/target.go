@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+)
+
+// FlagTargets are the individually targeted user keys of a flag
+type FlagTargets struct {
+	Variations map[string][]string `json:"variations"` // user keys per served variation
+	Excluded   []string            `json:"excluded"`
+}
+
+// SegmentTargets are the user keys listed on a segment
+type SegmentTargets struct {
+	Included []string `json:"included"`
+	Excluded []string `json:"excluded"`
+}
+
+// UpdateFlagTargetsRequest adds and removes individually targeted user keys
+// of a flag. Removals are applied first.
+type UpdateFlagTargetsRequest struct {
+	VariationKey string   `json:"variation_key"` // variation served to the added keys
+	Excluded     bool     `json:"excluded"`      // exclude the added keys instead
+	Add          []string `json:"add"`
+	Remove       []string `json:"remove"`
+}
+
+// UpdateSegmentTargetsRequest adds and removes user keys listed on a segment.
+// Removals are applied first.
+type UpdateSegmentTargetsRequest struct {
+	Excluded bool     `json:"excluded"` // exclude the added keys instead of including them
+	Add      []string `json:"add"`
+	Remove   []string `json:"remove"`
+}
+
+// TargetRepository handles individually targeted user keys of flags and
+// segments
+type TargetRepository struct {
+	db     *pgxpool.Pool
+	logger zerolog.Logger
+}
+
+// NewTargetRepository creates a new target repository
+func NewTargetRepository(db *pgxpool.Pool, logger zerolog.Logger) *TargetRepository {
+	return &TargetRepository{db: db, logger: logger.With().Str("repository", "target").Logger()}
+}
+
+// AddFlagKeys targets user keys of a flag at a variation, or excludes them
+// when variationKey is empty. Keys already targeted are moved.
+func (r *TargetRepository) AddFlagKeys(ctx context.Context, flagID uuid.UUID, variationKey string, keys []string) error {
+	q := `INSERT INTO flag_target_keys (flag_id, user_key, variation_key)
+		SELECT $1, k, NULLIF($2, '') FROM unnest($3::text[]) AS k
+		ON CONFLICT (flag_id, user_key) DO UPDATE SET variation_key = EXCLUDED.variation_key`
+	if _, err := r.db.Exec(ctx, q, flagID, variationKey, keys); err != nil {
+		r.logger.Error().Err(err).Msg("Failed to add flag target keys")
+		return err
+	}
+	return nil
+}
+
+// RemoveFlagKeys removes user keys from a flag's targets and exclusions
+func (r *TargetRepository) RemoveFlagKeys(ctx context.Context, flagID uuid.UUID, keys []string) error {
+	if _, err := r.db.Exec(ctx, `DELETE FROM flag_target_keys WHERE flag_id = $1 AND user_key = ANY($2)`, flagID, keys); err != nil {
+		r.logger.Error().Err(err).Msg("Failed to remove flag target keys")
+		return err
+	}
+	return nil
+}
+
+// GetFlagTargets returns the targeted user keys of a flag
+func (r *TargetRepository) GetFlagTargets(ctx context.Context, flagID uuid.UUID) (*FlagTargets, error) {
+	targets, err := r.listFlagTargets(ctx, `SELECT flag_id::text, user_key, COALESCE(variation_key, '') FROM flag_target_keys WHERE flag_id = $1 ORDER BY user_key`, flagID)
+	if err != nil {
+		return nil, err
+	}
+	if t, ok := targets[flagID.String()]; ok {
+		return t, nil
+	}
+	return &FlagTargets{Variations: map[string][]string{}, Excluded: []string{}}, nil
+}
+
+// ListFlagTargets returns the targeted user keys of every flag of an
+// environment that has any, keyed by flag key
+func (r *TargetRepository) ListFlagTargets(ctx context.Context, envID uuid.UUID) (map[string]*FlagTargets, error) {
+	return r.listFlagTargets(ctx, `SELECT f.key, t.user_key, COALESCE(t.variation_key, '')
+		FROM flag_target_keys t JOIN flags f ON f.id = t.flag_id
+		WHERE f.env_id = $1 ORDER BY t.user_key`, envID)
+}
+
+// listFlagTargets groups (owner, user key, variation) rows by owner
+func (r *TargetRepository) listFlagTargets(ctx context.Context, query string, id uuid.UUID) (map[string]*FlagTargets, error) {
+	rows, err := r.db.Query(ctx, query, id)
+	if err != nil {
+		r.logger.Error().Err(err).Msg("Failed to list flag target keys")
+		return nil, err
+	}
+	defer rows.Close()
+
+	targets := make(map[string]*FlagTargets)
+	for rows.Next() {
+		var owner, userKey, variationKey string
+		if err := rows.Scan(&owner, &userKey, &variationKey); err != nil {
+			r.logger.Error().Err(err).Msg("Failed to scan flag target key")
+			return nil, err
+		}
+
+		t, ok := targets[owner]
+		if !ok {
+			t = &FlagTargets{Variations: map[string][]string{}, Excluded: []string{}}
+			targets[owner] = t
+		}
+		if variationKey == "" {
+			t.Excluded = append(t.Excluded, userKey)
+		} else {
+			t.Variations[variationKey] = append(t.Variations[variationKey], userKey)
+		}
+	}
+	return targets, rows.Err()
+}
+
+// AddSegmentKeys includes user keys in a segment, or excludes them from it.
+// Keys already listed are moved.
+func (r *TargetRepository) AddSegmentKeys(ctx context.Context, segmentID uuid.UUID, included bool, keys []string) error {
+	q := `INSERT INTO segment_target_keys (segment_id, user_key, included)
+		SELECT $1, k, $2 FROM unnest($3::text[]) AS k
+		ON CONFLICT (segment_id, user_key) DO UPDATE SET included = EXCLUDED.included`
+	if _, err := r.db.Exec(ctx, q, segmentID, included, keys); err != nil {
+		r.logger.Error().Err(err).Msg("Failed to add segment target keys")
+		return err
+	}
+	return nil
+}
+
+// RemoveSegmentKeys removes user keys from a segment's lists
+func (r *TargetRepository) RemoveSegmentKeys(ctx context.Context, segmentID uuid.UUID, keys []string) error {
+	if _, err := r.db.Exec(ctx, `DELETE FROM segment_target_keys WHERE segment_id = $1 AND user_key = ANY($2)`, segmentID, keys); err != nil {
+		r.logger.Error().Err(err).Msg("Failed to remove segment target keys")
+		return err
+	}
+	return nil
+}
+
+// GetSegmentTargets returns the user keys listed on a segment
+func (r *TargetRepository) GetSegmentTargets(ctx context.Context, segmentID uuid.UUID) (*SegmentTargets, error) {
+	targets, err := r.listSegmentTargets(ctx, `SELECT segment_id::text, user_key, included FROM segment_target_keys WHERE segment_id = $1 ORDER BY user_key`, segmentID)
+	if err != nil {
+		return nil, err
+	}
+	if t, ok := targets[segmentID.String()]; ok {
+		return t, nil
+	}
+	return &SegmentTargets{Included: []string{}, Excluded: []string{}}, nil
+}
+
+// ListSegmentTargets returns the listed user keys of every segment of an
+// environment that has any, keyed by segment key
+func (r *TargetRepository) ListSegmentTargets(ctx context.Context, envID uuid.UUID) (map[string]*SegmentTargets, error) {
+	return r.listSegmentTargets(ctx, `SELECT s.key, t.user_key, t.included
+		FROM segment_target_keys t JOIN segments s ON s.id = t.segment_id
+		WHERE s.env_id = $1 ORDER BY t.user_key`, envID)
+}
+
+// listSegmentTargets groups (owner, user key, included) rows by owner
+func (r *TargetRepository) listSegmentTargets(ctx context.Context, query string, id uuid.UUID) (map[string]*SegmentTargets, error) {
+	rows, err := r.db.Query(ctx, query, id)
+	if err != nil {
+		r.logger.Error().Err(err).Msg("Failed to list segment target keys")
+		return nil, err
+	}
+	defer rows.Close()
+
+	targets := make(map[string]*SegmentTargets)
+	for rows.Next() {
+		var owner, userKey string
+		var included bool
+		if err := rows.Scan(&owner, &userKey, &included); err != nil {
+			r.logger.Error().Err(err).Msg("Failed to scan segment target key")
+			return nil, err
+		}
+
+		t, ok := targets[owner]
+		if !ok {
+			t = &SegmentTargets{Included: []string{}, Excluded: []string{}}
+			targets[owner] = t
+		}
+		if included {
+			t.Included = append(t.Included, userKey)
+		} else {
+			t.Excluded = append(t.Excluded, userKey)
+		}
+	}
+	return targets, rows.Err()
+}
@@ -157,6 +157,12 @@ func (s *Server) SetupRoutes(r *chi.Mux) {
 									r.Get("/", s.handlers.Environment.Get)
 									r.Put("/", s.handlers.Environment.Update)
 									r.Delete("/", s.handlers.Environment.Delete)
+									r.Get("/context-schema", s.handlers.Environment.GetContextSchema)
+									r.Put("/context-schema", s.handlers.Environment.UpdateContextSchema)
+									r.Get("/holdout", s.handlers.Environment.GetHoldout)
+									r.Put("/holdout", s.handlers.Environment.UpdateHoldout)
+									r.Delete("/holdout", s.handlers.Environment.DeleteHoldout)
+									r.Get("/prerequisite-graph", s.handlers.Flag.PrerequisiteGraph)
 
 									// Flags
 									r.Route("/flags", func(r chi.Router) {
@@ -169,6 +175,18 @@ func (s *Server) SetupRoutes(r *chi.Mux) {
 											r.Delete("/", s.handlers.Flag.Delete)
 											r.Post("/publish", s.handlers.Flag.Publish)
 											r.Post("/unpublish", s.handlers.Flag.Unpublish)
+											r.Post("/kill", s.handlers.Flag.Kill)
+											r.Post("/restore", s.handlers.Flag.Restore)
+											r.Put("/rules", s.handlers.Flag.UpdateRules)
+											r.Post("/rules/{ruleId}/ramp", s.handlers.Flag.ControlRamp)
+											r.Put("/prerequisites", s.handlers.Flag.UpdatePrerequisites)
+											r.Get("/targets", s.handlers.Flag.GetTargets)
+											r.Patch("/targets", s.handlers.Flag.UpdateTargets)
+											r.Delete("/assignments", s.handlers.Flag.ResetAssignments)
+											r.Post("/lint", s.handlers.Flag.Lint)
+											r.Post("/diff", s.handlers.Flag.PreviewRules)
+											r.Get("/compare", s.handlers.Flag.Compare)
+											r.Post("/simulate", s.handlers.Flag.Simulate)
 										})
 									})
 
@@ -181,6 +199,8 @@ func (s *Server) SetupRoutes(r *chi.Mux) {
 											r.Get("/", s.handlers.Segment.Get)
 											r.Put("/", s.handlers.Segment.Update)
 											r.Delete("/", s.handlers.Segment.Delete)
+											r.Get("/targets", s.handlers.Segment.GetTargets)
+											r.Patch("/targets", s.handlers.Segment.UpdateTargets)
 										})
 									})
 
@@ -1,9 +1,11 @@
 package services
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -13,17 +15,24 @@ import (
 
 	"github.com/Sidd-007/feature-flag-platform/cmd/control-plane/internal/repository"
 	"github.com/Sidd-007/feature-flag-platform/pkg/bucketing"
+	"github.com/Sidd-007/feature-flag-platform/pkg/dsl"
+	"github.com/Sidd-007/feature-flag-platform/pkg/hashing"
+	"github.com/Sidd-007/feature-flag-platform/pkg/operators"
 )
 
 // EnvironmentConfig represents the configuration for an environment
 type EnvironmentConfig struct {
-	EnvKey    string                              `json:"env_key"`
-	Version   int                                 `json:"version"`
-	Salt      string                              `json:"salt"`
-	Flags     map[string]*bucketing.FlagConfig    `json:"flags"`
-	Segments  map[string]*bucketing.SegmentConfig `json:"segments"`
-	UpdatedAt time.Time                           `json:"updated_at"`
-	ETag      string                              `json:"etag"`
+	EnvKey        string                              `json:"env_key"`
+	Version       int                                 `json:"version"`
+	Salt          string                              `json:"salt"`
+	HashVersion   hashing.Version                     `json:"hash_version"`
+	Holdout       *bucketing.HoldoutConfig            `json:"holdout,omitempty"`
+	Flags         map[string]*bucketing.FlagConfig    `json:"flags"`
+	Segments      map[string]*bucketing.SegmentConfig `json:"segments"`
+	ContextSchema *bucketing.ContextSchema            `json:"context_schema,omitempty"`
+	Plans         map[string]*dsl.CompiledPlan        `json:"plans,omitempty"` // rules of each flag, which is shipped without them
+	UpdatedAt     time.Time                           `json:"updated_at"`
+	ETag          string                              `json:"etag"`
 }
 
 // ConfigService handles environment configuration compilation and distribution
@@ -42,7 +51,8 @@ func NewConfigService(repos *repository.Repositories, redis *redis.Client, logge
 	}
 }
 
-// CompileEnvironmentConfig compiles all flags and segments for an environment
+// CompileEnvironmentConfig compiles the published flags and the segments of
+// an environment
 func (s *ConfigService) CompileEnvironmentConfig(ctx context.Context, envID uuid.UUID) (*EnvironmentConfig, error) {
 	// Get environment details
 	env, err := s.repos.Environment.GetByID(ctx, envID)
@@ -56,27 +66,125 @@ func (s *ConfigService) CompileEnvironmentConfig(ctx context.Context, envID uuid
 		return nil, fmt.Errorf("failed to get flags: %w", err)
 	}
 
-	// TODO: Get all segments for the environment when segments are implemented
+	schema, err := s.loadContextSchema(ctx, envID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get context schema: %w", err)
+	}
+
+	holdout, err := s.loadHoldout(ctx, envID, env.HashVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get holdout: %w", err)
+	}
 
-	// Convert flags to bucketing format
+	segments, _, err := s.repos.Segment.List(ctx, envID, maxSegments, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get segments: %w", err)
+	}
+
+	// Convert flags to bucketing format and compile each into the plan edge
+	// evaluators take its rules from, so rollout allocation is computed once
+	// per publish
 	flagConfigs := make(map[string]*bucketing.FlagConfig)
+	plans := make(map[string]*dsl.CompiledPlan)
+	var previous *EnvironmentConfig // last published config, loaded for broken flags
+	loadedPrevious := false
 	for _, flag := range flags {
-		flagConfig := s.convertFlagToBucketingConfig(flag)
+		// Evaluators only get flags as they were last published; saved rules
+		// are drafts until they pass lint on publish
+		if !flag.Published {
+			continue
+		}
+		published := *flag
+		published.RulesJSON = flag.PublishedRules
+
+		flagConfig := s.convertFlagToBucketingConfig(&published)
+		flagConfig.HashVersion = env.HashVersion
+
+		// Keep unknown operators and invalid operands from reaching
+		// evaluators. A broken flag does not hold back the rest of the
+		// environment, so kill switches always publish: it keeps the rules
+		// it was last shipped with, or ships without rules and serves its
+		// default variation.
+		if compileErr := flagConfig.Compile(operators.SystemClock); compileErr != nil {
+			if !loadedPrevious {
+				if previous, err = s.LoadConfigFromRedis(ctx, env.Key); err != nil {
+					s.logger.Warn().Err(err).Str("env_key", env.Key).Msg("Failed to load the last published config")
+				}
+				loadedPrevious = true
+			}
+			s.fallBackToShippedRules(flagConfig, previous, compileErr)
+		}
+
+		s.dropUnknownOffVariation(flagConfig)
+
+		// The plan is the only copy of the rules evaluators are shipped
 		flagConfigs[flag.Key] = flagConfig
+		plans[flag.Key] = dsl.PlanFromFlag(flagConfig, time.Now())
+		flagConfig.Rules = nil
+	}
+
+	// Prerequisites are checked for cycles when saved. A cycle that got in
+	// anyway leaves the flags on it serving their default variation once the
+	// maximum prerequisite depth is reached, so it does not block publishing.
+	if cycle := bucketing.FindPrerequisiteCycle(flagConfigs); cycle != nil {
+		s.logger.Error().Strs("cycle", cycle).Msg("Prerequisites create a dependency cycle")
+	}
+
+	if err := s.attachExperiments(ctx, envID, env.HashVersion, flagConfigs); err != nil {
+		return nil, err
+	}
+
+	// The holdout keeps its users out of every experiment
+	if holdout != nil {
+		for _, flagConfig := range flagConfigs {
+			if flagConfig.Experiment != nil {
+				flagConfig.Holdout = holdout
+			}
+		}
+	}
+
+	flagTargets, err := s.repos.Target.ListFlagTargets(ctx, envID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get flag targets: %w", err)
+	}
+	for key, targets := range flagTargets {
+		if flagConfig, exists := flagConfigs[key]; exists {
+			if missing := applyFlagTargets(flagConfig, targets); len(missing) > 0 {
+				s.logger.Warn().Str("flag_key", key).Strs("variations", missing).Msg("Flag targets variations the flag does not have, leaving them out")
+			}
+		}
+	}
+
+	segmentTargets, err := s.repos.Target.ListSegmentTargets(ctx, envID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get segment targets: %w", err)
 	}
 
-	// Convert segments to bucketing format (empty for now)
 	segmentConfigs := make(map[string]*bucketing.SegmentConfig)
-	// TODO: Implement segment conversion when segments are ready
+	for _, segment := range segments {
+		if !segment.IsActive {
+			continue
+		}
+		segmentConfig := s.convertSegmentToBucketingConfig(segment)
+		if targets, exists := segmentTargets[segment.Key]; exists {
+			segmentConfig.Included = bucketing.NewKeySet(targets.Included...)
+			segmentConfig.Excluded = bucketing.NewKeySet(targets.Excluded...)
+		}
+		segmentConfigs[segment.Key] = segmentConfig
+	}
 
 	// Create environment config
 	config := &EnvironmentConfig{
-		EnvKey:    env.Key,
-		Version:   env.Version,
-		Salt:      env.Salt,
-		Flags:     flagConfigs,
-		Segments:  segmentConfigs,
-		UpdatedAt: time.Now(),
+		EnvKey:        env.Key,
+		Version:       env.Version,
+		Salt:          env.Salt,
+		HashVersion:   env.HashVersion,
+		Holdout:       holdout,
+		Flags:         flagConfigs,
+		Segments:      segmentConfigs,
+		ContextSchema: schema,
+		Plans:         plans,
+		UpdatedAt:     time.Now(),
 	}
 
 	// Generate ETag based on version and update time
@@ -115,6 +223,132 @@ func (s *ConfigService) PublishEnvironmentConfig(ctx context.Context, envID uuid
 	return config, nil
 }
 
+// maxSwitchRetries bounds the attempts to patch a kill switch into the
+// published config while other publishes race it
+const maxSwitchRetries = 5
+
+// PublishFlagSwitch publishes a new version of an environment's config with
+// a flag's kill switch and off variation as saved, on top of the config last
+// published so every other flag is shipped as it was. Without a published
+// config holding the flag, the config is compiled from the published flags.
+func (s *ConfigService) PublishFlagSwitch(ctx context.Context, envID uuid.UUID, flag *repository.Flag) (*EnvironmentConfig, error) {
+	if err := s.repos.Environment.IncrementVersion(ctx, envID); err != nil {
+		return nil, fmt.Errorf("failed to increment environment version: %w", err)
+	}
+	env, err := s.repos.Environment.GetByID(ctx, envID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get environment: %w", err)
+	}
+
+	config, err := s.patchFlagSwitch(ctx, env, flag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to patch published config: %w", err)
+	}
+
+	if config == nil {
+		if config, err = s.CompileEnvironmentConfig(ctx, envID); err != nil {
+			return nil, fmt.Errorf("failed to compile environment config: %w", err)
+		}
+		if err := s.StoreConfigInRedis(ctx, config); err != nil {
+			return nil, fmt.Errorf("failed to store config in Redis: %w", err)
+		}
+	}
+
+	s.logger.Info().
+		Str("env_key", config.EnvKey).
+		Int("version", config.Version).
+		Str("flag_key", flag.Key).
+		Bool("on", flag.On).
+		Msg("Flag kill switch published")
+
+	return config, nil
+}
+
+// patchFlagSwitch replaces a flag's kill switch and off variation in the
+// config stored in Redis, retrying when another publish changes it in the
+// meantime. It returns nil when no stored config holds the flag.
+func (s *ConfigService) patchFlagSwitch(ctx context.Context, env *repository.Environment, flag *repository.Flag) (*EnvironmentConfig, error) {
+	key := s.redisKey(env.Key)
+
+	var config *EnvironmentConfig
+	patch := func(tx *redis.Tx) error {
+		config = nil
+
+		data, err := tx.Get(ctx, key).Bytes()
+		if err == redis.Nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var current EnvironmentConfig
+		if err := json.Unmarshal(data, &current); err != nil {
+			return fmt.Errorf("failed to unmarshal config: %w", err)
+		}
+		flagConfig, exists := current.Flags[flag.Key]
+		if !exists {
+			return nil
+		}
+
+		on := flag.On
+		flagConfig.On = &on
+		flagConfig.OffVariation = flag.OffVariation
+		s.dropUnknownOffVariation(flagConfig)
+
+		current.Version = env.Version
+		current.UpdatedAt = time.Now()
+		current.ETag = fmt.Sprintf(`"%d-%d"`, current.Version, current.UpdatedAt.Unix())
+
+		patched, err := json.Marshal(&current)
+		if err != nil {
+			return fmt.Errorf("failed to marshal config: %w", err)
+		}
+		if _, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, patched, 24*time.Hour)
+			return nil
+		}); err != nil {
+			return err
+		}
+		config = &current
+		return nil
+	}
+
+	for attempt := 0; attempt < maxSwitchRetries; attempt++ {
+		err := s.redis.Watch(ctx, patch, key)
+		if err != redis.TxFailedErr {
+			return config, err
+		}
+	}
+	return nil, fmt.Errorf("config changed concurrently %d times", maxSwitchRetries)
+}
+
+// fallBackToShippedRules replaces the rules of a flag that failed to compile
+// with the rules it was shipped with in the previous config, or with no rules
+// when there are none that compile
+func (s *ConfigService) fallBackToShippedRules(flagConfig *bucketing.FlagConfig, previous *EnvironmentConfig, compileErr error) {
+	if previous != nil {
+		plan := previous.Plans[flagConfig.Key]
+		if plan != nil && dsl.ApplyPlan(plan, flagConfig) == nil && flagConfig.Compile(operators.SystemClock) == nil {
+			s.logger.Error().Err(compileErr).Str("flag_key", flagConfig.Key).Msg("Failed to compile flag, shipping the rules it was last shipped with")
+			return
+		}
+	}
+
+	flagConfig.Rules = []bucketing.Rule{}
+	s.logger.Error().Err(compileErr).Str("flag_key", flagConfig.Key).Msg("Failed to compile flag, shipping it without rules")
+}
+
+// dropUnknownOffVariation clears an off variation the flag does not ship,
+// which would make the kill switch fail every evaluation; the default
+// variation is served instead
+func (s *ConfigService) dropUnknownOffVariation(flagConfig *bucketing.FlagConfig) {
+	if flagConfig.OffVariation != "" && !hasVariation(flagConfig, flagConfig.OffVariation) {
+		s.logger.Warn().Str("flag_key", flagConfig.Key).Str("off_variation", flagConfig.OffVariation).Msg("Off variation does not exist, serving the default variation while off")
+		flagConfig.OffVariation = ""
+	}
+}
+
 // GetEnvironmentConfig retrieves config from Redis or compiles if not found
 func (s *ConfigService) GetEnvironmentConfig(ctx context.Context, envKey string) (*EnvironmentConfig, error) {
 	// Try to load from Redis first
@@ -211,25 +445,39 @@ func (s *ConfigService) redisKey(envKey string) string {
 	return fmt.Sprintf("ff:config:%s", envKey)
 }
 
+// convertFlagToBucketingConfig converts a flag as it is shipped to
+// evaluators. Lint, previews and simulations go through it too, so they
+// check exactly the variations evaluators serve.
 func (s *ConfigService) convertFlagToBucketingConfig(flag *repository.Flag) *bucketing.FlagConfig {
-	// Create default variations based on flag type for now
-	// TODO: Parse actual variations from database when field is added
-	variations := s.createDefaultVariations(flag.Type, flag.DefaultVariation)
+	// Flags without stored variations get the defaults of their type
+	var variations []bucketing.Variation
+	if err := decodeJSONColumn(flag.Variations, &variations); err != nil || len(variations) == 0 {
+		if err != nil {
+			s.logger.Warn().Err(err).Str("flag_key", flag.Key).Msg("Failed to parse variations JSON, using default variations")
+		}
+		variations = s.createDefaultVariations(flag.Type, flag.DefaultVariation)
+	}
 
 	// Parse rules from JSON if available
 	var rules []bucketing.Rule
 	if flag.RulesJSON != nil {
-		if rulesBytes, ok := flag.RulesJSON.([]byte); ok {
-			if err := json.Unmarshal(rulesBytes, &rules); err != nil {
-				s.logger.Warn().Err(err).Str("flag_key", flag.Key).Msg("Failed to parse rules JSON, using empty rules")
-				rules = []bucketing.Rule{}
-			}
-		} else {
-			s.logger.Warn().Str("flag_key", flag.Key).Msg("RulesJSON is not []byte, using empty rules")
-			rules = []bucketing.Rule{}
+		decoded, err := decodeRules(flag.RulesJSON)
+		if err != nil {
+			s.logger.Warn().Err(err).Str("flag_key", flag.Key).Msg("Failed to parse rules JSON, using empty rules")
+			decoded = []bucketing.Rule{}
 		}
+		rules = decoded
 	}
 
+	var prerequisites []bucketing.Prerequisite
+	if flag.Prerequisites != nil {
+		if err := decodeJSONColumn(flag.Prerequisites, &prerequisites); err != nil {
+			s.logger.Warn().Err(err).Str("flag_key", flag.Key).Msg("Failed to parse prerequisites JSON, using no prerequisites")
+			prerequisites = nil
+		}
+	}
+
+	on := flag.On
 	return &bucketing.FlagConfig{
 		Key:               flag.Key,
 		Type:              flag.Type,
@@ -237,15 +485,246 @@ func (s *ConfigService) convertFlagToBucketingConfig(flag *repository.Flag) *buc
 		DefaultVariation:  flag.DefaultVariation,
 		Rules:             rules,
 		Status:            flag.Status,
+		BucketBy:          flag.BucketBy,
+		Prerequisites:     prerequisites,
 		TrafficAllocation: 1.0, // Default to 100% traffic
+		On:                &on,
+		OffVariation:      flag.OffVariation,
+	}
+}
+
+// decodeRules decodes a flag's rules column. Flags created without rules hold
+// the column default '{}', which means no rules.
+func decodeRules(value any) ([]bucketing.Rule, error) {
+	var raw json.RawMessage
+	if err := decodeJSONColumn(value, &raw); err != nil {
+		return nil, err
+	}
+
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || string(raw) == "{}" || string(raw) == "null" {
+		return []bucketing.Rule{}, nil
+	}
+
+	var rules []bucketing.Rule
+	if err := json.Unmarshal(raw, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// attachExperiments attaches the running experiments of an environment to
+// their flags, with layer buckets allocated per exclusion group in the bucket
+// space of the environment's hash version
+func (s *ConfigService) attachExperiments(ctx context.Context, envID uuid.UUID, version hashing.Version, flagConfigs map[string]*bucketing.FlagConfig) error {
+	experiments, err := s.repos.Experiment.ListRunning(ctx, envID)
+	if err != nil {
+		return fmt.Errorf("failed to get experiments: %w", err)
+	}
+
+	configs := make([]*bucketing.ExperimentConfig, 0, len(experiments))
+	for _, experiment := range experiments {
+		flagConfig, exists := flagConfigs[experiment.FlagKey]
+		if !exists {
+			continue
+		}
+		if flagConfig.Experiment != nil {
+			s.logger.Warn().
+				Str("flag_key", experiment.FlagKey).
+				Str("experiment_key", experiment.Key).
+				Str("running_experiment_key", flagConfig.Experiment.Key).
+				Msg("Flag already has a running experiment, ignoring experiment")
+			continue
+		}
+
+		config := &bucketing.ExperimentConfig{
+			Key:               experiment.Key,
+			ExclusionGroup:    experiment.ExclusionGroup,
+			TrafficAllocation: experiment.TrafficAllocation,
+		}
+		flagConfig.Experiment = config
+		configs = append(configs, config)
+	}
+
+	return bucketing.AllocateLayers(configs, version)
+}
+
+// loadContextSchema returns the environment's context schema, or nil when none
+// is declared
+func (s *ConfigService) loadContextSchema(ctx context.Context, envID uuid.UUID) (*bucketing.ContextSchema, error) {
+	value, err := s.repos.Environment.GetContextSchema(ctx, envID)
+	if err != nil {
+		return nil, err
+	}
+	return decodeContextSchema(value)
+}
+
+// loadHoldout returns the environment's holdout in the bucket space of its
+// hash version, or nil when it has none
+func (s *ConfigService) loadHoldout(ctx context.Context, envID uuid.UUID, version hashing.Version) (*bucketing.HoldoutConfig, error) {
+	value, err := s.repos.Environment.GetHoldout(ctx, envID)
+	if err != nil {
+		return nil, err
+	}
+	holdout, err := decodeHoldout(value)
+	if err != nil || holdout == nil {
+		return nil, err
+	}
+	holdout.HashVersion = version
+	return holdout, nil
+}
+
+// decodeContextSchema decodes an environment's context_schema column. The
+// column default '{}' declares no attributes and means no schema.
+func decodeContextSchema(value any) (*bucketing.ContextSchema, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	var schema bucketing.ContextSchema
+	if err := decodeJSONColumn(value, &schema); err != nil {
+		return nil, err
+	}
+	if len(schema.Attributes) == 0 && !schema.Strict {
+		return nil, nil
 	}
+	return &schema, nil
 }
 
-// convertSegmentToBucketingConfig will be implemented when segments are ready
-// func (s *ConfigService) convertSegmentToBucketingConfig(segment *repository.Segment) *bucketing.SegmentConfig {
-//     // TODO: Implement segment conversion
-//     return nil
-// }
+// decodeHoldout decodes an environment's holdout column. NULL means no
+// holdout.
+func decodeHoldout(value any) (*bucketing.HoldoutConfig, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	var holdout bucketing.HoldoutConfig
+	if err := decodeJSONColumn(value, &holdout); err != nil {
+		return nil, err
+	}
+	return &holdout, nil
+}
+
+// decodeJSONColumn decodes a JSONB column scanned into an untyped value. The
+// driver may return raw bytes, a string or already-decoded maps and slices.
+func decodeJSONColumn(value any, target any) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, target)
+	case string:
+		return json.Unmarshal([]byte(v), target)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(data, target)
+	}
+}
+
+// applyFlagTargets sets a flag's individual targets, in the order of its
+// variations. It returns the keys of targeted variations the flag does not
+// have, sorted; their targets cannot be served.
+func applyFlagTargets(flagConfig *bucketing.FlagConfig, targets *repository.FlagTargets) []string {
+	for _, variation := range flagConfig.Variations {
+		if keys, ok := targets.Variations[variation.Key]; ok {
+			flagConfig.Targets = append(flagConfig.Targets, bucketing.Target{
+				VariationKey: variation.Key,
+				Keys:         bucketing.NewKeySet(keys...),
+			})
+		}
+	}
+	if len(targets.Excluded) > 0 {
+		flagConfig.ExcludedKeys = bucketing.NewKeySet(targets.Excluded...)
+	}
+
+	var missing []string
+	for variationKey := range targets.Variations {
+		if !hasVariation(flagConfig, variationKey) {
+			missing = append(missing, variationKey)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// segmentOperators maps the operator names of the segment API to the
+// operators of the bucketing engine
+var segmentOperators = map[string]string{
+	"equals":                "eq",
+	"not_equals":            "neq",
+	"not_in":                "nin",
+	"greater_than":          "gt",
+	"greater_than_or_equal": "gte",
+	"less_than":             "lt",
+	"less_than_or_equal":    "lte",
+	"semver_equals":         "semver_eq",
+	"semver_greater":        "semver_gt",
+	"semver_less":           "semver_lt",
+}
+
+// convertSegmentToBucketingConfig converts a segment and its rules. Conditions
+// the engine cannot compile are kept, and never match at evaluation.
+func (s *ConfigService) convertSegmentToBucketingConfig(segment *repository.Segment) *bucketing.SegmentConfig {
+	segmentConfig, err := decodeSegment(segment.Key, segment.Name, segment.Rules)
+	if err != nil {
+		s.logger.Warn().Err(err).Str("segment_key", segment.Key).Msg("Failed to parse segment rules JSON, using no conditions")
+	}
+
+	if err := segmentConfig.Compile(operators.SystemClock); err != nil {
+		s.logger.Warn().Err(err).Str("segment_key", segment.Key).Msg("Segment has conditions the engine does not support")
+	}
+	return segmentConfig
+}
+
+// decodeSegment decodes a segment's rules column into a segment
+// configuration, translating operator names to those of the engine. On error
+// the segment is returned without rules.
+func decodeSegment(key, name string, rulesJSON []byte) (*bucketing.SegmentConfig, error) {
+	segmentConfig := &bucketing.SegmentConfig{Key: key, Name: name}
+
+	var rules struct {
+		Conditions       []bucketing.Condition   `json:"conditions"`
+		Rules            []bucketing.SegmentRule `json:"rules"`
+		IncludedSegments []string                `json:"included_segments"`
+		ExcludedSegments []string                `json:"excluded_segments"`
+	}
+	if len(rulesJSON) > 0 {
+		if err := json.Unmarshal(rulesJSON, &rules); err != nil {
+			return segmentConfig, err
+		}
+	}
+
+	translateSegmentOperators(rules.Conditions)
+	for i := range rules.Rules {
+		translateSegmentOperators(rules.Rules[i].Conditions)
+	}
+
+	segmentConfig.Conditions = rules.Conditions
+	segmentConfig.Rules = rules.Rules
+	segmentConfig.IncludedSegments = rules.IncludedSegments
+	segmentConfig.ExcludedSegments = rules.ExcludedSegments
+	return segmentConfig, nil
+}
+
+// translateSegmentOperators renames segment API operators in condition trees
+func translateSegmentOperators(conditions []bucketing.Condition) {
+	for i := range conditions {
+		translateSegmentOperator(&conditions[i])
+	}
+}
+
+// translateSegmentOperator renames segment API operators in a condition tree
+func translateSegmentOperator(condition *bucketing.Condition) {
+	if operator, ok := segmentOperators[condition.Operator]; ok {
+		condition.Operator = operator
+	}
+	translateSegmentOperators(condition.And)
+	translateSegmentOperators(condition.Or)
+	if condition.Not != nil {
+		translateSegmentOperator(condition.Not)
+	}
+}
 
 func (s *ConfigService) createDefaultVariations(flagType, defaultVariation string) []bucketing.Variation {
 	switch strings.ToLower(flagType) {
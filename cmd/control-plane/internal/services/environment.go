@@ -2,12 +2,17 @@ package services
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 
 	"github.com/google/uuid"
 	"github.com/rs/zerolog"
 
 	"github.com/Sidd-007/feature-flag-platform/cmd/control-plane/internal/repository"
+	"github.com/Sidd-007/feature-flag-platform/pkg/bucketing"
+	"github.com/Sidd-007/feature-flag-platform/pkg/hashing"
 	"github.com/Sidd-007/feature-flag-platform/pkg/rbac"
 )
 
@@ -32,6 +37,12 @@ func (s *EnvironmentService) Create(ctx context.Context, projectID uuid.UUID, re
 	if _, err := s.repos.Project.GetByID(ctx, projectID); err != nil {
 		return nil, fmt.Errorf("project not found")
 	}
+	if req.HashVersion == 0 {
+		req.HashVersion = hashing.DefaultHashVersion
+	}
+	if !req.HashVersion.Valid() {
+		return nil, fmt.Errorf("unknown hash version %d", req.HashVersion)
+	}
 	req.ProjectID = projectID
 	env, err := s.repos.Environment.Create(ctx, req)
 	if err != nil {
@@ -79,3 +90,153 @@ func (s *EnvironmentService) Delete(ctx context.Context, id uuid.UUID) error {
 	}
 	return nil
 }
+
+// GetContextSchema returns the context schema of an environment. An
+// environment without a schema returns an empty one.
+func (s *EnvironmentService) GetContextSchema(ctx context.Context, id uuid.UUID) (*bucketing.ContextSchema, error) {
+	value, err := s.repos.Environment.GetContextSchema(ctx, id)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return nil, fmt.Errorf("environment not found")
+		}
+		return nil, fmt.Errorf("failed to retrieve context schema")
+	}
+
+	schema, err := decodeContextSchema(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode context schema")
+	}
+	if schema == nil {
+		schema = &bucketing.ContextSchema{}
+	}
+	if schema.Attributes == nil {
+		schema.Attributes = map[string]*bucketing.AttributeSchema{}
+	}
+	return schema, nil
+}
+
+// UpdateContextSchema validates and replaces the context schema of an
+// environment. It takes effect in evaluators the next time the environment's
+// configuration is published.
+func (s *EnvironmentService) UpdateContextSchema(ctx context.Context, id uuid.UUID, schema *bucketing.ContextSchema) (*bucketing.ContextSchema, error) {
+	if schema == nil {
+		schema = &bucketing.ContextSchema{}
+	}
+	if schema.Attributes == nil {
+		schema.Attributes = map[string]*bucketing.AttributeSchema{}
+	}
+	if err := schema.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid context schema: %w", err)
+	}
+
+	schemaJSON, err := json.Marshal(schema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid context schema: %w", err)
+	}
+
+	if err := s.repos.Environment.UpdateContextSchema(ctx, id, schemaJSON); err != nil {
+		if err == repository.ErrNotFound {
+			return nil, fmt.Errorf("environment not found")
+		}
+		return nil, fmt.Errorf("failed to update context schema")
+	}
+
+	s.logger.Info().Str("env_id", id.String()).Int("attributes", len(schema.Attributes)).Msg("Context schema updated")
+	return schema, nil
+}
+
+// GetHoldout returns the global holdout of an environment
+func (s *EnvironmentService) GetHoldout(ctx context.Context, id uuid.UUID) (*bucketing.HoldoutConfig, error) {
+	holdout, err := s.loadHoldout(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if holdout == nil {
+		return nil, fmt.Errorf("holdout not found")
+	}
+	return holdout, nil
+}
+
+// UpdateHoldout validates and replaces the global holdout of an environment.
+// A holdout without a salt keeps the salt of the current one, or gets a new
+// random salt, so its membership only changes when the salt is changed. It
+// takes effect in evaluators the next time the environment's configuration is
+// published.
+func (s *EnvironmentService) UpdateHoldout(ctx context.Context, id uuid.UUID, holdout *bucketing.HoldoutConfig) (*bucketing.HoldoutConfig, error) {
+	if err := holdout.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid holdout: %w", err)
+	}
+
+	if holdout.Salt == "" {
+		current, err := s.loadHoldout(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if current != nil {
+			holdout.Salt = current.Salt
+		}
+	}
+	if holdout.Salt == "" {
+		salt, err := newHoldoutSalt()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate holdout salt")
+		}
+		holdout.Salt = salt
+	}
+	// The hash version is the environment's, set when configuration is compiled
+	holdout.HashVersion = 0
+
+	holdoutJSON, err := json.Marshal(holdout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid holdout: %w", err)
+	}
+
+	if err := s.repos.Environment.UpdateHoldout(ctx, id, holdoutJSON); err != nil {
+		if err == repository.ErrNotFound {
+			return nil, fmt.Errorf("environment not found")
+		}
+		return nil, fmt.Errorf("failed to update holdout")
+	}
+
+	s.logger.Info().Str("env_id", id.String()).Str("holdout_key", holdout.Key).Float64("percentage", holdout.Percentage).Msg("Holdout updated")
+	return holdout, nil
+}
+
+// DeleteHoldout removes the global holdout of an environment
+func (s *EnvironmentService) DeleteHoldout(ctx context.Context, id uuid.UUID) error {
+	if err := s.repos.Environment.UpdateHoldout(ctx, id, nil); err != nil {
+		if err == repository.ErrNotFound {
+			return fmt.Errorf("environment not found")
+		}
+		return fmt.Errorf("failed to delete holdout")
+	}
+
+	s.logger.Info().Str("env_id", id.String()).Msg("Holdout removed")
+	return nil
+}
+
+// loadHoldout returns the holdout of an environment, or nil when it has none
+func (s *EnvironmentService) loadHoldout(ctx context.Context, id uuid.UUID) (*bucketing.HoldoutConfig, error) {
+	value, err := s.repos.Environment.GetHoldout(ctx, id)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return nil, fmt.Errorf("environment not found")
+		}
+		return nil, fmt.Errorf("failed to retrieve holdout")
+	}
+
+	holdout, err := decodeHoldout(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode holdout")
+	}
+	return holdout, nil
+}
+
+// newHoldoutSalt returns a random holdout salt
+func newHoldoutSalt() (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(salt), nil
+}
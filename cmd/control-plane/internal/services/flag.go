@@ -2,7 +2,11 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/nats-io/nats.go"
@@ -10,9 +14,55 @@ import (
 	"github.com/rs/zerolog"
 
 	"github.com/Sidd-007/feature-flag-platform/cmd/control-plane/internal/repository"
+	"github.com/Sidd-007/feature-flag-platform/pkg/bucketing"
+	"github.com/Sidd-007/feature-flag-platform/pkg/dsl"
+	"github.com/Sidd-007/feature-flag-platform/pkg/hashing"
 	"github.com/Sidd-007/feature-flag-platform/pkg/rbac"
 )
 
+// maxSegments bounds the number of segments loaded per environment
+const maxSegments = 1000
+
+// assignmentResetTimeout bounds each Redis call resetting sticky assignments
+const assignmentResetTimeout = 5 * time.Second
+
+// configUpdateSubject is the NATS subject edge evaluators receive
+// configuration updates on
+const configUpdateSubject = "ff.config.updates"
+
+// RulesLintError is returned when a flag cannot be published because its
+// rules have lint errors
+type RulesLintError struct {
+	Report *dsl.LintReport
+}
+
+// Error implements the error interface
+func (e *RulesLintError) Error() string {
+	return fmt.Sprintf("flag %s has rule errors and cannot be published", e.Report.FlagKey)
+}
+
+// PrerequisiteCycleError is returned when prerequisites would make flags
+// depend on themselves
+type PrerequisiteCycleError struct {
+	Cycle []string
+}
+
+// Error implements the error interface
+func (e *PrerequisiteCycleError) Error() string {
+	return fmt.Sprintf("prerequisites create a dependency cycle: %s", strings.Join(e.Cycle, " -> "))
+}
+
+// configUpdateMessage is a configuration update sent to edge evaluators.
+// Type is "full_refresh", carrying the configuration, or "reload", telling
+// them to read the configuration just stored in Redis.
+type configUpdateMessage struct {
+	Type      string             `json:"type"`
+	EnvKey    string             `json:"env_key"`
+	Version   int                `json:"version"`
+	Config    *EnvironmentConfig `json:"config,omitempty"`
+	Timestamp int64              `json:"timestamp"`
+}
+
 // FlagService handles flag operations
 type FlagService struct {
 	repos         *repository.Repositories
@@ -20,6 +70,7 @@ type FlagService struct {
 	nats          *nats.Conn
 	rbac          *rbac.RBAC
 	configService *ConfigService
+	compiler      *dsl.Compiler
 	logger        zerolog.Logger
 }
 
@@ -31,6 +82,7 @@ func NewFlagService(repos *repository.Repositories, redisClient *redis.Client, n
 		nats:          natsConn,
 		rbac:          rbacManager,
 		configService: configService,
+		compiler:      dsl.NewCompiler(),
 		logger:        logger.With().Str("service", "flag").Logger(),
 	}
 }
@@ -70,6 +122,19 @@ func (s *FlagService) GetByKey(ctx context.Context, envID uuid.UUID, key string)
 }
 
 func (s *FlagService) Update(ctx context.Context, id uuid.UUID, req *repository.UpdateFlagRequest) (*repository.Flag, error) {
+	if req.OffVariation != "" {
+		current, err := s.repos.Flag.GetByID(ctx, id)
+		if err != nil {
+			if err == repository.ErrNotFound {
+				return nil, fmt.Errorf("flag not found")
+			}
+			return nil, fmt.Errorf("failed to retrieve flag")
+		}
+		if !hasVariation(s.flagConfig(current, nil), req.OffVariation) {
+			return nil, fmt.Errorf("off variation %q does not exist", req.OffVariation)
+		}
+	}
+
 	f, err := s.repos.Flag.Update(ctx, id, req)
 	if err != nil {
 		if err == repository.ErrNotFound {
@@ -115,7 +180,9 @@ func (s *FlagService) Delete(ctx context.Context, id uuid.UUID) error {
 	return nil
 }
 
-// PublishFlag publishes an individual flag
+// PublishFlag publishes an individual flag with its current rules. Only
+// published rules are shipped to evaluators, so rules saved with lint errors
+// never reach them.
 func (s *FlagService) PublishFlag(ctx context.Context, envID uuid.UUID, flagKey string) (*repository.Flag, error) {
 	// Get the flag first
 	flag, err := s.repos.Flag.GetByKey(ctx, envID, flagKey)
@@ -123,8 +190,26 @@ func (s *FlagService) PublishFlag(ctx context.Context, envID uuid.UUID, flagKey
 		return nil, fmt.Errorf("flag not found: %w", err)
 	}
 
-	// Set published to true
-	publishedFlag, err := s.repos.Flag.SetPublished(ctx, flag.ID, true)
+	rules, err := decodeRules(flag.RulesJSON)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rules: %w", err)
+	}
+
+	// Refuse to publish rules with lint errors
+	report, err := s.lintFlag(ctx, envID, flag, rules)
+	if err != nil {
+		return nil, err
+	}
+	if report.HasErrors() {
+		return nil, &RulesLintError{Report: report}
+	}
+
+	// Publish exactly the rules that passed lint
+	rulesJSON, err := json.Marshal(rules)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rules: %w", err)
+	}
+	publishedFlag, err := s.repos.Flag.Publish(ctx, flag.ID, rulesJSON)
 	if err != nil {
 		return nil, fmt.Errorf("failed to publish flag: %w", err)
 	}
@@ -150,3 +235,547 @@ func (s *FlagService) UnpublishFlag(ctx context.Context, envID uuid.UUID, flagKe
 	s.logger.Info().Str("env_id", envID.String()).Str("flag_key", flagKey).Msg("Flag unpublished successfully")
 	return unpublishedFlag, nil
 }
+
+// SetFlagOn turns a flag's kill switch on or off and pushes it to edge
+// evaluators straight away, leaving every other flag as last published. A
+// flag that is off serves its off variation to everyone. The switch is saved
+// even when the push fails, so retrying is safe.
+func (s *FlagService) SetFlagOn(ctx context.Context, envID uuid.UUID, flagKey string, on bool) (*repository.Flag, error) {
+	flag, err := s.GetByKey(ctx, envID, flagKey)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := s.repos.Flag.SetOn(ctx, flag.ID, on)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return nil, fmt.Errorf("flag not found")
+		}
+		return nil, fmt.Errorf("failed to update flag")
+	}
+
+	if err := s.pushFlagSwitch(ctx, envID, updated); err != nil {
+		s.logger.Error().Err(err).Str("env_id", envID.String()).Str("flag_key", flagKey).Bool("on", on).Msg("Failed to push flag kill switch")
+		return nil, fmt.Errorf("failed to push config: %w", err)
+	}
+
+	s.logger.Info().Str("env_id", envID.String()).Str("flag_key", flagKey).Bool("on", on).Msg("Flag kill switch updated")
+	return updated, nil
+}
+
+// pushFlagSwitch publishes a new version of an environment's configuration
+// with a flag's kill switch and sends it to edge evaluators. A configuration
+// too large for a message makes them reload it from Redis instead.
+func (s *FlagService) pushFlagSwitch(ctx context.Context, envID uuid.UUID, flag *repository.Flag) error {
+	config, err := s.configService.PublishFlagSwitch(ctx, envID, flag)
+	if err != nil {
+		return err
+	}
+
+	update := configUpdateMessage{
+		Type:      "full_refresh",
+		EnvKey:    config.EnvKey,
+		Version:   config.Version,
+		Config:    config,
+		Timestamp: time.Now().Unix(),
+	}
+	data, err := json.Marshal(update)
+	if err != nil {
+		return fmt.Errorf("failed to marshal config update: %w", err)
+	}
+	if int64(len(data)) > s.nats.MaxPayload() {
+		update.Type, update.Config = "reload", nil
+		if data, err = json.Marshal(update); err != nil {
+			return fmt.Errorf("failed to marshal config update: %w", err)
+		}
+	}
+
+	if err := s.nats.Publish(configUpdateSubject, data); err != nil {
+		return fmt.Errorf("failed to publish config update: %w", err)
+	}
+	return s.nats.Flush()
+}
+
+// UpdateRules replaces a flag's rules and returns the lint report for the new
+// rules. The rules are a draft until the flag is published, which rules with
+// lint errors block; evaluators keep serving the rules last published.
+func (s *FlagService) UpdateRules(ctx context.Context, envID uuid.UUID, flagKey string, rules []bucketing.Rule) (*repository.Flag, *dsl.LintReport, error) {
+	flag, err := s.GetByKey(ctx, envID, flagKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	version, err := s.hashVersion(ctx, envID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if rules == nil {
+		rules = []bucketing.Rule{}
+	}
+	allocateRollouts(flag, rules, version)
+
+	report, err := s.lintFlag(ctx, envID, flag, rules)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rulesJSON, err := json.Marshal(rules)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid rules: %w", err)
+	}
+
+	updated, err := s.repos.Flag.UpdateRules(ctx, flag.ID, rulesJSON)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return nil, nil, fmt.Errorf("flag not found")
+		}
+		return nil, nil, fmt.Errorf("failed to update flag rules")
+	}
+
+	s.logger.Info().
+		Str("env_id", envID.String()).
+		Str("flag_key", flagKey).
+		Int("rules_count", len(rules)).
+		Int("lint_issues", len(report.Issues)).
+		Msg("Flag rules updated successfully")
+	return updated, report, nil
+}
+
+// Ramp control actions
+const (
+	RampActionPause  = "pause"
+	RampActionResume = "resume"
+	RampActionAbort  = "abort"
+)
+
+// ControlRamp pauses, resumes or aborts the ramp of a flag rule's rollout.
+// A paused ramp holds its percentage until resumed; an aborted one serves the
+// control variation to everyone. The control applies to the published rules
+// too, without publishing the flag's draft rules, when they have the ramp and
+// it can take the action.
+func (s *FlagService) ControlRamp(ctx context.Context, envID uuid.UUID, flagKey, ruleID, action string) (*repository.Flag, error) {
+	flag, err := s.GetByKey(ctx, envID, flagKey)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := decodeRules(flag.RulesJSON)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rules: %w", err)
+	}
+
+	now := time.Now()
+	ramp, err := controlRamp(rules, ruleID, action, now)
+	if err != nil {
+		return nil, err
+	}
+
+	rulesJSON, err := json.Marshal(rules)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rules: %w", err)
+	}
+
+	var publishedJSON []byte
+	if flag.Published && flag.PublishedRules != nil {
+		published, err := decodeRules(flag.PublishedRules)
+		if err == nil {
+			_, err = controlRamp(published, ruleID, action, now)
+		}
+		if err == nil {
+			publishedJSON, err = json.Marshal(published)
+		}
+		if err != nil {
+			s.logger.Warn().Err(err).Str("flag_key", flagKey).Str("rule_id", ruleID).Msg("Ramp control not applied to published rules")
+			publishedJSON = nil
+		}
+	}
+
+	updated, err := s.repos.Flag.ControlRules(ctx, flag.ID, rulesJSON, publishedJSON)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return nil, fmt.Errorf("flag not found")
+		}
+		return nil, fmt.Errorf("failed to update flag rules")
+	}
+
+	s.logger.Info().
+		Str("env_id", envID.String()).
+		Str("flag_key", flagKey).
+		Str("rule_id", ruleID).
+		Str("action", action).
+		Bool("published", publishedJSON != nil).
+		Float64("percentage", ramp.Percentage(now)).
+		Msg("Flag ramp updated successfully")
+	return updated, nil
+}
+
+// controlRamp takes a ramp control action on the ramp of the rule with the
+// given ID and returns the ramp
+func controlRamp(rules []bucketing.Rule, ruleID, action string, now time.Time) (*bucketing.Ramp, error) {
+	var ramp *bucketing.Ramp
+	for i := range rules {
+		if rules[i].ID == ruleID {
+			if rules[i].Rollout == nil || rules[i].Rollout.Ramp == nil {
+				return nil, fmt.Errorf("rule has no ramp")
+			}
+			ramp = rules[i].Rollout.Ramp
+			break
+		}
+	}
+	if ramp == nil {
+		return nil, fmt.Errorf("rule not found")
+	}
+
+	var err error
+	switch action {
+	case RampActionPause:
+		err = ramp.Pause(now)
+	case RampActionResume:
+		err = ramp.Resume(now)
+	case RampActionAbort:
+		err = ramp.Abort()
+	default:
+		err = fmt.Errorf("unknown ramp action %q", action)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ramp, nil
+}
+
+// UpdatePrerequisites replaces a flag's prerequisites. Prerequisites on
+// unknown flags, on variations the prerequisite flag is not published with,
+// and ones that would create a dependency cycle, are rejected.
+func (s *FlagService) UpdatePrerequisites(ctx context.Context, envID uuid.UUID, flagKey string, prerequisites []bucketing.Prerequisite) (*repository.Flag, error) {
+	flag, err := s.GetByKey(ctx, envID, flagKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if prerequisites == nil {
+		prerequisites = []bucketing.Prerequisite{}
+	}
+
+	flagConfigs, err := s.environmentFlags(ctx, envID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, prereq := range prerequisites {
+		prereqFlag, exists := flagConfigs[prereq.FlagKey]
+		if !exists {
+			return nil, fmt.Errorf("prerequisite flag %s not found", prereq.FlagKey)
+		}
+		// Checked against the published variations, so the requirement can
+		// be met at evaluators
+		if !hasVariation(prereqFlag, prereq.VariationKey) {
+			return nil, fmt.Errorf("prerequisite flag %s has no variation %s", prereq.FlagKey, prereq.VariationKey)
+		}
+	}
+
+	flagConfigs[flagKey].Prerequisites = prerequisites
+	if cycle := bucketing.FindPrerequisiteCycle(flagConfigs); cycle != nil {
+		return nil, &PrerequisiteCycleError{Cycle: cycle}
+	}
+
+	prerequisitesJSON, err := json.Marshal(prerequisites)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prerequisites: %w", err)
+	}
+
+	updated, err := s.repos.Flag.UpdatePrerequisites(ctx, flag.ID, prerequisitesJSON)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return nil, fmt.Errorf("flag not found")
+		}
+		return nil, fmt.Errorf("failed to update flag prerequisites")
+	}
+
+	s.logger.Info().
+		Str("env_id", envID.String()).
+		Str("flag_key", flagKey).
+		Int("prerequisites_count", len(prerequisites)).
+		Msg("Flag prerequisites updated successfully")
+	return updated, nil
+}
+
+// ResetAssignments forgets the sticky assignments recorded for a flag and for
+// its running experiments, so their users are bucketed again by hashing
+func (s *FlagService) ResetAssignments(ctx context.Context, envID uuid.UUID, flagKey string) error {
+	if _, err := s.GetByKey(ctx, envID, flagKey); err != nil {
+		return err
+	}
+
+	env, err := s.repos.Environment.GetByID(ctx, envID)
+	if err != nil {
+		return fmt.Errorf("environment not found")
+	}
+
+	experiments, err := s.repos.Experiment.ListRunning(ctx, envID)
+	if err != nil {
+		return fmt.Errorf("failed to get experiments")
+	}
+
+	experimentKeys := []string{flagKey}
+	for _, experiment := range experiments {
+		if experiment.FlagKey == flagKey {
+			experimentKeys = append(experimentKeys, experiment.Key)
+		}
+	}
+
+	bucketer := bucketing.NewBucketer()
+	store := bucketing.NewRedisAssignmentStore(s.redis, assignmentResetTimeout, 0)
+	for _, key := range experimentKeys {
+		if err := store.Reset(bucketer.AssignmentExperiment(env.Salt, key)); err != nil {
+			s.logger.Error().Err(err).Str("env_id", envID.String()).Str("experiment", key).Msg("Failed to reset sticky assignments")
+			return fmt.Errorf("failed to reset assignments")
+		}
+	}
+
+	s.logger.Info().Str("env_id", envID.String()).Str("flag_key", flagKey).Strs("experiments", experimentKeys).Msg("Sticky assignments reset successfully")
+	return nil
+}
+
+// GetTargets returns the individually targeted user keys of a flag
+func (s *FlagService) GetTargets(ctx context.Context, envID uuid.UUID, flagKey string) (*repository.FlagTargets, error) {
+	flag, err := s.GetByKey(ctx, envID, flagKey)
+	if err != nil {
+		return nil, err
+	}
+
+	targets, err := s.repos.Target.GetFlagTargets(ctx, flag.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve flag targets")
+	}
+	return targets, nil
+}
+
+// UpdateTargets adds and removes individually targeted user keys of a flag
+// and returns the resulting targets
+func (s *FlagService) UpdateTargets(ctx context.Context, envID uuid.UUID, flagKey string, req *repository.UpdateFlagTargetsRequest) (*repository.FlagTargets, error) {
+	flag, err := s.GetByKey(ctx, envID, flagKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateTargetKeys(req.Add, req.Remove); err != nil {
+		return nil, err
+	}
+
+	variationKey := req.VariationKey
+	if req.Excluded {
+		variationKey = ""
+	} else if len(req.Add) > 0 && !hasVariation(s.flagConfig(flag, nil), variationKey) {
+		return nil, fmt.Errorf("flag has no variation %s", variationKey)
+	}
+
+	if len(req.Remove) > 0 {
+		if err := s.repos.Target.RemoveFlagKeys(ctx, flag.ID, req.Remove); err != nil {
+			return nil, fmt.Errorf("failed to update flag targets")
+		}
+	}
+	if len(req.Add) > 0 {
+		if err := s.repos.Target.AddFlagKeys(ctx, flag.ID, variationKey, req.Add); err != nil {
+			return nil, fmt.Errorf("failed to update flag targets")
+		}
+	}
+
+	s.logger.Info().
+		Str("env_id", envID.String()).
+		Str("flag_key", flagKey).
+		Int("added", len(req.Add)).
+		Int("removed", len(req.Remove)).
+		Msg("Flag targets updated successfully")
+	return s.GetTargets(ctx, envID, flagKey)
+}
+
+// PrerequisiteGraph returns the prerequisite dependency graph of an environment
+func (s *FlagService) PrerequisiteGraph(ctx context.Context, envID uuid.UUID) (*bucketing.PrerequisiteGraph, error) {
+	if _, err := s.repos.Environment.GetByID(ctx, envID); err != nil {
+		return nil, fmt.Errorf("environment not found")
+	}
+
+	flagConfigs, err := s.environmentFlags(ctx, envID)
+	if err != nil {
+		return nil, err
+	}
+	return bucketing.BuildPrerequisiteGraph(flagConfigs), nil
+}
+
+// environmentFlags converts every flag of an environment as it would be
+// shipped to evaluators, keyed by flag key
+func (s *FlagService) environmentFlags(ctx context.Context, envID uuid.UUID) (map[string]*bucketing.FlagConfig, error) {
+	flags, _, err := s.repos.Flag.List(ctx, envID, 1000, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve flags")
+	}
+
+	flagConfigs := make(map[string]*bucketing.FlagConfig, len(flags))
+	for _, flag := range flags {
+		flagConfigs[flag.Key] = s.flagConfig(flag, nil)
+	}
+	return flagConfigs, nil
+}
+
+// hasVariation reports whether a flag defines the variation with the given key
+func hasVariation(flagConfig *bucketing.FlagConfig, key string) bool {
+	for _, variation := range flagConfig.Variations {
+		if variation.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// LintRules lints the saved rules of a flag
+func (s *FlagService) LintRules(ctx context.Context, envID uuid.UUID, flagKey string) (*dsl.LintReport, error) {
+	flag, err := s.GetByKey(ctx, envID, flagKey)
+	if err != nil {
+		return nil, err
+	}
+	return s.lintFlag(ctx, envID, flag, nil)
+}
+
+// PreviewRules compares a flag's saved rules with proposed ones without
+// saving them
+func (s *FlagService) PreviewRules(ctx context.Context, envID uuid.UUID, flagKey string, rules []bucketing.Rule) (*dsl.PlanDiff, error) {
+	flag, err := s.GetByKey(ctx, envID, flagKey)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := s.hashVersion(ctx, envID)
+	if err != nil {
+		return nil, err
+	}
+
+	if rules == nil {
+		rules = []bucketing.Rule{}
+	}
+	allocateRollouts(flag, rules, version)
+
+	before, after := s.flagConfig(flag, nil), s.flagConfig(flag, rules)
+	before.HashVersion, after.HashVersion = version, version
+	return s.compiler.DiffFlags(before, after), nil
+}
+
+// allocateRollouts persists the bucket ranges of rollouts using the minimal
+// allocation strategy, starting from the buckets the flag's saved rule with
+// the same ID serves, in the bucket space of the environment's hash version
+func allocateRollouts(flag *repository.Flag, rules []bucketing.Rule, version hashing.Version) {
+	previous := make(map[string]*bucketing.Rollout)
+	if saved, err := decodeRules(flag.RulesJSON); err == nil {
+		for i := range saved {
+			if saved[i].Rollout != nil && saved[i].VariationKey == "" {
+				previous[saved[i].ID] = saved[i].Rollout
+			}
+		}
+	}
+
+	for i := range rules {
+		if rules[i].Rollout != nil {
+			bucketing.AllocateRollout(previous[rules[i].ID], rules[i].Rollout, version, time.Now())
+		}
+	}
+}
+
+// CompareEnvironments compares a flag with the flag of the same key in
+// another environment of the same project
+func (s *FlagService) CompareEnvironments(ctx context.Context, envID uuid.UUID, flagKey string, otherEnvID uuid.UUID) (*dsl.PlanDiff, error) {
+	env, err := s.repos.Environment.GetByID(ctx, envID)
+	if err != nil {
+		return nil, fmt.Errorf("environment not found")
+	}
+	otherEnv, err := s.repos.Environment.GetByID(ctx, otherEnvID)
+	if err != nil || otherEnv.ProjectID != env.ProjectID {
+		return nil, fmt.Errorf("environment not found")
+	}
+
+	flag, err := s.GetByKey(ctx, envID, flagKey)
+	if err != nil {
+		return nil, err
+	}
+	otherFlag, err := s.GetByKey(ctx, otherEnvID, flagKey)
+	if err != nil {
+		return nil, err
+	}
+
+	before, after := s.flagConfig(flag, nil), s.flagConfig(otherFlag, nil)
+	before.HashVersion, after.HashVersion = env.HashVersion, otherEnv.HashVersion
+	return s.compiler.DiffFlags(before, after), nil
+}
+
+// SimulateAllocation evaluates a flag, as published to evaluators, for a
+// newline-delimited JSON stream of contexts and reports where they end up
+func (s *FlagService) SimulateAllocation(ctx context.Context, envID uuid.UUID, flagKey string, contexts io.Reader) (*bucketing.SimulationReport, error) {
+	if _, err := s.repos.Environment.GetByID(ctx, envID); err != nil {
+		return nil, fmt.Errorf("environment not found")
+	}
+
+	config, err := s.configService.CompileEnvironmentConfig(ctx, envID)
+	if err != nil {
+		return nil, err
+	}
+	flagConfig, exists := config.Flags[flagKey]
+	if !exists {
+		return nil, fmt.Errorf("flag not found")
+	}
+
+	// Flags are shipped without rules; evaluators take them from the plans
+	for key, flag := range config.Flags {
+		if plan := config.Plans[key]; plan != nil {
+			if err := dsl.ApplyPlan(plan, flag); err != nil {
+				return nil, fmt.Errorf("invalid plan for flag %s: %w", key, err)
+			}
+		}
+	}
+
+	simulator := bucketing.NewSimulator(flagConfig, config.Flags, config.Segments, config.Salt)
+	if err := simulator.SimulateNDJSON(contexts); err != nil {
+		return nil, fmt.Errorf("invalid contexts: %w", err)
+	}
+	return simulator.Report(), nil
+}
+
+// hashVersion returns the bucketing hash version of an environment
+func (s *FlagService) hashVersion(ctx context.Context, envID uuid.UUID) (hashing.Version, error) {
+	env, err := s.repos.Environment.GetByID(ctx, envID)
+	if err != nil {
+		return 0, fmt.Errorf("environment not found")
+	}
+	return env.HashVersion, nil
+}
+
+// flagConfig converts a flag as it is shipped to evaluators, with rules
+// replacing the saved ones when non-nil
+func (s *FlagService) flagConfig(flag *repository.Flag, rules []bucketing.Rule) *bucketing.FlagConfig {
+	flagConfig := s.configService.convertFlagToBucketingConfig(flag)
+	if rules != nil {
+		flagConfig.Rules = rules
+	}
+	return flagConfig
+}
+
+// lintFlag lints a flag as it would be shipped to evaluators, with rules
+// replacing the saved ones when non-nil. Variation references are checked
+// against the variations defined on the flag.
+func (s *FlagService) lintFlag(ctx context.Context, envID uuid.UUID, flag *repository.Flag, rules []bucketing.Rule) (*dsl.LintReport, error) {
+	flagConfig := s.flagConfig(flag, rules)
+
+	segments, _, err := s.repos.Segment.List(ctx, envID, maxSegments, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load segments")
+	}
+	segmentKeys := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		segmentKeys = append(segmentKeys, segment.Key)
+	}
+
+	schema, err := s.configService.loadContextSchema(ctx, envID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load context schema")
+	}
+
+	return s.compiler.LintFlag(flagConfig, &dsl.LintEnvironment{Segments: segmentKeys, Schema: schema}), nil
+}
@@ -2,15 +2,39 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/rs/zerolog"
 
 	"github.com/Sidd-007/feature-flag-platform/cmd/control-plane/internal/repository"
+	"github.com/Sidd-007/feature-flag-platform/pkg/bucketing"
 	"github.com/Sidd-007/feature-flag-platform/pkg/rbac"
 )
 
+// SegmentInUseError is returned when deleting a segment that flags or other
+// segments still refer to
+type SegmentInUseError struct {
+	SegmentKey string
+	Flags      []string
+	Segments   []string
+}
+
+// Error implements the error interface
+func (e *SegmentInUseError) Error() string {
+	var users []string
+	for _, key := range e.Flags {
+		users = append(users, "flag "+key)
+	}
+	for _, key := range e.Segments {
+		users = append(users, "segment "+key)
+	}
+	return fmt.Sprintf("segment %s is still used by %s", e.SegmentKey, strings.Join(users, ", "))
+}
+
 // SegmentService handles segment business logic
 type SegmentService struct {
 	repos  *repository.Repositories
@@ -56,6 +80,9 @@ func (s *SegmentService) Create(ctx context.Context, envID uuid.UUID, req *repos
 		if err := s.validateSegmentRules(req.Rules); err != nil {
 			return nil, fmt.Errorf("invalid rules: %w", err)
 		}
+		if err := s.validateSegmentReferences(ctx, envID, req.Key, req.Rules); err != nil {
+			return nil, fmt.Errorf("invalid rules: %w", err)
+		}
 	} else {
 		// Set default empty rules
 		req.Rules = map[string]interface{}{
@@ -140,7 +167,7 @@ func (s *SegmentService) List(ctx context.Context, envID uuid.UUID, limit, offse
 // Update updates an existing segment
 func (s *SegmentService) Update(ctx context.Context, id uuid.UUID, req *repository.UpdateSegmentRequest) (*repository.Segment, error) {
 	// Get existing segment to validate environment access
-	_, err := s.repos.Segment.GetByID(ctx, id)
+	existing, err := s.repos.Segment.GetByID(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("segment not found")
 	}
@@ -150,6 +177,9 @@ func (s *SegmentService) Update(ctx context.Context, id uuid.UUID, req *reposito
 		if err := s.validateSegmentRules(req.Rules); err != nil {
 			return nil, fmt.Errorf("invalid rules: %w", err)
 		}
+		if err := s.validateSegmentReferences(ctx, existing.EnvID, existing.Key, req.Rules); err != nil {
+			return nil, fmt.Errorf("invalid rules: %w", err)
+		}
 	}
 
 	// Update segment
@@ -177,8 +207,10 @@ func (s *SegmentService) Delete(ctx context.Context, id uuid.UUID) error {
 		return fmt.Errorf("segment not found")
 	}
 
-	// TODO: Check if segment is being used by any flags before deletion
-	// For now, we'll allow deletion
+	// Refuse to delete segments that flags or other segments still refer to
+	if err := s.checkSegmentUnused(ctx, existing); err != nil {
+		return err
+	}
 
 	// Delete segment
 	err = s.repos.Segment.Delete(ctx, id)
@@ -197,7 +229,52 @@ func (s *SegmentService) Delete(ctx context.Context, id uuid.UUID) error {
 	return nil
 }
 
-// validateSegmentRules validates segment targeting rules
+// GetTargets returns the user keys listed on a segment
+func (s *SegmentService) GetTargets(ctx context.Context, id uuid.UUID) (*repository.SegmentTargets, error) {
+	if _, err := s.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+
+	targets, err := s.repos.Target.GetSegmentTargets(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve segment targets")
+	}
+	return targets, nil
+}
+
+// UpdateTargets adds and removes user keys listed on a segment and returns
+// the resulting lists
+func (s *SegmentService) UpdateTargets(ctx context.Context, id uuid.UUID, req *repository.UpdateSegmentTargetsRequest) (*repository.SegmentTargets, error) {
+	if _, err := s.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+
+	if err := validateTargetKeys(req.Add, req.Remove); err != nil {
+		return nil, err
+	}
+
+	if len(req.Remove) > 0 {
+		if err := s.repos.Target.RemoveSegmentKeys(ctx, id, req.Remove); err != nil {
+			return nil, fmt.Errorf("failed to update segment targets")
+		}
+	}
+	if len(req.Add) > 0 {
+		if err := s.repos.Target.AddSegmentKeys(ctx, id, !req.Excluded, req.Add); err != nil {
+			return nil, fmt.Errorf("failed to update segment targets")
+		}
+	}
+
+	s.logger.Info().
+		Str("segment_id", id.String()).
+		Int("added", len(req.Add)).
+		Int("removed", len(req.Remove)).
+		Msg("Segment targets updated successfully")
+	return s.GetTargets(ctx, id)
+}
+
+// validateSegmentRules validates segment targeting rules. Besides its
+// conditions, a segment may have alternative rules and include or exclude
+// other segments.
 func (s *SegmentService) validateSegmentRules(rules interface{}) error {
 	// Convert to map for validation
 	rulesMap, ok := rules.(map[string]interface{})
@@ -205,9 +282,49 @@ func (s *SegmentService) validateSegmentRules(rules interface{}) error {
 		return fmt.Errorf("rules must be an object")
 	}
 
+	for _, field := range []string{"included_segments", "excluded_segments"} {
+		if refs, exists := rulesMap[field]; exists {
+			refsArray, ok := refs.([]interface{})
+			if !ok {
+				return fmt.Errorf("%s must be an array", field)
+			}
+			for i, ref := range refsArray {
+				if key, ok := ref.(string); !ok || key == "" {
+					return fmt.Errorf("%s entry %d must be a segment key", field, i)
+				}
+			}
+		}
+	}
+
+	if alternatives, exists := rulesMap["rules"]; exists {
+		alternativesArray, ok := alternatives.([]interface{})
+		if !ok {
+			return fmt.Errorf("rules must be an array")
+		}
+		for i, alternative := range alternativesArray {
+			alternativeMap, ok := alternative.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("rule %d must be an object", i)
+			}
+			conditionsArray, ok := alternativeMap["conditions"].([]interface{})
+			if !ok {
+				return fmt.Errorf("rule %d must contain 'conditions' array", i)
+			}
+			if err := validateSegmentConditions(conditionsArray); err != nil {
+				return fmt.Errorf("rule %d: %w", i, err)
+			}
+		}
+	}
+
 	// Check for conditions array
 	conditions, exists := rulesMap["conditions"]
 	if !exists {
+		if _, hasRules := rulesMap["rules"]; hasRules {
+			return nil
+		}
+		if _, hasSegments := rulesMap["included_segments"]; hasSegments {
+			return nil
+		}
 		return fmt.Errorf("rules must contain 'conditions' array")
 	}
 
@@ -216,6 +333,109 @@ func (s *SegmentService) validateSegmentRules(rules interface{}) error {
 		return fmt.Errorf("conditions must be an array")
 	}
 
+	return validateSegmentConditions(conditionsArray)
+}
+
+// validateSegmentReferences checks that the segments a segment refers to
+// exist and that the references do not form a cycle
+func (s *SegmentService) validateSegmentReferences(ctx context.Context, envID uuid.UUID, key string, rules interface{}) error {
+	rulesJSON, err := json.Marshal(rules)
+	if err != nil {
+		return err
+	}
+	candidate, err := decodeSegment(key, "", rulesJSON)
+	if err != nil {
+		return err
+	}
+
+	refs := bucketing.SegmentReferences(candidate)
+	if len(refs) == 0 {
+		return nil
+	}
+
+	segments, err := s.environmentSegments(ctx, envID)
+	if err != nil {
+		return err
+	}
+	segments[key] = candidate
+
+	for _, ref := range refs {
+		if _, exists := segments[ref]; !exists {
+			return fmt.Errorf("referenced segment %s not found", ref)
+		}
+	}
+
+	if cycle := bucketing.FindSegmentCycle(segments); cycle != nil {
+		return fmt.Errorf("segment references create a cycle: %s", strings.Join(cycle, " -> "))
+	}
+	return nil
+}
+
+// checkSegmentUnused returns a SegmentInUseError when flags or other segments
+// of the segment's environment refer to it
+func (s *SegmentService) checkSegmentUnused(ctx context.Context, segment *repository.Segment) error {
+	inUse := &SegmentInUseError{SegmentKey: segment.Key}
+
+	flags, _, err := s.repos.Flag.List(ctx, segment.EnvID, 1000, 0)
+	if err != nil {
+		return fmt.Errorf("failed to check segment usage: %w", err)
+	}
+	for _, flag := range flags {
+		rules, err := decodeRules(flag.RulesJSON)
+		if err != nil {
+			continue
+		}
+		if containsKey(bucketing.FlagSegmentReferences(&bucketing.FlagConfig{Rules: rules}), segment.Key) {
+			inUse.Flags = append(inUse.Flags, flag.Key)
+		}
+	}
+
+	segments, err := s.environmentSegments(ctx, segment.EnvID)
+	if err != nil {
+		return fmt.Errorf("failed to check segment usage: %w", err)
+	}
+	for key, other := range segments {
+		if key != segment.Key && containsKey(bucketing.SegmentReferences(other), segment.Key) {
+			inUse.Segments = append(inUse.Segments, key)
+		}
+	}
+
+	if len(inUse.Flags) > 0 || len(inUse.Segments) > 0 {
+		sort.Strings(inUse.Flags)
+		sort.Strings(inUse.Segments)
+		return inUse
+	}
+	return nil
+}
+
+// environmentSegments decodes every segment of an environment, keyed by
+// segment key
+func (s *SegmentService) environmentSegments(ctx context.Context, envID uuid.UUID) (map[string]*bucketing.SegmentConfig, error) {
+	segments, _, err := s.repos.Segment.List(ctx, envID, maxSegments, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load segments")
+	}
+
+	configs := make(map[string]*bucketing.SegmentConfig, len(segments))
+	for _, segment := range segments {
+		config, err := decodeSegment(segment.Key, segment.Name, segment.Rules)
+		if err != nil {
+			// Undecodable segments refer to nothing but still exist
+			config = &bucketing.SegmentConfig{Key: segment.Key, Name: segment.Name}
+		}
+		configs[segment.Key] = config
+	}
+	return configs, nil
+}
+
+// containsKey reports whether a sorted list of keys contains the key
+func containsKey(keys []string, key string) bool {
+	i := sort.SearchStrings(keys, key)
+	return i < len(keys) && keys[i] == key
+}
+
+// validateSegmentConditions validates a list of segment conditions
+func validateSegmentConditions(conditionsArray []interface{}) error {
 	// Validate each condition
 	for i, cond := range conditionsArray {
 		condMap, ok := cond.(map[string]interface{})
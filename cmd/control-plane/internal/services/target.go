@@ -0,0 +1,28 @@
+package services
+
+import "fmt"
+
+// maxTargetKeysPerRequest bounds the user keys added or removed in one request
+const maxTargetKeysPerRequest = 10000
+
+// validateTargetKeys checks the user keys of a target list update
+func validateTargetKeys(add, remove []string) error {
+	if len(add)+len(remove) == 0 {
+		return fmt.Errorf("no user keys to add or remove")
+	}
+	if len(add)+len(remove) > maxTargetKeysPerRequest {
+		return fmt.Errorf("at most %d user keys can be added or removed per request", maxTargetKeysPerRequest)
+	}
+
+	for _, keys := range [][]string{add, remove} {
+		for _, key := range keys {
+			if key == "" {
+				return fmt.Errorf("user keys must not be empty")
+			}
+			if len(key) > 255 {
+				return fmt.Errorf("user key %.32s... is longer than 255 characters", key)
+			}
+		}
+	}
+	return nil
+}
@@ -11,23 +11,78 @@ import (
 	"github.com/rs/zerolog"
 
 	"github.com/Sidd-007/feature-flag-platform/pkg/bucketing"
+	"github.com/Sidd-007/feature-flag-platform/pkg/dsl"
+	"github.com/Sidd-007/feature-flag-platform/pkg/hashing"
+	"github.com/Sidd-007/feature-flag-platform/pkg/operators"
 )
 
 // EnvironmentConfig represents the configuration for an environment
 type EnvironmentConfig struct {
-	EnvKey    string                              `json:"env_key"`
-	Version   int                                 `json:"version"`
-	Salt      string                              `json:"salt"`
-	Flags     map[string]*bucketing.FlagConfig    `json:"flags"`
-	Segments  map[string]*bucketing.SegmentConfig `json:"segments"`
-	UpdatedAt time.Time                           `json:"updated_at"`
-	ETag      string                              `json:"etag"`
+	EnvKey        string                              `json:"env_key"`
+	Version       int                                 `json:"version"`
+	Salt          string                              `json:"salt"`
+	HashVersion   hashing.Version                     `json:"hash_version,omitempty"`
+	Holdout       *bucketing.HoldoutConfig            `json:"holdout,omitempty"`
+	Flags         map[string]*bucketing.FlagConfig    `json:"flags"`
+	Segments      map[string]*bucketing.SegmentConfig `json:"segments"`
+	ContextSchema *bucketing.ContextSchema            `json:"context_schema,omitempty"`
+	Plans         map[string]*dsl.CompiledPlan        `json:"plans,omitempty"` // rules of each flag, which is shipped without them
+	UpdatedAt     time.Time                           `json:"updated_at"`
+	ETag          string                              `json:"etag"`
+}
+
+// Compile sets the rules of every flag from its plan and pre-compiles the
+// plans and segments into typed matchers. Invalid conditions are reported but
+// do not prevent the rest of the configuration from being compiled; they fall
+// back to uncompiled evaluation, which never matches an invalid operand.
+// Rollouts are served from the bucket ranges laid out at publish. Flags
+// shipped without a valid plan are reported and serve their default
+// variation. Flags are bucketed with the environment's hash version when it
+// is set, and flags running an experiment are subject to the environment's
+// holdout. Relative time operators read the current time from clock, which
+// should be the evaluating bucketer's.
+func (e *EnvironmentConfig) Compile(clock operators.Clock) []error {
+	var errs []error
+
+	compiler := dsl.NewCompiler()
+	compiler.SetClock(clock)
+	for key, flag := range e.Flags {
+		if e.HashVersion != 0 {
+			flag.HashVersion = e.HashVersion
+		}
+		if e.Holdout != nil && flag.Experiment != nil {
+			flag.Holdout = e.Holdout
+		}
+
+		plan := e.Plans[key]
+		if plan == nil {
+			flag.Rules = nil
+			errs = append(errs, fmt.Errorf("flag %s has no plan", key))
+			continue
+		}
+		if err := compiler.PreparePlan(plan); err != nil {
+			errs = append(errs, fmt.Errorf("plan %s: %w", key, err))
+		}
+		if err := dsl.ApplyPlan(plan, flag); err != nil {
+			flag.Rules = nil
+			errs = append(errs, fmt.Errorf("plan %s: %w", key, err))
+		}
+	}
+
+	for key, segment := range e.Segments {
+		if err := segment.Compile(clock); err != nil {
+			errs = append(errs, fmt.Errorf("segment %s: %w", key, err))
+		}
+	}
+
+	return errs
 }
 
 // ConfigCache manages flag configurations in memory and Redis
 type ConfigCache struct {
 	redis  *redis.Client
 	logger zerolog.Logger
+	clock  operators.Clock // compiled conditions read the current time from it
 
 	// In-memory cache with read-write mutex for concurrent access
 	mu      sync.RWMutex
@@ -51,10 +106,21 @@ func NewConfigCache(redisClient *redis.Client, logger zerolog.Logger) *ConfigCac
 	return &ConfigCache{
 		redis:   redisClient,
 		logger:  logger.With().Str("component", "config_cache").Logger(),
+		clock:   operators.SystemClock,
 		configs: make(map[string]*EnvironmentConfig),
 	}
 }
 
+// SetClock sets the clock configurations loaded from now on are compiled
+// with. It should match the clock of the bucketer evaluating them and be
+// called before the cache is used. A nil clock restores the system clock.
+func (c *ConfigCache) SetClock(clock operators.Clock) {
+	if clock == nil {
+		clock = operators.SystemClock
+	}
+	c.clock = clock
+}
+
 // ConfigLoader interface for loading configs when not in cache
 type ConfigLoader interface {
 	FetchConfig(ctx context.Context, envKey string) error
@@ -149,6 +215,26 @@ func (c *ConfigCache) InvalidateConfig(envKey string) {
 	}()
 }
 
+// ReloadConfig replaces the in-memory configuration of an environment with
+// the one stored in Redis, for updates too large to be sent in full. Unlike
+// InvalidateConfig it leaves Redis alone. Without a stored configuration the
+// in-memory copy is dropped, so the next request loads it.
+func (c *ConfigCache) ReloadConfig(ctx context.Context, envKey string) error {
+	config, err := c.loadFromRedis(ctx, envKey)
+	if err == nil && config != nil {
+		c.setConfig(envKey, config)
+		return nil
+	}
+
+	c.mu.Lock()
+	if _, exists := c.configs[envKey]; exists {
+		delete(c.configs, envKey)
+		c.recordEviction()
+	}
+	c.mu.Unlock()
+	return err
+}
+
 // ListCachedEnvironments returns list of cached environment keys
 func (c *ConfigCache) ListCachedEnvironments() []string {
 	c.mu.RLock()
@@ -194,6 +280,11 @@ func (c *ConfigCache) WarmupCache(ctx context.Context, envKeys []string) error {
 // Private methods
 
 func (c *ConfigCache) setConfig(envKey string, config *EnvironmentConfig) {
+	// Compile once per load so evaluations run pre-built matchers
+	for _, err := range config.Compile(c.clock) {
+		c.logger.Warn().Err(err).Str("env_key", envKey).Msg("Failed to compile config")
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -2,12 +2,14 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/rs/zerolog"
 
+	edgemiddleware "github.com/Sidd-007/feature-flag-platform/cmd/edge-evaluator/internal/middleware"
 	"github.com/Sidd-007/feature-flag-platform/cmd/edge-evaluator/internal/services"
 	"github.com/Sidd-007/feature-flag-platform/pkg/bucketing"
 )
@@ -44,12 +46,24 @@ func (h *EvaluationHandler) EvaluateFlags(w http.ResponseWriter, r *http.Request
 		h.sendError(w, http.StatusBadRequest, "invalid_request", "User context with user_key is required")
 		return
 	}
+	if err := req.Context.ValidateContexts(); err != nil {
+		h.sendError(w, http.StatusBadRequest, "invalid_context", err.Error())
+		return
+	}
+
+	req.Explain = req.Explain || explainRequested(r)
+	if req.Explain && !h.authorizeExplain(w, r) {
+		return
+	}
 
 	// Add request ID to response
 	requestID := middleware.GetReqID(r.Context())
 
 	response, err := h.evaluationService.EvaluateFlags(r.Context(), &req)
 	if err != nil {
+		if h.sendContextSchemaError(w, err) {
+			return
+		}
 		h.logger.Error().Err(err).Str("env_key", req.EnvKey).Msg("Failed to evaluate flags")
 		h.sendError(w, http.StatusInternalServerError, "evaluation_failed", err.Error())
 		return
@@ -81,17 +95,28 @@ func (h *EvaluationHandler) EvaluateAllFlags(w http.ResponseWriter, r *http.Requ
 		h.sendError(w, http.StatusBadRequest, "invalid_request", "User context with user_key is required")
 		return
 	}
+	if err := body.Context.ValidateContexts(); err != nil {
+		h.sendError(w, http.StatusBadRequest, "invalid_context", err.Error())
+		return
+	}
 
 	req := &services.EvaluationRequest{
 		EnvKey:        envKey,
 		Context:       body.Context,
 		IncludeReason: body.IncludeReason,
+		Explain:       explainRequested(r),
+	}
+	if req.Explain && !h.authorizeExplain(w, r) {
+		return
 	}
 
 	requestID := middleware.GetReqID(r.Context())
 
 	response, err := h.evaluationService.EvaluateFlags(r.Context(), req)
 	if err != nil {
+		if h.sendContextSchemaError(w, err) {
+			return
+		}
 		h.logger.Error().Err(err).Str("env_key", envKey).Msg("Failed to evaluate all flags")
 		h.sendError(w, http.StatusInternalServerError, "evaluation_failed", err.Error())
 		return
@@ -129,9 +154,21 @@ func (h *EvaluationHandler) EvaluateFlag(w http.ResponseWriter, r *http.Request)
 		h.sendError(w, http.StatusBadRequest, "invalid_request", "User context with user_key is required")
 		return
 	}
+	if err := body.Context.ValidateContexts(); err != nil {
+		h.sendError(w, http.StatusBadRequest, "invalid_context", err.Error())
+		return
+	}
 
-	result, err := h.evaluationService.EvaluateFlag(r.Context(), envKey, flagKey, body.Context)
+	explain := explainRequested(r)
+	if explain && !h.authorizeExplain(w, r) {
+		return
+	}
+
+	result, err := h.evaluationService.EvaluateFlag(r.Context(), envKey, flagKey, body.Context, explain)
 	if err != nil {
+		if h.sendContextSchemaError(w, err) {
+			return
+		}
 		h.logger.Error().Err(err).Str("env_key", envKey).Str("flag_key", flagKey).Msg("Failed to evaluate flag")
 		h.sendError(w, http.StatusInternalServerError, "evaluation_failed", err.Error())
 		return
@@ -142,6 +179,39 @@ func (h *EvaluationHandler) EvaluateFlag(w http.ResponseWriter, r *http.Request)
 
 // Helper methods
 
+// explainRequested reports whether the request asks for evaluation traces
+// with the explain=true query parameter
+func explainRequested(r *http.Request) bool {
+	return r.URL.Query().Get("explain") == "true"
+}
+
+// authorizeExplain responds 403 unless the request was made with an
+// admin-scope API key, since traces reveal targeting rules and attribute
+// values. It reports whether explaining is allowed.
+func (h *EvaluationHandler) authorizeExplain(w http.ResponseWriter, r *http.Request) bool {
+	if edgemiddleware.HasAdminScope(r) {
+		return true
+	}
+	h.sendError(w, http.StatusForbidden, "forbidden", "Explaining evaluations requires an admin-scope API key")
+	return false
+}
+
+// sendContextSchemaError responds 422 with the violations when err rejects the
+// evaluation context, and reports whether it did
+func (h *EvaluationHandler) sendContextSchemaError(w http.ResponseWriter, err error) bool {
+	var schemaErr *services.ContextSchemaError
+	if !errors.As(err, &schemaErr) {
+		return false
+	}
+
+	h.sendJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+		"error":      "invalid_context",
+		"message":    schemaErr.Error(),
+		"violations": schemaErr.Violations,
+	})
+	return true
+}
+
 func (h *EvaluationHandler) sendJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -138,6 +138,22 @@ func GetClaims(r *http.Request) *auth.Claims {
 	return claims
 }
 
+// GetAuthContext extracts the API key context from request
+func GetAuthContext(r *http.Request) *auth.Context {
+	authCtx, ok := r.Context().Value(AuthContextKeyClaims).(*auth.Context)
+	if !ok {
+		return nil
+	}
+	return authCtx
+}
+
+// HasAdminScope reports whether the request was authenticated with an
+// admin-scope API key
+func HasAdminScope(r *http.Request) bool {
+	authCtx := GetAuthContext(r)
+	return authCtx != nil && authCtx.Scope == string(auth.ScopeAdmin)
+}
+
 // Helper functions
 
 func extractTokenFromHeader(r *http.Request) string {
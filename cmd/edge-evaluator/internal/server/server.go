@@ -220,6 +220,9 @@ func (s *Server) initNATS() error {
 func (s *Server) initAuth() error {
 	s.tokenManager = auth.NewTokenManager(s.config.Auth.JWTSecret)
 	s.bucketer = bucketing.NewBucketer()
+	if s.config.EdgeEvaluator.StickyAssignments {
+		s.bucketer.SetAssignmentStore(bucketing.NewRedisAssignmentStore(s.redis, s.config.Redis.ReadTimeout, s.config.EdgeEvaluator.StickyAssignmentTTL))
+	}
 
 	s.logger.Info().Msg("Auth components initialized")
 	return nil
@@ -228,6 +231,7 @@ func (s *Server) initAuth() error {
 // Cache initialization
 func (s *Server) initCache() error {
 	s.configCache = cache.NewConfigCache(s.redis, s.logger)
+	s.configCache.SetClock(s.bucketer.Clock())
 	s.logger.Info().Msg("Configuration cache initialized")
 	return nil
 }
@@ -236,7 +240,7 @@ func (s *Server) initCache() error {
 func (s *Server) initServices() error {
 	s.eventService = services.NewEventService(s.config, s.logger)
 	s.configService = services.NewConfigService(s.configCache, s.nats, s.config, s.logger)
-	s.evaluationService = services.NewEvaluationService(s.configCache, s.bucketer, s.configService, s.eventService, s.logger)
+	s.evaluationService = services.NewEvaluationService(s.configCache, s.bucketer, s.configService, s.eventService, s.config.EdgeEvaluator.ContextValidation, s.logger)
 
 	// Start config service (for receiving config updates)
 	if err := s.configService.Start(); err != nil {
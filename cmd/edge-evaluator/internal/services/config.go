@@ -34,7 +34,7 @@ type ConfigService struct {
 
 // ConfigUpdateMessage represents a configuration update message
 type ConfigUpdateMessage struct {
-	Type      string                   `json:"type"` // "full_refresh", "incremental", "invalidate"
+	Type      string                   `json:"type"` // "full_refresh", "incremental", "reload", "invalidate"
 	EnvKey    string                   `json:"env_key"`
 	Version   int                      `json:"version"`
 	Config    *cache.EnvironmentConfig `json:"config,omitempty"`
@@ -130,6 +130,14 @@ func (s *ConfigService) handleConfigUpdate(msg *nats.Msg) {
 		if update.Config != nil {
 			s.cache.SetConfig(update.EnvKey, update.Config)
 		}
+	case "reload":
+		// The configuration was too large to send; it is already in Redis
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := s.cache.ReloadConfig(ctx, update.EnvKey)
+		cancel()
+		if err != nil {
+			s.logger.Error().Err(err).Str("env_key", update.EnvKey).Msg("Failed to reload config from Redis")
+		}
 	case "invalidate":
 		s.cache.InvalidateConfig(update.EnvKey)
 	default:
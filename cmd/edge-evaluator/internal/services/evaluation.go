@@ -11,13 +11,32 @@ import (
 	"github.com/Sidd-007/feature-flag-platform/pkg/bucketing"
 )
 
+// Context validation modes
+const (
+	ContextValidationOff      = "off"
+	ContextValidationAnnotate = "annotate"
+	ContextValidationReject   = "reject"
+)
+
+// ContextSchemaError is returned in reject mode when the evaluation context
+// violates the environment's context schema
+type ContextSchemaError struct {
+	Violations []bucketing.SchemaViolation
+}
+
+// Error implements the error interface
+func (e *ContextSchemaError) Error() string {
+	return fmt.Sprintf("context violates the environment's context schema (%d violations)", len(e.Violations))
+}
+
 // EvaluationService handles flag evaluation
 type EvaluationService struct {
-	cache        *cache.ConfigCache
-	bucketer     *bucketing.Bucketer
-	configLoader cache.ConfigLoader
-	eventService *EventService
-	logger       zerolog.Logger
+	cache             *cache.ConfigCache
+	bucketer          *bucketing.Bucketer
+	configLoader      cache.ConfigLoader
+	eventService      *EventService
+	contextValidation string
+	logger            zerolog.Logger
 }
 
 // EvaluationRequest represents a flag evaluation request
@@ -26,24 +45,31 @@ type EvaluationRequest struct {
 	FlagKeys      []string           `json:"flag_keys,omitempty"` // If empty, evaluate all flags
 	Context       *bucketing.Context `json:"context"`
 	IncludeReason bool               `json:"include_reason,omitempty"`
+
+	// Explain attaches a trace of each evaluation to its result. Explained
+	// evaluations are not tracked as exposures.
+	Explain bool `json:"explain,omitempty"`
 }
 
 // EvaluationResponse represents the response containing evaluated flags
 type EvaluationResponse struct {
-	Flags         map[string]*bucketing.EvaluationResult `json:"flags"`
-	ConfigVersion int                                    `json:"config_version"`
-	EvaluatedAt   time.Time                              `json:"evaluated_at"`
-	RequestID     string                                 `json:"request_id,omitempty"`
+	Flags             map[string]*bucketing.EvaluationResult `json:"flags"`
+	ConfigVersion     int                                    `json:"config_version"`
+	EvaluatedAt       time.Time                              `json:"evaluated_at"`
+	RequestID         string                                 `json:"request_id,omitempty"`
+	ContextViolations []bucketing.SchemaViolation            `json:"context_violations,omitempty"`
 }
 
-// NewEvaluationService creates a new evaluation service
-func NewEvaluationService(configCache *cache.ConfigCache, bucketer *bucketing.Bucketer, configLoader cache.ConfigLoader, eventService *EventService, logger zerolog.Logger) *EvaluationService {
+// NewEvaluationService creates a new evaluation service. contextValidation is
+// one of the ContextValidation modes; unknown values disable validation.
+func NewEvaluationService(configCache *cache.ConfigCache, bucketer *bucketing.Bucketer, configLoader cache.ConfigLoader, eventService *EventService, contextValidation string, logger zerolog.Logger) *EvaluationService {
 	return &EvaluationService{
-		cache:        configCache,
-		bucketer:     bucketer,
-		configLoader: configLoader,
-		eventService: eventService,
-		logger:       logger.With().Str("service", "evaluation").Logger(),
+		cache:             configCache,
+		bucketer:          bucketer,
+		configLoader:      configLoader,
+		eventService:      eventService,
+		contextValidation: contextValidation,
+		logger:            logger.With().Str("service", "evaluation").Logger(),
 	}
 }
 
@@ -62,6 +88,11 @@ func (s *EvaluationService) EvaluateFlags(ctx context.Context, req *EvaluationRe
 		return nil, fmt.Errorf("environment configuration not found")
 	}
 
+	violations, err := s.validateContext(envConfig, req.Context)
+	if err != nil {
+		return nil, err
+	}
+
 	// Determine which flags to evaluate
 	flagKeys := req.FlagKeys
 	if len(flagKeys) == 0 {
@@ -84,13 +115,14 @@ func (s *EvaluationService) EvaluateFlags(ctx context.Context, req *EvaluationRe
 			continue
 		}
 
-		// Only evaluate active flags
-		if flagConfig.Status != "active" {
+		// Only evaluate active flags, and flags that are off, which serve
+		// their off variation whatever their status
+		if flagConfig.IsOn() && flagConfig.Status != "active" {
 			s.logger.Debug().Str("flag_key", flagKey).Str("status", flagConfig.Status).Msg("Flag not active, skipping")
 			continue
 		}
 
-		result, err := s.bucketer.EvaluateFlag(flagConfig, req.Context, envConfig.Salt, envConfig.Segments)
+		result, err := s.evaluate(envConfig, flagConfig, req.Context, req.Explain)
 		if err != nil {
 			s.logger.Error().Err(err).Str("flag_key", flagKey).Msg("Failed to evaluate flag")
 			// Create error result instead of failing the entire request
@@ -107,7 +139,7 @@ func (s *EvaluationService) EvaluateFlags(ctx context.Context, req *EvaluationRe
 		}
 
 		// Clear reason if not requested
-		if !req.IncludeReason {
+		if !req.IncludeReason && !req.Explain {
 			result.Reason = ""
 		}
 
@@ -115,17 +147,19 @@ func (s *EvaluationService) EvaluateFlags(ctx context.Context, req *EvaluationRe
 	}
 
 	response := &EvaluationResponse{
-		Flags:         results,
-		ConfigVersion: envConfig.Version,
-		EvaluatedAt:   time.Now(),
+		Flags:             results,
+		ConfigVersion:     envConfig.Version,
+		EvaluatedAt:       time.Now(),
+		ContextViolations: violations,
 	}
 
 	// Track exposure events for successfully evaluated flags
-	if s.eventService != nil {
+	if s.eventService != nil && !req.Explain {
+		exposureContext := envConfig.ContextSchema.RedactPII(req.Context)
 		for flagKey, result := range results {
 			// Track all successful evaluations (result is not nil)
 			go func(fk string, r *bucketing.EvaluationResult) {
-				err := s.eventService.TrackExposure(context.Background(), req.EnvKey, fk, r, req.Context, envConfig.Version)
+				err := s.eventService.TrackExposure(context.Background(), req.EnvKey, fk, r, exposureContext, envConfig.Version)
 				if err != nil {
 					s.logger.Error().Err(err).
 						Str("flag_key", fk).
@@ -148,8 +182,10 @@ func (s *EvaluationService) EvaluateFlags(ctx context.Context, req *EvaluationRe
 	return response, nil
 }
 
-// EvaluateFlag evaluates a single flag for a user context
-func (s *EvaluationService) EvaluateFlag(ctx context.Context, envKey, flagKey string, userContext *bucketing.Context) (*bucketing.EvaluationResult, error) {
+// EvaluateFlag evaluates a single flag for a user context. With explain, a
+// trace of the evaluation is attached to the result and no exposure is
+// tracked.
+func (s *EvaluationService) EvaluateFlag(ctx context.Context, envKey, flagKey string, userContext *bucketing.Context, explain bool) (*bucketing.EvaluationResult, error) {
 	start := time.Now()
 
 	// Get environment configuration with fallback
@@ -169,8 +205,13 @@ func (s *EvaluationService) EvaluateFlag(ctx context.Context, envKey, flagKey st
 		return nil, fmt.Errorf("flag not found")
 	}
 
-	// Check if flag is active
-	if flagConfig.Status != "active" {
+	if _, err := s.validateContext(envConfig, userContext); err != nil {
+		return nil, err
+	}
+
+	// Check if flag is active. A flag that is off is evaluated regardless,
+	// since its kill switch wins over its status.
+	if flagConfig.IsOn() && flagConfig.Status != "active" && !explain {
 		// Return default variation for inactive flags
 		result := &bucketing.EvaluationResult{
 			FlagKey:      flagKey,
@@ -186,16 +227,17 @@ func (s *EvaluationService) EvaluateFlag(ctx context.Context, envKey, flagKey st
 	}
 
 	// Evaluate the flag
-	result, err := s.bucketer.EvaluateFlag(flagConfig, userContext, envConfig.Salt, envConfig.Segments)
+	result, err := s.evaluate(envConfig, flagConfig, userContext, explain)
 	if err != nil {
 		s.logger.Error().Err(err).Str("flag_key", flagKey).Msg("Failed to evaluate flag")
 		return nil, fmt.Errorf("flag evaluation failed")
 	}
 
 	// Track exposure event for successful flag evaluation
-	if s.eventService != nil {
+	if s.eventService != nil && !explain {
+		exposureContext := envConfig.ContextSchema.RedactPII(userContext)
 		go func() {
-			err := s.eventService.TrackExposure(context.Background(), envKey, flagKey, result, userContext, envConfig.Version)
+			err := s.eventService.TrackExposure(context.Background(), envKey, flagKey, result, exposureContext, envConfig.Version)
 			if err != nil {
 				s.logger.Error().Err(err).
 					Str("flag_key", flagKey).
@@ -229,11 +271,14 @@ func (s *EvaluationService) GetEnvironmentInfo(ctx context.Context, envKey strin
 		return nil, fmt.Errorf("environment not found")
 	}
 
-	activeFlags := 0
+	activeFlags, offFlags := 0, 0
 	for _, flag := range envConfig.Flags {
 		if flag.Status == "active" {
 			activeFlags++
 		}
+		if !flag.IsOn() {
+			offFlags++
+		}
 	}
 
 	return map[string]interface{}{
@@ -241,6 +286,7 @@ func (s *EvaluationService) GetEnvironmentInfo(ctx context.Context, envKey strin
 		"version":      envConfig.Version,
 		"flags_total":  len(envConfig.Flags),
 		"flags_active": activeFlags,
+		"flags_off":    offFlags,
 		"segments":     len(envConfig.Segments),
 		"updated_at":   envConfig.UpdatedAt,
 	}, nil
@@ -248,6 +294,48 @@ func (s *EvaluationService) GetEnvironmentInfo(ctx context.Context, envKey strin
 
 // Private helper methods
 
+// evaluate evaluates a flag with the bucketer. Flags bound to the plan the
+// control plane compiled for them serve their rollouts from the plan's bucket
+// ranges; configurations published before plans were shipped carry none, so
+// their rollouts are laid out per request. Prerequisites are resolved against
+// the other flags of the environment. Explained evaluations take the same
+// path through the bucketer, recording it as they go, so the trace is of the
+// evaluation that served the result; they only differ in not recording sticky
+// assignments.
+func (s *EvaluationService) evaluate(envConfig *cache.EnvironmentConfig, flagConfig *bucketing.FlagConfig, userContext *bucketing.Context, explain bool) (*bucketing.EvaluationResult, error) {
+	if explain {
+		return s.bucketer.ExplainFlag(flagConfig, envConfig.Flags, userContext, envConfig.Salt, envConfig.Segments)
+	}
+	return s.bucketer.EvaluateFlagWithPrerequisites(flagConfig, envConfig.Flags, userContext, envConfig.Salt, envConfig.Segments)
+}
+
+// validateContext checks the context against the environment's context schema.
+// Violations are logged and returned for annotation, or rejected with a
+// ContextSchemaError in reject mode.
+func (s *EvaluationService) validateContext(envConfig *cache.EnvironmentConfig, userContext *bucketing.Context) ([]bucketing.SchemaViolation, error) {
+	if s.contextValidation != ContextValidationAnnotate && s.contextValidation != ContextValidationReject {
+		return nil, nil
+	}
+
+	violations := envConfig.ContextSchema.ValidateContext(userContext)
+	if len(violations) == 0 {
+		return nil, nil
+	}
+
+	for _, violation := range violations {
+		s.logger.Warn().
+			Str("env_key", envConfig.EnvKey).
+			Str("attribute", violation.Attribute).
+			Str("code", violation.Code).
+			Msg(violation.Message)
+	}
+
+	if s.contextValidation == ContextValidationReject {
+		return nil, &ContextSchemaError{Violations: violations}
+	}
+	return violations, nil
+}
+
 func (s *EvaluationService) findVariation(variations []bucketing.Variation, key string) *bucketing.Variation {
 	for _, variation := range variations {
 		if variation.Key == key {
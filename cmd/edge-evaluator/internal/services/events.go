@@ -33,9 +33,17 @@ type ExposureEvent struct {
 	Value          interface{}            `json:"value"`
 	DefaultUsed    bool                   `json:"default_used"`
 	Reason         string                 `json:"reason,omitempty"`
+	ExperimentKey  string                 `json:"experiment_key,omitempty"`
+	InHoldout      bool                   `json:"in_holdout,omitempty"`
+	HoldoutKey     string                 `json:"holdout_key,omitempty"`
 	ConfigVersion  int                    `json:"config_version"`
 	UserAttributes map[string]interface{} `json:"user_attributes,omitempty"`
 	RequestID      string                 `json:"request_id,omitempty"`
+
+	// ContextKinds lists the kinds of the entities the evaluation was for,
+	// and Contexts the entities other than the user
+	ContextKinds []string                          `json:"context_kinds,omitempty"`
+	Contexts     map[string]*bucketing.KindContext `json:"contexts,omitempty"`
 }
 
 // CustomEvent represents a custom tracking event
@@ -75,8 +83,13 @@ func (s *EventService) TrackExposure(ctx context.Context, envKey, flagKey string
 		Value:         result.Value,
 		DefaultUsed:   false, // We'll determine this based on evaluation success
 		Reason:        result.Reason,
+		ExperimentKey: result.ExperimentKey,
+		InHoldout:     result.InHoldout,
+		HoldoutKey:    result.HoldoutKey,
 		ConfigVersion: configVersion,
 		RequestID:     extractRequestID(ctx),
+		ContextKinds:  userContext.Kinds(),
+		Contexts:      userContext.Contexts,
 	}
 
 	// Add user attributes if available
@@ -19,6 +19,8 @@ type ExposureEvent struct {
 	UserKeyHash   string                 `json:"user_key_hash"`
 	BucketingID   string                 `json:"bucketing_id"`
 	ExperimentKey string                 `json:"experiment_key,omitempty"`
+	HoldoutKey    string                 `json:"holdout_key,omitempty"` // holdout the flag is subject to
+	InHoldout     bool                   `json:"in_holdout,omitempty"`
 	SessionID     string                 `json:"session_id,omitempty"`
 	Context       map[string]interface{} `json:"context,omitempty"`
 	Meta          map[string]interface{} `json:"meta,omitempty"`
@@ -26,6 +28,7 @@ type ExposureEvent struct {
 	Reason        string                 `json:"reason,omitempty"`
 	Bucket        int                    `json:"bucket,omitempty"`
 	RuleID        string                 `json:"rule_id,omitempty"`
+	ContextKinds  []string               `json:"context_kinds,omitempty"` // kinds the flag was evaluated for
 }
 
 // MetricEvent represents a custom metric event
@@ -66,8 +69,8 @@ func (s *EventStorage) StoreExposureEvents(ctx context.Context, events []Exposur
 	batch, err := s.clickhouse.PrepareBatch(ctx, `
 		INSERT INTO events_exposure 
 		(date, timestamp, env_key, flag_key, variation_key, user_key_hash, bucketing_id, 
-		 experiment_key, session_id, context_json, meta_json, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		 experiment_key, holdout_key, in_holdout, session_id, context_kinds, context_json, meta_json, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`)
 	if err != nil {
 		return fmt.Errorf("failed to prepare exposure events batch: %w", err)
@@ -89,6 +92,16 @@ func (s *EventStorage) StoreExposureEvents(ctx context.Context, events []Exposur
 			}
 		}
 
+		contextKinds := event.ContextKinds
+		if contextKinds == nil {
+			contextKinds = []string{}
+		}
+
+		inHoldout := uint8(0)
+		if event.InHoldout {
+			inHoldout = 1
+		}
+
 		err = batch.Append(
 			event.Timestamp.Truncate(24*time.Hour), // date
 			event.Timestamp,                        // timestamp
@@ -98,7 +111,10 @@ func (s *EventStorage) StoreExposureEvents(ctx context.Context, events []Exposur
 			event.UserKeyHash,
 			event.BucketingID,
 			event.ExperimentKey,
+			event.HoldoutKey,
+			inHoldout,
 			event.SessionID,
+			contextKinds,
 			contextJSON,
 			metaJSON,
 			time.Now(),
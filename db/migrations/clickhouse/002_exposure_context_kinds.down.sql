@@ -0,0 +1,2 @@
+-- Remove context_kinds column from events_exposure table
+ALTER TABLE events_exposure DROP COLUMN IF EXISTS context_kinds;
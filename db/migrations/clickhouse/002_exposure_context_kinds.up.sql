@@ -0,0 +1,3 @@
+-- Record the context kinds (user, organization, device, ...) each exposure
+-- was evaluated for
+ALTER TABLE events_exposure ADD COLUMN IF NOT EXISTS context_kinds Array(String) DEFAULT [] AFTER session_id;
@@ -0,0 +1,3 @@
+-- Remove holdout fields from events_exposure table
+ALTER TABLE events_exposure DROP COLUMN IF EXISTS in_holdout;
+ALTER TABLE events_exposure DROP COLUMN IF EXISTS holdout_key;
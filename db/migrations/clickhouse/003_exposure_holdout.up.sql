@@ -0,0 +1,4 @@
+-- Record global holdout membership on exposures, so the analytics engine can
+-- compare holdout users against everyone else
+ALTER TABLE events_exposure ADD COLUMN IF NOT EXISTS holdout_key String DEFAULT '' AFTER experiment_key;
+ALTER TABLE events_exposure ADD COLUMN IF NOT EXISTS in_holdout UInt8 DEFAULT 0 AFTER holdout_key;
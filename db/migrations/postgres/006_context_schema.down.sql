@@ -0,0 +1,2 @@
+-- Remove context attribute schema from environments
+ALTER TABLE environments DROP COLUMN IF EXISTS context_schema;
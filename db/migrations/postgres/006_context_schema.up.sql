@@ -0,0 +1,2 @@
+-- Add typed context attribute schema to environments
+ALTER TABLE environments ADD COLUMN context_schema JSONB NOT NULL DEFAULT '{}';
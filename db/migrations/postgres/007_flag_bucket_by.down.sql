@@ -0,0 +1,2 @@
+-- Remove bucket_by field from flags table
+ALTER TABLE flags DROP COLUMN IF EXISTS bucket_by;
@@ -0,0 +1,2 @@
+-- Add the attribute flags bucket users by; empty buckets by user key
+ALTER TABLE flags ADD COLUMN bucket_by TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+-- Remove prerequisites field from flags table
+ALTER TABLE flags DROP COLUMN IF EXISTS prerequisites;
@@ -0,0 +1,2 @@
+-- Add prerequisite flags that must serve a given variation before a flag is evaluated
+ALTER TABLE flags ADD COLUMN prerequisites JSONB NOT NULL DEFAULT '[]';
@@ -0,0 +1,3 @@
+-- Remove individual user targeting tables
+DROP TABLE IF EXISTS segment_target_keys;
+DROP TABLE IF EXISTS flag_target_keys;
@@ -0,0 +1,17 @@
+-- Individually targeted user keys of flags; a NULL variation excludes the user
+CREATE TABLE IF NOT EXISTS flag_target_keys (
+    flag_id UUID NOT NULL REFERENCES flags(id) ON DELETE CASCADE,
+    user_key VARCHAR(255) NOT NULL,
+    variation_key VARCHAR(100),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (flag_id, user_key)
+);
+
+-- User keys always included in or excluded from segments
+CREATE TABLE IF NOT EXISTS segment_target_keys (
+    segment_id UUID NOT NULL REFERENCES segments(id) ON DELETE CASCADE,
+    user_key VARCHAR(255) NOT NULL,
+    included BOOLEAN NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (segment_id, user_key)
+);
@@ -0,0 +1,2 @@
+-- Remove hash_version field from environments table
+ALTER TABLE environments DROP COLUMN IF EXISTS hash_version;
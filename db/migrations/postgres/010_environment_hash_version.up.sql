@@ -0,0 +1,3 @@
+-- Add the bucketing hash version of environments; existing environments keep
+-- the original algorithm so users stay in their buckets
+ALTER TABLE environments ADD COLUMN hash_version INT NOT NULL DEFAULT 1;
@@ -0,0 +1,2 @@
+-- Remove holdout field from environments table
+ALTER TABLE environments DROP COLUMN IF EXISTS holdout;
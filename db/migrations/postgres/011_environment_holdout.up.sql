@@ -0,0 +1,2 @@
+-- Add the global holdout of environments; NULL means no holdout
+ALTER TABLE environments ADD COLUMN holdout JSONB;
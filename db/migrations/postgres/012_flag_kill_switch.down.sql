@@ -0,0 +1,3 @@
+-- Remove kill switch fields from flags table
+ALTER TABLE flags DROP COLUMN IF EXISTS off_variation;
+ALTER TABLE flags DROP COLUMN IF EXISTS is_on;
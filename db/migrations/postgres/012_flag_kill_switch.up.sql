@@ -0,0 +1,4 @@
+-- Add the kill switch of flags and the variation served while a flag is off;
+-- an empty off variation serves the default variation
+ALTER TABLE flags ADD COLUMN is_on BOOLEAN NOT NULL DEFAULT true;
+ALTER TABLE flags ADD COLUMN off_variation TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+-- Remove published rules from flags table
+ALTER TABLE flags DROP COLUMN IF EXISTS published_rules;
@@ -0,0 +1,5 @@
+-- Add the rules a flag was last published with; evaluators are only shipped
+-- these, so rules saved since are drafts. Flags already published keep
+-- serving their current rules.
+ALTER TABLE flags ADD COLUMN published_rules JSONB;
+UPDATE flags SET published_rules = rules_json WHERE published;
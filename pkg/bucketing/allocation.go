@@ -0,0 +1,71 @@
+package bucketing
+
+import (
+	"time"
+
+	"github.com/Sidd-007/feature-flag-platform/pkg/hashing"
+)
+
+// HasPersistedRanges reports whether rollout variations carry persisted
+// bucket ranges
+func HasPersistedRanges(variations []RolloutVariation) bool {
+	for _, rv := range variations {
+		if len(rv.Ranges) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// RolloutAllocation returns the buckets each variation of a rollout is served
+// from at the given time, in the bucket space of the hash version
+func RolloutAllocation(rollout *Rollout, version hashing.Version, now time.Time) []hashing.VariationBuckets {
+	variations := RolloutVariations(rollout, now)
+	if rollout.Ramp == nil && HasPersistedRanges(variations) {
+		allocation := make([]hashing.VariationBuckets, len(variations))
+		for i, rv := range variations {
+			allocation[i] = hashing.VariationBuckets{Key: rv.VariationKey, Ranges: rv.Ranges}
+		}
+		return allocation
+	}
+
+	keys, weights := rolloutWeights(variations)
+	return hashing.ForVersion(version).ContiguousAllocation(keys, weights)
+}
+
+// AllocateRollout persists the bucket ranges of a rollout using the minimal
+// allocation strategy, moving as few buckets as possible from where previous,
+// the rollout's prior version, served them at now. previous may be nil for a
+// new rollout. Ranges are in the bucket space of the hash version. Ramps and
+// rollouts using another strategy have their ranges cleared, so they are laid
+// out contiguously.
+func AllocateRollout(previous, rollout *Rollout, version hashing.Version, now time.Time) {
+	if rollout.Ramp != nil || rollout.Allocation != hashing.AllocationMinimal {
+		for i := range rollout.Variations {
+			rollout.Variations[i].Ranges = nil
+		}
+		return
+	}
+
+	var before []hashing.VariationBuckets
+	if previous != nil {
+		before = RolloutAllocation(previous, version, now)
+	}
+
+	keys, weights := rolloutWeights(rollout.Variations)
+	after := hashing.ForVersion(version).ReallocateBuckets(before, keys, weights)
+	for i := range rollout.Variations {
+		rollout.Variations[i].Ranges = after[i].Ranges
+	}
+}
+
+// rolloutWeights splits rollout variations into keys and weights
+func rolloutWeights(variations []RolloutVariation) ([]string, []float64) {
+	keys := make([]string, len(variations))
+	weights := make([]float64, len(variations))
+	for i, rv := range variations {
+		keys[i] = rv.VariationKey
+		weights[i] = rv.Weight
+	}
+	return keys, weights
+}
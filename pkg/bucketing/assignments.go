@@ -0,0 +1,122 @@
+package bucketing
+
+import (
+	"sync"
+)
+
+// AssignmentStore records the variation first served to a bucketing ID by a
+// sticky rollout, so later changes to the rollout's weights or traffic
+// allocation do not move users between variations. Implementations must be
+// safe for concurrent use.
+type AssignmentStore interface {
+	// Get returns the variation recorded for the bucketing ID in the
+	// experiment, if any
+	Get(bucketingID, experiment string) (string, bool, error)
+	// Save records the variation served to the bucketing ID in the
+	// experiment. An assignment already recorded for the bucketing ID is
+	// kept, so concurrent first evaluations agree on one variation.
+	Save(bucketingID, experiment, variationKey string) error
+	// Reset forgets every assignment recorded in the experiment
+	Reset(experiment string) error
+}
+
+// MemoryAssignmentStore is an AssignmentStore held in process memory
+type MemoryAssignmentStore struct {
+	mu          sync.RWMutex
+	assignments map[string]map[string]string // experiment -> bucketing ID -> variation
+}
+
+// NewMemoryAssignmentStore creates an empty in-memory assignment store
+func NewMemoryAssignmentStore() *MemoryAssignmentStore {
+	return &MemoryAssignmentStore{assignments: make(map[string]map[string]string)}
+}
+
+// Get implements AssignmentStore
+func (s *MemoryAssignmentStore) Get(bucketingID, experiment string) (string, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	variationKey, ok := s.assignments[experiment][bucketingID]
+	return variationKey, ok, nil
+}
+
+// Save implements AssignmentStore. An assignment already recorded is kept.
+func (s *MemoryAssignmentStore) Save(bucketingID, experiment, variationKey string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.assignments[experiment] == nil {
+		s.assignments[experiment] = make(map[string]string)
+	}
+	if _, exists := s.assignments[experiment][bucketingID]; !exists {
+		s.assignments[experiment][bucketingID] = variationKey
+	}
+	return nil
+}
+
+// Reset implements AssignmentStore
+func (s *MemoryAssignmentStore) Reset(experiment string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.assignments, experiment)
+	return nil
+}
+
+// SetAssignmentStore enables sticky bucketing for rollouts that request it,
+// recording assignments in store. A nil store disables it. It should be
+// called before the bucketer is shared across goroutines.
+func (b *Bucketer) SetAssignmentStore(store AssignmentStore) {
+	b.assignments = store
+}
+
+// AssignmentStore returns the store sticky assignments are recorded in, or
+// nil when sticky bucketing is disabled
+func (b *Bucketer) AssignmentStore() AssignmentStore {
+	return b.assignments
+}
+
+// StickyExperimentKey returns the key of the experiment a flag's sticky
+// assignments belong to: its running experiment, or the flag itself
+func StickyExperimentKey(flagConfig *FlagConfig) string {
+	if flagConfig.Experiment != nil {
+		return flagConfig.Experiment.Key
+	}
+	return flagConfig.Key
+}
+
+// AssignmentExperiment returns the identifier assignments of an experiment
+// are recorded under in an environment. It is derived from the environment
+// salt, so experiments with the same key in different environments are
+// stored and reset independently.
+func (b *Bucketer) AssignmentExperiment(envSalt, experimentKey string) string {
+	return b.hasher.GenerateBucketingID(envSalt, "assignments:", experimentKey)
+}
+
+// StickyAssignment returns the variation recorded for a sticky rollout's
+// rule bucketing ID, if any and if the variation still exists. Store errors
+// are treated as a missing assignment so evaluation falls back to hashing.
+func (b *Bucketer) StickyAssignment(flagConfig *FlagConfig, ruleBucketingID, envSalt string) *Variation {
+	if b.assignments == nil {
+		return nil
+	}
+
+	experiment := b.AssignmentExperiment(envSalt, StickyExperimentKey(flagConfig))
+	variationKey, found, err := b.assignments.Get(ruleBucketingID, experiment)
+	if err != nil || !found {
+		return nil
+	}
+	return b.findVariation(flagConfig.Variations, variationKey)
+}
+
+// RecordAssignment records the variation a sticky rollout served to a rule
+// bucketing ID. Failures to record are ignored; the user is assigned again by
+// hashing on the next evaluation.
+func (b *Bucketer) RecordAssignment(flagConfig *FlagConfig, ruleBucketingID, envSalt, variationKey string) {
+	if b.assignments == nil {
+		return
+	}
+
+	experiment := b.AssignmentExperiment(envSalt, StickyExperimentKey(flagConfig))
+	_ = b.assignments.Save(ruleBucketingID, experiment, variationKey)
+}
@@ -0,0 +1,109 @@
+package bucketing
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMemoryAssignmentStoreKeepsFirstAssignment(t *testing.T) {
+	store := NewMemoryAssignmentStore()
+	if err := store.Save("user-1", "exp", "control"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save("user-1", "exp", "treatment"); err != nil {
+		t.Fatal(err)
+	}
+
+	variationKey, found, err := store.Get("user-1", "exp")
+	if err != nil || !found || variationKey != "control" {
+		t.Errorf("Get = %q, %v, %v, want the first assignment control", variationKey, found, err)
+	}
+
+	if err := store.Reset("exp"); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := store.Get("user-1", "exp"); found {
+		t.Errorf("assignment survived Reset")
+	}
+}
+
+// stickyFlag is a flag serving a sticky 50/50 rollout to everyone
+func stickyFlag(trafficAllocation float64) *FlagConfig {
+	return &FlagConfig{
+		Key:               "checkout",
+		Status:            "active",
+		DefaultVariation:  "off",
+		TrafficAllocation: trafficAllocation,
+		Variations: []Variation{
+			{Key: "off", Value: false},
+			{Key: "control", Value: "control"},
+			{Key: "treatment", Value: "treatment"},
+		},
+		Rules: []Rule{{
+			ID:                "everyone",
+			TrafficAllocation: 1,
+			Rollout: &Rollout{
+				StickyBuckets: true,
+				Variations: []RolloutVariation{
+					{VariationKey: "control", Weight: 50},
+					{VariationKey: "treatment", Weight: 50},
+				},
+			},
+		}},
+	}
+}
+
+func TestStickyAssignmentSurvivesLowerTrafficAllocation(t *testing.T) {
+	bucketer := NewBucketer()
+	bucketer.SetAssignmentStore(NewMemoryAssignmentStore())
+
+	served := make(map[string]string)
+	for i := 0; i < 200; i++ {
+		userKey := fmt.Sprintf("user-%d", i)
+		result, err := bucketer.EvaluateFlag(stickyFlag(1), &Context{UserKey: userKey}, "salt", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		served[userKey] = result.VariationKey
+	}
+
+	// Without assignments, lowering the allocation excludes users
+	lowered := stickyFlag(0.1)
+	plain := NewBucketer()
+	excluded := 0
+	for userKey, variationKey := range served {
+		result, err := bucketer.EvaluateFlag(lowered, &Context{UserKey: userKey}, "salt", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.VariationKey != variationKey {
+			t.Errorf("%s moved from %s to %s (%s)", userKey, variationKey, result.VariationKey, result.Reason)
+		}
+
+		if result, _ := plain.EvaluateFlag(lowered, &Context{UserKey: userKey}, "salt", nil); result.Reason == "excluded by traffic allocation" {
+			excluded++
+		}
+	}
+	if excluded == 0 {
+		t.Fatalf("lowering traffic allocation excluded no users")
+	}
+
+	// New users outside the allocation are still excluded
+	for i := 200; i < 400; i++ {
+		context := &Context{UserKey: fmt.Sprintf("user-%d", i)}
+		want, err := plain.EvaluateFlag(lowered, context, "salt", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want.Reason != "excluded by traffic allocation" {
+			continue
+		}
+		result, err := bucketer.EvaluateFlag(lowered, context, "salt", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.VariationKey != "off" || result.Reason != want.Reason {
+			t.Errorf("%s outside the allocation was served %s (%s)", context.UserKey, result.VariationKey, result.Reason)
+		}
+	}
+}
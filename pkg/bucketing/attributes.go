@@ -0,0 +1,162 @@
+package bucketing
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/Sidd-007/feature-flag-platform/pkg/operators"
+)
+
+// LookupAttribute resolves an attribute path against context attributes.
+// Paths are either dotted ("company.plan", "roles.0") or JSON pointers
+// ("/company/plan"). A top-level key that matches the whole path takes
+// precedence, so attributes whose names contain dots keep working. Maps are
+// traversed by key and lists by index.
+func LookupAttribute(attributes map[string]interface{}, path string) (interface{}, bool) {
+	if value, ok := attributes[path]; ok {
+		return value, true
+	}
+
+	if !strings.ContainsAny(path, "./") {
+		return nil, false
+	}
+	return lookupSegments(attributes, splitAttributePath(path))
+}
+
+// lookupSegments follows path segments down from attributes
+func lookupSegments(attributes map[string]interface{}, segments []string) (interface{}, bool) {
+	var current interface{} = attributes
+	for _, segment := range segments {
+		next, ok := childValue(current, segment)
+		if !ok {
+			return nil, false
+		}
+		current = next
+	}
+	return current, true
+}
+
+// ResolveAttribute returns the value a condition on the attribute compares
+// against: the user key for "user_key", the attribute at the given path
+// otherwise, and the current time in the context's timezone for "now" unless
+// the context provides it. Paths starting with a context kind, such as
+// "organization.plan", address that kind's context. Missing attributes
+// resolve to nil. The current time is read from clock; nil is the system
+// clock.
+func ResolveAttribute(context *Context, attribute string, clock operators.Clock) interface{} {
+	if attribute == "user_key" {
+		return context.UserKey
+	}
+
+	value, _ := lookupContextAttribute(context, attribute)
+	if value == nil && attribute == operators.NowAttribute {
+		value = operators.NowIn(clock, context.Timezone)
+	}
+	return value
+}
+
+// BucketingKey returns the value users are bucketed by: the bucket_by
+// attribute when set and present, otherwise the user key. A bucket_by naming
+// a context kind, such as "organization", buckets by that kind's key. The
+// boolean is false when a bucket_by attribute was set but missing, so the
+// user key was used instead. Strings, numbers and booleans can be bucketed
+// by; other values count as missing.
+func BucketingKey(context *Context, bucketBy string) (string, bool) {
+	if bucketBy == "" || bucketBy == "user_key" || bucketBy == UserKind {
+		return context.UserKey, true
+	}
+
+	value, _ := lookupContextAttribute(context, bucketBy)
+	switch v := value.(type) {
+	case string:
+		if v != "" {
+			return v, true
+		}
+	case bool:
+		return strconv.FormatBool(v), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), true
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(v), true
+	}
+	return context.UserKey, false
+}
+
+// WithBucketingFallback notes in a reason that the bucket_by attribute was
+// missing and the user key was bucketed by instead. An empty attribute leaves
+// the reason unchanged.
+func WithBucketingFallback(reason, bucketBy string) string {
+	if bucketBy == "" {
+		return reason
+	}
+	return fmt.Sprintf("%s (bucket_by %s missing, bucketed by user key)", reason, bucketBy)
+}
+
+// attributeRoot returns the top-level attribute a path starts from
+func attributeRoot(path string) string {
+	segments := splitAttributePath(path)
+	if len(segments) == 0 {
+		return path
+	}
+	return segments[0]
+}
+
+// pointerUnescaper decodes the ~1 and ~0 escapes of JSON pointer segments
+var pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+
+// splitAttributePath splits a dotted path or JSON pointer into its segments
+func splitAttributePath(path string) []string {
+	if strings.HasPrefix(path, "/") {
+		segments := strings.Split(path[1:], "/")
+		for i, segment := range segments {
+			segments[i] = pointerUnescaper.Replace(segment)
+		}
+		return segments
+	}
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, ".")
+}
+
+// childValue returns the element of a map or list addressed by a path segment
+func childValue(container interface{}, segment string) (interface{}, bool) {
+	switch c := container.(type) {
+	case map[string]interface{}:
+		value, ok := c[segment]
+		return value, ok
+	case []interface{}:
+		index, err := strconv.Atoi(segment)
+		if err != nil || index < 0 || index >= len(c) {
+			return nil, false
+		}
+		return c[index], true
+	case nil:
+		return nil, false
+	}
+
+	// Typed maps and slices supplied by Go callers
+	rv := reflect.ValueOf(container)
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, false
+		}
+		value := rv.MapIndex(reflect.ValueOf(segment).Convert(rv.Type().Key()))
+		if !value.IsValid() {
+			return nil, false
+		}
+		return value.Interface(), true
+	case reflect.Slice, reflect.Array:
+		index, err := strconv.Atoi(segment)
+		if err != nil || index < 0 || index >= rv.Len() {
+			return nil, false
+		}
+		return rv.Index(index).Interface(), true
+	}
+	return nil, false
+}
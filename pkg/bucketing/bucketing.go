@@ -2,38 +2,109 @@ package bucketing
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/Sidd-007/feature-flag-platform/pkg/hashing"
+	"github.com/Sidd-007/feature-flag-platform/pkg/operators"
 )
 
 // Bucketer handles user bucketing for feature flags and experiments
 type Bucketer struct {
-	hasher *hashing.Hasher
+	hasher      *hashing.Hasher
+	assignments AssignmentStore // records sticky rollout assignments, nil when disabled
+	clock       operators.Clock // resolves "now" and ramp schedules
 }
 
 // NewBucketer creates a new bucketer instance
 func NewBucketer() *Bucketer {
 	return &Bucketer{
 		hasher: hashing.NewHasher(),
+		clock:  operators.SystemClock,
 	}
 }
 
+// SetClock replaces the clock the bucketer reads the current time from, so
+// evaluations can be reproduced in tests. A nil clock restores the system
+// clock. Flags and segments evaluated by the bucketer should be compiled with
+// the same clock. It should be called before the bucketer is shared across
+// goroutines.
+func (b *Bucketer) SetClock(clock operators.Clock) {
+	if clock == nil {
+		clock = operators.SystemClock
+	}
+	b.clock = clock
+}
+
+// Clock returns the clock the bucketer reads the current time from
+func (b *Bucketer) Clock() operators.Clock {
+	return b.clock
+}
+
 // Context represents the user and environment context for bucketing
 type Context struct {
 	UserKey     string                 `json:"user_key"`
 	Attributes  map[string]interface{} `json:"attributes"`
 	Environment string                 `json:"environment"`
+	Timezone    string                 `json:"timezone,omitempty"` // IANA zone used to resolve "now"
+
+	// Contexts describes the entities other than the user the evaluation is
+	// for, keyed by kind, such as "organization" or "device"
+	Contexts map[string]*KindContext `json:"contexts,omitempty"`
+
+	// segmentMemo caches segment membership, see MatchSegment. Contexts are
+	// per request: one whose attributes change must be replaced, and one
+	// context must not be evaluated from several goroutines at once.
+	segmentMemo map[*SegmentConfig]bool
 }
 
 // FlagConfig represents the configuration for a feature flag
 type FlagConfig struct {
-	Key               string      `json:"key"`
-	Type              string      `json:"type"` // boolean, multivariate, json
-	Variations        []Variation `json:"variations"`
-	DefaultVariation  string      `json:"default_variation"`
-	Rules             []Rule      `json:"rules"`
-	Status            string      `json:"status"`
-	TrafficAllocation float64     `json:"traffic_allocation"` // 0.0 to 1.0
+	Key               string            `json:"key"`
+	Type              string            `json:"type"` // boolean, multivariate, json
+	Variations        []Variation       `json:"variations"`
+	DefaultVariation  string            `json:"default_variation"`
+	Rules             []Rule            `json:"rules,omitempty"`     // shipped to evaluators as plans instead
+	Status            string            `json:"status"`              // active or archived
+	TrafficAllocation float64           `json:"traffic_allocation"`  // 0.0 to 1.0
+	BucketBy          string            `json:"bucket_by,omitempty"` // attribute to bucket by instead of the user key
+	Prerequisites     []Prerequisite    `json:"prerequisites,omitempty"`
+	Experiment        *ExperimentConfig `json:"experiment,omitempty"` // running experiment on the flag
+	Targets           []Target          `json:"targets,omitempty"`    // user keys served a variation before rules
+	ExcludedKeys      KeySet            `json:"excluded_keys,omitempty"`
+
+	// HashVersion is the environment's bucketing hash version; unset means
+	// hashing.DefaultHashVersion
+	HashVersion hashing.Version `json:"hash_version,omitempty"`
+
+	// Holdout is the environment's global holdout, set on flags running an
+	// experiment
+	Holdout *HoldoutConfig `json:"holdout,omitempty"`
+
+	// On is the flag's kill switch. A flag that is off serves its off
+	// variation to everyone, whatever its status, targets and rules; unset
+	// means on.
+	On *bool `json:"on,omitempty"`
+
+	// OffVariation is served while the flag is off; unset means the default
+	// variation
+	OffVariation string `json:"off_variation,omitempty"`
+}
+
+// ReasonOff is the reason given for results of flags that are off
+const ReasonOff = "OFF"
+
+// IsOn reports whether the flag's kill switch is on
+func (f *FlagConfig) IsOn() bool {
+	return f.On == nil || *f.On
+}
+
+// OffVariationKey returns the key of the variation served while the flag is
+// off
+func (f *FlagConfig) OffVariationKey() string {
+	if f.OffVariation != "" {
+		return f.OffVariation
+	}
+	return f.DefaultVariation
 }
 
 // Variation represents a flag variation
@@ -50,32 +121,188 @@ type Rule struct {
 	Conditions        []Condition `json:"conditions"`
 	VariationKey      string      `json:"variation_key,omitempty"`
 	Rollout           *Rollout    `json:"rollout,omitempty"`
-	TrafficAllocation float64     `json:"traffic_allocation"` // 0.0 to 1.0
+	TrafficAllocation float64     `json:"traffic_allocation"`  // 0.0 to 1.0
+	BucketBy          string      `json:"bucket_by,omitempty"` // overrides the flag's bucket_by for this rule
 }
 
-// Condition represents a single targeting condition
+// Condition represents a single targeting condition. When And, Or or Not is
+// set the condition is a logical node over its children instead of a comparison.
 type Condition struct {
-	Attribute string      `json:"attribute"`
-	Operator  string      `json:"operator"` // eq, neq, in, nin, lt, gt, lte, gte, contains, regex, semver
+	Attribute string      `json:"attribute,omitempty"`
+	Operator  string      `json:"operator,omitempty"` // eq, neq, in, nin, lt, gt, lte, gte, contains, regex, semver
 	Value     interface{} `json:"value"`
+	And       []Condition `json:"and,omitempty"`
+	Or        []Condition `json:"or,omitempty"`
+	Not       *Condition  `json:"not,omitempty"`
+
+	// matcher is the pre-compiled typed evaluator, set by FlagConfig.Compile
+	// or SetMatcher
+	matcher operators.Matcher
+}
+
+// IsLogical reports whether the condition is an and/or/not node
+func (c *Condition) IsLogical() bool {
+	return len(c.And) > 0 || len(c.Or) > 0 || c.Not != nil
+}
+
+// checkNode rejects a node that sets more than one of And, Or and Not, or
+// mixes one with a comparison. Evaluation would silently ignore part of it.
+func (c *Condition) checkNode() error {
+	logical := 0
+	for _, set := range []bool{len(c.And) > 0, len(c.Or) > 0, c.Not != nil} {
+		if set {
+			logical++
+		}
+	}
+	if logical > 1 {
+		return fmt.Errorf("condition can only have one of and, or and not")
+	}
+	if logical == 1 && (c.Attribute != "" || c.Operator != "" || c.Value != nil) {
+		return fmt.Errorf("logical condition cannot also have an attribute, operator or value")
+	}
+	return nil
 }
 
-// Rollout represents percentage-based rollout configuration
+// SetMatcher supplies the typed evaluator of a comparison compiled elsewhere,
+// such as by a plan for the flag. Like FlagConfig.Compile, it must be called
+// before the flag is shared across goroutines.
+func (c *Condition) SetMatcher(matcher operators.Matcher) {
+	c.matcher = matcher
+}
+
+// Compile pre-compiles every condition of the flag into typed matchers so
+// evaluation does not re-parse operands per request. Relative time operators
+// read the current time from clock; nil is the system clock. It should be
+// called once when the configuration is loaded, before the flag is shared
+// across goroutines.
+func (f *FlagConfig) Compile(clock operators.Clock) error {
+	for i := range f.Rules {
+		if err := compileConditions(f.Rules[i].Conditions, clock); err != nil {
+			return fmt.Errorf("rule %s: %w", f.Rules[i].ID, err)
+		}
+	}
+	return nil
+}
+
+// Compile pre-compiles the segment's conditions and rules into typed
+// matchers, like FlagConfig.Compile
+func (s *SegmentConfig) Compile(clock operators.Clock) error {
+	if err := compileConditions(s.Conditions, clock); err != nil {
+		return err
+	}
+	for i := range s.Rules {
+		if err := compileConditions(s.Rules[i].Conditions, clock); err != nil {
+			return fmt.Errorf("rule %s: %w", s.Rules[i].ID, err)
+		}
+	}
+	return nil
+}
+
+// compileConditions compiles a condition tree in place
+func compileConditions(conditions []Condition, clock operators.Clock) error {
+	for i := range conditions {
+		if err := compileCondition(&conditions[i], clock); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compileCondition compiles a single condition node
+func compileCondition(condition *Condition, clock operators.Clock) error {
+	if err := condition.checkNode(); err != nil {
+		return err
+	}
+	if condition.Not != nil {
+		return compileCondition(condition.Not, clock)
+	}
+
+	if condition.IsLogical() {
+		if err := compileConditions(condition.And, clock); err != nil {
+			return err
+		}
+		return compileConditions(condition.Or, clock)
+	}
+
+	// Segment membership is resolved against the segment map, not an operator
+	if condition.Attribute == "segment" {
+		return nil
+	}
+
+	matcher, err := operators.Compile(condition.Operator, condition.Value, clock)
+	if err != nil {
+		return fmt.Errorf("condition on %s: %w", condition.Attribute, err)
+	}
+	condition.matcher = matcher
+	return nil
+}
+
+// Rollout represents percentage-based rollout configuration. A rollout with
+// a ramp takes its weights from the ramp's schedule instead of Variations.
 type Rollout struct {
 	Variations []RolloutVariation `json:"variations"`
+	Ramp       *Ramp              `json:"ramp,omitempty"`
+
+	// StickyBuckets keeps users on the variation first served to them when
+	// the bucketer has an assignment store, even after the flag's or the
+	// rule's traffic allocation is lowered
+	StickyBuckets bool `json:"sticky_buckets,omitempty"`
+
+	// Allocation is the strategy bucket ranges are computed with when the
+	// weights change: contiguous (default) or minimal
+	Allocation string `json:"allocation,omitempty"`
+
+	// layout is the allocation laid out ahead of evaluation for
+	// layoutVersion, set by SetLayout
+	layout        []hashing.VariationBuckets
+	layoutVersion hashing.Version
+}
+
+// SetLayout supplies the buckets each variation of the rollout is served
+// from, laid out ahead of time for a hash version, so evaluations do not
+// allocate them per request. The layout is only used by flags bucketed with
+// that version, and never for ramps, whose weights change over time. It must
+// be called before the flag is shared across goroutines.
+func (r *Rollout) SetLayout(version hashing.Version, layout []hashing.VariationBuckets) {
+	r.layout = layout
+	r.layoutVersion = hashing.ForVersion(version).Version()
+}
+
+// allocation returns the buckets each variation of the rollout is served
+// from with the hasher at the given time, from its layout when it has one for
+// the hasher, and where the buckets came from, one of the Layout constants
+func (r *Rollout) allocation(hasher *hashing.Hasher, now time.Time) ([]hashing.VariationBuckets, string) {
+	if r.Ramp == nil && r.layout != nil && r.layoutVersion == hasher.Version() {
+		return r.layout, LayoutPlan
+	}
+	if r.Ramp == nil && HasPersistedRanges(r.Variations) {
+		return RolloutAllocation(r, hasher.Version(), now), LayoutPersisted
+	}
+	return RolloutAllocation(r, hasher.Version(), now), LayoutWeights
 }
 
 // RolloutVariation represents a variation in a rollout
 type RolloutVariation struct {
 	VariationKey string  `json:"variation_key"`
 	Weight       float64 `json:"weight"`
+
+	// Ranges are the buckets allocated to the variation when they were
+	// persisted by the minimal allocation strategy; otherwise they are laid
+	// out contiguously from the weights
+	Ranges []hashing.BucketRange `json:"ranges,omitempty"`
 }
 
 // SegmentConfig represents a user segment
 type SegmentConfig struct {
-	Key        string      `json:"key"`
-	Name       string      `json:"name"`
-	Conditions []Condition `json:"conditions"`
+	Key        string        `json:"key"`
+	Name       string        `json:"name"`
+	Conditions []Condition   `json:"conditions"`
+	Rules      []SegmentRule `json:"rules,omitempty"`    // alternatives to Conditions, any of which matches
+	Included   KeySet        `json:"included,omitempty"` // user keys always in the segment
+	Excluded   KeySet        `json:"excluded,omitempty"` // user keys never in the segment
+
+	IncludedSegments []string `json:"included_segments,omitempty"` // members of these segments are members
+	ExcludedSegments []string `json:"excluded_segments,omitempty"` // members of these segments are not
 }
 
 // EvaluationResult represents the result of flag evaluation
@@ -89,10 +316,31 @@ type EvaluationResult struct {
 	RuleID        string      `json:"rule_id,omitempty"`
 	InExperiment  bool        `json:"in_experiment"`
 	ExperimentKey string      `json:"experiment_key,omitempty"`
+	InHoldout     bool        `json:"in_holdout,omitempty"`
+	HoldoutKey    string      `json:"holdout_key,omitempty"` // holdout the flag is subject to
+	Trace         *Trace      `json:"trace,omitempty"`       // set by ExplainFlag
 }
 
-// EvaluateFlag evaluates a feature flag for the given context
+// EvaluateFlag evaluates a feature flag for the given context. Flags with
+// prerequisites should be evaluated with EvaluateFlagWithPrerequisites; here
+// their prerequisites are never met.
 func (b *Bucketer) EvaluateFlag(flagConfig *FlagConfig, context *Context, envSalt string, segments map[string]*SegmentConfig) (*EvaluationResult, error) {
+	result, err := b.evaluateFlag(flagConfig, nil, context, envSalt, segments, 0, nil)
+	return b.ReportHoldout(result, flagConfig, context), err
+}
+
+// EvaluateFlagWithPrerequisites evaluates a feature flag for the given
+// context, resolving its prerequisites against flags, the flag set of the
+// environment. A flag whose prerequisites are not met serves its default
+// variation.
+func (b *Bucketer) EvaluateFlagWithPrerequisites(flagConfig *FlagConfig, flags map[string]*FlagConfig, context *Context, envSalt string, segments map[string]*SegmentConfig) (*EvaluationResult, error) {
+	result, err := b.evaluateFlag(flagConfig, flags, context, envSalt, segments, 0, nil)
+	return b.ReportHoldout(result, flagConfig, context), err
+}
+
+// evaluateFlag evaluates a flag at the given prerequisite depth, recording
+// the evaluation in trace when it is not nil
+func (b *Bucketer) evaluateFlag(flagConfig *FlagConfig, flags map[string]*FlagConfig, context *Context, envSalt string, segments map[string]*SegmentConfig, depth int, trace *Trace) (*EvaluationResult, error) {
 	if flagConfig == nil {
 		return nil, fmt.Errorf("flag config is nil")
 	}
@@ -101,68 +349,256 @@ func (b *Bucketer) EvaluateFlag(flagConfig *FlagConfig, context *Context, envSal
 		return nil, fmt.Errorf("invalid context: user key is required")
 	}
 
+	// Generate bucketing ID from the flag's bucketing key
+	bucketKey, keyFound := BucketingKey(context, flagConfig.BucketBy)
+	hasher := hashing.ForVersion(flagConfig.HashVersion)
+	bucketingID := b.hasher.GenerateBucketingID(envSalt, flagConfig.Key, bucketKey)
+	bucket := hasher.DeterministicBucket(bucketingID)
+
+	if trace != nil {
+		*trace = Trace{
+			FlagKey:         flagConfig.Key,
+			Status:          flagConfig.Status,
+			BucketingID:     bucketingID,
+			Bucket:          bucket,
+			Buckets:         hasher.Buckets(),
+			HashVersion:     hasher.Version(),
+			BucketBy:        flagConfig.BucketBy,
+			BucketByMissing: !keyFound,
+			Rules:           []RuleTrace{},
+		}
+	}
+
+	// The kill switch wins over everything else
+	if !flagConfig.IsOn() {
+		return b.createOffResult(flagConfig, bucketingID, bucket)
+	}
+
 	// Check if flag is active
 	if flagConfig.Status != "active" {
-		return b.createDefaultResult(flagConfig, context, envSalt, "flag is not active")
+		return b.createDefaultResult(flagConfig, bucketingID, bucket, "flag is not active")
+	}
+
+	// Prerequisites must be met before the flag's own targeting applies
+	if reason := PrerequisiteFailure(flagConfig, flags, depth, func(prereq *FlagConfig) (*EvaluationResult, error) {
+		if trace == nil {
+			return b.evaluateFlag(prereq, flags, context, envSalt, segments, depth+1, nil)
+		}
+		prereqTrace := &Trace{}
+		result, err := b.evaluateFlag(prereq, flags, context, envSalt, segments, depth+1, prereqTrace)
+		trace.tracePrerequisite(flagConfig, prereq, result, err, prereqTrace)
+		return result, err
+	}); reason != "" {
+		return b.createDefaultResult(flagConfig, bucketingID, bucket, reason)
 	}
 
-	// Generate bucketing ID
-	bucketingID := b.hasher.GenerateBucketingID(envSalt, flagConfig.Key, context.UserKey)
-	bucket := b.hasher.DeterministicBucket(bucketingID)
+	// Individually targeted users bypass traffic allocation and rules
+	if variationKey, reason := MatchTargets(flagConfig, context.UserKey); variationKey != "" {
+		if trace != nil {
+			trace.Target = reason
+		}
+		variation := b.findVariation(flagConfig.Variations, variationKey)
+		if variation == nil {
+			return nil, fmt.Errorf("target variation '%s' not found", variationKey)
+		}
+		return &EvaluationResult{
+			FlagKey:      flagConfig.Key,
+			VariationKey: variation.Key,
+			Value:        variation.Value,
+			Reason:       reason,
+			BucketingID:  bucketingID,
+			Bucket:       bucket,
+		}, nil
+	}
 
-	// Check traffic allocation first
+	// Users in the environment's holdout are kept out of experiments
+	if flagConfig.Experiment != nil && flagConfig.Holdout != nil {
+		heldOut := b.IsHeldOut(flagConfig.Holdout, context)
+		if trace != nil {
+			trace.Holdout = b.traceHoldout(flagConfig.Holdout, context, heldOut)
+		}
+		if heldOut {
+			return b.createDefaultResult(flagConfig, bucketingID, bucket, HoldoutReason(flagConfig.Holdout))
+		}
+	}
+
+	// A missing bucket_by attribute is reported when it decided the outcome
+	fallback := ""
+
+	// Check traffic allocation first. Users it excludes are still served
+	// the sticky assignments they were given before it was lowered.
+	excluded := ""
 	if flagConfig.TrafficAllocation < 1.0 {
-		if !b.hasher.IsInPercentageRange(bucket, flagConfig.TrafficAllocation*100) {
-			return b.createDefaultResult(flagConfig, context, envSalt, "excluded by traffic allocation")
+		if !keyFound {
+			fallback = flagConfig.BucketBy
+		}
+		inRange := hasher.IsInPercentageRange(bucket, flagConfig.TrafficAllocation*100)
+		if trace != nil {
+			trace.TrafficAllocation = &TrafficCheck{Percentage: flagConfig.TrafficAllocation * 100, Bucket: bucket, Passed: inRange}
+		}
+		if !inRange {
+			excluded = WithBucketingFallback("excluded by traffic allocation", fallback)
+			if b.assignments == nil || !hasStickyRollout(flagConfig) {
+				return b.createDefaultResult(flagConfig, bucketingID, bucket, excluded)
+			}
+		}
+	}
+
+	// Users the flag's experiment does not claim are served the default
+	if flagConfig.Experiment != nil && excluded == "" {
+		if trace != nil {
+			trace.Experiment = b.traceExperiment(flagConfig.Experiment, context, envSalt)
+		}
+		if claimed, reason := b.ClaimExperiment(flagConfig.Experiment, context, envSalt); !claimed {
+			return b.createDefaultResult(flagConfig, bucketingID, bucket, reason)
 		}
 	}
 
 	// Evaluate rules in order
 	for _, rule := range flagConfig.Rules {
-		if b.evaluateRule(&rule, context, segments) {
+		matched := b.evaluateRule(&rule, context, segments)
+
+		var ruleTrace *RuleTrace
+		if trace != nil {
+			trace.Rules = append(trace.Rules, RuleTrace{
+				RuleID:     rule.ID,
+				Matched:    matched,
+				Conditions: b.traceConditions(rule.Conditions, context, segments),
+				Outcome:    "conditions not met",
+			})
+			ruleTrace = &trace.Rules[len(trace.Rules)-1]
+		}
+
+		if matched {
+			// Rules bucket by the flag's key unless they set their own
+			ruleBucketBy, ruleBucketingID, ruleKeyFound := flagConfig.BucketBy, bucketingID, keyFound
+			if rule.BucketBy != "" && rule.BucketBy != flagConfig.BucketBy {
+				var ruleKey string
+				ruleBucketBy = rule.BucketBy
+				ruleKey, ruleKeyFound = BucketingKey(context, rule.BucketBy)
+				ruleBucketingID = b.hasher.GenerateBucketingID(envSalt, flagConfig.Key, ruleKey)
+			}
+
+			// Users keep the variation a sticky rollout first served them, even
+			// when the rule's weights or traffic allocation changed since
+			sticky := rule.VariationKey == "" && rule.Rollout != nil && rule.Rollout.StickyBuckets
+			if sticky {
+				if variation := b.StickyAssignment(flagConfig, ruleBucketingID+rule.ID, envSalt); variation != nil {
+					if ruleTrace != nil {
+						ruleTrace.StickyAssignment = variation.Key
+						ruleTrace.VariationKey = variation.Key
+						ruleTrace.Outcome = "served sticky assignment"
+					}
+					return InExperiment(&EvaluationResult{
+						FlagKey:      flagConfig.Key,
+						VariationKey: variation.Key,
+						Value:        variation.Value,
+						Reason:       fmt.Sprintf("matched rule %s: sticky assignment", rule.ID),
+						BucketingID:  bucketingID,
+						Bucket:       bucket,
+						RuleID:       rule.ID,
+					}, flagConfig), nil
+				}
+			}
+			if excluded != "" {
+				if ruleTrace != nil {
+					ruleTrace.Outcome = "excluded by traffic allocation"
+				}
+				break
+			}
+
 			// Check rule-level traffic allocation
 			if rule.TrafficAllocation < 1.0 {
-				ruleBasedBucket := b.hasher.DeterministicBucket(bucketingID + rule.ID)
-				if !b.hasher.IsInPercentageRange(ruleBasedBucket, rule.TrafficAllocation*100) {
+				ruleBasedBucket := hasher.DeterministicBucket(ruleBucketingID + rule.ID)
+				inRange := hasher.IsInPercentageRange(ruleBasedBucket, rule.TrafficAllocation*100)
+				if ruleTrace != nil {
+					ruleTrace.TrafficAllocation = &TrafficCheck{Percentage: rule.TrafficAllocation * 100, Bucket: ruleBasedBucket, Passed: inRange}
+				}
+				if !inRange {
+					if ruleTrace != nil {
+						ruleTrace.Outcome = "excluded by rule traffic allocation"
+					}
 					continue // Skip this rule due to traffic allocation
 				}
 			}
 
+			ruleFallback := fallback
+			if rule.TrafficAllocation < 1.0 && !ruleKeyFound {
+				ruleFallback = ruleBucketBy
+			}
+
+			var reason string
+			var variation *Variation
+
 			// Rule matches, determine variation
 			if rule.VariationKey != "" {
 				// Direct variation assignment
-				variation := b.findVariation(flagConfig.Variations, rule.VariationKey)
-				if variation != nil {
-					return &EvaluationResult{
-						FlagKey:      flagConfig.Key,
-						VariationKey: rule.VariationKey,
-						Value:        variation.Value,
-						Reason:       fmt.Sprintf("matched rule %s", rule.ID),
-						BucketingID:  bucketingID,
-						Bucket:       bucket,
-						RuleID:       rule.ID,
-					}, nil
-				}
+				variation = b.findVariation(flagConfig.Variations, rule.VariationKey)
+				reason = fmt.Sprintf("matched rule %s", rule.ID)
 			} else if rule.Rollout != nil {
 				// Percentage rollout
-				variation, reason := b.evaluateRollout(rule.Rollout, flagConfig.Variations, bucketingID, rule.ID)
-				if variation != nil {
-					return &EvaluationResult{
-						FlagKey:      flagConfig.Key,
-						VariationKey: variation.Key,
-						Value:        variation.Value,
-						Reason:       fmt.Sprintf("matched rule %s: %s", rule.ID, reason),
-						BucketingID:  bucketingID,
-						Bucket:       bucket,
-						RuleID:       rule.ID,
-					}, nil
+				var rolloutReason string
+				variation, rolloutReason = b.evaluateRollout(hasher, rule.Rollout, flagConfig.Variations, ruleBucketingID, rule.ID)
+				reason = fmt.Sprintf("matched rule %s: %s", rule.ID, rolloutReason)
+				if ruleTrace != nil {
+					_, layout := rule.Rollout.allocation(hasher, b.clock.Now())
+					ruleTrace.Rollout = &RolloutTrace{Bucket: hasher.DeterministicBucket(ruleBucketingID + rule.ID), Layout: layout, Reason: rolloutReason}
+					if variation != nil {
+						ruleTrace.Rollout.VariationKey = variation.Key
+					}
 				}
+				// Explaining an evaluation must not change later ones
+				if sticky && variation != nil && trace == nil {
+					b.RecordAssignment(flagConfig, ruleBucketingID+rule.ID, envSalt, variation.Key)
+				}
+				if !ruleKeyFound {
+					ruleFallback = ruleBucketBy
+				}
+			}
+
+			if ruleTrace != nil {
+				ruleTrace.Outcome = "no variation to serve"
+			}
+			if variation != nil {
+				if ruleTrace != nil {
+					ruleTrace.VariationKey = variation.Key
+					ruleTrace.Outcome = "served variation"
+				}
+				return InExperiment(&EvaluationResult{
+					FlagKey:      flagConfig.Key,
+					VariationKey: variation.Key,
+					Value:        variation.Value,
+					Reason:       WithBucketingFallback(reason, ruleFallback),
+					BucketingID:  bucketingID,
+					Bucket:       bucket,
+					RuleID:       rule.ID,
+				}, flagConfig), nil
 			}
 		}
 	}
 
+	if excluded != "" {
+		return b.createDefaultResult(flagConfig, bucketingID, bucket, excluded)
+	}
+
 	// No rules matched, return default variation
-	return b.createDefaultResult(flagConfig, context, envSalt, "no rules matched")
+	result, err := b.createDefaultResult(flagConfig, bucketingID, bucket, "no rules matched")
+	if err != nil {
+		return nil, err
+	}
+	return InExperiment(result, flagConfig), nil
+}
+
+// hasStickyRollout reports whether any of a flag's rules serves a sticky
+// rollout
+func hasStickyRollout(flagConfig *FlagConfig) bool {
+	for i := range flagConfig.Rules {
+		rule := &flagConfig.Rules[i]
+		if rule.VariationKey == "" && rule.Rollout != nil && rule.Rollout.StickyBuckets {
+			return true
+		}
+	}
+	return false
 }
 
 // evaluateRule checks if a rule matches the given context
@@ -172,8 +608,13 @@ func (b *Bucketer) evaluateRule(rule *Rule, context *Context, segments map[strin
 	}
 
 	// All conditions must match (AND logic)
-	for _, condition := range rule.Conditions {
-		if !b.evaluateCondition(&condition, context, segments) {
+	return b.evaluateAll(rule.Conditions, context, segments)
+}
+
+// evaluateAll returns true if every condition matches, stopping at the first miss
+func (b *Bucketer) evaluateAll(conditions []Condition, context *Context, segments map[string]*SegmentConfig) bool {
+	for i := range conditions {
+		if !b.evaluateCondition(&conditions[i], context, segments) {
 			return false
 		}
 	}
@@ -181,19 +622,43 @@ func (b *Bucketer) evaluateRule(rule *Rule, context *Context, segments map[strin
 	return true
 }
 
+// evaluateLogical evaluates an and/or/not node with short-circuiting
+func (b *Bucketer) evaluateLogical(condition *Condition, context *Context, segments map[string]*SegmentConfig) bool {
+	// Invalid nodes never match
+	if condition.checkNode() != nil {
+		return false
+	}
+	if condition.Not != nil {
+		return !b.evaluateCondition(condition.Not, context, segments)
+	}
+
+	if len(condition.Or) > 0 {
+		for i := range condition.Or {
+			if b.evaluateCondition(&condition.Or[i], context, segments) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return b.evaluateAll(condition.And, context, segments)
+}
+
 // evaluateCondition evaluates a single condition
 func (b *Bucketer) evaluateCondition(condition *Condition, context *Context, segments map[string]*SegmentConfig) bool {
+	if condition.IsLogical() {
+		return b.evaluateLogical(condition, context, segments)
+	}
+
 	// Special handling for segment conditions
 	if condition.Attribute == "segment" {
 		return b.evaluateSegmentCondition(condition, context, segments)
 	}
 
-	// Get attribute value from context
-	var attributeValue interface{}
-	if condition.Attribute == "user_key" {
-		attributeValue = context.UserKey
-	} else {
-		attributeValue = context.Attributes[condition.Attribute]
+	attributeValue := ResolveAttribute(context, condition.Attribute, b.clock)
+
+	if condition.matcher != nil {
+		return condition.matcher(attributeValue)
 	}
 
 	return b.compareValues(attributeValue, condition.Operator, condition.Value)
@@ -206,83 +671,36 @@ func (b *Bucketer) evaluateSegmentCondition(condition *Condition, context *Conte
 		return false
 	}
 
-	segment, exists := segments[segmentKey]
-	if !exists {
-		return false
-	}
-
-	// Check if user matches segment conditions
-	for _, segmentCondition := range segment.Conditions {
-		if !b.evaluateCondition(&segmentCondition, context, segments) {
-			return false
-		}
-	}
-
-	return true
+	return b.MatchSegment(segmentKey, context, segments)
 }
 
-// compareValues compares two values using the given operator
+// compareValues compares two values using the given operator. This is the
+// slow path for conditions that were not pre-compiled with FlagConfig.Compile.
 func (b *Bucketer) compareValues(left interface{}, operator string, right interface{}) bool {
-	// This is a simplified implementation. In production, you'd want more robust type handling
-	switch operator {
-	case "eq":
-		return fmt.Sprintf("%v", left) == fmt.Sprintf("%v", right)
-	case "neq":
-		return fmt.Sprintf("%v", left) != fmt.Sprintf("%v", right)
-	case "in":
-		if rightSlice, ok := right.([]interface{}); ok {
-			leftStr := fmt.Sprintf("%v", left)
-			for _, item := range rightSlice {
-				if fmt.Sprintf("%v", item) == leftStr {
-					return true
-				}
-			}
-		}
-		return false
-	case "nin":
-		return !b.compareValues(left, "in", right)
-	case "contains":
-		leftStr := fmt.Sprintf("%v", left)
-		rightStr := fmt.Sprintf("%v", right)
-		return len(leftStr) > 0 && len(rightStr) > 0 &&
-			fmt.Sprintf("%v", left) != "" &&
-			fmt.Sprintf("%v", right) != ""
-	// Add more operators as needed (lt, gt, regex, semver, etc.)
-	default:
-		return false
-	}
+	return operators.Evaluate(operator, left, right, b.clock)
 }
 
-// evaluateRollout determines which variation to serve based on rollout configuration
-func (b *Bucketer) evaluateRollout(rollout *Rollout, variations []Variation, bucketingID, ruleID string) (*Variation, string) {
-	if rollout == nil || len(rollout.Variations) == 0 {
+// evaluateRollout determines which variation to serve based on rollout
+// configuration, bucketing with the flag's hasher
+func (b *Bucketer) evaluateRollout(hasher *hashing.Hasher, rollout *Rollout, variations []Variation, bucketingID, ruleID string) (*Variation, string) {
+	if rollout == nil {
 		return nil, "no rollout variations"
 	}
-
-	// Create variation allocations
-	keys := make([]string, len(rollout.Variations))
-	weights := make([]float64, len(rollout.Variations))
-
-	for i, rv := range rollout.Variations {
-		keys[i] = rv.VariationKey
-		weights[i] = rv.Weight
-	}
-
-	allocations := b.hasher.CreateVariationAllocations(keys, weights)
-	if len(allocations) == 0 {
-		return nil, "failed to create allocations"
+	allocation, _ := rollout.allocation(hasher, b.clock.Now())
+	if len(allocation) == 0 {
+		return nil, "no rollout variations"
 	}
 
 	// Use rule-specific bucketing to avoid correlation
-	ruleBucket := b.hasher.DeterministicBucket(bucketingID + ruleID)
+	ruleBucket := hasher.DeterministicBucket(bucketingID + ruleID)
 
 	// Find which allocation contains this bucket
-	for _, allocation := range allocations {
-		if allocation.BucketRange.Contains(ruleBucket) {
-			variation := b.findVariation(variations, allocation.Key)
-			if variation != nil {
-				return variation, fmt.Sprintf("rollout bucket %d in range %d-%d",
-					ruleBucket, allocation.BucketRange.Start, allocation.BucketRange.End)
+	for _, vb := range allocation {
+		for _, r := range vb.Ranges {
+			if r.Contains(ruleBucket) {
+				if variation := b.findVariation(variations, vb.Key); variation != nil {
+					return variation, fmt.Sprintf("rollout bucket %d in range %d-%d", ruleBucket, r.Start, r.End)
+				}
 			}
 		}
 	}
@@ -301,10 +719,7 @@ func (b *Bucketer) findVariation(variations []Variation, key string) *Variation
 }
 
 // createDefaultResult creates a result using the default variation
-func (b *Bucketer) createDefaultResult(flagConfig *FlagConfig, context *Context, envSalt, reason string) (*EvaluationResult, error) {
-	bucketingID := b.hasher.GenerateBucketingID(envSalt, flagConfig.Key, context.UserKey)
-	bucket := b.hasher.DeterministicBucket(bucketingID)
-
+func (b *Bucketer) createDefaultResult(flagConfig *FlagConfig, bucketingID string, bucket int, reason string) (*EvaluationResult, error) {
 	variation := b.findVariation(flagConfig.Variations, flagConfig.DefaultVariation)
 	if variation == nil {
 		return nil, fmt.Errorf("default variation '%s' not found", flagConfig.DefaultVariation)
@@ -319,3 +734,21 @@ func (b *Bucketer) createDefaultResult(flagConfig *FlagConfig, context *Context,
 		Bucket:       bucket,
 	}, nil
 }
+
+// createOffResult creates a result using the off variation
+func (b *Bucketer) createOffResult(flagConfig *FlagConfig, bucketingID string, bucket int) (*EvaluationResult, error) {
+	variationKey := flagConfig.OffVariationKey()
+	variation := b.findVariation(flagConfig.Variations, variationKey)
+	if variation == nil {
+		return nil, fmt.Errorf("off variation '%s' not found", variationKey)
+	}
+
+	return &EvaluationResult{
+		FlagKey:      flagConfig.Key,
+		VariationKey: variationKey,
+		Value:        variation.Value,
+		Reason:       ReasonOff,
+		BucketingID:  bucketingID,
+		Bucket:       bucket,
+	}, nil
+}
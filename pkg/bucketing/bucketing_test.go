@@ -0,0 +1,63 @@
+package bucketing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Sidd-007/feature-flag-platform/pkg/operators"
+)
+
+func TestBucketerClock(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	clock := operators.FixedClock(now)
+
+	flagConfig := &FlagConfig{
+		Key:               "welcome-banner",
+		Status:            "active",
+		DefaultVariation:  "off",
+		TrafficAllocation: 1,
+		Variations: []Variation{
+			{Key: "off", Value: false},
+			{Key: "on", Value: true},
+		},
+		Rules: []Rule{{
+			ID:                "new-business-hours",
+			TrafficAllocation: 1,
+			VariationKey:      "on",
+			Conditions: []Condition{
+				{Attribute: "signed_up_at", Operator: "newer_than", Value: "7d"},
+				{Attribute: "now", Operator: "between", Value: "09:00-17:00"},
+			},
+		}},
+	}
+	if err := flagConfig.Compile(clock); err != nil {
+		t.Fatal(err)
+	}
+
+	bucketer := NewBucketer()
+	bucketer.SetClock(clock)
+
+	tests := []struct {
+		signedUpAt string
+		timezone   string
+		want       string
+	}{
+		{"2026-03-08T00:00:00Z", "", "on"},
+		{"2026-02-01T00:00:00Z", "", "off"},
+		{"2026-03-08T00:00:00Z", "Asia/Tokyo", "off"},
+	}
+	for _, tt := range tests {
+		context := &Context{
+			UserKey:    "user-1",
+			Timezone:   tt.timezone,
+			Attributes: map[string]interface{}{"signed_up_at": tt.signedUpAt},
+		}
+		result, err := bucketer.EvaluateFlag(flagConfig, context, "salt", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.VariationKey != tt.want {
+			t.Errorf("signed up %s in %q: served %s (%s), want %s", tt.signedUpAt, tt.timezone, result.VariationKey, result.Reason, tt.want)
+		}
+	}
+}
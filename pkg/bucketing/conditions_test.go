@@ -0,0 +1,159 @@
+package bucketing
+
+import (
+	"testing"
+
+	"github.com/Sidd-007/feature-flag-platform/pkg/operators"
+)
+
+// probeCalls counts evaluations of the test_bucketing_probe operator, which
+// matches when its operand is true
+var probeCalls int
+
+func init() {
+	operators.MustRegister(operators.Definition{
+		Name:        "test_bucketing_probe",
+		OperandType: operators.ArgBool,
+		Compile: func(operand interface{}) (operators.Matcher, error) {
+			match := operand.(bool)
+			return func(interface{}) bool {
+				probeCalls++
+				return match
+			}, nil
+		},
+	})
+}
+
+// conditionFlag serves "on" to everyone matching conditions and "off"
+// otherwise
+func conditionFlag(conditions ...Condition) *FlagConfig {
+	return &FlagConfig{
+		Key:               "conditions",
+		Status:            "active",
+		DefaultVariation:  "off",
+		TrafficAllocation: 1,
+		Variations: []Variation{
+			{Key: "off", Value: false},
+			{Key: "on", Value: true},
+		},
+		Rules: []Rule{{
+			ID:                "rule",
+			TrafficAllocation: 1,
+			VariationKey:      "on",
+			Conditions:        conditions,
+		}},
+	}
+}
+
+func eq(attribute string, value interface{}) Condition {
+	return Condition{Attribute: attribute, Operator: "eq", Value: value}
+}
+
+func probe(match bool) Condition {
+	return Condition{Attribute: "x", Operator: "test_bucketing_probe", Value: match}
+}
+
+func TestEvaluateLogicalConditions(t *testing.T) {
+	tests := []struct {
+		name       string
+		conditions []Condition
+		want       string
+	}{
+		{"flat list is an implicit and", []Condition{eq("country", "US"), eq("plan", "free")}, "off"},
+		{"flat list matching every condition", []Condition{eq("country", "US"), eq("plan", "pro")}, "on"},
+		{"and", []Condition{{And: []Condition{eq("country", "US"), eq("plan", "pro")}}}, "on"},
+		{"or", []Condition{{Or: []Condition{eq("country", "CA"), eq("plan", "pro")}}}, "on"},
+		{"or without a match", []Condition{{Or: []Condition{eq("country", "CA"), eq("plan", "free")}}}, "off"},
+		{"not", []Condition{{Not: &Condition{And: []Condition{eq("country", "US"), eq("plan", "pro")}}}}, "off"},
+		{"nested", []Condition{{Or: []Condition{
+			{And: []Condition{eq("country", "CA"), eq("plan", "pro")}},
+			{And: []Condition{eq("country", "US"), {Not: &Condition{Attribute: "age", Operator: "lt", Value: 21}}}},
+		}}}, "on"},
+		{"nested without a match", []Condition{
+			{Or: []Condition{eq("plan", "team"), {Not: &Condition{Attribute: "country", Operator: "eq", Value: "US"}}}},
+			{Attribute: "age", Operator: "gt", Value: 18},
+		}, "off"},
+	}
+
+	bucketer := NewBucketer()
+	context := &Context{
+		UserKey:    "user-1",
+		Attributes: map[string]interface{}{"country": "US", "plan": "pro", "age": 30},
+	}
+	for _, tt := range tests {
+		flagConfig := conditionFlag(tt.conditions...)
+		if err := flagConfig.Compile(nil); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		result, err := bucketer.EvaluateFlag(flagConfig, context, "salt", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.VariationKey != tt.want {
+			t.Errorf("%s: served %s (%s), want %s", tt.name, result.VariationKey, result.Reason, tt.want)
+		}
+	}
+}
+
+func TestEvaluateLogicalShortCircuits(t *testing.T) {
+	tests := []struct {
+		name       string
+		conditions []Condition
+		want       string
+		calls      int
+	}{
+		{"flat list", []Condition{probe(false), probe(true)}, "off", 1},
+		{"and", []Condition{{And: []Condition{probe(false), probe(true)}}}, "off", 1},
+		{"or", []Condition{{Or: []Condition{probe(true), probe(false)}}}, "on", 1},
+		{"or falling through", []Condition{{Or: []Condition{probe(false), probe(true)}}}, "on", 2},
+		{"not", []Condition{{Not: &Condition{Or: []Condition{probe(true), probe(true)}}}}, "off", 1},
+	}
+
+	bucketer := NewBucketer()
+	context := &Context{UserKey: "user-1", Attributes: map[string]interface{}{"x": 1}}
+	for _, tt := range tests {
+		flagConfig := conditionFlag(tt.conditions...)
+		if err := flagConfig.Compile(nil); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		probeCalls = 0
+		result, err := bucketer.EvaluateFlag(flagConfig, context, "salt", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.VariationKey != tt.want {
+			t.Errorf("%s: served %s, want %s", tt.name, result.VariationKey, tt.want)
+		}
+		if probeCalls != tt.calls {
+			t.Errorf("%s: evaluated %d comparisons, want %d", tt.name, probeCalls, tt.calls)
+		}
+	}
+}
+
+func TestCompileRejectsAmbiguousLogicalNodes(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition Condition
+	}{
+		{"and with or", Condition{And: []Condition{eq("a", 1)}, Or: []Condition{eq("b", 2)}}},
+		{"or with not", Condition{Or: []Condition{eq("a", 1)}, Not: &Condition{Attribute: "b", Operator: "eq", Value: 2}}},
+		{"not with a comparison", Condition{Not: &Condition{Attribute: "a", Operator: "eq", Value: 1}, Attribute: "b", Operator: "eq", Value: 2}},
+		{"nested and with attribute", Condition{Or: []Condition{{And: []Condition{eq("a", 1)}, Attribute: "b"}}}},
+	}
+
+	bucketer := NewBucketer()
+	context := &Context{UserKey: "user-1", Attributes: map[string]interface{}{"a": 1, "b": 2}}
+	for _, tt := range tests {
+		flagConfig := conditionFlag(tt.condition)
+		if err := flagConfig.Compile(nil); err == nil {
+			t.Errorf("%s: Compile accepted the condition", tt.name)
+		}
+
+		// Uncompiled configs must not match the node either
+		result, err := bucketer.EvaluateFlag(conditionFlag(tt.condition), context, "salt", nil)
+		if err == nil && result.VariationKey == "on" {
+			t.Errorf("%s: the condition matched", tt.name)
+		}
+	}
+}
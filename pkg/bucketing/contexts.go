@@ -0,0 +1,126 @@
+package bucketing
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// UserKind is the context kind of the user. The user is described by a
+// context's UserKey and Attributes; other kinds are held in its Contexts.
+const UserKind = "user"
+
+// reservedKinds are names conditions already give a meaning to, so they
+// cannot name a context kind
+var reservedKinds = map[string]bool{UserKind: true, "user_key": true, "segment": true, "now": true}
+
+// KindContext describes an entity other than the user that an evaluation is
+// made for, such as the user's organization or device
+type KindContext struct {
+	Key        string                 `json:"key"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// UnmarshalJSON decodes a context. A multi-kind context may describe the
+// user as its "user" entry of contexts; that entry is moved to the user key
+// and attributes, which take precedence when also set.
+func (c *Context) UnmarshalJSON(data []byte) error {
+	type plain Context
+	var decoded plain
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*c = Context(decoded)
+
+	user, ok := c.Contexts[UserKind]
+	if !ok {
+		return nil
+	}
+	delete(c.Contexts, UserKind)
+	if user == nil {
+		return nil
+	}
+	if c.UserKey == "" {
+		c.UserKey = user.Key
+	}
+	if len(user.Attributes) > 0 {
+		attributes := make(map[string]interface{}, len(user.Attributes)+len(c.Attributes))
+		for name, value := range user.Attributes {
+			attributes[name] = value
+		}
+		for name, value := range c.Attributes {
+			attributes[name] = value
+		}
+		c.Attributes = attributes
+	}
+	return nil
+}
+
+// Kinds returns the kinds of the entities the context describes, sorted
+func (c *Context) Kinds() []string {
+	kinds := make([]string, 0, len(c.Contexts)+1)
+	if c.UserKey != "" {
+		kinds = append(kinds, UserKind)
+	}
+	for kind, entry := range c.Contexts {
+		if entry != nil && kind != UserKind {
+			kinds = append(kinds, kind)
+		}
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// ValidateContexts checks the context kinds besides the user: each must have
+// a key, and its name must not be reserved or contain the "." and "/"
+// separators of attribute paths
+func (c *Context) ValidateContexts() error {
+	kinds := make([]string, 0, len(c.Contexts))
+	for kind := range c.Contexts {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	for _, kind := range kinds {
+		if kind == "" || reservedKinds[kind] || strings.ContainsAny(kind, "./") {
+			return fmt.Errorf("invalid context kind %q", kind)
+		}
+		if entry := c.Contexts[kind]; entry == nil || entry.Key == "" {
+			return fmt.Errorf("context %s: key is required", kind)
+		}
+	}
+	return nil
+}
+
+// lookupContextAttribute resolves an attribute path against a context. Paths
+// starting with the name of a context kind the context carries address that
+// kind: the kind name alone or "<kind>.key" is its key, and "<kind>.<path>"
+// is the kind's attribute at path. Other paths address the user's
+// attributes, so attributes named after a kind keep working for contexts
+// without it.
+func lookupContextAttribute(context *Context, path string) (interface{}, bool) {
+	if len(context.Contexts) > 0 {
+		segments := splitAttributePath(path)
+		if len(segments) > 0 {
+			if entry, ok := context.Contexts[segments[0]]; ok && entry != nil {
+				return lookupKindAttribute(entry, segments[1:])
+			}
+		}
+	}
+	return LookupAttribute(context.Attributes, path)
+}
+
+// lookupKindAttribute resolves the path segments after a kind name against
+// the kind's context
+func lookupKindAttribute(entry *KindContext, segments []string) (interface{}, bool) {
+	if len(segments) == 0 || (len(segments) == 1 && segments[0] == "key") {
+		return entry.Key, entry.Key != ""
+	}
+
+	// As for the user, an attribute named by the whole path takes precedence
+	if value, ok := entry.Attributes[strings.Join(segments, ".")]; ok {
+		return value, true
+	}
+	return lookupSegments(entry.Attributes, segments)
+}
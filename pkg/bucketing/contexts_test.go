@@ -0,0 +1,168 @@
+package bucketing
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func orgContext(userKey, orgKey string, orgAttributes map[string]interface{}) *Context {
+	return &Context{
+		UserKey:    userKey,
+		Attributes: map[string]interface{}{"plan": "free"},
+		Contexts: map[string]*KindContext{
+			"organization": {Key: orgKey, Attributes: orgAttributes},
+			"device":       {Key: "device-" + userKey, Attributes: map[string]interface{}{"os": "ios"}},
+		},
+	}
+}
+
+func TestBucketingKeyByKind(t *testing.T) {
+	context := orgContext("user-1", "acme", map[string]interface{}{"region": "eu", "seats": 40.0})
+
+	tests := []struct {
+		bucketBy string
+		want     string
+		found    bool
+	}{
+		{"", "user-1", true},
+		{"user", "user-1", true},
+		{"organization", "acme", true},
+		{"organization.key", "acme", true},
+		{"organization.region", "eu", true},
+		{"organization.seats", "40", true},
+		{"device", "device-user-1", true},
+		{"organization.missing", "user-1", false},
+		{"team", "user-1", false},
+	}
+	for _, tt := range tests {
+		got, found := BucketingKey(context, tt.bucketBy)
+		if got != tt.want || found != tt.found {
+			t.Errorf("BucketingKey(%q) = %q, %v, want %q, %v", tt.bucketBy, got, found, tt.want, tt.found)
+		}
+	}
+}
+
+func TestRolloutBucketsByKind(t *testing.T) {
+	flagConfig := conditionFlag()
+	flagConfig.BucketBy = "organization"
+	flagConfig.Rules[0].VariationKey = ""
+	flagConfig.Rules[0].Rollout = &Rollout{Variations: []RolloutVariation{{VariationKey: "on", Weight: 50}, {VariationKey: "off", Weight: 50}}}
+
+	bucketer := NewBucketer()
+	served := map[string]int{}
+	for org := 0; org < 200; org++ {
+		orgKey := fmt.Sprintf("org-%d", org)
+		var first *EvaluationResult
+		for user := 0; user < 5; user++ {
+			result, err := bucketer.EvaluateFlag(flagConfig, orgContext(fmt.Sprintf("user-%d-%d", org, user), orgKey, nil), "salt", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if first == nil {
+				first = result
+				served[result.VariationKey]++
+			} else if result.VariationKey != first.VariationKey || result.Bucket != first.Bucket {
+				t.Fatalf("%s: users served %s in bucket %d and %s in bucket %d", orgKey, first.VariationKey, first.Bucket, result.VariationKey, result.Bucket)
+			}
+		}
+	}
+	if served["on"] < 70 || served["off"] < 70 {
+		t.Errorf("organizations served %v, want about half on", served)
+	}
+
+	// Users without an organization are bucketed by their own key
+	result, err := bucketer.EvaluateFlag(flagConfig, &Context{UserKey: "user-1"}, "salt", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result.Reason, "bucket_by organization missing") {
+		t.Errorf("missing organization not reported: %q", result.Reason)
+	}
+}
+
+func TestConditionsOnKindAttributes(t *testing.T) {
+	flagConfig := conditionFlag(
+		Condition{Attribute: "organization.region", Operator: "eq", Value: "eu"},
+		Condition{Attribute: "device.os", Operator: "eq", Value: "ios"},
+		Condition{Attribute: "plan", Operator: "eq", Value: "free"},
+	)
+	if err := flagConfig.Compile(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		context *Context
+		want    string
+	}{
+		{"every kind matches", orgContext("user-1", "acme", map[string]interface{}{"region": "eu"}), "on"},
+		{"organization does not match", orgContext("user-1", "acme", map[string]interface{}{"region": "us"}), "off"},
+		{"user attribute named after a missing kind", &Context{
+			UserKey: "user-1",
+			Attributes: map[string]interface{}{
+				"plan":         "free",
+				"organization": map[string]interface{}{"region": "eu"},
+				"device":       map[string]interface{}{"os": "ios"},
+			},
+		}, "on"},
+	}
+
+	bucketer := NewBucketer()
+	for _, tt := range tests {
+		result, err := bucketer.EvaluateFlag(flagConfig, tt.context, "salt", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.VariationKey != tt.want {
+			t.Errorf("%s: served %s, want %s", tt.name, result.VariationKey, tt.want)
+		}
+	}
+}
+
+func TestContextUnmarshalUserKind(t *testing.T) {
+	var context Context
+	data := `{
+		"attributes": {"plan": "pro"},
+		"contexts": {
+			"user": {"key": "user-1", "attributes": {"plan": "free", "country": "US"}},
+			"organization": {"key": "acme"}
+		}
+	}`
+	if err := json.Unmarshal([]byte(data), &context); err != nil {
+		t.Fatal(err)
+	}
+
+	if context.UserKey != "user-1" {
+		t.Errorf("user key = %q, want user-1", context.UserKey)
+	}
+	if want := map[string]interface{}{"plan": "pro", "country": "US"}; !reflect.DeepEqual(context.Attributes, want) {
+		t.Errorf("attributes = %v, want %v", context.Attributes, want)
+	}
+	if want := []string{"organization", "user"}; !reflect.DeepEqual(context.Kinds(), want) {
+		t.Errorf("kinds = %v, want %v", context.Kinds(), want)
+	}
+}
+
+func TestValidateContexts(t *testing.T) {
+	tests := []struct {
+		contexts map[string]*KindContext
+		valid    bool
+	}{
+		{map[string]*KindContext{"organization": {Key: "acme"}, "device": {Key: "d-1"}}, true},
+		{map[string]*KindContext{"organization": {}}, false},
+		{map[string]*KindContext{"organization": nil}, false},
+		{map[string]*KindContext{"segment": {Key: "s"}}, false},
+		{map[string]*KindContext{"now": {Key: "n"}}, false},
+		{map[string]*KindContext{"org.unit": {Key: "u"}}, false},
+		{map[string]*KindContext{"": {Key: "k"}}, false},
+	}
+	for _, tt := range tests {
+		context := &Context{UserKey: "user-1", Contexts: tt.contexts}
+		if err := context.ValidateContexts(); (err == nil) != tt.valid {
+			t.Errorf("%v: ValidateContexts() = %v, want valid %v", tt.contexts, err, tt.valid)
+		}
+	}
+}
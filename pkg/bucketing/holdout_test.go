@@ -0,0 +1,127 @@
+package bucketing
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Sidd-007/feature-flag-platform/pkg/hashing"
+)
+
+func TestHoldoutKeepsUsersOutOfExperiments(t *testing.T) {
+	holdout := &HoldoutConfig{Key: "global", Percentage: 10, Salt: "holdout-salt"}
+	experiment := &ExperimentConfig{Key: "exp", TrafficAllocation: 1}
+	if err := AllocateLayers([]*ExperimentConfig{experiment}, hashing.HashV1); err != nil {
+		t.Fatal(err)
+	}
+	withExperiment := experimentFlag("experiment", experiment)
+	withExperiment.Holdout = holdout
+	withoutExperiment := conditionFlag()
+	withoutExperiment.Holdout = holdout
+
+	bucketer := NewBucketer()
+	heldOut := 0
+	for i := 0; i < 5000; i++ {
+		context := &Context{UserKey: fmt.Sprintf("user-%d", i)}
+		result, err := bucketer.EvaluateFlag(withExperiment, context, "salt", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inHoldout := bucketer.IsHeldOut(holdout, context)
+		if result.InHoldout != inHoldout || result.HoldoutKey != "global" {
+			t.Fatalf("%s: reported in holdout %v (%q), want %v", context.UserKey, result.InHoldout, result.HoldoutKey, inHoldout)
+		}
+		if inHoldout {
+			heldOut++
+			if result.VariationKey != "off" || result.InExperiment || result.Reason != "in holdout global" {
+				t.Fatalf("%s is held out but was served %s (%s), in experiment %v", context.UserKey, result.VariationKey, result.Reason, result.InExperiment)
+			}
+		} else if result.VariationKey != "on" || !result.InExperiment {
+			t.Fatalf("%s is not held out but was served %s (%s)", context.UserKey, result.VariationKey, result.Reason)
+		}
+
+		// Flags without an experiment ignore the holdout
+		result, err = bucketer.EvaluateFlag(withoutExperiment, context, "salt", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.VariationKey != "on" || result.InHoldout || result.HoldoutKey != "" {
+			t.Fatalf("%s: flag without an experiment served %s, in holdout %v", context.UserKey, result.VariationKey, result.InHoldout)
+		}
+	}
+	if heldOut < 400 || heldOut > 600 {
+		t.Errorf("%d of 5000 users held out, want about 500", heldOut)
+	}
+}
+
+func TestHoldoutMembershipIsStable(t *testing.T) {
+	small := &HoldoutConfig{Key: "global", Percentage: 5, Salt: "holdout-salt"}
+	large := &HoldoutConfig{Key: "global", Percentage: 20, Salt: "holdout-salt"}
+	resalted := &HoldoutConfig{Key: "global", Percentage: 5, Salt: "other-salt"}
+
+	bucketer := NewBucketer()
+	moved := 0
+	for i := 0; i < 5000; i++ {
+		context := &Context{UserKey: fmt.Sprintf("user-%d", i)}
+		if bucketer.IsHeldOut(small, context) && !bucketer.IsHeldOut(large, context) {
+			t.Fatalf("%s left the holdout when it grew", context.UserKey)
+		}
+		if bucketer.IsHeldOut(small, context) != bucketer.IsHeldOut(resalted, context) {
+			moved++
+		}
+	}
+	if moved == 0 {
+		t.Error("changing the salt did not reshuffle the holdout")
+	}
+
+	if bucketer.IsHeldOut(&HoldoutConfig{Key: "global", Salt: "holdout-salt"}, &Context{UserKey: "user-1"}) {
+		t.Error("a 0% holdout held a user out")
+	}
+	if bucketer.IsHeldOut(nil, &Context{UserKey: "user-1"}) {
+		t.Error("a nil holdout held a user out")
+	}
+}
+
+func TestHoldoutReportedForTargetedUsers(t *testing.T) {
+	holdout := &HoldoutConfig{Key: "global", Percentage: 50, Salt: "holdout-salt"}
+	experiment := &ExperimentConfig{Key: "exp", TrafficAllocation: 1}
+	if err := AllocateLayers([]*ExperimentConfig{experiment}, hashing.HashV1); err != nil {
+		t.Fatal(err)
+	}
+	flagConfig := experimentFlag("experiment", experiment)
+	flagConfig.Holdout = holdout
+
+	bucketer := NewBucketer()
+	userKey := ""
+	for i := 0; userKey == ""; i++ {
+		if key := fmt.Sprintf("user-%d", i); bucketer.IsHeldOut(holdout, &Context{UserKey: key}) {
+			userKey = key
+		}
+	}
+	flagConfig.Targets = []Target{{VariationKey: "on", Keys: NewKeySet(userKey)}}
+
+	result, err := bucketer.EvaluateFlag(flagConfig, &Context{UserKey: userKey}, "salt", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.VariationKey != "on" || !result.InHoldout {
+		t.Errorf("targeted held-out user served %s (%s), in holdout %v", result.VariationKey, result.Reason, result.InHoldout)
+	}
+}
+
+func TestHoldoutValidate(t *testing.T) {
+	tests := []struct {
+		holdout HoldoutConfig
+		valid   bool
+	}{
+		{HoldoutConfig{Key: "global", Percentage: 5}, true},
+		{HoldoutConfig{Key: "global"}, true},
+		{HoldoutConfig{Key: " ", Percentage: 5}, false},
+		{HoldoutConfig{Key: "global", Percentage: -1}, false},
+		{HoldoutConfig{Key: "global", Percentage: 100}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.holdout.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%+v: Validate() = %v, want valid %v", tt.holdout, err, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package bucketing
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Sidd-007/feature-flag-platform/pkg/hashing"
+)
+
+// experimentFlag serves "on" to every user its experiment claims
+func experimentFlag(key string, experiment *ExperimentConfig) *FlagConfig {
+	flagConfig := conditionFlag()
+	flagConfig.Key = key
+	flagConfig.Experiment = experiment
+	return flagConfig
+}
+
+func TestAllocateLayers(t *testing.T) {
+	first := &ExperimentConfig{Key: "first", ExclusionGroup: "checkout", TrafficAllocation: 0.3}
+	second := &ExperimentConfig{Key: "second", ExclusionGroup: "checkout", TrafficAllocation: 0.5}
+	alone := &ExperimentConfig{Key: "alone", TrafficAllocation: 0.4}
+	other := &ExperimentConfig{Key: "other", ExclusionGroup: "search", TrafficAllocation: 1}
+
+	if err := AllocateLayers([]*ExperimentConfig{first, alone, second, other}, hashing.HashV3); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		experiment *ExperimentConfig
+		start, end int
+	}{
+		{first, 0, 300000},
+		{second, 300000, 800000},
+		{alone, 0, 400000},
+		{other, 0, 1000000},
+	}
+	for _, tt := range tests {
+		e := tt.experiment
+		if e.StartBucket != tt.start || e.EndBucket != tt.end || e.HashVersion != hashing.HashV3 {
+			t.Errorf("%s: buckets [%d, %d) of version %d, want [%d, %d) of version 3", e.Key, e.StartBucket, e.EndBucket, e.HashVersion, tt.start, tt.end)
+		}
+	}
+
+	full := []*ExperimentConfig{
+		{Key: "a", ExclusionGroup: "g", TrafficAllocation: 0.6},
+		{Key: "b", ExclusionGroup: "g", TrafficAllocation: 0.5},
+	}
+	if err := AllocateLayers(full, hashing.HashV1); err == nil {
+		t.Error("allocated 110% of an exclusion group")
+	}
+	if err := AllocateLayers([]*ExperimentConfig{{Key: "a", TrafficAllocation: 1.5}}, hashing.HashV1); err == nil {
+		t.Error("allocated an experiment 150% of traffic")
+	}
+}
+
+func TestExclusionGroupClaimsUsersOnce(t *testing.T) {
+	experiments := []*ExperimentConfig{
+		{Key: "a", ExclusionGroup: "checkout", TrafficAllocation: 0.5},
+		{Key: "b", ExclusionGroup: "checkout", TrafficAllocation: 0.3},
+	}
+	if err := AllocateLayers(experiments, hashing.HashV1); err != nil {
+		t.Fatal(err)
+	}
+	flagA := experimentFlag("flag-a", experiments[0])
+	flagB := experimentFlag("flag-b", experiments[1])
+
+	bucketer := NewBucketer()
+	counts := map[string]int{}
+	for i := 0; i < 4000; i++ {
+		context := &Context{UserKey: fmt.Sprintf("user-%d", i)}
+		a, err := bucketer.EvaluateFlag(flagA, context, "salt", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := bucketer.EvaluateFlag(flagB, context, "salt", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if a.InExperiment != (a.VariationKey == "on") || b.InExperiment != (b.VariationKey == "on") {
+			t.Fatalf("%s: in experiment %v and %v, served %s and %s", context.UserKey, a.InExperiment, b.InExperiment, a.VariationKey, b.VariationKey)
+		}
+		switch {
+		case a.InExperiment && b.InExperiment:
+			t.Fatalf("%s is in both experiments of the group", context.UserKey)
+		case a.InExperiment:
+			counts["a"]++
+		case b.InExperiment:
+			counts["b"]++
+			if a.Reason != "not allocated to experiment a in exclusion group checkout" {
+				t.Errorf("%s: reason %q for flag-a", context.UserKey, a.Reason)
+			}
+		default:
+			counts["neither"]++
+		}
+	}
+
+	for key, want := range map[string]int{"a": 2000, "b": 1200, "neither": 800} {
+		if got := counts[key]; got < want*85/100 || got > want*115/100 {
+			t.Errorf("%s: %d users, want about %d", key, got, want)
+		}
+	}
+}
+
+func TestAddingExperimentKeepsExistingClaims(t *testing.T) {
+	before := []*ExperimentConfig{
+		{Key: "a", ExclusionGroup: "g", TrafficAllocation: 0.3},
+		{Key: "b", ExclusionGroup: "g", TrafficAllocation: 0.3},
+	}
+	after := []*ExperimentConfig{
+		{Key: "a", ExclusionGroup: "g", TrafficAllocation: 0.3},
+		{Key: "b", ExclusionGroup: "g", TrafficAllocation: 0.3},
+		{Key: "c", ExclusionGroup: "g", TrafficAllocation: 0.3},
+	}
+	if err := AllocateLayers(before, hashing.HashV1); err != nil {
+		t.Fatal(err)
+	}
+	if err := AllocateLayers(after, hashing.HashV1); err != nil {
+		t.Fatal(err)
+	}
+
+	bucketer := NewBucketer()
+	for i := 0; i < 2000; i++ {
+		context := &Context{UserKey: fmt.Sprintf("user-%d", i)}
+		for j := range before {
+			was, _ := bucketer.ClaimExperiment(before[j], context, "salt")
+			is, _ := bucketer.ClaimExperiment(after[j], context, "salt")
+			if was != is {
+				t.Fatalf("%s: claimed by %s changed from %v to %v", context.UserKey, before[j].Key, was, is)
+			}
+		}
+	}
+}
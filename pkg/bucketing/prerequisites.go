@@ -0,0 +1,147 @@
+package bucketing
+
+import (
+	"fmt"
+	"sort"
+)
+
+// MaxPrerequisiteDepth bounds prerequisite chains during evaluation, so a
+// cycle that slipped past validation cannot recurse forever
+const MaxPrerequisiteDepth = 16
+
+// Prerequisite makes a flag depend on another flag: the flag is only
+// evaluated when the prerequisite flag serves the given variation for the
+// same context
+type Prerequisite struct {
+	FlagKey      string `json:"flag_key"`
+	VariationKey string `json:"variation_key"`
+}
+
+// PrerequisiteEdge is a dependency of one flag on another
+type PrerequisiteEdge struct {
+	From         string `json:"from"` // dependent flag
+	To           string `json:"to"`   // prerequisite flag
+	VariationKey string `json:"variation_key"`
+}
+
+// PrerequisiteGraph is the prerequisite dependency graph of an environment
+type PrerequisiteGraph struct {
+	Flags []string           `json:"flags"`
+	Edges []PrerequisiteEdge `json:"edges"`
+
+	// Cycle is a dependency cycle, starting and ending with the same flag,
+	// when the graph has one
+	Cycle []string `json:"cycle,omitempty"`
+}
+
+// BuildPrerequisiteGraph returns the prerequisite graph of a flag set, with
+// flags and edges in a stable order. Prerequisites on unknown flags are
+// included as edges.
+func BuildPrerequisiteGraph(flags map[string]*FlagConfig) *PrerequisiteGraph {
+	graph := &PrerequisiteGraph{
+		Flags: make([]string, 0, len(flags)),
+		Edges: []PrerequisiteEdge{},
+	}
+
+	for key := range flags {
+		graph.Flags = append(graph.Flags, key)
+	}
+	sort.Strings(graph.Flags)
+
+	for _, key := range graph.Flags {
+		for _, prereq := range flags[key].Prerequisites {
+			graph.Edges = append(graph.Edges, PrerequisiteEdge{From: key, To: prereq.FlagKey, VariationKey: prereq.VariationKey})
+		}
+	}
+
+	graph.Cycle = FindPrerequisiteCycle(flags)
+	return graph
+}
+
+// FindPrerequisiteCycle returns a prerequisite cycle as a path of flag keys
+// that starts and ends with the same flag, or nil when there is none
+func FindPrerequisiteCycle(flags map[string]*FlagConfig) []string {
+	const (
+		unvisited = iota
+		inProgress
+		done
+	)
+
+	keys := make([]string, 0, len(flags))
+	for key := range flags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	state := make(map[string]int, len(flags))
+	var path []string
+
+	var visit func(key string) []string
+	visit = func(key string) []string {
+		state[key] = inProgress
+		path = append(path, key)
+
+		for _, prereq := range flags[key].Prerequisites {
+			if _, exists := flags[prereq.FlagKey]; !exists {
+				continue
+			}
+			switch state[prereq.FlagKey] {
+			case inProgress:
+				for i, k := range path {
+					if k == prereq.FlagKey {
+						cycle := append([]string{}, path[i:]...)
+						return append(cycle, prereq.FlagKey)
+					}
+				}
+			case unvisited:
+				if cycle := visit(prereq.FlagKey); cycle != nil {
+					return cycle
+				}
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[key] = done
+		return nil
+	}
+
+	for _, key := range keys {
+		if state[key] == unvisited {
+			if cycle := visit(key); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// PrerequisiteFailure checks a flag's prerequisites in order and returns the
+// reason the first unmet one fails, or "" when all are met. evaluate
+// evaluates a prerequisite flag for the same context; depth is the number of
+// prerequisite flags already being evaluated above this one.
+func PrerequisiteFailure(flagConfig *FlagConfig, flags map[string]*FlagConfig, depth int, evaluate func(*FlagConfig) (*EvaluationResult, error)) string {
+	for _, prereq := range flagConfig.Prerequisites {
+		prereqFlag, exists := flags[prereq.FlagKey]
+		if !exists || prereqFlag == nil {
+			return fmt.Sprintf("prerequisite %s not found", prereq.FlagKey)
+		}
+		if !prereqFlag.IsOn() {
+			return fmt.Sprintf("prerequisite %s is off", prereq.FlagKey)
+		}
+		if prereqFlag.Status != "active" {
+			return fmt.Sprintf("prerequisite %s is not active", prereq.FlagKey)
+		}
+		if depth >= MaxPrerequisiteDepth {
+			return fmt.Sprintf("prerequisite %s exceeds the maximum prerequisite depth", prereq.FlagKey)
+		}
+
+		result, err := evaluate(prereqFlag)
+		if err != nil {
+			return fmt.Sprintf("prerequisite %s failed: %s", prereq.FlagKey, err.Error())
+		}
+		if result.VariationKey != prereq.VariationKey {
+			return fmt.Sprintf("prerequisite %s not met: served %s, requires %s", prereq.FlagKey, result.VariationKey, prereq.VariationKey)
+		}
+	}
+	return ""
+}
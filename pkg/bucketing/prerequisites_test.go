@@ -0,0 +1,156 @@
+package bucketing
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// dependentFlag serves "on" to everyone once its prerequisites are met
+func dependentFlag(key string, prerequisites ...Prerequisite) *FlagConfig {
+	flagConfig := conditionFlag()
+	flagConfig.Key = key
+	flagConfig.Prerequisites = prerequisites
+	return flagConfig
+}
+
+// flagSet keys flags by their key
+func flagSet(flags ...*FlagConfig) map[string]*FlagConfig {
+	set := make(map[string]*FlagConfig, len(flags))
+	for _, flagConfig := range flags {
+		set[flagConfig.Key] = flagConfig
+	}
+	return set
+}
+
+func requires(flagKey string) Prerequisite {
+	return Prerequisite{FlagKey: flagKey, VariationKey: "on"}
+}
+
+func TestFindPrerequisiteCycle(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags map[string]*FlagConfig
+		want  []string
+	}{
+		{"chain", flagSet(dependentFlag("a", requires("b")), dependentFlag("b", requires("c")), dependentFlag("c")), nil},
+		{"diamond", flagSet(dependentFlag("a", requires("b"), requires("c")), dependentFlag("b", requires("d")), dependentFlag("c", requires("d")), dependentFlag("d")), nil},
+		{"unknown prerequisite", flagSet(dependentFlag("a", requires("gone"))), nil},
+		{"self", flagSet(dependentFlag("a", requires("a"))), []string{"a", "a"}},
+		{"three flags", flagSet(dependentFlag("a", requires("b")), dependentFlag("b", requires("c")), dependentFlag("c", requires("a"))), []string{"a", "b", "c", "a"}},
+		{"cycle below an acyclic flag", flagSet(dependentFlag("a", requires("b")), dependentFlag("b", requires("c")), dependentFlag("c", requires("b"))), []string{"b", "c", "b"}},
+	}
+
+	for _, tt := range tests {
+		if got := FindPrerequisiteCycle(tt.flags); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: cycle = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBuildPrerequisiteGraph(t *testing.T) {
+	graph := BuildPrerequisiteGraph(flagSet(
+		dependentFlag("checkout", requires("payments"), requires("cart")),
+		dependentFlag("payments", requires("checkout")),
+		dependentFlag("cart"),
+	))
+
+	if want := []string{"cart", "checkout", "payments"}; !reflect.DeepEqual(graph.Flags, want) {
+		t.Errorf("flags = %v, want %v", graph.Flags, want)
+	}
+	wantEdges := []PrerequisiteEdge{
+		{From: "checkout", To: "payments", VariationKey: "on"},
+		{From: "checkout", To: "cart", VariationKey: "on"},
+		{From: "payments", To: "checkout", VariationKey: "on"},
+	}
+	if !reflect.DeepEqual(graph.Edges, wantEdges) {
+		t.Errorf("edges = %v, want %v", graph.Edges, wantEdges)
+	}
+	if want := []string{"checkout", "payments", "checkout"}; !reflect.DeepEqual(graph.Cycle, want) {
+		t.Errorf("cycle = %v, want %v", graph.Cycle, want)
+	}
+}
+
+func TestEvaluateFlagWithPrerequisites(t *testing.T) {
+	off := false
+	disabled := dependentFlag("disabled")
+	disabled.On = &off
+	archived := dependentFlag("archived")
+	archived.Status = "archived"
+	country := conditionFlag(eq("country", "US"))
+	country.Key = "country"
+
+	flags := flagSet(
+		dependentFlag("base"),
+		dependentFlag("chained", requires("base")),
+		dependentFlag("on-chain", requires("chained")),
+		disabled, archived, country,
+		dependentFlag("needs-disabled", requires("disabled")),
+		dependentFlag("needs-archived", requires("archived")),
+		dependentFlag("needs-missing", requires("missing")),
+		dependentFlag("needs-off", Prerequisite{FlagKey: "base", VariationKey: "off"}),
+		dependentFlag("needs-us", requires("country")),
+		dependentFlag("loop-a", requires("loop-b")),
+		dependentFlag("loop-b", requires("loop-a")),
+	)
+	for _, flagConfig := range flags {
+		if err := flagConfig.Compile(nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		flag    string
+		country string
+		want    string
+		reason  string
+	}{
+		{"chained", "US", "on", ""},
+		{"on-chain", "US", "on", ""},
+		{"needs-us", "US", "on", ""},
+		{"needs-us", "CA", "off", "prerequisite country not met: served off, requires on"},
+		{"needs-off", "US", "off", "prerequisite base not met: served on, requires off"},
+		{"needs-disabled", "US", "off", "prerequisite disabled is off"},
+		{"needs-archived", "US", "off", "prerequisite archived is not active"},
+		{"needs-missing", "US", "off", "prerequisite missing not found"},
+		{"loop-a", "US", "off", "prerequisite loop-b"},
+	}
+
+	bucketer := NewBucketer()
+	for _, tt := range tests {
+		context := &Context{UserKey: "user-1", Attributes: map[string]interface{}{"country": tt.country}}
+		result, err := bucketer.EvaluateFlagWithPrerequisites(flags[tt.flag], flags, context, "salt", nil)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.flag, err)
+		}
+		if result.VariationKey != tt.want || !strings.HasPrefix(result.Reason, tt.reason) {
+			t.Errorf("%s in %s: served %s (%s), want %s (%s)", tt.flag, tt.country, result.VariationKey, result.Reason, tt.want, tt.reason)
+		}
+	}
+
+	// Without the flag set prerequisites are never met
+	result, err := bucketer.EvaluateFlag(flags["chained"], &Context{UserKey: "user-1"}, "salt", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.VariationKey != "off" {
+		t.Errorf("EvaluateFlag served %s (%s), want off", result.VariationKey, result.Reason)
+	}
+}
+
+func TestPrerequisiteDepthLimit(t *testing.T) {
+	calls := 0
+	flags := flagSet(dependentFlag("a"))
+	evaluate := func(*FlagConfig) (*EvaluationResult, error) {
+		calls++
+		return &EvaluationResult{VariationKey: "on"}, nil
+	}
+
+	flagConfig := dependentFlag("b", requires("a"))
+	if reason := PrerequisiteFailure(flagConfig, flags, MaxPrerequisiteDepth-1, evaluate); reason != "" || calls != 1 {
+		t.Errorf("below the limit: reason %q after %d evaluations", reason, calls)
+	}
+	if reason := PrerequisiteFailure(flagConfig, flags, MaxPrerequisiteDepth, evaluate); !strings.Contains(reason, "maximum prerequisite depth") || calls != 1 {
+		t.Errorf("at the limit: reason %q after %d evaluations", reason, calls)
+	}
+}
@@ -0,0 +1,193 @@
+package bucketing
+
+import (
+	"fmt"
+	"time"
+)
+
+// Ramp states
+const (
+	RampRunning = "running"
+	RampPaused  = "paused"
+	RampAborted = "aborted"
+)
+
+// Ramp is a rollout schedule that moves users from a control variation to a
+// treatment variation over time, either in steps or linearly. The treatment
+// percentage never decreases while the ramp runs, and users are bucketed so
+// that a growing percentage only ever moves them from control into treatment.
+type Ramp struct {
+	ControlVariation   string      `json:"control_variation"`
+	TreatmentVariation string      `json:"treatment_variation"`
+	Steps              []RampStep  `json:"steps,omitempty"`
+	Linear             *LinearRamp `json:"linear,omitempty"`
+	Status             string      `json:"status,omitempty"`    // running (default), paused or aborted
+	PausedAt           *time.Time  `json:"paused_at,omitempty"` // the schedule is frozen at this time while paused
+}
+
+// RampStep sets the treatment percentage from a point in time
+type RampStep struct {
+	At         time.Time `json:"at"`
+	Percentage float64   `json:"percentage"` // 0 to 100
+}
+
+// LinearRamp raises the treatment percentage linearly between two points in
+// time. Before Start no one is in treatment; after End EndPercentage is.
+type LinearRamp struct {
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	StartPercentage float64   `json:"start_percentage"`
+	EndPercentage   float64   `json:"end_percentage"`
+}
+
+// Validate checks that the ramp has exactly one well-formed schedule whose
+// percentage never decreases
+func (r *Ramp) Validate() error {
+	if r.ControlVariation == "" || r.TreatmentVariation == "" {
+		return fmt.Errorf("ramp must have control and treatment variations")
+	}
+	if r.ControlVariation == r.TreatmentVariation {
+		return fmt.Errorf("ramp control and treatment variations must differ")
+	}
+
+	switch r.Status {
+	case "", RampRunning, RampAborted:
+	case RampPaused:
+		if r.PausedAt == nil {
+			return fmt.Errorf("paused ramp must have paused_at")
+		}
+	default:
+		return fmt.Errorf("unknown ramp status %q", r.Status)
+	}
+
+	if (len(r.Steps) > 0) == (r.Linear != nil) {
+		return fmt.Errorf("ramp must have either steps or a linear schedule")
+	}
+
+	if r.Linear != nil {
+		l := r.Linear
+		if !l.End.After(l.Start) {
+			return fmt.Errorf("linear ramp must end after it starts")
+		}
+		if l.StartPercentage < 0 || l.EndPercentage > 100 || l.StartPercentage > l.EndPercentage {
+			return fmt.Errorf("linear ramp percentages must increase within 0 to 100")
+		}
+		return nil
+	}
+
+	for i, step := range r.Steps {
+		if step.Percentage < 0 || step.Percentage > 100 {
+			return fmt.Errorf("ramp step %d: percentage must be between 0 and 100", i)
+		}
+		if i > 0 {
+			prev := r.Steps[i-1]
+			if !step.At.After(prev.At) {
+				return fmt.Errorf("ramp step %d: steps must be in chronological order", i)
+			}
+			if step.Percentage < prev.Percentage {
+				return fmt.Errorf("ramp step %d: percentage must not decrease", i)
+			}
+		}
+	}
+	return nil
+}
+
+// Percentage returns the treatment percentage at the given time. An aborted
+// ramp serves no treatment; a paused one stays where it was paused.
+func (r *Ramp) Percentage(now time.Time) float64 {
+	switch r.Status {
+	case RampAborted:
+		return 0
+	case RampPaused:
+		if r.PausedAt != nil {
+			now = *r.PausedAt
+		}
+	}
+
+	if r.Linear != nil {
+		l := r.Linear
+		switch {
+		case now.Before(l.Start):
+			return 0
+		case !now.Before(l.End):
+			return l.EndPercentage
+		}
+		progress := float64(now.Sub(l.Start)) / float64(l.End.Sub(l.Start))
+		return l.StartPercentage + progress*(l.EndPercentage-l.StartPercentage)
+	}
+
+	percentage := 0.0
+	for _, step := range r.Steps {
+		if now.Before(step.At) {
+			break
+		}
+		percentage = step.Percentage
+	}
+	return percentage
+}
+
+// Variations returns the rollout weights of the ramp at the given time. The
+// treatment comes first so its bucket range only grows from the bottom of the
+// bucket space as the percentage rises.
+func (r *Ramp) Variations(now time.Time) []RolloutVariation {
+	percentage := r.Percentage(now)
+	return []RolloutVariation{
+		{VariationKey: r.TreatmentVariation, Weight: percentage},
+		{VariationKey: r.ControlVariation, Weight: 100 - percentage},
+	}
+}
+
+// Pause freezes the ramp at its current percentage
+func (r *Ramp) Pause(now time.Time) error {
+	if r.Status == RampAborted {
+		return fmt.Errorf("ramp is aborted")
+	}
+	if r.Status == RampPaused {
+		return fmt.Errorf("ramp is already paused")
+	}
+	r.Status = RampPaused
+	r.PausedAt = &now
+	return nil
+}
+
+// Resume continues a paused ramp from the percentage it was paused at,
+// shifting the rest of the schedule by the time spent paused
+func (r *Ramp) Resume(now time.Time) error {
+	if r.Status != RampPaused || r.PausedAt == nil {
+		return fmt.Errorf("ramp is not paused")
+	}
+
+	shift := now.Sub(*r.PausedAt)
+	for i := range r.Steps {
+		if r.Steps[i].At.After(*r.PausedAt) {
+			r.Steps[i].At = r.Steps[i].At.Add(shift)
+		}
+	}
+	if r.Linear != nil {
+		r.Linear.Start = r.Linear.Start.Add(shift)
+		r.Linear.End = r.Linear.End.Add(shift)
+	}
+
+	r.Status = RampRunning
+	r.PausedAt = nil
+	return nil
+}
+
+// Abort ends the ramp, serving the control variation to everyone
+func (r *Ramp) Abort() error {
+	if r.Status == RampAborted {
+		return fmt.Errorf("ramp is already aborted")
+	}
+	r.Status = RampAborted
+	r.PausedAt = nil
+	return nil
+}
+
+// RolloutVariations returns the rollout's variation weights at the given
+// time, as given by its ramp when it has one
+func RolloutVariations(rollout *Rollout, now time.Time) []RolloutVariation {
+	if rollout.Ramp != nil {
+		return rollout.Ramp.Variations(now)
+	}
+	return rollout.Variations
+}
@@ -0,0 +1,219 @@
+package bucketing
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Sidd-007/feature-flag-platform/pkg/hashing"
+	"github.com/Sidd-007/feature-flag-platform/pkg/operators"
+)
+
+var rampStart = time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+
+func hours(h float64) time.Time {
+	return rampStart.Add(time.Duration(h * float64(time.Hour)))
+}
+
+func stepRamp() *Ramp {
+	return &Ramp{
+		ControlVariation:   "off",
+		TreatmentVariation: "on",
+		Steps: []RampStep{
+			{At: hours(0), Percentage: 5},
+			{At: hours(24), Percentage: 25},
+			{At: hours(48), Percentage: 100},
+		},
+	}
+}
+
+func linearRamp() *Ramp {
+	return &Ramp{
+		ControlVariation:   "off",
+		TreatmentVariation: "on",
+		Linear:             &LinearRamp{Start: hours(0), End: hours(10), StartPercentage: 10, EndPercentage: 60},
+	}
+}
+
+func TestRampPercentage(t *testing.T) {
+	tests := []struct {
+		name string
+		ramp *Ramp
+		at   time.Time
+		want float64
+	}{
+		{"before the first step", stepRamp(), hours(-1), 0},
+		{"at the first step", stepRamp(), hours(0), 5},
+		{"between steps", stepRamp(), hours(30), 25},
+		{"after the last step", stepRamp(), hours(100), 100},
+		{"before a linear ramp", linearRamp(), hours(-1), 0},
+		{"at the start of a linear ramp", linearRamp(), hours(0), 10},
+		{"halfway through a linear ramp", linearRamp(), hours(5), 35},
+		{"at the end of a linear ramp", linearRamp(), hours(10), 60},
+		{"after a linear ramp", linearRamp(), hours(20), 60},
+	}
+
+	for _, tt := range tests {
+		if got := tt.ramp.Percentage(tt.at); got != tt.want {
+			t.Errorf("%s: percentage = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRampPauseAndResume(t *testing.T) {
+	linear := linearRamp()
+	if err := linear.Pause(hours(2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := linear.Pause(hours(3)); err == nil {
+		t.Error("paused a paused ramp")
+	}
+	if got := linear.Percentage(hours(8)); got != 20 {
+		t.Errorf("paused linear ramp: percentage = %v, want 20", got)
+	}
+	if err := linear.Resume(hours(5)); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		at   time.Time
+		want float64
+	}{{hours(5), 20}, {hours(10), 45}, {hours(13), 60}} {
+		if got := linear.Percentage(tt.at); got != tt.want {
+			t.Errorf("resumed linear ramp at %v: percentage = %v, want %v", tt.at, got, tt.want)
+		}
+	}
+
+	steps := stepRamp()
+	if err := steps.Pause(hours(12)); err != nil {
+		t.Fatal(err)
+	}
+	if got := steps.Percentage(hours(30)); got != 5 {
+		t.Errorf("paused step ramp: percentage = %v, want 5", got)
+	}
+	if err := steps.Resume(hours(18)); err != nil {
+		t.Fatal(err)
+	}
+	if err := steps.Resume(hours(19)); err == nil {
+		t.Error("resumed a running ramp")
+	}
+	for _, tt := range []struct {
+		at   time.Time
+		want float64
+	}{{hours(18), 5}, {hours(29), 5}, {hours(30), 25}, {hours(54), 100}} {
+		if got := steps.Percentage(tt.at); got != tt.want {
+			t.Errorf("resumed step ramp at %v: percentage = %v, want %v", tt.at, got, tt.want)
+		}
+	}
+	if !steps.Steps[0].At.Equal(hours(0)) {
+		t.Errorf("step before the pause moved to %v", steps.Steps[0].At)
+	}
+}
+
+func TestRampAbort(t *testing.T) {
+	ramp := stepRamp()
+	if err := ramp.Pause(hours(30)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ramp.Abort(); err != nil {
+		t.Fatal(err)
+	}
+	if ramp.PausedAt != nil {
+		t.Error("aborted ramp kept paused_at")
+	}
+	if err := ramp.Abort(); err == nil {
+		t.Error("aborted a ramp twice")
+	}
+	if err := ramp.Pause(hours(31)); err == nil {
+		t.Error("paused an aborted ramp")
+	}
+	if err := ramp.Resume(hours(31)); err == nil {
+		t.Error("resumed an aborted ramp")
+	}
+
+	want := []RolloutVariation{{VariationKey: "on", Weight: 0}, {VariationKey: "off", Weight: 100}}
+	got := ramp.Variations(hours(100))
+	if len(got) != 2 || got[0].VariationKey != want[0].VariationKey || got[0].Weight != 0 || got[1].Weight != 100 {
+		t.Errorf("aborted ramp variations = %+v, want %+v", got, want)
+	}
+}
+
+func TestRampValidate(t *testing.T) {
+	pausedAt := hours(1)
+	tests := []struct {
+		name  string
+		edit  func(r *Ramp)
+		valid bool
+	}{
+		{"valid", func(r *Ramp) {}, true},
+		{"same variations", func(r *Ramp) { r.TreatmentVariation = "off" }, false},
+		{"missing control", func(r *Ramp) { r.ControlVariation = "" }, false},
+		{"both schedules", func(r *Ramp) { r.Linear = linearRamp().Linear }, false},
+		{"no schedule", func(r *Ramp) { r.Steps = nil }, false},
+		{"decreasing steps", func(r *Ramp) { r.Steps[2].Percentage = 20 }, false},
+		{"unordered steps", func(r *Ramp) { r.Steps[1].At = hours(72) }, false},
+		{"percentage over 100", func(r *Ramp) { r.Steps[2].Percentage = 101 }, false},
+		{"paused without paused_at", func(r *Ramp) { r.Status = RampPaused }, false},
+		{"paused", func(r *Ramp) { r.Status, r.PausedAt = RampPaused, &pausedAt }, true},
+		{"unknown status", func(r *Ramp) { r.Status = "stalled" }, false},
+	}
+
+	for _, tt := range tests {
+		ramp := stepRamp()
+		tt.edit(ramp)
+		if err := ramp.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%s: Validate() = %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+
+	linear := linearRamp()
+	linear.Linear.End = linear.Linear.Start
+	if err := linear.Validate(); err == nil {
+		t.Error("accepted a linear ramp that ends when it starts")
+	}
+}
+
+// TestRampOnlyMovesUsersIntoTreatment checks the property ramps exist for: as
+// the percentage rises, users only ever move from control into treatment
+func TestRampOnlyMovesUsersIntoTreatment(t *testing.T) {
+	for _, version := range []hashing.Version{hashing.HashV1, hashing.HashV3} {
+		ramp := &Ramp{
+			ControlVariation:   "off",
+			TreatmentVariation: "on",
+			Linear:             &LinearRamp{Start: hours(0), End: hours(10), StartPercentage: 0, EndPercentage: 100},
+		}
+		flagConfig := conditionFlag()
+		flagConfig.HashVersion = version
+		flagConfig.Rules[0].VariationKey = ""
+		flagConfig.Rules[0].Rollout = &Rollout{Ramp: ramp}
+
+		bucketer := NewBucketer()
+		treated := make(map[string]bool)
+		previous := 0
+		for h := 0.0; h <= 10; h += 0.5 {
+			bucketer.SetClock(operators.FixedClock(hours(h)))
+			count := 0
+			for i := 0; i < 2000; i++ {
+				userKey := fmt.Sprintf("user-%d", i)
+				result, err := bucketer.EvaluateFlag(flagConfig, &Context{UserKey: userKey}, "salt", nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				inTreatment := result.VariationKey == "on"
+				if treated[userKey] && !inTreatment {
+					t.Fatalf("version %d: %s moved back to control at %v%%", version, userKey, ramp.Percentage(hours(h)))
+				}
+				treated[userKey] = inTreatment
+				if inTreatment {
+					count++
+				}
+			}
+			if count < previous {
+				t.Errorf("version %d: treatment shrank from %d to %d users", version, previous, count)
+			}
+			previous = count
+		}
+		if previous != 2000 {
+			t.Errorf("version %d: %d of 2000 users in treatment at 100%%", version, previous)
+		}
+	}
+}
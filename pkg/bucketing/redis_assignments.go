@@ -0,0 +1,67 @@
+package bucketing
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisAssignmentPrefix prefixes the Redis hashes holding the assignments of
+// each experiment
+const redisAssignmentPrefix = "assignments:"
+
+// RedisAssignmentStore is an AssignmentStore shared through Redis. Each
+// experiment is a hash from bucketing ID to variation key, so resetting an
+// experiment deletes a single key.
+type RedisAssignmentStore struct {
+	client  *redis.Client
+	timeout time.Duration
+	ttl     time.Duration
+}
+
+// NewRedisAssignmentStore creates an assignment store on the given client.
+// Operations time out after timeout; a positive ttl expires the assignments
+// of an experiment that has not seen a new assignment for that long.
+func NewRedisAssignmentStore(client *redis.Client, timeout, ttl time.Duration) *RedisAssignmentStore {
+	return &RedisAssignmentStore{client: client, timeout: timeout, ttl: ttl}
+}
+
+// Get implements AssignmentStore
+func (s *RedisAssignmentStore) Get(bucketingID, experiment string) (string, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	variationKey, err := s.client.HGet(ctx, redisAssignmentPrefix+experiment, bucketingID).Result()
+	if err == redis.Nil {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return variationKey, true, nil
+}
+
+// Save implements AssignmentStore. An assignment already recorded by another
+// evaluator is kept.
+func (s *RedisAssignmentStore) Save(bucketingID, experiment, variationKey string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	key := redisAssignmentPrefix + experiment
+	pipe := s.client.TxPipeline()
+	pipe.HSetNX(ctx, key, bucketingID, variationKey)
+	if s.ttl > 0 {
+		pipe.Expire(ctx, key, s.ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Reset implements AssignmentStore
+func (s *RedisAssignmentStore) Reset(experiment string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	return s.client.Del(ctx, redisAssignmentPrefix+experiment).Err()
+}
@@ -0,0 +1,413 @@
+package bucketing
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/Sidd-007/feature-flag-platform/pkg/operators"
+	"github.com/Sidd-007/feature-flag-platform/pkg/semver"
+)
+
+// AttributeType is the declared type of a context attribute
+type AttributeType string
+
+const (
+	AttributeString   AttributeType = "string"
+	AttributeNumber   AttributeType = "number"
+	AttributeBoolean  AttributeType = "boolean"
+	AttributeVersion  AttributeType = "version"  // semantic version string
+	AttributeDateTime AttributeType = "datetime" // RFC3339 timestamp, date or Unix seconds
+	AttributeList     AttributeType = "list"
+)
+
+// Schema violation codes
+const (
+	SchemaMissingAttribute    = "missing_attribute"
+	SchemaTypeMismatch        = "type_mismatch"
+	SchemaValueNotAllowed     = "value_not_allowed"
+	SchemaUndeclaredAttribute = "undeclared_attribute"
+)
+
+// ContextSchema declares the attributes evaluation contexts are expected to
+// carry. It is configured per environment and shipped with its configuration.
+type ContextSchema struct {
+	Attributes map[string]*AttributeSchema `json:"attributes"`
+
+	// Strict reports attributes that are not declared as violations
+	Strict bool `json:"strict,omitempty"`
+}
+
+// AttributeSchema declares a single context attribute
+type AttributeSchema struct {
+	Type          AttributeType `json:"type"`
+	Description   string        `json:"description,omitempty"`
+	Required      bool          `json:"required,omitempty"`
+	AllowedValues []interface{} `json:"allowed_values,omitempty"` // for lists, applies to each element
+	PII           bool          `json:"pii,omitempty"`            // never forwarded to analytics
+}
+
+// SchemaViolation describes an attribute or condition that does not match the
+// schema
+type SchemaViolation struct {
+	Attribute string `json:"attribute"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+}
+
+// operatorTypes lists the attribute types each built-in operator is meaningful
+// for. Operators not listed here, such as custom registered ones, are not
+// checked.
+var operatorTypes = map[string][]AttributeType{
+	"eq":           {AttributeString, AttributeNumber, AttributeBoolean, AttributeVersion, AttributeDateTime},
+	"neq":          {AttributeString, AttributeNumber, AttributeBoolean, AttributeVersion, AttributeDateTime},
+	"in":           {AttributeString, AttributeNumber, AttributeBoolean, AttributeVersion},
+	"nin":          {AttributeString, AttributeNumber, AttributeBoolean, AttributeVersion},
+	"lt":           {AttributeNumber},
+	"gt":           {AttributeNumber},
+	"lte":          {AttributeNumber},
+	"gte":          {AttributeNumber},
+	"contains":     {AttributeString, AttributeList},
+	"contains_any": {AttributeList},
+	"contains_all": {AttributeList},
+	"regex":        {AttributeString, AttributeVersion},
+	"semver":       {AttributeVersion, AttributeString},
+	"semver_eq":    {AttributeVersion, AttributeString},
+	"semver_neq":   {AttributeVersion, AttributeString},
+	"semver_lt":    {AttributeVersion, AttributeString},
+	"semver_gt":    {AttributeVersion, AttributeString},
+	"semver_lte":   {AttributeVersion, AttributeString},
+	"semver_gte":   {AttributeVersion, AttributeString},
+	"before":       {AttributeDateTime},
+	"after":        {AttributeDateTime},
+	"between":      {AttributeDateTime},
+	"older_than":   {AttributeDateTime},
+	"newer_than":   {AttributeDateTime},
+}
+
+// valueOperators compare the attribute against operand values of the same
+// type, so their operands can be checked against the schema
+var valueOperators = map[string]bool{"eq": true, "neq": true, "in": true, "nin": true}
+
+// Validate checks that the schema itself is well formed
+func (s *ContextSchema) Validate() error {
+	if s == nil {
+		return nil
+	}
+	for name, attr := range s.Attributes {
+		if name == "" {
+			return fmt.Errorf("attribute name is required")
+		}
+		if attr == nil {
+			return fmt.Errorf("attribute %s: definition is required", name)
+		}
+		switch attr.Type {
+		case AttributeString, AttributeNumber, AttributeBoolean, AttributeVersion, AttributeDateTime, AttributeList:
+		default:
+			return fmt.Errorf("attribute %s: unknown type %q", name, attr.Type)
+		}
+		if attr.Type == AttributeList {
+			continue
+		}
+		for _, allowed := range attr.AllowedValues {
+			if !attr.conforms(allowed) {
+				return fmt.Errorf("attribute %s: allowed value %s is not a %s", name, describe(allowed), attr.Type)
+			}
+		}
+	}
+	return nil
+}
+
+// Attribute returns the declaration of the named attribute
+func (s *ContextSchema) Attribute(name string) (*AttributeSchema, bool) {
+	if s == nil {
+		return nil, false
+	}
+	attr, ok := s.Attributes[name]
+	return attr, ok && attr != nil
+}
+
+// ValidateContext reports every way the context departs from the schema.
+// A nil schema accepts any context.
+func (s *ContextSchema) ValidateContext(context *Context) []SchemaViolation {
+	if s == nil || context == nil {
+		return nil
+	}
+
+	var violations []SchemaViolation
+	for _, name := range s.names() {
+		attr := s.Attributes[name]
+
+		var value interface{}
+		if name == "user_key" {
+			if context.UserKey != "" {
+				value = context.UserKey
+			}
+		} else {
+			value, _ = lookupContextAttribute(context, name)
+		}
+
+		if value == nil {
+			if attr.Required {
+				violations = append(violations, SchemaViolation{name, SchemaMissingAttribute, "required attribute is missing"})
+			}
+			continue
+		}
+		if v := attr.check(name, value); v != nil {
+			violations = append(violations, *v)
+		}
+	}
+
+	if s.Strict {
+		// A top-level attribute is declared when any declared path starts at
+		// it; an attribute of a context kind when a path starts at
+		// "<kind>.<attribute>", or when the kind itself is declared
+		roots := make(map[string]bool, len(s.Attributes))
+		for name := range s.Attributes {
+			roots[name] = true
+			roots[attributeRoot(name)] = true
+			if segments := splitAttributePath(name); len(segments) > 1 {
+				roots[segments[0]+"."+segments[1]] = true
+			}
+		}
+		undeclared := make([]string, 0)
+		for name := range context.Attributes {
+			if !roots[name] {
+				undeclared = append(undeclared, name)
+			}
+		}
+		for kind, entry := range context.Contexts {
+			if _, declared := s.Attributes[kind]; entry == nil || declared {
+				continue
+			}
+			for name := range entry.Attributes {
+				if path := kind + "." + name; !roots[path] {
+					undeclared = append(undeclared, path)
+				}
+			}
+		}
+		sort.Strings(undeclared)
+		for _, name := range undeclared {
+			violations = append(violations, SchemaViolation{name, SchemaUndeclaredAttribute, "attribute is not declared in the context schema"})
+		}
+	}
+
+	return violations
+}
+
+// CheckCondition checks a leaf condition against the schema: the attribute
+// must be declared, the operator must suit its type, and equality and
+// membership operands must be values the attribute can take. Segment
+// references and the implicit "now" and "user_key" attributes are only
+// checked when declared.
+func (s *ContextSchema) CheckCondition(attribute, operator string, operand interface{}) *SchemaViolation {
+	if s == nil || attribute == "segment" {
+		return nil
+	}
+
+	attr, ok := s.Attribute(attribute)
+	if !ok {
+		if attribute == operators.NowAttribute || attribute == "user_key" {
+			return nil
+		}
+		return &SchemaViolation{attribute, SchemaUndeclaredAttribute, "attribute is not declared in the context schema"}
+	}
+
+	if types, known := operatorTypes[operator]; known && !containsType(types, attr.Type) {
+		return &SchemaViolation{attribute, SchemaTypeMismatch, fmt.Sprintf("operator %s cannot be applied to a %s attribute", operator, attr.Type)}
+	}
+
+	if !valueOperators[operator] {
+		return nil
+	}
+	values := []interface{}{operand}
+	if operator == "in" || operator == "nin" {
+		values = listItems(operand)
+	}
+	for _, value := range values {
+		if !attr.conforms(value) {
+			return &SchemaViolation{attribute, SchemaTypeMismatch, fmt.Sprintf("operand %s is not a %s", describe(value), attr.Type)}
+		}
+		if !attr.allows(value) {
+			return &SchemaViolation{attribute, SchemaValueNotAllowed, fmt.Sprintf("operand %s is not an allowed value", describe(value))}
+		}
+	}
+	return nil
+}
+
+// RedactPII returns a copy of the context without the attributes the schema
+// marks as PII, including those of other context kinds, declared as
+// "<kind>.<attribute>". The context itself is returned when nothing needs
+// removing.
+func (s *ContextSchema) RedactPII(context *Context) *Context {
+	if s == nil || context == nil {
+		return context
+	}
+
+	redacted := *context
+	changed := false
+	if attributes := s.redactAttributes("", context.Attributes); attributes != nil {
+		redacted.Attributes = attributes
+		changed = true
+	}
+
+	contextsCopied := false
+	for kind, entry := range context.Contexts {
+		if entry == nil {
+			continue
+		}
+		attributes := s.redactAttributes(kind+".", entry.Attributes)
+		if attributes == nil {
+			continue
+		}
+		if !contextsCopied {
+			redacted.Contexts = make(map[string]*KindContext, len(context.Contexts))
+			for k, v := range context.Contexts {
+				redacted.Contexts[k] = v
+			}
+			contextsCopied = true
+		}
+		redacted.Contexts[kind] = &KindContext{Key: entry.Key, Attributes: attributes}
+		changed = true
+	}
+
+	if !changed {
+		return context
+	}
+	return &redacted
+}
+
+// redactAttributes returns a copy of attributes without those the schema
+// declares, under the given prefix, as PII, or nil when none are present
+func (s *ContextSchema) redactAttributes(prefix string, attributes map[string]interface{}) map[string]interface{} {
+	var redacted map[string]interface{}
+	for name, value := range attributes {
+		if attr, ok := s.Attribute(prefix + name); !ok || !attr.PII || value == nil {
+			continue
+		}
+		if redacted == nil {
+			redacted = make(map[string]interface{}, len(attributes))
+			for k, v := range attributes {
+				redacted[k] = v
+			}
+		}
+		delete(redacted, name)
+	}
+	return redacted
+}
+
+// names returns the declared attribute names in a stable order
+func (s *ContextSchema) names() []string {
+	names := make([]string, 0, len(s.Attributes))
+	for name, attr := range s.Attributes {
+		if attr != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// check validates a present attribute value
+func (a *AttributeSchema) check(name string, value interface{}) *SchemaViolation {
+	if !a.conforms(value) {
+		return &SchemaViolation{name, SchemaTypeMismatch, fmt.Sprintf("value %s is not a %s", describe(value), a.Type)}
+	}
+
+	items := []interface{}{value}
+	if a.Type == AttributeList {
+		items = listItems(value)
+	}
+	for _, item := range items {
+		if !a.allows(item) {
+			return &SchemaViolation{name, SchemaValueNotAllowed, fmt.Sprintf("value %s is not an allowed value", describe(item))}
+		}
+	}
+	return nil
+}
+
+// conforms reports whether a value has the declared type. Values are not
+// coerced: the string "30" is not a number.
+func (a *AttributeSchema) conforms(value interface{}) bool {
+	switch a.Type {
+	case AttributeString:
+		_, ok := value.(string)
+		return ok
+	case AttributeNumber:
+		if _, ok := value.(string); ok {
+			return false
+		}
+		_, ok := operators.ToFloat64(value)
+		return ok
+	case AttributeBoolean:
+		_, ok := value.(bool)
+		return ok
+	case AttributeVersion:
+		s, ok := value.(string)
+		if !ok {
+			return false
+		}
+		_, err := semver.Parse(s)
+		return err == nil
+	case AttributeDateTime:
+		_, ok := operators.ParseTime(value)
+		return ok
+	case AttributeList:
+		if value == nil {
+			return false
+		}
+		kind := reflect.TypeOf(value).Kind()
+		return kind == reflect.Slice || kind == reflect.Array
+	}
+	return false
+}
+
+// allows reports whether a value is one of the allowed values, if any are
+// declared
+func (a *AttributeSchema) allows(value interface{}) bool {
+	if len(a.AllowedValues) == 0 {
+		return true
+	}
+	key := operators.Key(value)
+	for _, allowed := range a.AllowedValues {
+		if operators.Key(allowed) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// listItems returns the elements of a slice value, or the value itself when
+// it is not a slice
+func listItems(value interface{}) []interface{} {
+	if value == nil {
+		return []interface{}{nil}
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return []interface{}{value}
+	}
+	items := make([]interface{}, rv.Len())
+	for i := range items {
+		items[i] = rv.Index(i).Interface()
+	}
+	return items
+}
+
+func containsType(types []AttributeType, t AttributeType) bool {
+	for _, candidate := range types {
+		if candidate == t {
+			return true
+		}
+	}
+	return false
+}
+
+// describe formats a value for violation messages, quoting strings so that
+// "30" and 30 can be told apart
+func describe(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprintf("%v", value)
+}
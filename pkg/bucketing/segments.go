@@ -0,0 +1,190 @@
+package bucketing
+
+import (
+	"sort"
+)
+
+// SegmentRule is a group of conditions that must all match. A segment with
+// several rules matches when any of them does.
+type SegmentRule struct {
+	ID         string      `json:"id,omitempty"`
+	Conditions []Condition `json:"conditions"`
+}
+
+// MatchSegment reports whether the context is in the segment with the given
+// key. Unknown segments match no one. Membership is decided, in order, by the
+// segment's excluded and included user keys, its excluded and included
+// segments, then its conditions and rules. Results are cached on the context,
+// so each segment is evaluated at most once per context; a segment that
+// refers back to itself is not a member of itself.
+func (b *Bucketer) MatchSegment(segmentKey string, context *Context, segments map[string]*SegmentConfig) bool {
+	segment, exists := segments[segmentKey]
+	if !exists || segment == nil {
+		return false
+	}
+
+	if matched, ok := context.segmentMemo[segment]; ok {
+		return matched
+	}
+	if context.segmentMemo == nil {
+		context.segmentMemo = make(map[*SegmentConfig]bool)
+	}
+
+	// Mark the segment as non-member while it is evaluated, so reference
+	// cycles terminate
+	context.segmentMemo[segment] = false
+	matched := b.matchSegment(segment, context, segments)
+	context.segmentMemo[segment] = matched
+	return matched
+}
+
+// matchSegment evaluates segment membership without the memo
+func (b *Bucketer) matchSegment(segment *SegmentConfig, context *Context, segments map[string]*SegmentConfig) bool {
+	// Listed keys take precedence over everything else, exclusions first
+	if segment.Excluded.Contains(context.UserKey) {
+		return false
+	}
+	if segment.Included.Contains(context.UserKey) {
+		return true
+	}
+
+	for _, key := range segment.ExcludedSegments {
+		if b.MatchSegment(key, context, segments) {
+			return false
+		}
+	}
+	for _, key := range segment.IncludedSegments {
+		if b.MatchSegment(key, context, segments) {
+			return true
+		}
+	}
+
+	if len(segment.Conditions) == 0 && len(segment.Rules) == 0 {
+		// A segment without conditions matches everyone, unless it is defined
+		// by its included keys or segments
+		return len(segment.Included) == 0 && len(segment.IncludedSegments) == 0
+	}
+
+	if len(segment.Conditions) > 0 && b.evaluateAll(segment.Conditions, context, segments) {
+		return true
+	}
+	for i := range segment.Rules {
+		if b.evaluateAll(segment.Rules[i].Conditions, context, segments) {
+			return true
+		}
+	}
+	return false
+}
+
+// SegmentReferences returns the keys of the segments a segment refers to,
+// through its included and excluded segments or segment conditions, sorted
+// and without duplicates
+func SegmentReferences(segment *SegmentConfig) []string {
+	seen := make(map[string]bool)
+	for _, key := range segment.IncludedSegments {
+		seen[key] = true
+	}
+	for _, key := range segment.ExcludedSegments {
+		seen[key] = true
+	}
+	collectSegmentReferences(segment.Conditions, seen)
+	for i := range segment.Rules {
+		collectSegmentReferences(segment.Rules[i].Conditions, seen)
+	}
+	return sortedKeys(seen)
+}
+
+// FlagSegmentReferences returns the keys of the segments a flag's rules refer
+// to, sorted and without duplicates
+func FlagSegmentReferences(flagConfig *FlagConfig) []string {
+	seen := make(map[string]bool)
+	for i := range flagConfig.Rules {
+		collectSegmentReferences(flagConfig.Rules[i].Conditions, seen)
+	}
+	return sortedKeys(seen)
+}
+
+// FindSegmentCycle returns a segment reference cycle as a path of segment
+// keys that starts and ends with the same segment, or nil when there is none
+func FindSegmentCycle(segments map[string]*SegmentConfig) []string {
+	const (
+		unvisited = iota
+		inProgress
+		done
+	)
+
+	keys := make([]string, 0, len(segments))
+	for key := range segments {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	state := make(map[string]int, len(segments))
+	var path []string
+
+	var visit func(key string) []string
+	visit = func(key string) []string {
+		state[key] = inProgress
+		path = append(path, key)
+
+		for _, ref := range SegmentReferences(segments[key]) {
+			if _, exists := segments[ref]; !exists {
+				continue
+			}
+			switch state[ref] {
+			case inProgress:
+				for i, k := range path {
+					if k == ref {
+						cycle := append([]string{}, path[i:]...)
+						return append(cycle, ref)
+					}
+				}
+			case unvisited:
+				if cycle := visit(ref); cycle != nil {
+					return cycle
+				}
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[key] = done
+		return nil
+	}
+
+	for _, key := range keys {
+		if state[key] == unvisited {
+			if cycle := visit(key); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// collectSegmentReferences adds the segments referenced by a condition tree
+func collectSegmentReferences(conditions []Condition, seen map[string]bool) {
+	for i := range conditions {
+		condition := &conditions[i]
+		if condition.Not != nil {
+			collectSegmentReferences([]Condition{*condition.Not}, seen)
+		}
+		collectSegmentReferences(condition.And, seen)
+		collectSegmentReferences(condition.Or, seen)
+
+		if !condition.IsLogical() && condition.Attribute == "segment" {
+			if key, ok := condition.Value.(string); ok {
+				seen[key] = true
+			}
+		}
+	}
+}
+
+// sortedKeys returns the keys of a set in sorted order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,156 @@
+package bucketing
+
+import (
+	"reflect"
+	"testing"
+)
+
+func inSegment(key string) Condition {
+	return Condition{Attribute: "segment", Operator: "eq", Value: key}
+}
+
+func TestFindSegmentCycle(t *testing.T) {
+	tests := []struct {
+		name     string
+		segments map[string]*SegmentConfig
+		want     []string
+	}{
+		{"no references", map[string]*SegmentConfig{"a": {}, "b": {}}, nil},
+		{"chain", map[string]*SegmentConfig{
+			"a": {IncludedSegments: []string{"b"}},
+			"b": {Conditions: []Condition{inSegment("c")}},
+			"c": {},
+		}, nil},
+		{"unknown reference", map[string]*SegmentConfig{"a": {IncludedSegments: []string{"gone"}}}, nil},
+		{"self through a condition", map[string]*SegmentConfig{
+			"a": {Conditions: []Condition{{Not: &Condition{Attribute: "segment", Operator: "eq", Value: "a"}}}},
+		}, []string{"a", "a"}},
+		{"included and excluded segments", map[string]*SegmentConfig{
+			"a": {IncludedSegments: []string{"b"}},
+			"b": {ExcludedSegments: []string{"a"}},
+		}, []string{"a", "b", "a"}},
+		{"through a segment rule", map[string]*SegmentConfig{
+			"a": {Rules: []SegmentRule{{Conditions: []Condition{{Or: []Condition{eq("plan", "pro"), inSegment("b")}}}}}},
+			"b": {IncludedSegments: []string{"c"}},
+			"c": {Conditions: []Condition{inSegment("b")}},
+		}, []string{"b", "c", "b"}},
+	}
+
+	for _, tt := range tests {
+		if got := FindSegmentCycle(tt.segments); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: cycle = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSegmentReferences(t *testing.T) {
+	segment := &SegmentConfig{
+		IncludedSegments: []string{"c", "a"},
+		ExcludedSegments: []string{"b"},
+		Conditions:       []Condition{{Not: &Condition{Attribute: "segment", Operator: "eq", Value: "d"}}},
+		Rules:            []SegmentRule{{Conditions: []Condition{{And: []Condition{inSegment("a"), inSegment("e")}}}}},
+	}
+	if got, want := SegmentReferences(segment), []string{"a", "b", "c", "d", "e"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("references = %v, want %v", got, want)
+	}
+}
+
+func TestMatchSegmentPrecedence(t *testing.T) {
+	segments := map[string]*SegmentConfig{
+		"us":       {Conditions: []Condition{eq("country", "US")}},
+		"staff":    {Included: NewKeySet("staff-1", "staff-2")},
+		"blocked":  {Included: NewKeySet("blocked-1", "staff-2")},
+		"everyone": {},
+		"customers": {
+			Conditions:       []Condition{eq("plan", "pro")},
+			Rules:            []SegmentRule{{Conditions: []Condition{eq("plan", "team")}}},
+			Included:         NewKeySet("vip", "banned"),
+			Excluded:         NewKeySet("banned", "pro-but-excluded"),
+			IncludedSegments: []string{"staff"},
+			ExcludedSegments: []string{"blocked"},
+		},
+	}
+
+	tests := []struct {
+		segment string
+		user    string
+		plan    string
+		want    bool
+	}{
+		{"customers", "user-1", "pro", true},
+		{"customers", "user-1", "team", true},
+		{"customers", "user-1", "free", false},
+		{"customers", "vip", "free", true},
+		{"customers", "banned", "pro", false},
+		{"customers", "pro-but-excluded", "pro", false},
+		{"customers", "staff-1", "free", true},
+		{"customers", "blocked-1", "pro", false},
+		{"customers", "staff-2", "pro", false},
+		{"staff", "user-1", "pro", false},
+		{"everyone", "user-1", "free", true},
+		{"unknown", "user-1", "pro", false},
+	}
+
+	bucketer := NewBucketer()
+	for _, tt := range tests {
+		context := &Context{UserKey: tt.user, Attributes: map[string]interface{}{"plan": tt.plan}}
+		if got := bucketer.MatchSegment(tt.segment, context, segments); got != tt.want {
+			t.Errorf("%s with plan %s in %s = %v, want %v", tt.user, tt.plan, tt.segment, got, tt.want)
+		}
+	}
+}
+
+func TestMatchSegmentMemoizesMembership(t *testing.T) {
+	segments := map[string]*SegmentConfig{
+		"probed":  {Conditions: []Condition{probe(true)}},
+		"wrapper": {IncludedSegments: []string{"probed"}, Conditions: []Condition{probe(false)}},
+	}
+	flagConfig := conditionFlag(inSegment("wrapper"), inSegment("probed"))
+	flagConfig.Rules = append(flagConfig.Rules, Rule{ID: "again", TrafficAllocation: 1, VariationKey: "on", Conditions: []Condition{inSegment("probed")}})
+
+	bucketer := NewBucketer()
+	context := &Context{UserKey: "user-1", Attributes: map[string]interface{}{"x": 1}}
+	probeCalls = 0
+	result, err := bucketer.EvaluateFlag(flagConfig, context, "salt", segments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.VariationKey != "on" || probeCalls != 1 {
+		t.Errorf("served %s after %d condition evaluations, want on after 1", result.VariationKey, probeCalls)
+	}
+
+	// Another context is evaluated afresh
+	probeCalls = 0
+	if !bucketer.MatchSegment("probed", &Context{UserKey: "user-2", Attributes: map[string]interface{}{"x": 1}}, segments) || probeCalls != 1 {
+		t.Errorf("new context: %d condition evaluations, want 1", probeCalls)
+	}
+}
+
+func TestMatchSegmentTerminatesOnCycles(t *testing.T) {
+	segments := map[string]*SegmentConfig{
+		"self":     {Conditions: []Condition{inSegment("self")}},
+		"not-self": {Conditions: []Condition{{Not: &Condition{Attribute: "segment", Operator: "eq", Value: "not-self"}}}},
+		"a":        {IncludedSegments: []string{"b"}, Conditions: []Condition{eq("plan", "pro")}},
+		"b":        {IncludedSegments: []string{"a"}, Conditions: []Condition{eq("plan", "team")}},
+	}
+
+	tests := []struct {
+		segment string
+		plan    string
+		want    bool
+	}{
+		{"self", "pro", false},
+		{"not-self", "pro", true},
+		{"a", "free", false},
+		{"a", "team", true},
+		{"b", "pro", true},
+	}
+
+	bucketer := NewBucketer()
+	for _, tt := range tests {
+		context := &Context{UserKey: "user-1", Attributes: map[string]interface{}{"plan": tt.plan}}
+		if got := bucketer.MatchSegment(tt.segment, context, segments); got != tt.want {
+			t.Errorf("plan %s in %s = %v, want %v", tt.plan, tt.segment, got, tt.want)
+		}
+	}
+}
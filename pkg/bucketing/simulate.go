@@ -0,0 +1,275 @@
+package bucketing
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/Sidd-007/feature-flag-platform/pkg/hashing"
+)
+
+// Uniformity check parameters
+const (
+	// uniformityBins is the most bins buckets are grouped into
+	uniformityBins = 100
+	// uniformityMinExpected is the fewest samples expected per bin for the
+	// chi-square approximation to hold
+	uniformityMinExpected = 5
+	// UniformityAlpha is the significance level below which bucketing is
+	// reported as not uniform
+	UniformityAlpha = 0.01
+)
+
+// maxContextLine is the longest NDJSON context line a simulation reads
+const maxContextLine = 1 << 20
+
+// SimulationReport is the outcome of evaluating a flag for a sample of
+// contexts
+type SimulationReport struct {
+	FlagKey    string             `json:"flag_key"`
+	Contexts   int                `json:"contexts"` // contexts evaluated
+	Errors     int                `json:"errors"`   // contexts that could not be evaluated
+	Variations map[string]int     `json:"variations"`
+	Fractions  map[string]float64 `json:"fractions"` // share of the evaluated contexts per variation
+
+	// Rules lists the contexts each rule served, in rule order, followed by
+	// those no rule served, under an empty rule ID
+	Rules []RuleSimulation `json:"rules"`
+
+	// Uniformity checks the flag buckets of the contexts against a uniform
+	// distribution. It is nil when there are too few contexts to check.
+	Uniformity *UniformityCheck `json:"uniformity,omitempty"`
+}
+
+// RuleSimulation counts the variations a rule served
+type RuleSimulation struct {
+	RuleID     string         `json:"rule_id"`
+	Contexts   int            `json:"contexts"`
+	Variations map[string]int `json:"variations"`
+}
+
+// UniformityCheck is a chi-square goodness-of-fit test of buckets against
+// the uniform distribution, with the bucket space split into equal bins
+type UniformityCheck struct {
+	Samples          int     `json:"samples"`
+	Bins             int     `json:"bins"`
+	ChiSquare        float64 `json:"chi_square"`
+	DegreesOfFreedom int     `json:"degrees_of_freedom"`
+	PValue           float64 `json:"p_value"`
+	Uniform          bool    `json:"uniform"` // p-value is at least UniformityAlpha
+}
+
+// Simulator evaluates a flag for a stream of contexts, as evaluators of its
+// environment would, and tallies where they end up. Sticky assignments are
+// neither read nor recorded. A simulator is not safe for concurrent use.
+type Simulator struct {
+	bucketer   *Bucketer
+	flagConfig *FlagConfig
+	flags      map[string]*FlagConfig
+	segments   map[string]*SegmentConfig
+	envSalt    string
+
+	report  *SimulationReport
+	rules   map[string]*RuleSimulation
+	buckets []int
+}
+
+// NewSimulator creates a simulator of a flag. flags is the flag set of the
+// environment, against which prerequisites are resolved.
+func NewSimulator(flagConfig *FlagConfig, flags map[string]*FlagConfig, segments map[string]*SegmentConfig, envSalt string) *Simulator {
+	s := &Simulator{
+		bucketer:   NewBucketer(),
+		flagConfig: flagConfig,
+		flags:      flags,
+		segments:   segments,
+		envSalt:    envSalt,
+		report: &SimulationReport{
+			FlagKey:    flagConfig.Key,
+			Variations: make(map[string]int),
+			Rules:      make([]RuleSimulation, 0, len(flagConfig.Rules)+1),
+		},
+		rules: make(map[string]*RuleSimulation, len(flagConfig.Rules)+1),
+	}
+
+	// Rules are tallied in rule order, then the contexts no rule served
+	ruleIDs := make([]string, 0, len(flagConfig.Rules)+1)
+	for _, rule := range flagConfig.Rules {
+		ruleIDs = append(ruleIDs, rule.ID)
+	}
+	ruleIDs = append(ruleIDs, "")
+	seen := make(map[string]bool, len(ruleIDs))
+	for _, id := range ruleIDs {
+		if !seen[id] {
+			seen[id] = true
+			s.report.Rules = append(s.report.Rules, RuleSimulation{RuleID: id, Variations: make(map[string]int)})
+		}
+	}
+	for i := range s.report.Rules {
+		s.rules[s.report.Rules[i].RuleID] = &s.report.Rules[i]
+	}
+	return s
+}
+
+// Evaluate evaluates the flag for a context and tallies the result
+func (s *Simulator) Evaluate(context *Context) (*EvaluationResult, error) {
+	result, err := s.bucketer.EvaluateFlagWithPrerequisites(s.flagConfig, s.flags, context, s.envSalt, s.segments)
+	if err != nil {
+		s.report.Errors++
+		return nil, err
+	}
+
+	s.report.Contexts++
+	s.report.Variations[result.VariationKey]++
+	rule, exists := s.rules[result.RuleID]
+	if !exists {
+		rule = s.rules[""]
+	}
+	rule.Contexts++
+	rule.Variations[result.VariationKey]++
+	s.buckets = append(s.buckets, result.Bucket)
+	return result, nil
+}
+
+// SimulateNDJSON evaluates the flag for each context of a newline-delimited
+// JSON stream. Blank lines are skipped. Contexts that cannot be evaluated are
+// counted as errors; a line that is not a context stops the simulation.
+func (s *Simulator) SimulateNDJSON(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxContextLine)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		var context Context
+		if err := json.Unmarshal(data, &context); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		s.Evaluate(&context)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("line %d: %w", line+1, err)
+	}
+	return nil
+}
+
+// Report returns the tallies of the contexts evaluated so far
+func (s *Simulator) Report() *SimulationReport {
+	report := *s.report
+	report.Fractions = make(map[string]float64, len(report.Variations))
+	for key, count := range report.Variations {
+		report.Fractions[key] = float64(count) / float64(report.Contexts)
+	}
+	report.Uniformity = CheckUniformity(s.buckets, hashing.ForVersion(s.flagConfig.HashVersion).Buckets())
+	return &report
+}
+
+// CheckUniformity runs a chi-square test of buckets drawn from a bucket space
+// of the given size against the uniform distribution. The space is split
+// into up to 100 bins, fewer when there are not enough samples to expect 5 in
+// each. It returns nil when there are too few samples for two bins.
+func CheckUniformity(buckets []int, bucketSpace int) *UniformityCheck {
+	bins := min(uniformityBins, len(buckets)/uniformityMinExpected, bucketSpace)
+	if bins < 2 {
+		return nil
+	}
+
+	observed := make([]int, bins)
+	for _, bucket := range buckets {
+		bin := int(int64(bucket) * int64(bins) / int64(bucketSpace))
+		observed[max(0, min(bin, bins-1))]++
+	}
+
+	// Bins are as equal as the bucket space allows; each expects samples in
+	// proportion to the number of buckets it holds
+	chiSquare := 0.0
+	for i, count := range observed {
+		width := ceilDiv((i+1)*bucketSpace, bins) - ceilDiv(i*bucketSpace, bins)
+		expected := float64(len(buckets)) * float64(width) / float64(bucketSpace)
+		diff := float64(count) - expected
+		chiSquare += diff * diff / expected
+	}
+
+	df := bins - 1
+	pValue := chiSquarePValue(chiSquare, df)
+	return &UniformityCheck{
+		Samples:          len(buckets),
+		Bins:             bins,
+		ChiSquare:        chiSquare,
+		DegreesOfFreedom: df,
+		PValue:           pValue,
+		Uniform:          pValue >= UniformityAlpha,
+	}
+}
+
+// ceilDiv returns a / b rounded up, for non-negative a and positive b
+func ceilDiv(a, b int) int {
+	return (a + b - 1) / b
+}
+
+// chiSquarePValue returns the probability of a chi-square statistic at least
+// as large as the given one with df degrees of freedom
+func chiSquarePValue(chiSquare float64, df int) float64 {
+	if chiSquare <= 0 {
+		return 1
+	}
+	return upperRegularizedGamma(float64(df)/2, chiSquare/2)
+}
+
+// upperRegularizedGamma returns Q(a, x) = Γ(a, x) / Γ(a), from its series
+// when x < a+1 and its continued fraction otherwise
+func upperRegularizedGamma(a, x float64) float64 {
+	const (
+		epsilon    = 1e-15
+		tiny       = 1e-300
+		iterations = 1000
+	)
+
+	lgamma, _ := math.Lgamma(a)
+	scale := math.Exp(a*math.Log(x) - x - lgamma)
+
+	if x < a+1 {
+		term := 1 / a
+		sum := term
+		for n := 1; n < iterations; n++ {
+			term *= x / (a + float64(n))
+			sum += term
+			if math.Abs(term) < math.Abs(sum)*epsilon {
+				break
+			}
+		}
+		return math.Max(0, 1-sum*scale)
+	}
+
+	// Modified Lentz evaluation of the continued fraction
+	b := x + 1 - a
+	c := 1 / tiny
+	d := 1 / b
+	h := d
+	for i := 1; i < iterations; i++ {
+		an := -float64(i) * (float64(i) - a)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = b + an/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		h *= delta
+		if math.Abs(delta-1) < epsilon {
+			break
+		}
+	}
+	return scale * h
+}
@@ -0,0 +1,130 @@
+package bucketing
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestChiSquarePValue(t *testing.T) {
+	tests := []struct {
+		chiSquare float64
+		df        int
+		want      float64
+	}{
+		{0, 5, 1},
+		{3.841459, 1, 0.05},
+		{6.634897, 1, 0.01},
+		{2, 2, math.Exp(-1)},
+		{23.209251, 10, 0.01},
+		{123.225221, 99, 0.05},
+		{400, 99, 0},
+	}
+	for _, tt := range tests {
+		if got := chiSquarePValue(tt.chiSquare, tt.df); math.Abs(got-tt.want) > 1e-6 {
+			t.Errorf("p-value of %v with %d degrees of freedom = %v, want %v", tt.chiSquare, tt.df, got, tt.want)
+		}
+	}
+}
+
+func TestCheckUniformity(t *testing.T) {
+	// Every bucket once: a perfect fit
+	all := make([]int, 10000)
+	for i := range all {
+		all[i] = i
+	}
+	check := CheckUniformity(all, 10000)
+	if check.Bins != 100 || check.DegreesOfFreedom != 99 || check.ChiSquare != 0 || check.PValue != 1 || !check.Uniform {
+		t.Errorf("every bucket once: %+v", check)
+	}
+
+	// 8 of 10 samples in the lower half of the space
+	check = CheckUniformity([]int{0, 1, 1, 2, 3, 3, 4, 4, 5, 9}, 10)
+	if check.Bins != 2 || math.Abs(check.ChiSquare-3.6) > 1e-9 || math.Abs(check.PValue-math.Erfc(math.Sqrt(1.8))) > 1e-9 || !check.Uniform {
+		t.Errorf("8 of 10 in one half: %+v, want chi-square 3.6 and p-value %v", check, math.Erfc(math.Sqrt(1.8)))
+	}
+
+	// Everyone in the lowest tenth of the space
+	skewed := make([]int, 5000)
+	for i := range skewed {
+		skewed[i] = i % 1000
+	}
+	if check := CheckUniformity(skewed, 10000); check.Uniform || check.PValue > 1e-12 {
+		t.Errorf("skewed buckets: %+v", check)
+	}
+
+	if check := CheckUniformity(make([]int, 9), 10000); check != nil {
+		t.Errorf("9 samples: %+v, want no check", check)
+	}
+}
+
+func TestSimulatorCountsRules(t *testing.T) {
+	flagConfig := conditionFlag(eq("country", "US"))
+	flagConfig.Rules[0].ID = "us"
+	flagConfig.Rules = append(flagConfig.Rules, Rule{
+		ID:                "pro",
+		TrafficAllocation: 1,
+		Conditions:        []Condition{eq("plan", "pro")},
+		Rollout:           &Rollout{Variations: []RolloutVariation{{VariationKey: "on", Weight: 50}, {VariationKey: "off", Weight: 50}}},
+	})
+
+	var input strings.Builder
+	for i := 0; i < 3000; i++ {
+		country, plan := "CA", "free"
+		switch {
+		case i%3 == 0:
+			country = "US"
+		case i%3 == 1:
+			plan = "pro"
+		}
+		fmt.Fprintf(&input, `{"user_key": "user-%d", "attributes": {"country": %q, "plan": %q}}`+"\n", i, country, plan)
+		if i%1000 == 0 {
+			input.WriteString("\n" + `{"attributes": {"country": "US"}}` + "\n")
+		}
+	}
+
+	simulator := NewSimulator(flagConfig, nil, nil, "salt")
+	if err := simulator.SimulateNDJSON(strings.NewReader(input.String())); err != nil {
+		t.Fatal(err)
+	}
+	report := simulator.Report()
+
+	if report.Contexts != 3000 || report.Errors != 3 {
+		t.Fatalf("evaluated %d contexts with %d errors, want 3000 with 3", report.Contexts, report.Errors)
+	}
+	if len(report.Rules) != 3 || report.Rules[0].RuleID != "us" || report.Rules[1].RuleID != "pro" || report.Rules[2].RuleID != "" {
+		t.Fatalf("rules = %+v, want us, pro and the fall-through", report.Rules)
+	}
+	if us := report.Rules[0]; us.Contexts != 1000 || us.Variations["on"] != 1000 {
+		t.Errorf("us rule: %+v, want 1000 served on", us)
+	}
+	pro := report.Rules[1]
+	if pro.Contexts != 1000 || pro.Variations["on"]+pro.Variations["off"] != 1000 || pro.Variations["on"] < 400 || pro.Variations["on"] > 600 {
+		t.Errorf("pro rule: %+v, want 1000 split about evenly", pro)
+	}
+	if rest := report.Rules[2]; rest.Contexts != 1000 || rest.Variations["off"] != 1000 {
+		t.Errorf("fall-through: %+v, want 1000 served off", rest)
+	}
+
+	if report.Variations["on"] != 1000+pro.Variations["on"] || report.Variations["off"] != 1000+pro.Variations["off"] {
+		t.Errorf("variations = %v", report.Variations)
+	}
+	if got := report.Fractions["on"] + report.Fractions["off"]; math.Abs(got-1) > 1e-9 {
+		t.Errorf("fractions sum to %v", got)
+	}
+	if report.Uniformity == nil || report.Uniformity.Samples != 3000 || !report.Uniformity.Uniform {
+		t.Errorf("uniformity = %+v, want a uniform fit of 3000 samples", report.Uniformity)
+	}
+}
+
+func TestSimulateNDJSONRejectsInvalidLines(t *testing.T) {
+	simulator := NewSimulator(conditionFlag(), nil, nil, "salt")
+	err := simulator.SimulateNDJSON(strings.NewReader(`{"user_key": "user-1"}` + "\n\n" + `{"user_key": ` + "\n"))
+	if err == nil || !strings.HasPrefix(err.Error(), "line 3:") {
+		t.Errorf("error = %v, want one on line 3", err)
+	}
+	if report := simulator.Report(); report.Contexts != 1 {
+		t.Errorf("evaluated %d contexts before the invalid line, want 1", report.Contexts)
+	}
+}
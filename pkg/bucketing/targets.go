@@ -0,0 +1,135 @@
+package bucketing
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// compactKeySetThreshold is the size above which key sets are serialized in
+// their compact form
+const compactKeySetThreshold = 256
+
+// KeySet is a set of user keys with constant-time membership checks. Small
+// sets serialize as a JSON array of keys; larger ones as a base64 string of
+// the gzipped, newline-separated sorted keys. Both forms are accepted when
+// decoding.
+type KeySet map[string]struct{}
+
+// NewKeySet creates a key set holding the given keys
+func NewKeySet(keys ...string) KeySet {
+	set := make(KeySet, len(keys))
+	set.Add(keys...)
+	return set
+}
+
+// Contains reports whether the set holds the key
+func (s KeySet) Contains(key string) bool {
+	_, ok := s[key]
+	return ok
+}
+
+// Add adds keys to the set
+func (s KeySet) Add(keys ...string) {
+	for _, key := range keys {
+		s[key] = struct{}{}
+	}
+}
+
+// Remove removes keys from the set
+func (s KeySet) Remove(keys ...string) {
+	for _, key := range keys {
+		delete(s, key)
+	}
+}
+
+// Keys returns the keys of the set in sorted order
+func (s KeySet) Keys() []string {
+	keys := make([]string, 0, len(s))
+	for key := range s {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// MarshalJSON implements json.Marshaler
+func (s KeySet) MarshalJSON() ([]byte, error) {
+	keys := s.Keys()
+	if len(keys) <= compactKeySetThreshold {
+		return json.Marshal(keys)
+	}
+
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := io.WriteString(writer, strings.Join(keys, "\n")); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(base64.StdEncoding.EncodeToString(buf.Bytes()))
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (s *KeySet) UnmarshalJSON(data []byte) error {
+	var keys []string
+	if err := json.Unmarshal(data, &keys); err == nil {
+		*s = NewKeySet(keys...)
+		return nil
+	}
+
+	var encoded string
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return fmt.Errorf("key set must be an array of keys or a compact string")
+	}
+
+	compressed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("invalid compact key set: %w", err)
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return fmt.Errorf("invalid compact key set: %w", err)
+	}
+	defer reader.Close()
+
+	raw, err := io.ReadAll(reader)
+	if err != nil {
+		return fmt.Errorf("invalid compact key set: %w", err)
+	}
+
+	*s = make(KeySet)
+	if len(raw) > 0 {
+		s.Add(strings.Split(string(raw), "\n")...)
+	}
+	return nil
+}
+
+// Target serves a variation to an explicit list of user keys
+type Target struct {
+	VariationKey string `json:"variation_key"`
+	Keys         KeySet `json:"keys"`
+}
+
+// MatchTargets checks the user key against a flag's individual targets. It
+// returns the variation to serve and the reason, or an empty variation key
+// when the user is not targeted. Excluded users are served the default
+// variation and take precedence over included ones.
+func MatchTargets(flagConfig *FlagConfig, userKey string) (string, string) {
+	if flagConfig.ExcludedKeys.Contains(userKey) {
+		return flagConfig.DefaultVariation, "user key is excluded"
+	}
+
+	for _, target := range flagConfig.Targets {
+		if target.Keys.Contains(userKey) {
+			return target.VariationKey, fmt.Sprintf("user key is targeted for variation %s", target.VariationKey)
+		}
+	}
+	return "", ""
+}
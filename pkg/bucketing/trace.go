@@ -0,0 +1,322 @@
+package bucketing
+
+import (
+	"fmt"
+
+	"github.com/Sidd-007/feature-flag-platform/pkg/hashing"
+)
+
+// Trace explains how an evaluation reached its result: the checks made, in
+// order, up to the one that decided the variation. It is built by
+// ExplainFlag and is meant for support and debugging, not for hot paths.
+type Trace struct {
+	FlagKey     string          `json:"flag_key"`
+	Status      string          `json:"status"`
+	BucketingID string          `json:"bucketing_id"`
+	Bucket      int             `json:"bucket"`
+	Buckets     int             `json:"buckets"` // size of the bucket space
+	HashVersion hashing.Version `json:"hash_version"`
+	BucketBy    string          `json:"bucket_by,omitempty"`
+
+	// BucketByMissing is set when the bucket_by attribute was missing, so
+	// the user key was bucketed by instead
+	BucketByMissing bool `json:"bucket_by_missing,omitempty"`
+
+	Prerequisites     []PrerequisiteTrace `json:"prerequisites,omitempty"`
+	Target            string              `json:"target,omitempty"` // why the user was individually targeted
+	Holdout           *HoldoutTrace       `json:"holdout,omitempty"`
+	TrafficAllocation *TrafficCheck       `json:"traffic_allocation,omitempty"`
+	Experiment        *ExperimentTrace    `json:"experiment,omitempty"`
+	Rules             []RuleTrace         `json:"rules"`
+	Decision          Decision            `json:"decision"`
+}
+
+// PrerequisiteTrace is the evaluation of a prerequisite flag
+type PrerequisiteTrace struct {
+	FlagKey           string `json:"flag_key"`
+	RequiredVariation string `json:"required_variation"`
+	ServedVariation   string `json:"served_variation,omitempty"`
+	Met               bool   `json:"met"`
+	Error             string `json:"error,omitempty"`
+	Trace             *Trace `json:"trace,omitempty"`
+}
+
+// TrafficCheck is a traffic allocation check of a bucket against a percentage
+type TrafficCheck struct {
+	Percentage float64 `json:"percentage"`
+	Bucket     int     `json:"bucket"`
+	Passed     bool    `json:"passed"`
+}
+
+// HoldoutTrace is the check of whether the environment's holdout holds the
+// user out of the flag's experiment
+type HoldoutTrace struct {
+	Key        string  `json:"key"`
+	Percentage float64 `json:"percentage"`
+	Bucket     int     `json:"bucket"`
+	HeldOut    bool    `json:"held_out"`
+}
+
+// ExperimentTrace is the check of whether the flag's experiment claims the
+// user in its layer
+type ExperimentTrace struct {
+	Key         string `json:"key"`
+	Layer       string `json:"layer"`
+	Bucket      int    `json:"bucket"`
+	StartBucket int    `json:"start_bucket"`
+	EndBucket   int    `json:"end_bucket"`
+	Claimed     bool   `json:"claimed"`
+}
+
+// RuleTrace is the evaluation of a rule. Rules after the one that decided
+// the variation are not considered and not listed.
+type RuleTrace struct {
+	RuleID            string           `json:"rule_id"`
+	Matched           bool             `json:"matched"`
+	Conditions        []ConditionTrace `json:"conditions"`
+	StickyAssignment  string           `json:"sticky_assignment,omitempty"` // variation recorded for the user
+	TrafficAllocation *TrafficCheck    `json:"traffic_allocation,omitempty"`
+	Rollout           *RolloutTrace    `json:"rollout,omitempty"`
+	VariationKey      string           `json:"variation_key,omitempty"`
+	Outcome           string           `json:"outcome"`
+}
+
+// RolloutTrace is the selection of a variation by a rollout. Layout tells
+// where the buckets the rollout served were laid out.
+type RolloutTrace struct {
+	Bucket       int    `json:"bucket"`
+	Layout       string `json:"layout"`
+	VariationKey string `json:"variation_key,omitempty"`
+	Reason       string `json:"reason"`
+}
+
+// Rollout layouts
+const (
+	// LayoutPlan buckets were laid out when the flag's plan was compiled
+	LayoutPlan = "plan"
+	// LayoutPersisted buckets were persisted by the minimal allocation strategy
+	LayoutPersisted = "persisted"
+	// LayoutWeights buckets were laid out from the weights on evaluation
+	LayoutWeights = "weights"
+)
+
+// ConditionTrace is the evaluation of a condition. Leaf comparisons carry the
+// resolved attribute value; and/or/not nodes carry their children, which are
+// all evaluated here even where evaluation short-circuits.
+type ConditionTrace struct {
+	Logical   string           `json:"logical,omitempty"` // and, or or not
+	Attribute string           `json:"attribute,omitempty"`
+	Operator  string           `json:"operator,omitempty"`
+	Expected  interface{}      `json:"expected,omitempty"`
+	Actual    interface{}      `json:"actual,omitempty"`
+	Children  []ConditionTrace `json:"children,omitempty"`
+	Segment   *SegmentTrace    `json:"segment,omitempty"`
+	Passed    bool             `json:"passed"`
+}
+
+// SegmentTrace is the evaluation of segment membership
+type SegmentTrace struct {
+	Key        string             `json:"key"`
+	Found      bool               `json:"found"`
+	Matched    bool               `json:"matched"`
+	Reason     string             `json:"reason"`
+	Segments   []SegmentTrace     `json:"segments,omitempty"` // included and excluded segments considered
+	Conditions []ConditionTrace   `json:"conditions,omitempty"`
+	Rules      []SegmentRuleTrace `json:"rules,omitempty"`
+}
+
+// SegmentRuleTrace is the evaluation of a segment rule
+type SegmentRuleTrace struct {
+	RuleID     string           `json:"rule_id,omitempty"`
+	Conditions []ConditionTrace `json:"conditions"`
+	Matched    bool             `json:"matched"`
+}
+
+// Decision is the outcome of an evaluation
+type Decision struct {
+	VariationKey string `json:"variation_key"`
+	Reason       string `json:"reason"`
+	RuleID       string `json:"rule_id,omitempty"`
+}
+
+// ExplainFlag evaluates a flag like EvaluateFlagWithPrerequisites, on the
+// same code path, and attaches a trace of the evaluation to the result.
+// Sticky assignments are read but not recorded, so explaining has no side
+// effects.
+func (b *Bucketer) ExplainFlag(flagConfig *FlagConfig, flags map[string]*FlagConfig, context *Context, envSalt string, segments map[string]*SegmentConfig) (*EvaluationResult, error) {
+	trace := &Trace{}
+	result, err := b.evaluateFlag(flagConfig, flags, context, envSalt, segments, 0, trace)
+	if err != nil {
+		return nil, err
+	}
+	b.ReportHoldout(result, flagConfig, context)
+
+	trace.Decision = Decision{VariationKey: result.VariationKey, Reason: result.Reason, RuleID: result.RuleID}
+	result.Trace = trace
+	return result, nil
+}
+
+// traceConditions traces conditions that must all match
+func (b *Bucketer) traceConditions(conditions []Condition, context *Context, segments map[string]*SegmentConfig) []ConditionTrace {
+	traces := make([]ConditionTrace, len(conditions))
+	for i := range conditions {
+		traces[i] = b.traceCondition(&conditions[i], context, segments)
+	}
+	return traces
+}
+
+// traceCondition traces a condition. Whether it passed is decided by the
+// evaluator itself, so the trace always agrees with the evaluation.
+func (b *Bucketer) traceCondition(condition *Condition, context *Context, segments map[string]*SegmentConfig) ConditionTrace {
+	trace := ConditionTrace{Passed: b.evaluateCondition(condition, context, segments)}
+
+	switch {
+	case condition.Not != nil:
+		trace.Logical = "not"
+		trace.Children = b.traceConditions([]Condition{*condition.Not}, context, segments)
+	case len(condition.Or) > 0:
+		trace.Logical = "or"
+		trace.Children = b.traceConditions(condition.Or, context, segments)
+	case len(condition.And) > 0:
+		trace.Logical = "and"
+		trace.Children = b.traceConditions(condition.And, context, segments)
+	default:
+		trace.Attribute = condition.Attribute
+		trace.Operator = condition.Operator
+		trace.Expected = condition.Value
+		if condition.Attribute == "segment" {
+			if key, ok := condition.Value.(string); ok {
+				trace.Segment = b.traceSegment(key, context, segments, map[string]bool{})
+			}
+		} else {
+			trace.Actual = ResolveAttribute(context, condition.Attribute, b.clock)
+		}
+	}
+	return trace
+}
+
+// traceSegment traces segment membership in the order MatchSegment decides
+// it. visiting holds the segments being traced above this one, so reference
+// cycles terminate.
+func (b *Bucketer) traceSegment(key string, context *Context, segments map[string]*SegmentConfig, visiting map[string]bool) *SegmentTrace {
+	segment, exists := segments[key]
+	if !exists || segment == nil {
+		return &SegmentTrace{Key: key, Reason: "segment not found"}
+	}
+
+	trace := &SegmentTrace{Key: key, Found: true, Matched: b.MatchSegment(key, context, segments)}
+	if visiting[key] {
+		trace.Reason = "segment refers back to itself"
+		return trace
+	}
+	visiting[key] = true
+	defer delete(visiting, key)
+
+	if segment.Excluded.Contains(context.UserKey) {
+		trace.Reason = "user key is excluded"
+		return trace
+	}
+	if segment.Included.Contains(context.UserKey) {
+		trace.Reason = "user key is included"
+		return trace
+	}
+
+	for _, ref := range segment.ExcludedSegments {
+		child := b.traceSegment(ref, context, segments, visiting)
+		trace.Segments = append(trace.Segments, *child)
+		if child.Matched {
+			trace.Reason = fmt.Sprintf("excluded by segment %s", ref)
+			return trace
+		}
+	}
+	for _, ref := range segment.IncludedSegments {
+		child := b.traceSegment(ref, context, segments, visiting)
+		trace.Segments = append(trace.Segments, *child)
+		if child.Matched {
+			trace.Reason = fmt.Sprintf("included by segment %s", ref)
+			return trace
+		}
+	}
+
+	if len(segment.Conditions) == 0 && len(segment.Rules) == 0 {
+		if trace.Matched {
+			trace.Reason = "segment has no conditions"
+		} else {
+			trace.Reason = "not in the segment's included keys or segments"
+		}
+		return trace
+	}
+
+	if len(segment.Conditions) > 0 {
+		trace.Conditions = b.traceConditions(segment.Conditions, context, segments)
+		if allPassed(trace.Conditions) {
+			trace.Reason = "matched conditions"
+			return trace
+		}
+	}
+	for i := range segment.Rules {
+		rule := &segment.Rules[i]
+		ruleTrace := SegmentRuleTrace{RuleID: rule.ID, Conditions: b.traceConditions(rule.Conditions, context, segments)}
+		ruleTrace.Matched = allPassed(ruleTrace.Conditions)
+		trace.Rules = append(trace.Rules, ruleTrace)
+		if ruleTrace.Matched {
+			trace.Reason = fmt.Sprintf("matched rule %s", rule.ID)
+			return trace
+		}
+	}
+
+	trace.Reason = "no conditions or rules matched"
+	return trace
+}
+
+// allPassed reports whether every traced condition passed
+func allPassed(conditions []ConditionTrace) bool {
+	for _, condition := range conditions {
+		if !condition.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// traceExperiment traces the experiment claim check of a flag
+func (b *Bucketer) traceExperiment(experiment *ExperimentConfig, context *Context, envSalt string) *ExperimentTrace {
+	bucket := b.LayerBucket(experiment.LayerKey(), context, envSalt, experiment.HashVersion)
+	return &ExperimentTrace{
+		Key:         experiment.Key,
+		Layer:       experiment.LayerKey(),
+		Bucket:      bucket,
+		StartBucket: experiment.StartBucket,
+		EndBucket:   experiment.EndBucket,
+		Claimed:     bucket >= experiment.StartBucket && bucket < experiment.EndBucket,
+	}
+}
+
+// traceHoldout traces the holdout check of a flag
+func (b *Bucketer) traceHoldout(holdout *HoldoutConfig, context *Context, heldOut bool) *HoldoutTrace {
+	return &HoldoutTrace{
+		Key:        holdout.Key,
+		Percentage: holdout.Percentage,
+		Bucket:     b.HoldoutBucket(holdout, context),
+		HeldOut:    heldOut,
+	}
+}
+
+// tracePrerequisite records the evaluation of a prerequisite flag
+func (t *Trace) tracePrerequisite(flagConfig, prereq *FlagConfig, result *EvaluationResult, err error, prereqTrace *Trace) {
+	entry := PrerequisiteTrace{FlagKey: prereq.Key, Trace: prereqTrace}
+	for _, p := range flagConfig.Prerequisites {
+		if p.FlagKey == prereq.Key {
+			entry.RequiredVariation = p.VariationKey
+			break
+		}
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	} else {
+		entry.ServedVariation = result.VariationKey
+		entry.Met = result.VariationKey == entry.RequiredVariation
+		prereqTrace.Decision = Decision{VariationKey: result.VariationKey, Reason: result.Reason, RuleID: result.RuleID}
+	}
+	t.Prerequisites = append(t.Prerequisites, entry)
+}
@@ -244,6 +244,9 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("control_plane.url", "http://localhost:8080")
 	v.SetDefault("edge_evaluator.api_key", "")
 	v.SetDefault("edge_evaluator.poll_interval", "30s")
+	v.SetDefault("edge_evaluator.context_validation", "annotate")
+	v.SetDefault("edge_evaluator.sticky_assignments", false)
+	v.SetDefault("edge_evaluator.sticky_assignment_ttl", "0s")
 }
 
 // Validate validates the configuration
@@ -311,6 +314,16 @@ type ControlPlaneConfig struct {
 type EdgeEvaluatorConfig struct {
 	APIKey       string        `mapstructure:"api_key"`
 	PollInterval time.Duration `mapstructure:"poll_interval"`
+
+	// ContextValidation controls how contexts violating the environment's
+	// context schema are handled: "off", "annotate" or "reject"
+	ContextValidation string `mapstructure:"context_validation"`
+
+	// StickyAssignments records the assignments of rollouts with sticky
+	// buckets in Redis, expiring those of experiments idle for
+	// StickyAssignmentTTL (zero keeps them until reset)
+	StickyAssignments   bool          `mapstructure:"sticky_assignments"`
+	StickyAssignmentTTL time.Duration `mapstructure:"sticky_assignment_ttl"`
 }
 
 // EventIngestorConfig holds Event Ingestor specific configuration
@@ -3,26 +3,47 @@ package dsl
 import (
 	"encoding/json"
 	"fmt"
-	"regexp"
 	"strconv"
-	"strings"
+
+	"github.com/Sidd-007/feature-flag-platform/pkg/bucketing"
+	"github.com/Sidd-007/feature-flag-platform/pkg/hashing"
+	"github.com/Sidd-007/feature-flag-platform/pkg/operators"
 )
 
-// Compiler compiles rule DSL into an optimized evaluation plan
+// Compiler compiles rule DSL into an optimized evaluation plan. Operators are
+// resolved through the shared operators registry, so operators registered with
+// operators.Register are available to every compiler.
 type Compiler struct {
-	operators map[string]OperatorFunc
+	hashVersion hashing.Version // version rollout buckets are laid out for
+	clock       operators.Clock // read by relative time operators and ramps
 }
 
-// OperatorFunc represents a comparison operator function
-type OperatorFunc func(left, right interface{}) bool
-
-// NewCompiler creates a new DSL compiler
+// NewCompiler creates a new DSL compiler laying out rollouts for
+// hashing.DefaultHashVersion
 func NewCompiler() *Compiler {
-	c := &Compiler{
-		operators: make(map[string]OperatorFunc),
+	return &Compiler{hashVersion: hashing.DefaultHashVersion, clock: operators.SystemClock}
+}
+
+// SetClock replaces the clock that compiled conditions and ramps read the
+// current time from, so evaluations can be reproduced in tests. A nil clock
+// restores the system clock.
+func (c *Compiler) SetClock(clock operators.Clock) {
+	if clock == nil {
+		clock = operators.SystemClock
 	}
-	c.registerDefaultOperators()
-	return c
+	c.clock = clock
+}
+
+// SetHashVersion sets the hash version the plans compiled from now on lay out
+// rollout buckets for. It must match the hash version of the flags the plans
+// evaluate.
+func (c *Compiler) SetHashVersion(version hashing.Version) {
+	c.hashVersion = hashing.ForVersion(version).Version()
+}
+
+// hasher returns the hasher rollouts are laid out with
+func (c *Compiler) hasher() *hashing.Hasher {
+	return hashing.ForVersion(c.hashVersion)
 }
 
 // CompiledPlan represents the compiled evaluation plan for a rule set
@@ -30,24 +51,62 @@ type CompiledPlan struct {
 	FlagKey      string            `json:"flag_key"`
 	Rules        []CompiledRule    `json:"rules"`
 	DefaultValue interface{}       `json:"default_value"`
+	BucketBy     string            `json:"bucket_by,omitempty"` // attribute to bucket by instead of the user key
 	Metadata     map[string]string `json:"metadata"`
+
+	// HashVersion is the hash version rollout bucket ranges are laid out
+	// for; it must match the hash version of the flag the plan evaluates
+	HashVersion hashing.Version `json:"hash_version,omitempty"`
 }
 
-// CompiledRule represents a single compiled rule
+// CompiledRule represents a single compiled rule. Conditions form an implicit
+// AND; nested and/or/not expressions are carried as logical condition nodes.
 type CompiledRule struct {
 	ID                string              `json:"id"`
 	Conditions        []CompiledCondition `json:"conditions"`
 	Action            CompiledAction      `json:"action"`
 	TrafficAllocation float64             `json:"traffic_allocation"`
+	BucketBy          string              `json:"bucket_by,omitempty"` // overrides the plan's bucket_by
 	Priority          int                 `json:"priority"`
 }
 
-// CompiledCondition represents a compiled condition
+// CompiledCondition represents a compiled condition. A condition is either a
+// leaf comparison (Attribute, Operator, Value) or a logical node with And, Or
+// or Not children, which allows expression trees of arbitrary depth.
 type CompiledCondition struct {
-	Attribute string      `json:"attribute"`
-	Operator  string      `json:"operator"`
-	Value     interface{} `json:"value"`
-	Compiled  bool        `json:"compiled"`
+	Attribute string              `json:"attribute,omitempty"`
+	Operator  string              `json:"operator,omitempty"`
+	Value     interface{}         `json:"value"`
+	And       []CompiledCondition `json:"and,omitempty"`
+	Or        []CompiledCondition `json:"or,omitempty"`
+	Not       *CompiledCondition  `json:"not,omitempty"`
+	Compiled  bool                `json:"compiled"`
+
+	// matcher is the pre-compiled typed evaluator for leaf comparisons
+	matcher operators.Matcher
+}
+
+// IsLogical reports whether the condition is an and/or/not node rather than a comparison
+func (cc *CompiledCondition) IsLogical() bool {
+	return len(cc.And) > 0 || len(cc.Or) > 0 || cc.Not != nil
+}
+
+// checkNode rejects a node that sets more than one of And, Or and Not, or
+// mixes one with a comparison. Evaluation would silently ignore part of it.
+func (cc *CompiledCondition) checkNode() error {
+	logical := 0
+	for _, set := range []bool{len(cc.And) > 0, len(cc.Or) > 0, cc.Not != nil} {
+		if set {
+			logical++
+		}
+	}
+	if logical > 1 {
+		return fmt.Errorf("condition can only have one of and, or and not")
+	}
+	if logical == 1 && (cc.Attribute != "" || cc.Operator != "" || cc.Value != nil) {
+		return fmt.Errorf("logical condition cannot also have an attribute, operator or value")
+	}
+	return nil
 }
 
 // CompiledAction represents the action to take when rules match
@@ -57,18 +116,53 @@ type CompiledAction struct {
 	Rollout      *CompiledRollout `json:"rollout,omitempty"`
 }
 
-// CompiledRollout represents a compiled rollout configuration
+// CompiledRollout represents a compiled rollout configuration. For a ramp,
+// Variations hold the weights at compile time and evaluators recompute them
+// from the ramp's schedule.
 type CompiledRollout struct {
 	Variations  []RolloutVariation `json:"variations"`
 	TotalWeight float64            `json:"total_weight"`
+	Ramp        *bucketing.Ramp    `json:"ramp,omitempty"`
+
+	// StickyBuckets keeps users on the variation first served to them when
+	// the evaluator has an assignment store
+	StickyBuckets bool `json:"sticky_buckets,omitempty"`
 }
 
-// RolloutVariation represents a variation in a rollout
+// RolloutVariation represents a variation in a rollout. Variations allocated
+// with the minimal strategy are served from their persisted Ranges instead of
+// StartBucket to EndBucket.
 type RolloutVariation struct {
-	VariationKey string  `json:"variation_key"`
-	Weight       float64 `json:"weight"`
-	StartBucket  int     `json:"start_bucket"`
-	EndBucket    int     `json:"end_bucket"`
+	VariationKey string                `json:"variation_key"`
+	Weight       float64               `json:"weight"`
+	StartBucket  int                   `json:"start_bucket"`
+	EndBucket    int                   `json:"end_bucket"`
+	Ranges       []hashing.BucketRange `json:"ranges,omitempty"`
+}
+
+// Contains reports whether a rule bucket is served the variation
+func (v *RolloutVariation) Contains(bucket int) bool {
+	if len(v.Ranges) > 0 {
+		for _, r := range v.Ranges {
+			if r.Contains(bucket) {
+				return true
+			}
+		}
+		return false
+	}
+	return bucket >= v.StartBucket && bucket < v.EndBucket
+}
+
+// Size returns the number of rule buckets served the variation
+func (v *RolloutVariation) Size() int {
+	if len(v.Ranges) > 0 {
+		size := 0
+		for _, r := range v.Ranges {
+			size += r.Size()
+		}
+		return size
+	}
+	return v.EndBucket - v.StartBucket
 }
 
 // RuleDefinition represents the input rule definition
@@ -95,6 +189,7 @@ func (c *Compiler) CompileRules(flagKey string, rules []RuleDefinition, defaultV
 		Rules:        make([]CompiledRule, 0, len(rules)),
 		DefaultValue: defaultValue,
 		Metadata:     make(map[string]string),
+		HashVersion:  c.hasher().Version(),
 	}
 
 	for i, ruleDef := range rules {
@@ -154,15 +249,56 @@ func (c *Compiler) compileConditions(ifClause interface{}) ([]CompiledCondition,
 
 // compileConditionMap compiles a condition from a map
 func (c *Compiler) compileConditionMap(condMap map[string]interface{}) ([]CompiledCondition, error) {
-	// Handle logical operators
+	// A node is either one logical operator or a comparison; anything else
+	// would silently ignore part of it
+	logical := 0
+	for _, key := range []string{"and", "or", "not"} {
+		if _, exists := condMap[key]; exists {
+			logical++
+		}
+	}
+	if logical > 1 {
+		return nil, fmt.Errorf("condition can only have one of and, or and not")
+	}
+	if logical == 1 {
+		for _, key := range []string{"attribute", "operator", "value"} {
+			if value := condMap[key]; value != nil && value != "" {
+				return nil, fmt.Errorf("logical condition cannot also have %s", key)
+			}
+		}
+	}
+
+	// Handle logical operators. An "and" block is flattened into the
+	// surrounding implicit AND list; "or" and "not" produce logical nodes.
 	if and, exists := condMap["and"]; exists {
 		return c.compileConditions(and)
 	}
 
 	if or, exists := condMap["or"]; exists {
-		// For OR conditions, we need to handle them differently
-		// This is a simplified implementation - in production you'd want more sophisticated OR handling
-		return c.compileConditions(or)
+		orArray, ok := or.([]interface{})
+		if !ok || len(orArray) == 0 {
+			return nil, fmt.Errorf("or must be a non-empty array of conditions")
+		}
+
+		branches := make([]CompiledCondition, 0, len(orArray))
+		for i, item := range orArray {
+			branch, err := c.compileConditionGroup(item)
+			if err != nil {
+				return nil, fmt.Errorf("failed to compile or branch %d: %w", i, err)
+			}
+			branches = append(branches, *branch)
+		}
+
+		return []CompiledCondition{{Or: branches, Compiled: true}}, nil
+	}
+
+	if not, exists := condMap["not"]; exists {
+		negated, err := c.compileConditionGroup(not)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile not: %w", err)
+		}
+
+		return []CompiledCondition{{Not: negated, Compiled: true}}, nil
 	}
 
 	// Handle direct condition
@@ -174,19 +310,88 @@ func (c *Compiler) compileConditionMap(condMap map[string]interface{}) ([]Compil
 		return nil, fmt.Errorf("condition must have attribute, operator, and value")
 	}
 
-	condition := CompiledCondition{
+	condition, err := c.compileComparison(attribute, operator, value)
+	if err != nil {
+		return nil, err
+	}
+
+	return []CompiledCondition{*condition}, nil
+}
+
+// compileComparison validates a leaf comparison and pre-compiles its matcher
+func (c *Compiler) compileComparison(attribute, operator string, value interface{}) (*CompiledCondition, error) {
+	// Validate operator
+	if !c.isValidOperator(operator) {
+		return nil, fmt.Errorf("unknown operator %q", operator)
+	}
+
+	condition := &CompiledCondition{
 		Attribute: attribute,
 		Operator:  operator,
 		Value:     value,
 		Compiled:  true,
 	}
 
-	// Validate operator
-	if !c.isValidOperator(operator) {
-		return nil, fmt.Errorf("unsupported operator: %s", operator)
+	// Segment references are resolved by the evaluator, not by an operator
+	if attribute != "segment" {
+		matcher, err := operators.Compile(operator, value, c.clock)
+		if err != nil {
+			return nil, err
+		}
+		condition.matcher = matcher
+	}
+
+	return condition, nil
+}
+
+// PreparePlan pre-compiles the matchers of a plan that was deserialized
+// rather than produced by the compiler
+func (c *Compiler) PreparePlan(plan *CompiledPlan) error {
+	for i := range plan.Rules {
+		if err := c.prepareConditions(plan.Rules[i].Conditions); err != nil {
+			return fmt.Errorf("failed to prepare rule %s: %w", plan.Rules[i].ID, err)
+		}
 	}
+	return nil
+}
 
-	return []CompiledCondition{condition}, nil
+// prepareConditions compiles matchers for a condition tree in place
+func (c *Compiler) prepareConditions(conditions []CompiledCondition) error {
+	for i := range conditions {
+		if err := c.prepareCondition(&conditions[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prepareCondition compiles the matcher of a single condition node
+func (c *Compiler) prepareCondition(cond *CompiledCondition) error {
+	if err := cond.checkNode(); err != nil {
+		return err
+	}
+	if cond.Not != nil {
+		return c.prepareCondition(cond.Not)
+	}
+
+	if cond.IsLogical() {
+		if err := c.prepareConditions(cond.And); err != nil {
+			return err
+		}
+		return c.prepareConditions(cond.Or)
+	}
+
+	// Segment membership is resolved against the segment map, not an operator
+	if cond.Attribute == "segment" {
+		return nil
+	}
+
+	compiled, err := c.compileComparison(cond.Attribute, cond.Operator, cond.Value)
+	if err != nil {
+		return err
+	}
+	cond.matcher = compiled.matcher
+	return nil
 }
 
 // compileConditionArray compiles conditions from an array
@@ -204,6 +409,24 @@ func (c *Compiler) compileConditionArray(condArray []interface{}) ([]CompiledCon
 	return conditions, nil
 }
 
+// compileConditionGroup compiles a clause into a single condition node,
+// wrapping multiple conditions in an implicit AND node
+func (c *Compiler) compileConditionGroup(clause interface{}) (*CompiledCondition, error) {
+	conditions, err := c.compileConditions(clause)
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(conditions) {
+	case 0:
+		return nil, fmt.Errorf("condition group cannot be empty")
+	case 1:
+		return &conditions[0], nil
+	default:
+		return &CompiledCondition{And: conditions, Compiled: true}, nil
+	}
+}
+
 // compileAction compiles the action part of a rule
 func (c *Compiler) compileAction(thenClause interface{}) (*CompiledAction, error) {
 	if thenClause == nil {
@@ -254,6 +477,12 @@ func (c *Compiler) compileRollout(rolloutDef interface{}) (*CompiledRollout, err
 		return nil, fmt.Errorf("rollout must be an object")
 	}
 
+	sticky, _ := rolloutMap["sticky_buckets"].(bool)
+
+	if rampDef, exists := rolloutMap["ramp"]; exists {
+		return c.compileRamp(rampDef, sticky)
+	}
+
 	variationsInterface, exists := rolloutMap["variations"]
 	if !exists {
 		return nil, fmt.Errorf("rollout must have variations")
@@ -266,7 +495,6 @@ func (c *Compiler) compileRollout(rolloutDef interface{}) (*CompiledRollout, err
 
 	var variations []RolloutVariation
 	var totalWeight float64
-	currentBucket := 0
 
 	for i, varInterface := range variationsArray {
 		varMap, ok := varInterface.(map[string]interface{})
@@ -297,8 +525,6 @@ func (c *Compiler) compileRollout(rolloutDef interface{}) (*CompiledRollout, err
 		variations = append(variations, RolloutVariation{
 			VariationKey: key,
 			Weight:       weight,
-			StartBucket:  currentBucket,
-			EndBucket:    currentBucket, // Will be calculated after we know total weight
 		})
 	}
 
@@ -306,25 +532,55 @@ func (c *Compiler) compileRollout(rolloutDef interface{}) (*CompiledRollout, err
 		return nil, fmt.Errorf("total weight must be positive")
 	}
 
-	// Calculate bucket ranges
-	currentBucket = 0
-	for i := range variations {
-		bucketSize := int((variations[i].Weight / totalWeight) * 10000)
+	assignBuckets(variations, c.hasher())
 
-		// Ensure the last variation gets all remaining buckets
-		if i == len(variations)-1 {
-			bucketSize = 10000 - currentBucket
-		}
+	return &CompiledRollout{
+		Variations:    variations,
+		TotalWeight:   totalWeight,
+		StickyBuckets: sticky,
+	}, nil
+}
+
+// compileRamp compiles a ramp rollout configuration
+func (c *Compiler) compileRamp(rampDef interface{}, sticky bool) (*CompiledRollout, error) {
+	data, err := json.Marshal(rampDef)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ramp: %w", err)
+	}
+	var ramp bucketing.Ramp
+	if err := json.Unmarshal(data, &ramp); err != nil {
+		return nil, fmt.Errorf("invalid ramp: %w", err)
+	}
+	return c.rampRollout(&ramp, sticky)
+}
 
-		variations[i].StartBucket = currentBucket
-		variations[i].EndBucket = currentBucket + bucketSize
-		currentBucket += bucketSize
+// rampRollout validates a ramp and compiles it into a rollout with its
+// current weights
+func (c *Compiler) rampRollout(ramp *bucketing.Ramp, sticky bool) (*CompiledRollout, error) {
+	if err := ramp.Validate(); err != nil {
+		return nil, err
 	}
+	return rolloutFromBucketing(&bucketing.Rollout{Ramp: ramp, StickyBuckets: sticky}, c.hasher(), c.clock.Now()), nil
+}
 
-	return &CompiledRollout{
-		Variations:  variations,
-		TotalWeight: totalWeight,
-	}, nil
+// assignBuckets calculates the bucket range of each rollout variation from
+// its share of the total weight, using the same allocation as the bucketer so
+// a plan serves exactly the buckets the raw flag configuration would. When
+// the weights are all zero every range is left empty.
+func assignBuckets(variations []RolloutVariation, hasher *hashing.Hasher) {
+	weights := make([]float64, len(variations))
+	for i := range variations {
+		weights[i] = variations[i].Weight
+	}
+
+	ranges := hasher.AllocateBucketsForVariations(weights)
+	for i := range variations {
+		variations[i].StartBucket, variations[i].EndBucket = 0, 0
+		if i < len(ranges) {
+			variations[i].StartBucket = ranges[i].Start
+			variations[i].EndBucket = ranges[i].End
+		}
+	}
 }
 
 // optimizePlan optimizes the compiled plan
@@ -340,116 +596,80 @@ func (c *Compiler) optimizePlan(plan *CompiledPlan) {
 	plan.Metadata["rules_count"] = strconv.Itoa(len(plan.Rules))
 }
 
-// registerDefaultOperators registers the default comparison operators
-func (c *Compiler) registerDefaultOperators() {
-	c.operators["eq"] = func(left, right interface{}) bool {
-		return fmt.Sprintf("%v", left) == fmt.Sprintf("%v", right)
-	}
-
-	c.operators["neq"] = func(left, right interface{}) bool {
-		return fmt.Sprintf("%v", left) != fmt.Sprintf("%v", right)
-	}
+// isValidOperator checks if an operator is registered
+func (c *Compiler) isValidOperator(operator string) bool {
+	return operators.IsSupported(operator)
+}
 
-	c.operators["in"] = func(left, right interface{}) bool {
-		if rightArray, ok := right.([]interface{}); ok {
-			leftStr := fmt.Sprintf("%v", left)
-			for _, item := range rightArray {
-				if fmt.Sprintf("%v", item) == leftStr {
-					return true
-				}
-			}
-		}
+// EvaluateRule evaluates the conditions of a compiled rule as an implicit AND
+func (c *Compiler) EvaluateRule(rule *CompiledRule, context map[string]interface{}) bool {
+	if rule == nil {
 		return false
 	}
 
-	c.operators["nin"] = func(left, right interface{}) bool {
-		return !c.operators["in"](left, right)
-	}
-
-	c.operators["lt"] = func(left, right interface{}) bool {
-		leftFloat, leftOk := toFloat64(left)
-		rightFloat, rightOk := toFloat64(right)
-		return leftOk && rightOk && leftFloat < rightFloat
-	}
-
-	c.operators["gt"] = func(left, right interface{}) bool {
-		leftFloat, leftOk := toFloat64(left)
-		rightFloat, rightOk := toFloat64(right)
-		return leftOk && rightOk && leftFloat > rightFloat
-	}
+	return c.evaluateAll(rule.Conditions, context)
+}
 
-	c.operators["lte"] = func(left, right interface{}) bool {
-		leftFloat, leftOk := toFloat64(left)
-		rightFloat, rightOk := toFloat64(right)
-		return leftOk && rightOk && leftFloat <= rightFloat
+// EvaluateCondition evaluates a compiled condition, recursing into and/or/not
+// nodes with short-circuiting
+func (c *Compiler) EvaluateCondition(condition *CompiledCondition, context map[string]interface{}) bool {
+	if condition == nil {
+		return false
 	}
 
-	c.operators["gte"] = func(left, right interface{}) bool {
-		leftFloat, leftOk := toFloat64(left)
-		rightFloat, rightOk := toFloat64(right)
-		return leftOk && rightOk && leftFloat >= rightFloat
+	if condition.IsLogical() {
+		return c.evaluateLogical(condition, context)
 	}
 
-	c.operators["contains"] = func(left, right interface{}) bool {
-		leftStr := fmt.Sprintf("%v", left)
-		rightStr := fmt.Sprintf("%v", right)
-		return strings.Contains(leftStr, rightStr)
+	// Get attribute value from context, following nested paths. The current
+	// time is resolved in the zone given by the "timezone" attribute unless
+	// provided.
+	attributeValue, exists := bucketing.LookupAttribute(context, condition.Attribute)
+	if !exists {
+		if condition.Attribute != operators.NowAttribute {
+			return false
+		}
+		zone, _ := context["timezone"].(string)
+		attributeValue = operators.NowIn(c.clock, zone)
 	}
 
-	c.operators["regex"] = func(left, right interface{}) bool {
-		leftStr := fmt.Sprintf("%v", left)
-		rightStr := fmt.Sprintf("%v", right)
-		matched, err := regexp.MatchString(rightStr, leftStr)
-		return err == nil && matched
+	if condition.matcher != nil {
+		return condition.matcher(attributeValue)
 	}
-}
 
-// isValidOperator checks if an operator is supported
-func (c *Compiler) isValidOperator(operator string) bool {
-	_, exists := c.operators[operator]
-	return exists
+	// Conditions that were not prepared are compiled on every evaluation
+	return operators.Evaluate(condition.Operator, attributeValue, condition.Value, c.clock)
 }
 
-// EvaluateCondition evaluates a compiled condition
-func (c *Compiler) EvaluateCondition(condition *CompiledCondition, context map[string]interface{}) bool {
-	if condition == nil {
+// evaluateLogical evaluates an and/or/not node. Invalid nodes never match.
+func (c *Compiler) evaluateLogical(condition *CompiledCondition, context map[string]interface{}) bool {
+	if condition.checkNode() != nil {
 		return false
 	}
-
-	// Get attribute value from context
-	attributeValue, exists := context[condition.Attribute]
-	if !exists {
-		return false
+	if condition.Not != nil {
+		return !c.EvaluateCondition(condition.Not, context)
 	}
 
-	// Get operator function
-	operatorFunc, exists := c.operators[condition.Operator]
-	if !exists {
+	if len(condition.Or) > 0 {
+		for i := range condition.Or {
+			if c.EvaluateCondition(&condition.Or[i], context) {
+				return true
+			}
+		}
 		return false
 	}
 
-	return operatorFunc(attributeValue, condition.Value)
+	return c.evaluateAll(condition.And, context)
 }
 
-// toFloat64 converts various numeric types to float64
-func toFloat64(v interface{}) (float64, bool) {
-	switch val := v.(type) {
-	case float64:
-		return val, true
-	case float32:
-		return float64(val), true
-	case int:
-		return float64(val), true
-	case int32:
-		return float64(val), true
-	case int64:
-		return float64(val), true
-	case string:
-		if f, err := strconv.ParseFloat(val, 64); err == nil {
-			return f, true
+// evaluateAll returns true if every condition matches, stopping at the first miss
+func (c *Compiler) evaluateAll(conditions []CompiledCondition, context map[string]interface{}) bool {
+	for i := range conditions {
+		if !c.EvaluateCondition(&conditions[i], context) {
+			return false
 		}
 	}
-	return 0, false
+	return true
 }
 
 // SerializePlan serializes a compiled plan to JSON
@@ -463,5 +683,8 @@ func (c *Compiler) DeserializePlan(data []byte) (*CompiledPlan, error) {
 	if err := json.Unmarshal(data, &plan); err != nil {
 		return nil, err
 	}
+	if err := c.PreparePlan(&plan); err != nil {
+		return nil, err
+	}
 	return &plan, nil
 }
@@ -0,0 +1,132 @@
+package dsl
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/Sidd-007/feature-flag-platform/pkg/operators"
+)
+
+// countedCalls counts evaluations of the test_dsl_probe operator, which
+// matches when its operand is true
+var countedCalls int
+
+func init() {
+	operators.MustRegister(operators.Definition{
+		Name:        "test_dsl_probe",
+		OperandType: operators.ArgBool,
+		Compile: func(operand interface{}) (operators.Matcher, error) {
+			match := operand.(bool)
+			return func(interface{}) bool {
+				countedCalls++
+				return match
+			}, nil
+		},
+	})
+}
+
+func compileIf(t *testing.T, compiler *Compiler, ifClause string) (*CompiledPlan, error) {
+	t.Helper()
+	var rules []RuleDefinition
+	if err := json.Unmarshal([]byte(`[{"if": `+ifClause+`, "then": "on"}]`), &rules); err != nil {
+		t.Fatalf("invalid rule JSON %s: %v", ifClause, err)
+	}
+	return compiler.CompileRules("flag", rules, "off")
+}
+
+func TestCompileLogicalConditions(t *testing.T) {
+	context := map[string]interface{}{"country": "US", "plan": "pro", "age": 30}
+
+	tests := []struct {
+		name string
+		if_  string
+		want bool
+	}{
+		{"flat list is an implicit and", `[{"attribute": "country", "operator": "eq", "value": "US"}, {"attribute": "plan", "operator": "eq", "value": "free"}]`, false},
+		{"flat list matching every condition", `[{"attribute": "country", "operator": "eq", "value": "US"}, {"attribute": "plan", "operator": "eq", "value": "pro"}]`, true},
+		{"and", `{"and": [{"attribute": "country", "operator": "eq", "value": "US"}, {"attribute": "age", "operator": "gte", "value": 18}]}`, true},
+		{"or", `{"or": [{"attribute": "country", "operator": "eq", "value": "CA"}, {"attribute": "plan", "operator": "eq", "value": "pro"}]}`, true},
+		{"or without a match", `{"or": [{"attribute": "country", "operator": "eq", "value": "CA"}, {"attribute": "plan", "operator": "eq", "value": "free"}]}`, false},
+		{"not", `{"not": {"attribute": "country", "operator": "eq", "value": "CA"}}`, true},
+		{"not over an implicit and", `{"not": [{"attribute": "country", "operator": "eq", "value": "US"}, {"attribute": "plan", "operator": "eq", "value": "pro"}]}`, false},
+		{"nested", `{"or": [
+			{"and": [{"attribute": "country", "operator": "eq", "value": "CA"}, {"attribute": "plan", "operator": "eq", "value": "pro"}]},
+			{"and": [{"attribute": "country", "operator": "eq", "value": "US"}, {"not": {"attribute": "age", "operator": "lt", "value": 21}}]}
+		]}`, true},
+		{"nested without a match", `[{"or": [{"attribute": "plan", "operator": "eq", "value": "team"}, {"not": {"attribute": "country", "operator": "eq", "value": "US"}}]}, {"attribute": "age", "operator": "gt", "value": 18}]`, false},
+	}
+
+	compiler := NewCompiler()
+	for _, tt := range tests {
+		plan, err := compileIf(t, compiler, tt.if_)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := compiler.EvaluateRule(&plan.Rules[0], context); got != tt.want {
+			t.Errorf("%s: EvaluateRule = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCompileRejectsAmbiguousLogicalNodes(t *testing.T) {
+	tests := []string{
+		`{"and": [{"attribute": "a", "operator": "eq", "value": 1}], "or": [{"attribute": "b", "operator": "eq", "value": 2}]}`,
+		`{"or": [{"attribute": "a", "operator": "eq", "value": 1}], "not": {"attribute": "b", "operator": "eq", "value": 2}}`,
+		`{"not": {"attribute": "a", "operator": "eq", "value": 1}, "attribute": "b", "operator": "eq", "value": 2}`,
+		`[{"attribute": "a", "operator": "eq", "value": 1}, {"or": [{"attribute": "b", "operator": "eq", "value": 2}], "attribute": "c"}]`,
+	}
+
+	compiler := NewCompiler()
+	for _, ifClause := range tests {
+		if _, err := compileIf(t, compiler, ifClause); err == nil {
+			t.Errorf("expected %s to be rejected", ifClause)
+		}
+	}
+
+	// Plans deserialized from elsewhere are checked when prepared and linted
+	plan := &CompiledPlan{FlagKey: "flag", Rules: []CompiledRule{{ID: "mixed", TrafficAllocation: 1, Action: CompiledAction{VariationKey: "on"}, Conditions: []CompiledCondition{{
+		Or:  []CompiledCondition{{Attribute: "a", Operator: "eq", Value: 1.0}},
+		Not: &CompiledCondition{Attribute: "b", Operator: "eq", Value: 2.0},
+	}}}}}
+	if err := compiler.PreparePlan(plan); err == nil {
+		t.Error("PreparePlan accepted a node with both or and not")
+	}
+	if report := compiler.Lint(plan, nil); !report.HasErrors() {
+		t.Error("Lint accepted a node with both or and not")
+	}
+	if compiler.EvaluateRule(&plan.Rules[0], map[string]interface{}{"a": 1.0, "b": 3.0}) {
+		t.Error("a node with both or and not matched")
+	}
+}
+
+func TestEvaluateLogicalShortCircuits(t *testing.T) {
+	tests := []struct {
+		if_   string
+		want  bool
+		calls int
+	}{
+		{`[{"attribute": "x", "operator": "test_dsl_probe", "value": false}, {"attribute": "x", "operator": "test_dsl_probe", "value": true}]`, false, 1},
+		{`{"and": [{"attribute": "x", "operator": "test_dsl_probe", "value": false}, {"attribute": "x", "operator": "test_dsl_probe", "value": true}]}`, false, 1},
+		{`{"or": [{"attribute": "x", "operator": "test_dsl_probe", "value": true}, {"attribute": "x", "operator": "test_dsl_probe", "value": false}]}`, true, 1},
+		{`{"or": [{"attribute": "x", "operator": "test_dsl_probe", "value": false}, {"attribute": "x", "operator": "test_dsl_probe", "value": true}]}`, true, 2},
+		{`{"not": {"or": [{"attribute": "x", "operator": "test_dsl_probe", "value": true}, {"attribute": "x", "operator": "test_dsl_probe", "value": true}]}}`, false, 1},
+	}
+
+	compiler := NewCompiler()
+	context := map[string]interface{}{"x": 1}
+	for _, tt := range tests {
+		plan, err := compileIf(t, compiler, tt.if_)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.if_, err)
+		}
+		countedCalls = 0
+		if got := compiler.EvaluateRule(&plan.Rules[0], context); got != tt.want {
+			t.Errorf("%s: EvaluateRule = %v, want %v", tt.if_, got, tt.want)
+		}
+		if countedCalls != tt.calls {
+			t.Errorf("%s: evaluated %d comparisons, want %d", strings.Join(strings.Fields(tt.if_), " "), countedCalls, tt.calls)
+		}
+	}
+}
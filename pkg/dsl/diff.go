@@ -0,0 +1,653 @@
+package dsl
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/Sidd-007/feature-flag-platform/pkg/bucketing"
+	"github.com/Sidd-007/feature-flag-platform/pkg/hashing"
+)
+
+// Kinds of change reported for a rule
+const (
+	DiffAdded             = "added"
+	DiffRemoved           = "removed"
+	DiffMoved             = "moved"
+	DiffConditions        = "conditions"
+	DiffTrafficAllocation = "traffic_allocation"
+	DiffAllocation        = "allocation"
+	DiffBucketBy          = "bucket_by"
+	DiffHashVersion       = "hash_version"
+)
+
+// PlanDiff describes the semantic differences between two plans for a flag
+type PlanDiff struct {
+	FlagKey string `json:"flag_key"`
+
+	// OldDefault and NewDefault are set when the default value changed
+	OldDefault interface{} `json:"old_default,omitempty"`
+	NewDefault interface{} `json:"new_default,omitempty"`
+
+	// OldBucketBy and NewBucketBy are set when the plan's bucket_by changed
+	OldBucketBy *string `json:"old_bucket_by,omitempty"`
+	NewBucketBy *string `json:"new_bucket_by,omitempty"`
+
+	// OldHashVersion and NewHashVersion are set when the plan's hash version
+	// changed, which reshuffles every rule
+	OldHashVersion *hashing.Version `json:"old_hash_version,omitempty"`
+	NewHashVersion *hashing.Version `json:"new_hash_version,omitempty"`
+
+	// Flag-level settings evaluated before rules. They are only compared by
+	// DiffFlags and set when they changed.
+	OldOn                *bool    `json:"old_on,omitempty"`
+	NewOn                *bool    `json:"new_on,omitempty"`
+	OldOffVariation      *string  `json:"old_off_variation,omitempty"`
+	NewOffVariation      *string  `json:"new_off_variation,omitempty"`
+	OldStatus            *string  `json:"old_status,omitempty"`
+	NewStatus            *string  `json:"new_status,omitempty"`
+	OldTrafficAllocation *float64 `json:"old_traffic_allocation,omitempty"`
+	NewTrafficAllocation *float64 `json:"new_traffic_allocation,omitempty"`
+
+	// Targets lists the variations whose targeted user keys changed; a key
+	// moved between variations is removed from one and added to the other
+	Targets             []TargetChange `json:"targets,omitempty"`
+	AddedExcludedKeys   []string       `json:"added_excluded_keys,omitempty"`
+	RemovedExcludedKeys []string       `json:"removed_excluded_keys,omitempty"`
+
+	// Prerequisites lists the prerequisites that were added, removed or now
+	// require a different variation
+	Prerequisites []PrerequisiteChange `json:"prerequisites,omitempty"`
+
+	// Rules lists every rule that changed, in the order of the new plan with
+	// removed rules at their old position
+	Rules []RuleDiff `json:"rules"`
+
+	// MaxReassignedFraction is the largest share of any one population that
+	// is served a different variation: a rule's bucket space, for estimable
+	// rules, or all users, for changes to the default, the kill switch, the
+	// status and the flag's traffic allocation. It is not a share of all
+	// users, since how many users reach each rule is unknown, and changes to
+	// targets and prerequisites are not estimated.
+	MaxReassignedFraction float64 `json:"max_reassigned_fraction"`
+}
+
+// HasChanges reports whether the plans differ
+func (d *PlanDiff) HasChanges() bool {
+	return len(d.Rules) > 0 || d.OldDefault != nil || d.NewDefault != nil || d.OldBucketBy != nil || d.OldHashVersion != nil ||
+		d.OldOn != nil || d.OldOffVariation != nil || d.OldStatus != nil || d.OldTrafficAllocation != nil ||
+		len(d.Targets) > 0 || len(d.AddedExcludedKeys) > 0 || len(d.RemovedExcludedKeys) > 0 || len(d.Prerequisites) > 0
+}
+
+// TargetChange lists the user keys added to and removed from a variation's
+// targets, sorted
+type TargetChange struct {
+	VariationKey string   `json:"variation_key"`
+	Added        []string `json:"added,omitempty"`
+	Removed      []string `json:"removed,omitempty"`
+}
+
+// PrerequisiteChange is the variation a prerequisite flag must serve before
+// and after the change; empty when the prerequisite is absent
+type PrerequisiteChange struct {
+	FlagKey         string `json:"flag_key"`
+	OldVariationKey string `json:"old_variation_key,omitempty"`
+	NewVariationKey string `json:"new_variation_key,omitempty"`
+}
+
+// RuleDiff describes how a single rule changed. Positions are zero-based and
+// omitted for the plan the rule is absent from.
+type RuleDiff struct {
+	RuleID      string   `json:"rule_id"`
+	Changes     []string `json:"changes"`
+	OldPosition *int     `json:"old_position,omitempty"`
+	NewPosition *int     `json:"new_position,omitempty"`
+
+	// Conditions are given in DSL form
+	OldConditions string `json:"old_conditions,omitempty"`
+	NewConditions string `json:"new_conditions,omitempty"`
+
+	OldTrafficAllocation float64 `json:"old_traffic_allocation"`
+	NewTrafficAllocation float64 `json:"new_traffic_allocation"`
+
+	// OldBucketBy and NewBucketBy are the attributes the rule buckets by,
+	// inherited from the plan unless overridden; empty for the user key
+	OldBucketBy string `json:"old_bucket_by,omitempty"`
+	NewBucketBy string `json:"new_bucket_by,omitempty"`
+
+	// Allocation lists the variations whose share of the rule changed
+	Allocation []AllocationChange `json:"allocation,omitempty"`
+
+	// ReassignedFraction is the fraction of the rule's bucket space served a
+	// different variation, including buckets that start or stop falling
+	// through to later rules. It is only estimated when the rule exists in
+	// both plans with the same conditions; a move changes which users reach
+	// the rule, which is not reflected. When the bucketing key or the hash
+	// version changes, old and new buckets are treated as independent.
+	ReassignedFraction *float64 `json:"reassigned_fraction,omitempty"`
+}
+
+// AllocationChange is a variation's share of a rule, as a percentage, before
+// and after the change
+type AllocationChange struct {
+	VariationKey string  `json:"variation_key"`
+	OldPercent   float64 `json:"old_percent"`
+	NewPercent   float64 `json:"new_percent"`
+}
+
+// DiffFlags compares two bucketing flag configurations: their plans and the
+// flag-level settings evaluated before rules
+func (c *Compiler) DiffFlags(before, after *bucketing.FlagConfig) *PlanDiff {
+	now := c.clock.Now()
+	diff := c.Diff(PlanFromFlag(before, now), PlanFromFlag(after, now))
+
+	if before.IsOn() != after.IsOn() {
+		oldOn, newOn := before.IsOn(), after.IsOn()
+		diff.OldOn, diff.NewOn = &oldOn, &newOn
+	}
+	if before.OffVariationKey() != after.OffVariationKey() {
+		oldOff, newOff := before.OffVariationKey(), after.OffVariationKey()
+		diff.OldOffVariation, diff.NewOffVariation = &oldOff, &newOff
+	}
+	if before.Status != after.Status {
+		diff.OldStatus, diff.NewStatus = &before.Status, &after.Status
+	}
+	if before.TrafficAllocation != after.TrafficAllocation {
+		diff.OldTrafficAllocation, diff.NewTrafficAllocation = &before.TrafficAllocation, &after.TrafficAllocation
+	}
+
+	diff.Targets = targetChanges(before.Targets, after.Targets)
+	diff.AddedExcludedKeys = keysAdded(before.ExcludedKeys, after.ExcludedKeys)
+	diff.RemovedExcludedKeys = keysAdded(after.ExcludedKeys, before.ExcludedKeys)
+	diff.Prerequisites = prerequisiteChanges(before.Prerequisites, after.Prerequisites)
+
+	// Flags that are off or inactive serve one variation to everyone,
+	// whatever their rules
+	oldFixed, oldIsFixed := fixedVariation(before)
+	newFixed, newIsFixed := fixedVariation(after)
+	switch {
+	case oldIsFixed && newIsFixed:
+		diff.MaxReassignedFraction = 0
+		if oldFixed != newFixed {
+			diff.MaxReassignedFraction = 1
+		}
+	case oldIsFixed || newIsFixed:
+		diff.MaxReassignedFraction = 1
+	default:
+		if fraction := trafficReassignedFraction(before, after); fraction > diff.MaxReassignedFraction {
+			diff.MaxReassignedFraction = fraction
+		}
+	}
+
+	return diff
+}
+
+// fixedVariation returns the variation a flag serves to everyone when it is
+// off or not active, and false when it evaluates its rules
+func fixedVariation(flag *bucketing.FlagConfig) (string, bool) {
+	if !flag.IsOn() {
+		return flag.OffVariationKey(), true
+	}
+	if flag.Status != "active" {
+		return flag.DefaultVariation, true
+	}
+	return "", false
+}
+
+// trafficReassignedFraction returns the fraction of all users whose flag
+// traffic allocation check changes outcome. Users who start or stop being
+// excluded may still be served the default by the rules, so this is an upper
+// bound. When the bucketing key or the hash version changes, old and new
+// buckets are treated as independent.
+func trafficReassignedFraction(before, after *bucketing.FlagConfig) float64 {
+	oldTraffic := math.Max(0, math.Min(1, before.TrafficAllocation))
+	newTraffic := math.Max(0, math.Min(1, after.TrafficAllocation))
+
+	rebucketed := before.BucketBy != after.BucketBy ||
+		hashing.ForVersion(before.HashVersion).Version() != hashing.ForVersion(after.HashVersion).Version()
+	if rebucketed {
+		return oldTraffic*(1-newTraffic) + newTraffic*(1-oldTraffic)
+	}
+	return math.Abs(newTraffic - oldTraffic)
+}
+
+// targetChanges compares the user keys targeted for each variation, in the
+// order the variations are first targeted
+func targetChanges(before, after []bucketing.Target) []TargetChange {
+	oldKeys := targetKeys(before)
+	newKeys := targetKeys(after)
+
+	var variations []string
+	seen := make(map[string]bool)
+	for _, targets := range [][]bucketing.Target{before, after} {
+		for _, target := range targets {
+			if !seen[target.VariationKey] {
+				seen[target.VariationKey] = true
+				variations = append(variations, target.VariationKey)
+			}
+		}
+	}
+
+	var changes []TargetChange
+	for _, variationKey := range variations {
+		change := TargetChange{
+			VariationKey: variationKey,
+			Added:        keysAdded(oldKeys[variationKey], newKeys[variationKey]),
+			Removed:      keysAdded(newKeys[variationKey], oldKeys[variationKey]),
+		}
+		if len(change.Added) > 0 || len(change.Removed) > 0 {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// targetKeys returns the keys targeted for each variation. A key is only
+// served the first variation that targets it, as in MatchTargets.
+func targetKeys(targets []bucketing.Target) map[string]bucketing.KeySet {
+	keys := make(map[string]bucketing.KeySet)
+	targeted := make(bucketing.KeySet)
+	for _, target := range targets {
+		for _, key := range target.Keys.Keys() {
+			if targeted.Contains(key) {
+				continue
+			}
+			targeted.Add(key)
+			if keys[target.VariationKey] == nil {
+				keys[target.VariationKey] = make(bucketing.KeySet)
+			}
+			keys[target.VariationKey].Add(key)
+		}
+	}
+	return keys
+}
+
+// keysAdded returns the keys of after missing from before, sorted
+func keysAdded(before, after bucketing.KeySet) []string {
+	var added []string
+	for _, key := range after.Keys() {
+		if !before.Contains(key) {
+			added = append(added, key)
+		}
+	}
+	return added
+}
+
+// prerequisiteChanges compares prerequisites by flag key, in the order of
+// the new prerequisites with removed ones last. Only the first of several
+// prerequisites on the same flag is matched.
+func prerequisiteChanges(before, after []bucketing.Prerequisite) []PrerequisiteChange {
+	oldIndex := prerequisiteIndex(before)
+	newIndex := prerequisiteIndex(after)
+
+	var changes []PrerequisiteChange
+	for i, prereq := range after {
+		if newIndex[prereq.FlagKey] != i {
+			continue
+		}
+		change := PrerequisiteChange{FlagKey: prereq.FlagKey, NewVariationKey: prereq.VariationKey}
+		if j, ok := oldIndex[prereq.FlagKey]; ok {
+			change.OldVariationKey = before[j].VariationKey
+		}
+		if change.OldVariationKey != change.NewVariationKey {
+			changes = append(changes, change)
+		}
+	}
+	for i, prereq := range before {
+		if _, kept := newIndex[prereq.FlagKey]; !kept && oldIndex[prereq.FlagKey] == i {
+			changes = append(changes, PrerequisiteChange{FlagKey: prereq.FlagKey, OldVariationKey: prereq.VariationKey})
+		}
+	}
+	return changes
+}
+
+// prerequisiteIndex maps prerequisite flag keys to their first position
+func prerequisiteIndex(prereqs []bucketing.Prerequisite) map[string]int {
+	index := make(map[string]int, len(prereqs))
+	for i := range prereqs {
+		if _, seen := index[prereqs[i].FlagKey]; !seen {
+			index[prereqs[i].FlagKey] = i
+		}
+	}
+	return index
+}
+
+// Diff compares two plans for the same flag. Rules are matched by ID; rules
+// without an ID are matched by position.
+func (c *Compiler) Diff(before, after *CompiledPlan) *PlanDiff {
+	diff := &PlanDiff{FlagKey: after.FlagKey, Rules: []RuleDiff{}}
+	if diff.FlagKey == "" {
+		diff.FlagKey = before.FlagKey
+	}
+
+	if fmt.Sprint(before.DefaultValue) != fmt.Sprint(after.DefaultValue) {
+		diff.OldDefault = before.DefaultValue
+		diff.NewDefault = after.DefaultValue
+		diff.MaxReassignedFraction = 1
+	}
+
+	if before.BucketBy != after.BucketBy {
+		diff.OldBucketBy = &before.BucketBy
+		diff.NewBucketBy = &after.BucketBy
+	}
+
+	oldHasher := hashing.ForVersion(before.HashVersion)
+	newHasher := hashing.ForVersion(after.HashVersion)
+	if oldHasher.Version() != newHasher.Version() {
+		oldVersion, newVersion := oldHasher.Version(), newHasher.Version()
+		diff.OldHashVersion = &oldVersion
+		diff.NewHashVersion = &newVersion
+	}
+
+	oldIndex := ruleIndex(before.Rules)
+	newIndex := ruleIndex(after.Rules)
+	moved := movedRules(after.Rules, oldIndex)
+
+	// Removed rules are reported where they used to be
+	nextOld := 0
+	flushRemoved := func(upTo int) {
+		for ; nextOld < upTo; nextOld++ {
+			key := ruleKey(&before.Rules[nextOld], nextOld)
+			if _, kept := newIndex[key]; kept {
+				continue
+			}
+			rule := &before.Rules[nextOld]
+			diff.Rules = append(diff.Rules, RuleDiff{
+				RuleID:               rule.ID,
+				Changes:              []string{DiffRemoved},
+				OldPosition:          intPtr(nextOld),
+				OldConditions:        FormatConditions(rule.Conditions),
+				OldTrafficAllocation: rule.TrafficAllocation,
+				OldBucketBy:          effectiveBucketBy(before, rule),
+				Allocation:           allocationChanges(allocationOf(oldHasher, rule), nil),
+			})
+		}
+	}
+
+	for j := range after.Rules {
+		rule := &after.Rules[j]
+		key := ruleKey(rule, j)
+
+		i, existed := oldIndex[key]
+		if !existed {
+			diff.Rules = append(diff.Rules, RuleDiff{
+				RuleID:               rule.ID,
+				Changes:              []string{DiffAdded},
+				NewPosition:          intPtr(j),
+				NewConditions:        FormatConditions(rule.Conditions),
+				NewTrafficAllocation: rule.TrafficAllocation,
+				NewBucketBy:          effectiveBucketBy(after, rule),
+				Allocation:           allocationChanges(nil, allocationOf(newHasher, rule)),
+			})
+			continue
+		}
+
+		flushRemoved(i)
+		ruleDiff, changed := diffRule(oldHasher, newHasher, &before.Rules[i], rule, moved[key],
+			effectiveBucketBy(before, &before.Rules[i]), effectiveBucketBy(after, rule))
+		if changed {
+			ruleDiff.OldPosition = intPtr(i)
+			ruleDiff.NewPosition = intPtr(j)
+			diff.Rules = append(diff.Rules, ruleDiff)
+			if ruleDiff.ReassignedFraction != nil && *ruleDiff.ReassignedFraction > diff.MaxReassignedFraction {
+				diff.MaxReassignedFraction = *ruleDiff.ReassignedFraction
+			}
+		}
+	}
+	flushRemoved(len(before.Rules))
+
+	return diff
+}
+
+// diffRule compares two versions of the same rule, bucketed by the hashers of
+// their plans
+func diffRule(oldHasher, newHasher *hashing.Hasher, before, after *CompiledRule, moved bool, oldBucketBy, newBucketBy string) (RuleDiff, bool) {
+	diff := RuleDiff{
+		RuleID:               after.ID,
+		Changes:              []string{},
+		OldTrafficAllocation: before.TrafficAllocation,
+		NewTrafficAllocation: after.TrafficAllocation,
+		OldBucketBy:          oldBucketBy,
+		NewBucketBy:          newBucketBy,
+	}
+
+	if moved {
+		diff.Changes = append(diff.Changes, DiffMoved)
+	}
+
+	oldConditions := FormatConditions(before.Conditions)
+	newConditions := FormatConditions(after.Conditions)
+	if oldConditions != newConditions {
+		diff.Changes = append(diff.Changes, DiffConditions)
+		diff.OldConditions = oldConditions
+		diff.NewConditions = newConditions
+	}
+
+	if before.TrafficAllocation != after.TrafficAllocation {
+		diff.Changes = append(diff.Changes, DiffTrafficAllocation)
+	}
+
+	diff.Allocation = allocationChanges(allocationOf(oldHasher, before), allocationOf(newHasher, after))
+	if len(diff.Allocation) > 0 {
+		diff.Changes = append(diff.Changes, DiffAllocation)
+	}
+
+	if oldBucketBy != newBucketBy {
+		diff.Changes = append(diff.Changes, DiffBucketBy)
+	}
+
+	rehashed := oldHasher.Version() != newHasher.Version()
+	if rehashed {
+		diff.Changes = append(diff.Changes, DiffHashVersion)
+	}
+
+	if oldConditions != newConditions {
+		return diff, true
+	}
+
+	// Bucketing by a different key or hash reshuffles users independently of
+	// their old buckets, so only the outcome distributions can be compared
+	if oldBucketBy != newBucketBy || rehashed {
+		fraction := reshuffledFraction(oldHasher, newHasher, before, after)
+		diff.ReassignedFraction = &fraction
+	} else {
+		fraction := reassignedFraction(newHasher, before, after)
+		if fraction > 0 || len(diff.Changes) > 0 {
+			diff.ReassignedFraction = &fraction
+		}
+		// Rollouts can be reshuffled without changing any variation's share
+		if fraction > 0 && len(diff.Allocation) == 0 && before.TrafficAllocation == after.TrafficAllocation {
+			diff.Changes = append(diff.Changes, DiffAllocation)
+		}
+	}
+
+	return diff, len(diff.Changes) > 0
+}
+
+// reassignedFraction returns the fraction of a rule's bucket space whose
+// outcome differs between two versions of the rule. Traffic allocation and
+// rollouts are both decided by the rule bucket, so the outcome of every
+// bucket can be compared directly.
+func reassignedFraction(hasher *hashing.Hasher, before, after *CompiledRule) float64 {
+	changed := 0
+	for bucket := 0; bucket < hasher.Buckets(); bucket++ {
+		if ruleOutcome(hasher, before, bucket) != ruleOutcome(hasher, after, bucket) {
+			changed++
+		}
+	}
+	return float64(changed) / float64(hasher.Buckets())
+}
+
+// reshuffledFraction returns the expected fraction of a rule's users whose
+// outcome differs when the rule is bucketed by a different key or hash,
+// treating old and new buckets as independent
+func reshuffledFraction(oldHasher, newHasher *hashing.Hasher, before, after *CompiledRule) float64 {
+	oldCounts := make(map[string]int)
+	newCounts := make(map[string]int)
+	for bucket := 0; bucket < oldHasher.Buckets(); bucket++ {
+		oldCounts[ruleOutcome(oldHasher, before, bucket)]++
+	}
+	for bucket := 0; bucket < newHasher.Buckets(); bucket++ {
+		newCounts[ruleOutcome(newHasher, after, bucket)]++
+	}
+
+	same := 0.0
+	for outcome, count := range oldCounts {
+		same += float64(count) / float64(oldHasher.Buckets()) * float64(newCounts[outcome]) / float64(newHasher.Buckets())
+	}
+	return 1 - same
+}
+
+// ruleOutcome returns the variation a rule serves for a rule bucket, or an
+// empty string when the bucket falls through to later rules
+func ruleOutcome(hasher *hashing.Hasher, rule *CompiledRule, bucket int) string {
+	if rule.TrafficAllocation < 1.0 && !hasher.IsInPercentageRange(bucket, rule.TrafficAllocation*100) {
+		return ""
+	}
+	if rule.Action.Rollout == nil {
+		return rule.Action.VariationKey
+	}
+	for _, v := range rule.Action.Rollout.Variations {
+		if v.Contains(bucket) {
+			return v.VariationKey
+		}
+	}
+	return ""
+}
+
+// variationShare is a variation's percentage of the buckets a rule serves
+type variationShare struct {
+	key     string
+	percent float64
+}
+
+// allocationOf returns the share of each variation a rule serves, in the
+// order the variations appear
+func allocationOf(hasher *hashing.Hasher, rule *CompiledRule) []variationShare {
+	if rule.Action.Rollout == nil {
+		if rule.Action.VariationKey == "" {
+			return nil
+		}
+		return []variationShare{{rule.Action.VariationKey, 100}}
+	}
+
+	var shares []variationShare
+	for i := range rule.Action.Rollout.Variations {
+		v := &rule.Action.Rollout.Variations[i]
+		percent := float64(v.Size()) * 100 / float64(hasher.Buckets())
+		if i := shareIndex(shares, v.VariationKey); i >= 0 {
+			shares[i].percent += percent
+		} else {
+			shares = append(shares, variationShare{v.VariationKey, percent})
+		}
+	}
+	return shares
+}
+
+// allocationChanges pairs the shares of two allocations and returns those
+// that differ
+func allocationChanges(before, after []variationShare) []AllocationChange {
+	var changes []AllocationChange
+	for _, old := range before {
+		change := AllocationChange{VariationKey: old.key, OldPercent: old.percent}
+		if i := shareIndex(after, old.key); i >= 0 {
+			change.NewPercent = after[i].percent
+		}
+		if change.OldPercent != change.NewPercent {
+			changes = append(changes, change)
+		}
+	}
+	for _, cur := range after {
+		if shareIndex(before, cur.key) < 0 && cur.percent != 0 {
+			changes = append(changes, AllocationChange{VariationKey: cur.key, NewPercent: cur.percent})
+		}
+	}
+	return changes
+}
+
+func shareIndex(shares []variationShare, key string) int {
+	for i := range shares {
+		if shares[i].key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// effectiveBucketBy returns the attribute a rule buckets by
+func effectiveBucketBy(plan *CompiledPlan, rule *CompiledRule) string {
+	if rule.BucketBy != "" {
+		return rule.BucketBy
+	}
+	return plan.BucketBy
+}
+
+// ruleKey identifies a rule across plans
+func ruleKey(rule *CompiledRule, position int) string {
+	if rule.ID == "" {
+		return fmt.Sprintf("#%d", position)
+	}
+	return rule.ID
+}
+
+// ruleIndex maps rule keys to their position. Only the first of several
+// rules sharing an ID is matched.
+func ruleIndex(rules []CompiledRule) map[string]int {
+	index := make(map[string]int, len(rules))
+	for i := range rules {
+		key := ruleKey(&rules[i], i)
+		if _, seen := index[key]; !seen {
+			index[key] = i
+		}
+	}
+	return index
+}
+
+// movedRules returns the rules kept in both plans whose relative order
+// changed. The longest run of rules that kept their relative order is
+// considered stationary, so moving one rule reports only that rule.
+func movedRules(after []CompiledRule, oldIndex map[string]int) map[string]bool {
+	// Old positions of the kept rules, in new order
+	var keys []string
+	var positions []int
+	for j := range after {
+		key := ruleKey(&after[j], j)
+		if i, ok := oldIndex[key]; ok {
+			keys = append(keys, key)
+			positions = append(positions, i)
+		}
+	}
+
+	// Longest increasing subsequence of old positions
+	n := len(positions)
+	length := make([]int, n)
+	prev := make([]int, n)
+	best := -1
+	for j := 0; j < n; j++ {
+		length[j], prev[j] = 1, -1
+		for k := 0; k < j; k++ {
+			if positions[k] < positions[j] && length[k]+1 > length[j] {
+				length[j], prev[j] = length[k]+1, k
+			}
+		}
+		if best < 0 || length[j] > length[best] {
+			best = j
+		}
+	}
+
+	stationary := make(map[string]bool, n)
+	for j := best; j >= 0; j = prev[j] {
+		stationary[keys[j]] = true
+	}
+
+	moved := make(map[string]bool)
+	for _, key := range keys {
+		if !stationary[key] {
+			moved[key] = true
+		}
+	}
+	return moved
+}
+
+func intPtr(v int) *int {
+	return &v
+}
@@ -0,0 +1,116 @@
+package dsl
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Sidd-007/feature-flag-platform/pkg/bucketing"
+)
+
+func diffTestFlag() *bucketing.FlagConfig {
+	return &bucketing.FlagConfig{
+		Key:               "checkout",
+		DefaultVariation:  "off",
+		Status:            "active",
+		TrafficAllocation: 1.0,
+		Rules: []bucketing.Rule{
+			{ID: "pro", VariationKey: "on", TrafficAllocation: 1.0, Conditions: []bucketing.Condition{
+				{Attribute: "plan", Operator: "eq", Value: "pro"},
+			}},
+		},
+		Targets: []bucketing.Target{
+			{VariationKey: "on", Keys: bucketing.NewKeySet("alice", "bob")},
+		},
+		ExcludedKeys:  bucketing.NewKeySet("mallory"),
+		Prerequisites: []bucketing.Prerequisite{{FlagKey: "payments", VariationKey: "on"}},
+	}
+}
+
+func TestDiffFlagsUnchanged(t *testing.T) {
+	diff := NewCompiler().DiffFlags(diffTestFlag(), diffTestFlag())
+	if diff.HasChanges() {
+		t.Errorf("identical flags reported changes: %+v", diff)
+	}
+}
+
+func TestDiffFlagsFlagSettings(t *testing.T) {
+	before, after := diffTestFlag(), diffTestFlag()
+	after.TrafficAllocation = 0.25
+	after.OffVariation = "on"
+	after.Targets = []bucketing.Target{
+		{VariationKey: "on", Keys: bucketing.NewKeySet("alice")},
+		{VariationKey: "off", Keys: bucketing.NewKeySet("bob", "carol")},
+	}
+	after.ExcludedKeys = bucketing.NewKeySet("eve")
+	after.Prerequisites = []bucketing.Prerequisite{{FlagKey: "payments", VariationKey: "v2"}, {FlagKey: "billing", VariationKey: "on"}}
+
+	diff := NewCompiler().DiffFlags(before, after)
+	if !diff.HasChanges() || len(diff.Rules) != 0 {
+		t.Fatalf("expected flag-level changes only, got %+v", diff)
+	}
+
+	if diff.OldTrafficAllocation == nil || *diff.OldTrafficAllocation != 1.0 || *diff.NewTrafficAllocation != 0.25 {
+		t.Errorf("traffic allocation: got %v -> %v", diff.OldTrafficAllocation, diff.NewTrafficAllocation)
+	}
+	if diff.OldOffVariation == nil || *diff.OldOffVariation != "off" || *diff.NewOffVariation != "on" {
+		t.Errorf("off variation: got %v -> %v", diff.OldOffVariation, diff.NewOffVariation)
+	}
+	if diff.OldOn != nil || diff.OldStatus != nil {
+		t.Errorf("unexpected kill switch or status change: %+v", diff)
+	}
+
+	wantTargets := []TargetChange{
+		{VariationKey: "on", Removed: []string{"bob"}},
+		{VariationKey: "off", Added: []string{"bob", "carol"}},
+	}
+	if !reflect.DeepEqual(diff.Targets, wantTargets) {
+		t.Errorf("targets: got %+v, want %+v", diff.Targets, wantTargets)
+	}
+	if !reflect.DeepEqual(diff.AddedExcludedKeys, []string{"eve"}) || !reflect.DeepEqual(diff.RemovedExcludedKeys, []string{"mallory"}) {
+		t.Errorf("excluded keys: got +%v -%v", diff.AddedExcludedKeys, diff.RemovedExcludedKeys)
+	}
+
+	wantPrereqs := []PrerequisiteChange{
+		{FlagKey: "payments", OldVariationKey: "on", NewVariationKey: "v2"},
+		{FlagKey: "billing", NewVariationKey: "on"},
+	}
+	if !reflect.DeepEqual(diff.Prerequisites, wantPrereqs) {
+		t.Errorf("prerequisites: got %+v, want %+v", diff.Prerequisites, wantPrereqs)
+	}
+
+	// Lowering traffic from 100% to 25% moves three quarters of all users
+	// to the default; the off variation is not served while the flag is on
+	if diff.MaxReassignedFraction != 0.75 {
+		t.Errorf("MaxReassignedFraction = %v, want 0.75", diff.MaxReassignedFraction)
+	}
+}
+
+func TestDiffFlagsKillSwitch(t *testing.T) {
+	off := false
+	on, killed := diffTestFlag(), diffTestFlag()
+	killed.On = &off
+
+	diff := NewCompiler().DiffFlags(on, killed)
+	if diff.OldOn == nil || !*diff.OldOn || *diff.NewOn {
+		t.Errorf("kill switch: got %v -> %v", diff.OldOn, diff.NewOn)
+	}
+	if diff.MaxReassignedFraction != 1 {
+		t.Errorf("MaxReassignedFraction = %v, want 1", diff.MaxReassignedFraction)
+	}
+
+	// Rule changes of a flag that stays off reassign no one
+	changed := diffTestFlag()
+	changed.On = &off
+	changed.Rules[0].VariationKey = "off"
+	diff = NewCompiler().DiffFlags(killed, changed)
+	if len(diff.Rules) != 1 || diff.MaxReassignedFraction != 0 {
+		t.Errorf("rules of an off flag: got %d rule changes, MaxReassignedFraction = %v", len(diff.Rules), diff.MaxReassignedFraction)
+	}
+
+	// Unless the off variation changes
+	changed.OffVariation = "on"
+	diff = NewCompiler().DiffFlags(killed, changed)
+	if diff.MaxReassignedFraction != 1 {
+		t.Errorf("off variation of an off flag: MaxReassignedFraction = %v, want 1", diff.MaxReassignedFraction)
+	}
+}
@@ -0,0 +1,206 @@
+package dsl
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Sidd-007/feature-flag-platform/pkg/bucketing"
+	"github.com/Sidd-007/feature-flag-platform/pkg/hashing"
+)
+
+// PlanFromFlag converts a bucketing flag configuration into a plan with the
+// same rules, in order. The conversion is structural only: operators and
+// operands are not validated and no matchers are compiled, so the result can
+// describe flags that would fail to compile. Ramped rollouts take their
+// weights at now.
+func PlanFromFlag(flag *bucketing.FlagConfig, now time.Time) *CompiledPlan {
+	plan := &CompiledPlan{
+		FlagKey:      flag.Key,
+		Rules:        make([]CompiledRule, 0, len(flag.Rules)),
+		DefaultValue: flag.DefaultVariation,
+		BucketBy:     flag.BucketBy,
+		Metadata:     make(map[string]string),
+		HashVersion:  flag.HashVersion,
+	}
+	hasher := hashing.ForVersion(flag.HashVersion)
+
+	for i, rule := range flag.Rules {
+		compiled := CompiledRule{
+			ID:                rule.ID,
+			Conditions:        conditionsFromBucketing(rule.Conditions),
+			TrafficAllocation: rule.TrafficAllocation,
+			BucketBy:          rule.BucketBy,
+			Priority:          i,
+		}
+
+		// A variation key takes precedence over a rollout, as in the bucketer
+		if rule.VariationKey == "" && rule.Rollout != nil {
+			compiled.Action = CompiledAction{Type: "rollout", Rollout: rolloutFromBucketing(rule.Rollout, hasher, now)}
+		} else {
+			compiled.Action = CompiledAction{Type: "variation", VariationKey: rule.VariationKey}
+		}
+
+		plan.Rules = append(plan.Rules, compiled)
+	}
+
+	return plan
+}
+
+// rolloutFromBucketing converts a bucketing rollout with its weights at now,
+// laying out buckets for the hasher
+func rolloutFromBucketing(rollout *bucketing.Rollout, hasher *hashing.Hasher, now time.Time) *CompiledRollout {
+	variations := bucketing.RolloutVariations(rollout, now)
+	compiled := &CompiledRollout{
+		Variations:    make([]RolloutVariation, len(variations)),
+		Ramp:          rollout.Ramp,
+		StickyBuckets: rollout.StickyBuckets,
+	}
+	for i, v := range variations {
+		compiled.Variations[i] = RolloutVariation{VariationKey: v.VariationKey, Weight: v.Weight}
+		compiled.TotalWeight += v.Weight
+	}
+	assignBuckets(compiled.Variations, hasher)
+
+	// Persisted ranges replace the contiguous layout
+	if rollout.Ramp == nil && bucketing.HasPersistedRanges(variations) {
+		for i, v := range variations {
+			compiled.Variations[i].StartBucket, compiled.Variations[i].EndBucket = 0, 0
+			compiled.Variations[i].Ranges = v.Ranges
+		}
+	}
+	return compiled
+}
+
+// conditionsFromBucketing converts a bucketing condition tree
+func conditionsFromBucketing(conditions []bucketing.Condition) []CompiledCondition {
+	if conditions == nil {
+		return nil
+	}
+
+	compiled := make([]CompiledCondition, len(conditions))
+	for i := range conditions {
+		compiled[i] = conditionFromBucketing(&conditions[i])
+	}
+	return compiled
+}
+
+// conditionFromBucketing converts a single bucketing condition node
+func conditionFromBucketing(condition *bucketing.Condition) CompiledCondition {
+	compiled := CompiledCondition{
+		Attribute: condition.Attribute,
+		Operator:  condition.Operator,
+		Value:     condition.Value,
+		And:       conditionsFromBucketing(condition.And),
+		Or:        conditionsFromBucketing(condition.Or),
+	}
+	if condition.Not != nil {
+		not := conditionFromBucketing(condition.Not)
+		compiled.Not = &not
+	}
+	return compiled
+}
+
+// ApplyPlan sets a flag's rules from its plan, so the bucketer evaluates the
+// flag as the plan describes: with the plan's matchers, when it was prepared
+// with Compiler.PreparePlan, and serving rollouts from the bucket ranges laid
+// out when the plan was compiled. Evaluators are shipped plans instead of
+// flag rules. Plans for another flag or hash version are rejected and leave
+// the flag untouched. Like FlagConfig.Compile, it must be called before the
+// flag is shared across goroutines.
+func ApplyPlan(plan *CompiledPlan, flag *bucketing.FlagConfig) error {
+	if plan.FlagKey != flag.Key {
+		return fmt.Errorf("plan is for flag %s", plan.FlagKey)
+	}
+
+	version := hashing.ForVersion(flag.HashVersion).Version()
+	if planVersion := hashing.ForVersion(plan.HashVersion).Version(); planVersion != version {
+		return fmt.Errorf("plan is laid out for hash version %d, flag uses %d", planVersion, version)
+	}
+
+	rules := make([]bucketing.Rule, len(plan.Rules))
+	for i := range plan.Rules {
+		rules[i] = ruleFromPlan(&plan.Rules[i], version)
+	}
+	flag.Rules = rules
+	return nil
+}
+
+// ruleFromPlan converts a plan rule into the flag rule the bucketer
+// evaluates, with rollouts laid out for the hash version
+func ruleFromPlan(compiled *CompiledRule, version hashing.Version) bucketing.Rule {
+	rule := bucketing.Rule{
+		ID:                compiled.ID,
+		Conditions:        conditionsFromPlan(compiled.Conditions),
+		TrafficAllocation: compiled.TrafficAllocation,
+		BucketBy:          compiled.BucketBy,
+	}
+
+	rollout := compiled.Action.Rollout
+	if rollout == nil {
+		rule.VariationKey = compiled.Action.VariationKey
+		return rule
+	}
+
+	rule.Rollout = &bucketing.Rollout{
+		Variations:    make([]bucketing.RolloutVariation, len(rollout.Variations)),
+		Ramp:          rollout.Ramp,
+		StickyBuckets: rollout.StickyBuckets,
+	}
+	for i, v := range rollout.Variations {
+		rule.Rollout.Variations[i] = bucketing.RolloutVariation{VariationKey: v.VariationKey, Weight: v.Weight, Ranges: v.Ranges}
+	}
+	// Ramps recompute their weights at evaluation
+	if rollout.Ramp == nil {
+		rule.Rollout.SetLayout(version, planLayout(rollout))
+	}
+	return rule
+}
+
+// conditionsFromPlan converts a plan condition tree into flag conditions
+// carrying the plan's matchers. Segment references have no matcher and are
+// resolved by the bucketer.
+func conditionsFromPlan(compiled []CompiledCondition) []bucketing.Condition {
+	if compiled == nil {
+		return nil
+	}
+	conditions := make([]bucketing.Condition, len(compiled))
+	for i := range compiled {
+		conditions[i] = conditionFromPlan(&compiled[i])
+	}
+	return conditions
+}
+
+// conditionFromPlan converts a single plan condition node
+func conditionFromPlan(compiled *CompiledCondition) bucketing.Condition {
+	condition := bucketing.Condition{
+		Attribute: compiled.Attribute,
+		Operator:  compiled.Operator,
+		Value:     compiled.Value,
+		And:       conditionsFromPlan(compiled.And),
+		Or:        conditionsFromPlan(compiled.Or),
+	}
+	if compiled.Not != nil {
+		not := conditionFromPlan(compiled.Not)
+		condition.Not = &not
+	}
+	if compiled.matcher != nil {
+		condition.SetMatcher(compiled.matcher)
+	}
+	return condition
+}
+
+// planLayout returns the buckets each variation of a compiled rollout is
+// served from
+func planLayout(rollout *CompiledRollout) []hashing.VariationBuckets {
+	layout := make([]hashing.VariationBuckets, len(rollout.Variations))
+	for i, v := range rollout.Variations {
+		layout[i].Key = v.VariationKey
+		switch {
+		case len(v.Ranges) > 0:
+			layout[i].Ranges = v.Ranges
+		case v.EndBucket > v.StartBucket:
+			layout[i].Ranges = []hashing.BucketRange{{Start: v.StartBucket, End: v.EndBucket, Percentage: v.Weight / rollout.TotalWeight * 100}}
+		}
+	}
+	return layout
+}
@@ -0,0 +1,103 @@
+package dsl
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Sidd-007/feature-flag-platform/pkg/bucketing"
+	"github.com/Sidd-007/feature-flag-platform/pkg/hashing"
+	"github.com/Sidd-007/feature-flag-platform/pkg/operators"
+)
+
+func planTestFlag() *bucketing.FlagConfig {
+	return &bucketing.FlagConfig{
+		Key:               "checkout",
+		Status:            "active",
+		DefaultVariation:  "off",
+		TrafficAllocation: 1,
+		HashVersion:       hashing.HashV1,
+		Variations: []bucketing.Variation{
+			{Key: "off", Value: false},
+			{Key: "on", Value: true},
+			{Key: "beta", Value: true},
+		},
+		Rules: []bucketing.Rule{
+			{ID: "staff", TrafficAllocation: 1, VariationKey: "beta", Conditions: []bucketing.Condition{
+				{Or: []bucketing.Condition{
+					{Attribute: "email", Operator: "contains", Value: "@example.com"},
+					{Not: &bucketing.Condition{Attribute: "plan", Operator: "in", Value: []interface{}{"free", "trial"}}},
+				}},
+			}},
+			{ID: "rollout", TrafficAllocation: 0.8, Rollout: &bucketing.Rollout{Variations: []bucketing.RolloutVariation{
+				{VariationKey: "off", Weight: 70},
+				{VariationKey: "on", Weight: 30},
+			}}},
+		},
+	}
+}
+
+func TestApplyPlanEvaluatesLikeFlagRules(t *testing.T) {
+	clock := operators.FixedClock(time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC))
+
+	original := planTestFlag()
+	if err := original.Compile(clock); err != nil {
+		t.Fatal(err)
+	}
+
+	plan := PlanFromFlag(planTestFlag(), clock.Now())
+	compiler := NewCompiler()
+	compiler.SetClock(clock)
+	if err := compiler.PreparePlan(plan); err != nil {
+		t.Fatal(err)
+	}
+	shipped := planTestFlag()
+	shipped.Rules = nil
+	if err := ApplyPlan(plan, shipped); err != nil {
+		t.Fatal(err)
+	}
+
+	bucketer := bucketing.NewBucketer()
+	bucketer.SetClock(clock)
+	plans := []string{"free", "trial", "pro"}
+	for i := 0; i < 2000; i++ {
+		context := &bucketing.Context{
+			UserKey:    fmt.Sprintf("user-%d", i),
+			Attributes: map[string]interface{}{"plan": plans[i%len(plans)], "email": fmt.Sprintf("user-%d@example.org", i)},
+		}
+		if i%7 == 0 {
+			context.Attributes["email"] = fmt.Sprintf("user-%d@example.com", i)
+		}
+
+		want, err := bucketer.EvaluateFlag(original, context, "salt", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := bucketer.EvaluateFlag(shipped, context, "salt", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.VariationKey != want.VariationKey {
+			t.Fatalf("%s: plan serves %s, rules serve %s", context.UserKey, got.VariationKey, want.VariationKey)
+		}
+	}
+}
+
+func TestApplyPlanRejectsMismatchedPlans(t *testing.T) {
+	plan := PlanFromFlag(planTestFlag(), time.Now())
+
+	other := planTestFlag()
+	other.Key = "other"
+	if err := ApplyPlan(plan, other); err == nil {
+		t.Error("expected a plan for another flag to be rejected")
+	}
+
+	rehashed := planTestFlag()
+	rehashed.HashVersion = hashing.HashV2
+	if err := ApplyPlan(plan, rehashed); err == nil {
+		t.Error("expected a plan laid out for another hash version to be rejected")
+	}
+	if len(rehashed.Rules) != 2 || rehashed.Rules[0].VariationKey != "beta" {
+		t.Error("a rejected plan changed the flag's rules")
+	}
+}
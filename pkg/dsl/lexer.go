@@ -0,0 +1,242 @@
+package dsl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// TokenType identifies the kind of a lexical token in the rule language
+type TokenType int
+
+const (
+	TokenEOF TokenType = iota
+	TokenIdent
+	TokenString
+	TokenNumber
+	TokenKeyword
+	TokenOperator
+	TokenLParen
+	TokenRParen
+	TokenLBracket
+	TokenRBracket
+	TokenLBrace
+	TokenRBrace
+	TokenComma
+	TokenColon
+)
+
+// Token represents a single lexical token with its source position
+type Token struct {
+	Type   TokenType
+	Text   string
+	Line   int
+	Column int
+}
+
+// keywords are reserved words of the rule language
+var keywords = map[string]bool{
+	"and":     true,
+	"or":      true,
+	"not":     true,
+	"in":      true,
+	"if":      true,
+	"then":    true,
+	"default": true,
+	"rollout": true,
+	"true":    true,
+	"false":   true,
+}
+
+// punctuation maps single-character tokens to their types
+var punctuation = map[rune]TokenType{
+	'(': TokenLParen,
+	')': TokenRParen,
+	'[': TokenLBracket,
+	']': TokenRBracket,
+	'{': TokenLBrace,
+	'}': TokenRBrace,
+	',': TokenComma,
+	':': TokenColon,
+}
+
+// SyntaxError reports a lexing or parsing failure at a source position
+type SyntaxError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+// Error implements the error interface
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+}
+
+// Lexer splits rule language source text into tokens
+type Lexer struct {
+	src    []rune
+	pos    int
+	line   int
+	column int
+}
+
+// NewLexer creates a new lexer for the given source
+func NewLexer(src string) *Lexer {
+	return &Lexer{
+		src:    []rune(src),
+		line:   1,
+		column: 1,
+	}
+}
+
+// Tokenize returns all tokens in the source, terminated by a TokenEOF
+func (l *Lexer) Tokenize() ([]Token, error) {
+	var tokens []Token
+	for {
+		tok, err := l.Next()
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, tok)
+		if tok.Type == TokenEOF {
+			return tokens, nil
+		}
+	}
+}
+
+// Next returns the next token in the source
+func (l *Lexer) Next() (Token, error) {
+	l.skipWhitespaceAndComments()
+
+	line, column := l.line, l.column
+	if l.pos >= len(l.src) {
+		return Token{Type: TokenEOF, Line: line, Column: column}, nil
+	}
+
+	r := l.src[l.pos]
+	switch {
+	case r == '"':
+		return l.lexString()
+	case unicode.IsDigit(r) || (r == '-' && l.pos+1 < len(l.src) && unicode.IsDigit(l.src[l.pos+1])):
+		return l.lexNumber()
+	case isIdentStart(r):
+		return l.lexIdent(), nil
+	}
+
+	if tokType, ok := punctuation[r]; ok {
+		l.advance()
+		return Token{Type: tokType, Text: string(r), Line: line, Column: column}, nil
+	}
+
+	// Comparison operators: == != < > <= >=
+	if strings.ContainsRune("=!<>", r) {
+		l.advance()
+		text := string(r)
+		if l.pos < len(l.src) && l.src[l.pos] == '=' {
+			l.advance()
+			text += "="
+		}
+		if text == "=" || text == "!" {
+			return Token{}, &SyntaxError{Line: line, Column: column, Message: fmt.Sprintf("unexpected character %q", text)}
+		}
+		return Token{Type: TokenOperator, Text: text, Line: line, Column: column}, nil
+	}
+
+	return Token{}, &SyntaxError{Line: line, Column: column, Message: fmt.Sprintf("unexpected character %q", r)}
+}
+
+func (l *Lexer) advance() {
+	if l.src[l.pos] == '\n' {
+		l.line++
+		l.column = 1
+	} else {
+		l.column++
+	}
+	l.pos++
+}
+
+func (l *Lexer) skipWhitespaceAndComments() {
+	for l.pos < len(l.src) {
+		r := l.src[l.pos]
+		if unicode.IsSpace(r) {
+			l.advance()
+			continue
+		}
+		// Line comments start with #
+		if r == '#' {
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.advance()
+			}
+			continue
+		}
+		return
+	}
+}
+
+func (l *Lexer) lexString() (Token, error) {
+	line, column := l.line, l.column
+	start := l.pos
+	l.advance() // opening quote
+
+	for l.pos < len(l.src) {
+		r := l.src[l.pos]
+		if r == '\n' {
+			break
+		}
+		if r == '\\' && l.pos+1 < len(l.src) {
+			l.advance()
+			l.advance()
+			continue
+		}
+		l.advance()
+		if r == '"' {
+			value, err := strconv.Unquote(string(l.src[start:l.pos]))
+			if err != nil {
+				return Token{}, &SyntaxError{Line: line, Column: column, Message: "invalid string literal"}
+			}
+			return Token{Type: TokenString, Text: value, Line: line, Column: column}, nil
+		}
+	}
+
+	return Token{}, &SyntaxError{Line: line, Column: column, Message: "unterminated string literal"}
+}
+
+func (l *Lexer) lexNumber() (Token, error) {
+	line, column := l.line, l.column
+	start := l.pos
+	if l.src[l.pos] == '-' {
+		l.advance()
+	}
+	for l.pos < len(l.src) && (unicode.IsDigit(l.src[l.pos]) || l.src[l.pos] == '.') {
+		l.advance()
+	}
+
+	text := string(l.src[start:l.pos])
+	if _, err := strconv.ParseFloat(text, 64); err != nil {
+		return Token{}, &SyntaxError{Line: line, Column: column, Message: fmt.Sprintf("invalid number %q", text)}
+	}
+	return Token{Type: TokenNumber, Text: text, Line: line, Column: column}, nil
+}
+
+func (l *Lexer) lexIdent() Token {
+	line, column := l.line, l.column
+	start := l.pos
+	for l.pos < len(l.src) && isIdentPart(l.src[l.pos]) {
+		l.advance()
+	}
+
+	text := string(l.src[start:l.pos])
+	if keywords[text] {
+		return Token{Type: TokenKeyword, Text: text, Line: line, Column: column}
+	}
+	return Token{Type: TokenIdent, Text: text, Line: line, Column: column}
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isIdentPart(r rune) bool {
+	return r == '_' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,459 @@
+package dsl
+
+import (
+	"fmt"
+
+	"github.com/Sidd-007/feature-flag-platform/pkg/bucketing"
+	"github.com/Sidd-007/feature-flag-platform/pkg/operators"
+)
+
+// LintSeverity classifies a lint issue. Errors block publishing; warnings are
+// informational.
+type LintSeverity string
+
+const (
+	LintError   LintSeverity = "error"
+	LintWarning LintSeverity = "warning"
+)
+
+// Lint issue codes
+const (
+	LintUnsatisfiableRule = "unsatisfiable_rule"
+	LintShadowedRule      = "shadowed_rule"
+	LintZeroTraffic       = "zero_traffic_allocation"
+	LintZeroRolloutWeight = "zero_rollout_weight"
+	LintInvalidRollout    = "invalid_rollout"
+	LintMissingAction     = "missing_action"
+	LintUnknownVariation  = "unknown_variation"
+	LintUnknownSegment    = "unknown_segment"
+	LintInvalidRegex      = "invalid_regex"
+	LintInvalidCondition  = "invalid_condition"
+	LintUnknownOperator   = "unknown_operator"
+
+	// Context schema checks report the schema's violation codes
+	LintUndeclaredAttribute = bucketing.SchemaUndeclaredAttribute
+	LintAttributeType       = bucketing.SchemaTypeMismatch
+	LintValueNotAllowed     = bucketing.SchemaValueNotAllowed
+)
+
+// LintIssue describes a single problem found in a rule set. RuleID is empty
+// for issues that concern the flag as a whole.
+type LintIssue struct {
+	RuleID   string       `json:"rule_id,omitempty"`
+	Severity LintSeverity `json:"severity"`
+	Code     string       `json:"code"`
+	Message  string       `json:"message"`
+}
+
+// LintReport holds the issues found for a flag's rule set
+type LintReport struct {
+	FlagKey string      `json:"flag_key"`
+	Issues  []LintIssue `json:"issues"`
+}
+
+// HasErrors reports whether any issue has error severity
+func (r *LintReport) HasErrors() bool {
+	for _, issue := range r.Issues {
+		if issue.Severity == LintError {
+			return true
+		}
+	}
+	return false
+}
+
+// LintEnvironment lists what a rule set may reference. A nil list disables
+// the corresponding reference checks, and a nil schema disables attribute
+// type checks.
+type LintEnvironment struct {
+	Variations []string
+	Segments   []string
+	Schema     *bucketing.ContextSchema
+}
+
+// LintFlag lints a bucketing flag configuration. Variation references are
+// checked against the flag's own variations, including the default.
+func (c *Compiler) LintFlag(flag *bucketing.FlagConfig, env *LintEnvironment) *LintReport {
+	flagEnv := LintEnvironment{Variations: make([]string, 0, len(flag.Variations))}
+	if env != nil {
+		flagEnv.Segments = env.Segments
+		flagEnv.Schema = env.Schema
+	}
+	for _, v := range flag.Variations {
+		flagEnv.Variations = append(flagEnv.Variations, v.Key)
+	}
+
+	report := c.Lint(PlanFromFlag(flag, c.clock.Now()), &flagEnv)
+	if flag.DefaultVariation != "" && !contains(flagEnv.Variations, flag.DefaultVariation) {
+		report.add("", LintError, LintUnknownVariation, "default variation %q does not exist", flag.DefaultVariation)
+	}
+	if flag.OffVariation != "" && !contains(flagEnv.Variations, flag.OffVariation) {
+		report.add("", LintError, LintUnknownVariation, "off variation %q does not exist", flag.OffVariation)
+	}
+	return report
+}
+
+// Lint statically checks a plan for rules that can never match or are
+// shadowed by earlier rules, broken rollouts, invalid conditions and
+// references to variations or segments that do not exist
+func (c *Compiler) Lint(plan *CompiledPlan, env *LintEnvironment) *LintReport {
+	if env == nil {
+		env = &LintEnvironment{}
+	}
+
+	report := &LintReport{FlagKey: plan.FlagKey, Issues: []LintIssue{}}
+	seen := make(map[string]string)
+	catchAll := ""
+
+	for i := range plan.Rules {
+		rule := &plan.Rules[i]
+
+		c.lintConditions(report, rule.ID, rule.Conditions, env)
+		lintAction(report, rule, env)
+
+		if rule.TrafficAllocation <= 0 {
+			report.add(rule.ID, LintWarning, LintZeroTraffic, "rule has zero traffic allocation and never matches")
+			continue
+		}
+
+		if reason := unsatisfiable(rule.Conditions); reason != "" {
+			report.add(rule.ID, LintWarning, LintUnsatisfiableRule, "rule can never match: %s", reason)
+			continue
+		}
+
+		if catchAll != "" {
+			report.add(rule.ID, LintWarning, LintShadowedRule, "rule is unreachable: rule %s matches every context", catchAll)
+			continue
+		}
+
+		fullTraffic := rule.TrafficAllocation >= 1
+		if len(rule.Conditions) == 0 {
+			if fullTraffic {
+				catchAll = rule.ID
+			}
+			continue
+		}
+
+		key := FormatConditions(rule.Conditions)
+		if earlier, exists := seen[key]; exists {
+			report.add(rule.ID, LintWarning, LintShadowedRule, "rule is unreachable: rule %s has the same conditions", earlier)
+			continue
+		}
+		if fullTraffic {
+			seen[key] = rule.ID
+		}
+	}
+
+	return report
+}
+
+// add appends an issue to the report
+func (r *LintReport) add(ruleID string, severity LintSeverity, code, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, LintIssue{
+		RuleID:   ruleID,
+		Severity: severity,
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// lintConditions checks that every leaf condition uses a known operator with a
+// valid operand, references existing segments and agrees with the context
+// schema
+func (c *Compiler) lintConditions(report *LintReport, ruleID string, conditions []CompiledCondition, env *LintEnvironment) {
+	for i := range conditions {
+		cond := &conditions[i]
+
+		if err := cond.checkNode(); err != nil {
+			report.add(ruleID, LintError, LintInvalidCondition, "%v", err)
+			continue
+		}
+		if cond.Not != nil {
+			c.lintConditions(report, ruleID, []CompiledCondition{*cond.Not}, env)
+			continue
+		}
+		if cond.IsLogical() {
+			c.lintConditions(report, ruleID, cond.And, env)
+			c.lintConditions(report, ruleID, cond.Or, env)
+			continue
+		}
+
+		if cond.Attribute == "" {
+			report.add(ruleID, LintError, LintInvalidCondition, "condition has no attribute")
+			continue
+		}
+
+		if cond.Attribute == "segment" {
+			segment := operators.Key(cond.Value)
+			if env.Segments != nil && !contains(env.Segments, segment) {
+				report.add(ruleID, LintError, LintUnknownSegment, "segment %q does not exist", segment)
+			}
+			continue
+		}
+
+		if !c.isValidOperator(cond.Operator) {
+			report.add(ruleID, LintError, LintUnknownOperator, "unknown operator %q on attribute %q", cond.Operator, cond.Attribute)
+			continue
+		}
+
+		if _, err := operators.Compile(cond.Operator, cond.Value, c.clock); err != nil {
+			code := LintInvalidCondition
+			if cond.Operator == "regex" {
+				code = LintInvalidRegex
+			}
+			report.add(ruleID, LintError, code, "attribute %q: %v", cond.Attribute, err)
+			continue
+		}
+
+		// Type mismatches never match; undeclared attributes and values
+		// outside the allowed set are usually typos
+		if violation := env.Schema.CheckCondition(cond.Attribute, cond.Operator, cond.Value); violation != nil {
+			severity := LintWarning
+			if violation.Code == LintAttributeType {
+				severity = LintError
+			}
+			report.add(ruleID, severity, violation.Code, "attribute %q: %s", cond.Attribute, violation.Message)
+		}
+	}
+}
+
+// lintAction checks a rule's action and the variations it references
+func lintAction(report *LintReport, rule *CompiledRule, env *LintEnvironment) {
+	checkVariation := func(key string) {
+		if env.Variations != nil && !contains(env.Variations, key) {
+			report.add(rule.ID, LintError, LintUnknownVariation, "variation %q does not exist", key)
+		}
+	}
+
+	if rule.Action.Type != "rollout" {
+		if rule.Action.VariationKey == "" {
+			report.add(rule.ID, LintError, LintMissingAction, "rule does not serve a variation")
+			return
+		}
+		checkVariation(rule.Action.VariationKey)
+		return
+	}
+
+	rollout := rule.Action.Rollout
+	if rollout != nil && rollout.Ramp != nil {
+		if err := rollout.Ramp.Validate(); err != nil {
+			report.add(rule.ID, LintError, LintInvalidRollout, "invalid ramp: %v", err)
+			return
+		}
+	}
+	if rollout == nil || len(rollout.Variations) == 0 {
+		report.add(rule.ID, LintError, LintZeroRolloutWeight, "rollout has no variations")
+		return
+	}
+
+	var total float64
+	for _, v := range rollout.Variations {
+		if v.Weight < 0 {
+			report.add(rule.ID, LintError, LintInvalidRollout, "variation %q has negative weight %v", v.VariationKey, v.Weight)
+		}
+		total += v.Weight
+		checkVariation(v.VariationKey)
+	}
+	if total <= 0 {
+		report.add(rule.ID, LintError, LintZeroRolloutWeight, "rollout weights sum to zero")
+	}
+}
+
+// unsatisfiable returns a reason if the conditions, taken as an implicit AND,
+// can never all hold, or an empty string if they may. The analysis is
+// conservative: conditions it cannot reason about are assumed satisfiable.
+func unsatisfiable(conditions []CompiledCondition) string {
+	constraints := make(map[string]*attributeConstraints)
+	var order []string
+
+	var collect func(conditions []CompiledCondition) string
+	collect = func(conditions []CompiledCondition) string {
+		for i := range conditions {
+			cond := &conditions[i]
+			switch {
+			case cond.Not != nil:
+				// Negations are not analysed
+			case len(cond.Or) > 0:
+				if !allUnsatisfiable(cond.Or) {
+					continue
+				}
+				return "no branch of an or condition can match"
+			case len(cond.And) > 0:
+				if reason := collect(cond.And); reason != "" {
+					return reason
+				}
+			case cond.Attribute != "" && cond.Attribute != "segment":
+				ac, exists := constraints[cond.Attribute]
+				if !exists {
+					ac = &attributeConstraints{}
+					constraints[cond.Attribute] = ac
+					order = append(order, cond.Attribute)
+				}
+				ac.apply(cond.Operator, cond.Value)
+			}
+		}
+		return ""
+	}
+
+	if reason := collect(conditions); reason != "" {
+		return reason
+	}
+
+	for _, attribute := range order {
+		if !constraints[attribute].satisfiable() {
+			return fmt.Sprintf("conflicting conditions on %q", attribute)
+		}
+	}
+	return ""
+}
+
+// allUnsatisfiable reports whether every branch of an or node is unsatisfiable
+func allUnsatisfiable(branches []CompiledCondition) bool {
+	for i := range branches {
+		if unsatisfiable(branches[i:i+1]) == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// attributeConstraints accumulates what an implicit AND requires of a single
+// attribute: a numeric interval, an exact value and allowed/excluded sets
+type attributeConstraints struct {
+	lower, upper             float64
+	hasLower, hasUpper       bool
+	lowerStrict, upperStrict bool
+
+	equals     interface{}
+	hasEquals  bool
+	conflicted bool
+
+	allowed  map[string]interface{} // nil when unconstrained
+	excluded map[string]struct{}
+}
+
+// apply narrows the constraints by one comparison. Comparisons with invalid
+// operands are ignored; they are reported separately.
+func (a *attributeConstraints) apply(operator string, value interface{}) {
+	switch operator {
+	case "eq":
+		if a.hasEquals && operators.Key(a.equals) != operators.Key(value) {
+			a.conflicted = true
+		}
+		a.equals, a.hasEquals = value, true
+	case "neq":
+		a.exclude(value)
+	case "in":
+		items, ok := value.([]interface{})
+		if !ok {
+			return
+		}
+		allowed := make(map[string]interface{}, len(items))
+		for _, item := range items {
+			key := operators.Key(item)
+			if _, ok := a.allowed[key]; a.allowed == nil || ok {
+				allowed[key] = item
+			}
+		}
+		a.allowed = allowed
+	case "nin":
+		items, ok := value.([]interface{})
+		if !ok {
+			return
+		}
+		for _, item := range items {
+			a.exclude(item)
+		}
+	case "gt", "gte":
+		n, ok := operators.ToFloat64(value)
+		if !ok {
+			return
+		}
+		strict := operator == "gt"
+		if !a.hasLower || n > a.lower || (n == a.lower && strict) {
+			a.lower, a.lowerStrict, a.hasLower = n, strict, true
+		}
+	case "lt", "lte":
+		n, ok := operators.ToFloat64(value)
+		if !ok {
+			return
+		}
+		strict := operator == "lt"
+		if !a.hasUpper || n < a.upper || (n == a.upper && strict) {
+			a.upper, a.upperStrict, a.hasUpper = n, strict, true
+		}
+	}
+}
+
+func (a *attributeConstraints) exclude(value interface{}) {
+	if a.excluded == nil {
+		a.excluded = make(map[string]struct{})
+	}
+	a.excluded[operators.Key(value)] = struct{}{}
+}
+
+// satisfiable reports whether some value can meet every constraint
+func (a *attributeConstraints) satisfiable() bool {
+	if a.conflicted {
+		return false
+	}
+
+	if a.hasLower && a.hasUpper {
+		if a.lower > a.upper || (a.lower == a.upper && (a.lowerStrict || a.upperStrict)) {
+			return false
+		}
+	}
+
+	if a.hasEquals {
+		return a.admits(a.equals)
+	}
+
+	if a.allowed != nil {
+		for _, item := range a.allowed {
+			if a.admits(item) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+// admits reports whether a concrete value meets every constraint
+func (a *attributeConstraints) admits(value interface{}) bool {
+	key := operators.Key(value)
+	if _, excluded := a.excluded[key]; excluded {
+		return false
+	}
+	if a.allowed != nil {
+		if _, allowed := a.allowed[key]; !allowed {
+			return false
+		}
+	}
+
+	if !a.hasLower && !a.hasUpper {
+		return true
+	}
+
+	n, ok := operators.ToFloat64(value)
+	if !ok {
+		return false
+	}
+	if a.hasLower && (n < a.lower || (n == a.lower && a.lowerStrict)) {
+		return false
+	}
+	if a.hasUpper && (n > a.upper || (n == a.upper && a.upperStrict)) {
+		return false
+	}
+	return true
+}
+
+// contains reports whether a string list contains a value
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+package dsl
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Sidd-007/feature-flag-platform/pkg/bucketing"
+)
+
+func leaf(attribute, operator string, value interface{}) CompiledCondition {
+	return CompiledCondition{Attribute: attribute, Operator: operator, Value: value}
+}
+
+// serve is a rule serving a variation to all traffic matching the conditions
+func serve(id, variationKey string, conditions ...CompiledCondition) CompiledRule {
+	return CompiledRule{
+		ID:                id,
+		Conditions:        conditions,
+		Action:            CompiledAction{Type: "variation", VariationKey: variationKey},
+		TrafficAllocation: 1,
+	}
+}
+
+// rollout is a rule serving a rollout of the weights to all traffic
+func rollout(id string, weights map[string]float64) CompiledRule {
+	rule := CompiledRule{ID: id, Action: CompiledAction{Type: "rollout", Rollout: &CompiledRollout{}}, TrafficAllocation: 1}
+	for _, key := range []string{"on", "off", "purple"} {
+		if weight, exists := weights[key]; exists {
+			rule.Action.Rollout.Variations = append(rule.Action.Rollout.Variations, RolloutVariation{VariationKey: key, Weight: weight})
+		}
+	}
+	return rule
+}
+
+// issues formats the rule ID, severity and code of each issue
+func issues(report *LintReport) []string {
+	formatted := []string{}
+	for _, issue := range report.Issues {
+		formatted = append(formatted, fmt.Sprintf("%s %s %s", issue.RuleID, issue.Severity, issue.Code))
+	}
+	return formatted
+}
+
+func TestLint(t *testing.T) {
+	env := &LintEnvironment{Variations: []string{"on", "off"}, Segments: []string{"beta"}}
+
+	tests := []struct {
+		name  string
+		rules []CompiledRule
+		want  []string
+	}{
+		{"clean", []CompiledRule{
+			serve("adults", "on", leaf("age", "gte", 18.0), leaf("age", "lt", 65.0)),
+			serve("beta", "on", leaf("segment", "eq", "beta")),
+			rollout("rest", map[string]float64{"on": 10, "off": 90}),
+		}, []string{}},
+
+		{"unsatisfiable range", []CompiledRule{
+			serve("r1", "on", leaf("age", "gt", 30.0), leaf("age", "lt", 20.0)),
+		}, []string{"r1 warning unsatisfiable_rule"}},
+		{"empty range with a strict bound", []CompiledRule{
+			serve("r1", "on", leaf("age", "gte", 30.0), leaf("age", "lt", 30.0)),
+			serve("r2", "on", leaf("age", "gte", 30.0), leaf("age", "lte", 30.0)),
+		}, []string{"r1 warning unsatisfiable_rule"}},
+		{"conflicting equalities", []CompiledRule{
+			serve("r1", "on", leaf("country", "eq", "US"), leaf("country", "eq", "CA")),
+		}, []string{"r1 warning unsatisfiable_rule"}},
+		{"value both in and not in a list", []CompiledRule{
+			serve("r1", "on", leaf("country", "in", []interface{}{"US"}), leaf("country", "nin", []interface{}{"US", "CA"})),
+		}, []string{"r1 warning unsatisfiable_rule"}},
+		{"unsatisfiable nested and", []CompiledRule{
+			serve("r1", "on", CompiledCondition{And: []CompiledCondition{leaf("age", "gt", 30.0), leaf("age", "lt", 20.0)}}),
+		}, []string{"r1 warning unsatisfiable_rule"}},
+		{"or with one satisfiable branch", []CompiledRule{
+			serve("r1", "on", CompiledCondition{Or: []CompiledCondition{
+				{And: []CompiledCondition{leaf("age", "gt", 30.0), leaf("age", "lt", 20.0)}},
+				leaf("age", "gt", 40.0),
+			}}),
+			serve("r2", "on", CompiledCondition{Or: []CompiledCondition{
+				{And: []CompiledCondition{leaf("age", "gt", 30.0), leaf("age", "lt", 20.0)}},
+				{And: []CompiledCondition{leaf("plan", "eq", "pro"), leaf("plan", "eq", "free")}},
+			}}),
+		}, []string{"r2 warning unsatisfiable_rule"}},
+
+		{"shadowed by a catch-all", []CompiledRule{
+			serve("everyone", "on"),
+			serve("us", "off", leaf("country", "eq", "US")),
+			rollout("rest", map[string]float64{"on": 50, "off": 50}),
+		}, []string{"us warning shadowed_rule", "rest warning shadowed_rule"}},
+		{"catch-all with partial traffic", []CompiledRule{
+			{ID: "half", Action: CompiledAction{Type: "variation", VariationKey: "on"}, TrafficAllocation: 0.5},
+			serve("us", "off", leaf("country", "eq", "US")),
+		}, []string{}},
+		{"shadowed by the same conditions", []CompiledRule{
+			serve("r1", "on", leaf("country", "eq", "US")),
+			serve("r2", "off", leaf("country", "eq", "US")),
+		}, []string{"r2 warning shadowed_rule"}},
+		{"zero traffic", []CompiledRule{
+			{ID: "r1", Action: CompiledAction{Type: "variation", VariationKey: "on"}},
+		}, []string{"r1 warning zero_traffic_allocation"}},
+
+		{"zero-weight rollout", []CompiledRule{
+			rollout("r1", map[string]float64{"on": 0, "off": 0}),
+		}, []string{"r1 error zero_rollout_weight"}},
+		{"rollout without variations", []CompiledRule{
+			rollout("r1", nil),
+		}, []string{"r1 error zero_rollout_weight"}},
+		{"negative rollout weight", []CompiledRule{
+			rollout("r1", map[string]float64{"on": 110, "off": -10}),
+		}, []string{"r1 error invalid_rollout"}},
+		{"missing action", []CompiledRule{
+			{ID: "r1", TrafficAllocation: 1},
+		}, []string{"r1 error missing_action"}},
+
+		{"unknown variation", []CompiledRule{
+			serve("r1", "purple", leaf("country", "eq", "US")),
+			rollout("r2", map[string]float64{"on": 50, "purple": 50}),
+		}, []string{"r1 error unknown_variation", "r2 error unknown_variation"}},
+		{"unknown segment", []CompiledRule{
+			serve("r1", "on", leaf("segment", "eq", "alpha")),
+			serve("r2", "on", CompiledCondition{Not: &CompiledCondition{Attribute: "segment", Operator: "eq", Value: "gamma"}}),
+		}, []string{"r1 error unknown_segment", "r2 error unknown_segment"}},
+		{"bad regex", []CompiledRule{
+			serve("r1", "on", leaf("email", "regex", "([a-z]+@")),
+			serve("r2", "on", CompiledCondition{Or: []CompiledCondition{leaf("email", "regex", `.*@example\.com$`), leaf("email", "regex", "*")}}),
+		}, []string{"r1 error invalid_regex", "r2 error invalid_regex"}},
+		{"unknown operator", []CompiledRule{
+			serve("r1", "on", leaf("age", "roughly", 30.0)),
+		}, []string{"r1 error unknown_operator"}},
+		{"invalid operand", []CompiledRule{
+			serve("r1", "on", leaf("age", "gt", "thirty")),
+		}, []string{"r1 error invalid_condition"}},
+	}
+
+	compiler := NewCompiler()
+	for _, tt := range tests {
+		report := compiler.Lint(&CompiledPlan{FlagKey: "flag", Rules: tt.rules}, env)
+		if got := issues(report); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: issues = %q, want %q", tt.name, got, tt.want)
+		}
+		if report.HasErrors() != hasError(tt.want) {
+			t.Errorf("%s: HasErrors = %v", tt.name, report.HasErrors())
+		}
+	}
+}
+
+func hasError(issues []string) bool {
+	for _, issue := range issues {
+		if strings.Contains(issue, " error ") {
+			return true
+		}
+	}
+	return false
+}
+
+func TestLintWithoutEnvironmentSkipsReferenceChecks(t *testing.T) {
+	plan := &CompiledPlan{FlagKey: "flag", Rules: []CompiledRule{
+		serve("r1", "purple", leaf("segment", "eq", "alpha")),
+	}}
+	if got := issues(NewCompiler().Lint(plan, nil)); len(got) != 0 {
+		t.Errorf("issues = %q, want none", got)
+	}
+}
+
+func TestLintFlagChecksFlagVariations(t *testing.T) {
+	flag := &bucketing.FlagConfig{
+		Key:               "flag",
+		Status:            "active",
+		DefaultVariation:  "purple",
+		OffVariation:      "off",
+		TrafficAllocation: 1,
+		Variations:        []bucketing.Variation{{Key: "on", Value: true}, {Key: "off", Value: false}},
+		Rules: []bucketing.Rule{
+			{ID: "r1", TrafficAllocation: 1, VariationKey: "on", Conditions: []bucketing.Condition{{Attribute: "age", Operator: "gt", Value: 30.0}, {Attribute: "age", Operator: "lt", Value: 20.0}}},
+			{ID: "r2", TrafficAllocation: 1, VariationKey: "green"},
+		},
+	}
+
+	want := []string{"r1 warning unsatisfiable_rule", "r2 error unknown_variation", " error unknown_variation"}
+	if got := issues(NewCompiler().LintFlag(flag, nil)); !reflect.DeepEqual(got, want) {
+		t.Errorf("issues = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,648 @@
+package dsl
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Sidd-007/feature-flag-platform/pkg/bucketing"
+)
+
+// symbolOperators maps comparison symbols to compiled operator names
+var symbolOperators = map[string]string{
+	"==": "eq",
+	"!=": "neq",
+	"<":  "lt",
+	">":  "gt",
+	"<=": "lte",
+	">=": "gte",
+}
+
+// wordOperators maps operator words that differ from their compiled names
+var wordOperators = map[string]string{
+	"matches": "regex",
+}
+
+// Parser builds compiled conditions and plans from rule language source.
+//
+// Grammar:
+//
+//	ruleset    = [ "bucket_by" attribute ] { rule } [ "default" value ] EOF
+//	rule       = "if" ( "true" | expr ) "then" action { option }
+//	action     = value | "rollout" [ "sticky" ] ( weights | ramp )
+//	weights    = "{" string ":" number { "," string ":" number } "}"
+//	ramp       = "ramp" string "to" string ( "steps" | "linear" ) weights [ state ]
+//	state      = "running" | "paused" string | "aborted"
+//	option     = "traffic" number | "bucket_by" attribute
+//	expr       = and_expr { "or" and_expr }
+//	and_expr   = unary { "and" unary }
+//	unary      = "not" unary | primary
+//	primary    = "(" expr ")" | "segment" "(" string ")" | comparison
+//	comparison = operand operator value
+//	operand    = attribute | ident "(" attribute ")"
+//	attribute  = ident | string
+//	operator   = "==" | "!=" | "<" | ">" | "<=" | ">=" | "in" | "not" "in" | ident
+//	value      = string | number | "true" | "false" | "[" [ value { "," value } ] "]"
+//
+// Attributes may be dotted paths (company.plan) or, quoted, JSON pointers
+// ("/company/plan"). Ramp schedules map RFC 3339 times to treatment
+// percentages: every step, or the start and end of a linear ramp. traffic is
+// the fraction of matching users the rule applies to, from 0 to 1.
+type Parser struct {
+	compiler *Compiler
+	tokens   []Token
+	pos      int
+}
+
+// NewParser creates a parser for the given source that validates operators
+// against the compiler's registry
+func NewParser(compiler *Compiler, src string) (*Parser, error) {
+	tokens, err := NewLexer(src).Tokenize()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Parser{
+		compiler: compiler,
+		tokens:   tokens,
+	}, nil
+}
+
+// CompileExpression compiles a textual condition expression such as
+// `country in ["US","CA"] and not segment("beta-blocklist")` into conditions
+// forming an implicit AND
+func (c *Compiler) CompileExpression(src string) ([]CompiledCondition, error) {
+	p, err := NewParser(c, src)
+	if err != nil {
+		return nil, err
+	}
+
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenEOF, "end of expression"); err != nil {
+		return nil, err
+	}
+
+	return flattenAnd(expr), nil
+}
+
+// CompileText compiles a textual rule set into an evaluation plan
+func (c *Compiler) CompileText(flagKey, src string) (*CompiledPlan, error) {
+	if flagKey == "" {
+		return nil, fmt.Errorf("flag key is required")
+	}
+
+	p, err := NewParser(c, src)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &CompiledPlan{
+		FlagKey:     flagKey,
+		Rules:       make([]CompiledRule, 0),
+		Metadata:    make(map[string]string),
+		HashVersion: c.hasher().Version(),
+	}
+
+	if p.peekIdent("bucket_by") {
+		p.next()
+		attribute, err := p.parseAttribute()
+		if err != nil {
+			return nil, err
+		}
+		plan.BucketBy = attribute
+	}
+
+	for p.peekKeyword("if") {
+		rule, err := p.parseRule(len(plan.Rules))
+		if err != nil {
+			return nil, err
+		}
+		plan.Rules = append(plan.Rules, *rule)
+	}
+
+	if p.peekKeyword("default") {
+		p.next()
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		plan.DefaultValue = value
+	}
+
+	if err := p.expect(TokenEOF, `"if", "default" or end of input`); err != nil {
+		return nil, err
+	}
+
+	c.optimizePlan(plan)
+
+	return plan, nil
+}
+
+// parseRule parses a single "if ... then ..." statement
+func (p *Parser) parseRule(priority int) (*CompiledRule, error) {
+	p.next() // if
+
+	rule := &CompiledRule{
+		ID:                fmt.Sprintf("rule_%d", priority),
+		Priority:          priority,
+		TrafficAllocation: 1.0,
+		Conditions:        []CompiledCondition{},
+	}
+
+	if p.peekKeyword("true") && p.peekAt(1).Type == TokenKeyword && p.peekAt(1).Text == "then" {
+		p.next()
+	} else {
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		rule.Conditions = flattenAnd(expr)
+	}
+
+	if !p.peekKeyword("then") {
+		return nil, p.errorf(p.peek(), `expected "then", found %s`, describe(p.peek()))
+	}
+	p.next()
+
+	action, err := p.parseAction()
+	if err != nil {
+		return nil, err
+	}
+	rule.Action = *action
+
+	for {
+		switch {
+		case p.peekIdent("traffic"):
+			p.next()
+			tok := p.peek()
+			if tok.Type != TokenNumber {
+				return nil, p.errorf(tok, "expected traffic allocation, found %s", describe(tok))
+			}
+			p.next()
+			traffic, _ := strconv.ParseFloat(tok.Text, 64)
+			if traffic < 0 || traffic > 1 {
+				return nil, p.errorf(tok, "traffic allocation must be between 0 and 1")
+			}
+			rule.TrafficAllocation = traffic
+		case p.peekIdent("bucket_by"):
+			p.next()
+			attribute, err := p.parseAttribute()
+			if err != nil {
+				return nil, err
+			}
+			rule.BucketBy = attribute
+		default:
+			return rule, nil
+		}
+	}
+}
+
+// parseAttribute parses an attribute name, bare or quoted
+func (p *Parser) parseAttribute() (string, error) {
+	tok := p.peek()
+	if tok.Type != TokenIdent && tok.Type != TokenString {
+		return "", p.errorf(tok, "expected attribute name, found %s", describe(tok))
+	}
+	p.next()
+	return tok.Text, nil
+}
+
+// parseAction parses a variation key or rollout block
+func (p *Parser) parseAction() (*CompiledAction, error) {
+	if !p.peekKeyword("rollout") {
+		tok := p.peek()
+		if tok.Type != TokenString {
+			return nil, p.errorf(tok, "expected variation key or rollout, found %s", describe(tok))
+		}
+		p.next()
+		return &CompiledAction{Type: "variation", VariationKey: tok.Text}, nil
+	}
+
+	start := p.next() // rollout
+	sticky := p.peekIdent("sticky")
+	if sticky {
+		p.next()
+	}
+
+	if p.peekIdent("ramp") {
+		ramp, err := p.parseRamp()
+		if err != nil {
+			return nil, err
+		}
+		rollout, err := p.compiler.rampRollout(ramp, sticky)
+		if err != nil {
+			return nil, p.errorf(start, "%s", err.Error())
+		}
+		return &CompiledAction{Type: "rollout", Rollout: rollout}, nil
+	}
+
+	keys, weights, err := p.parseWeights("variation key", "weight")
+	if err != nil {
+		return nil, err
+	}
+	variations := make([]interface{}, len(keys))
+	for i := range keys {
+		variations[i] = map[string]interface{}{"key": keys[i], "weight": weights[i]}
+	}
+
+	rollout, err := p.compiler.compileRollout(map[string]interface{}{"variations": variations, "sticky_buckets": sticky})
+	if err != nil {
+		return nil, p.errorf(start, "%s", err.Error())
+	}
+
+	return &CompiledAction{Type: "rollout", Rollout: rollout}, nil
+}
+
+// parseRamp parses a ramp's variations, schedule and state
+func (p *Parser) parseRamp() (*bucketing.Ramp, error) {
+	p.next() // ramp
+
+	ramp := &bucketing.Ramp{}
+	tok := p.peek()
+	if tok.Type != TokenString {
+		return nil, p.errorf(tok, "expected control variation key, found %s", describe(tok))
+	}
+	p.next()
+	ramp.ControlVariation = tok.Text
+
+	if !p.peekIdent("to") {
+		return nil, p.errorf(p.peek(), `expected "to", found %s`, describe(p.peek()))
+	}
+	p.next()
+
+	tok = p.peek()
+	if tok.Type != TokenString {
+		return nil, p.errorf(tok, "expected treatment variation key, found %s", describe(tok))
+	}
+	p.next()
+	ramp.TreatmentVariation = tok.Text
+
+	scheduleTok := p.peek()
+	linear := p.peekIdent("linear")
+	if !linear && !p.peekIdent("steps") {
+		return nil, p.errorf(scheduleTok, `expected "steps" or "linear", found %s`, describe(scheduleTok))
+	}
+	p.next()
+
+	times, percentages, err := p.parseWeights("time", "percentage")
+	if err != nil {
+		return nil, err
+	}
+	at := make([]time.Time, len(times))
+	for i, s := range times {
+		if at[i], err = time.Parse(time.RFC3339Nano, s); err != nil {
+			return nil, p.errorf(scheduleTok, "invalid ramp time %q", s)
+		}
+	}
+
+	if linear {
+		if len(at) != 2 {
+			return nil, p.errorf(scheduleTok, "linear ramp must have a start and an end")
+		}
+		ramp.Linear = &bucketing.LinearRamp{Start: at[0], End: at[1], StartPercentage: percentages[0], EndPercentage: percentages[1]}
+	} else {
+		for i := range at {
+			ramp.Steps = append(ramp.Steps, bucketing.RampStep{At: at[i], Percentage: percentages[i]})
+		}
+	}
+
+	switch {
+	case p.peekIdent(bucketing.RampRunning), p.peekIdent(bucketing.RampAborted):
+		ramp.Status = p.next().Text
+	case p.peekIdent(bucketing.RampPaused):
+		p.next()
+		tok := p.peek()
+		if tok.Type != TokenString {
+			return nil, p.errorf(tok, "expected time paused at, found %s", describe(tok))
+		}
+		p.next()
+		pausedAt, err := time.Parse(time.RFC3339Nano, tok.Text)
+		if err != nil {
+			return nil, p.errorf(tok, "invalid ramp time %q", tok.Text)
+		}
+		ramp.Status = bucketing.RampPaused
+		ramp.PausedAt = &pausedAt
+	}
+
+	return ramp, nil
+}
+
+// parseWeights parses a non-empty "{ string: number, ... }" block
+func (p *Parser) parseWeights(keyName, valueName string) ([]string, []float64, error) {
+	if err := p.expect(TokenLBrace, `"{"`); err != nil {
+		return nil, nil, err
+	}
+
+	var keys []string
+	var values []float64
+	for {
+		keyTok := p.peek()
+		if keyTok.Type != TokenString {
+			return nil, nil, p.errorf(keyTok, "expected %s, found %s", keyName, describe(keyTok))
+		}
+		p.next()
+
+		if err := p.expect(TokenColon, `":"`); err != nil {
+			return nil, nil, err
+		}
+
+		valueTok := p.peek()
+		if valueTok.Type != TokenNumber {
+			return nil, nil, p.errorf(valueTok, "expected %s, found %s", valueName, describe(valueTok))
+		}
+		p.next()
+		value, _ := strconv.ParseFloat(valueTok.Text, 64)
+
+		keys = append(keys, keyTok.Text)
+		values = append(values, value)
+
+		if p.peek().Type != TokenComma {
+			break
+		}
+		p.next()
+	}
+
+	if err := p.expect(TokenRBrace, `"}"`); err != nil {
+		return nil, nil, err
+	}
+	return keys, values, nil
+}
+
+// parseExpr parses an or-expression
+func (p *Parser) parseExpr() (*CompiledCondition, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.peekKeyword("or") {
+		return left, nil
+	}
+
+	branches := []CompiledCondition{*left}
+	for p.peekKeyword("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		branches = append(branches, *right)
+	}
+
+	return &CompiledCondition{Or: branches, Compiled: true}, nil
+}
+
+// parseAnd parses an and-expression
+func (p *Parser) parseAnd() (*CompiledCondition, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.peekKeyword("and") {
+		return left, nil
+	}
+
+	operands := flattenAnd(left)
+	for p.peekKeyword("and") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, flattenAnd(right)...)
+	}
+
+	return &CompiledCondition{And: operands, Compiled: true}, nil
+}
+
+// parseUnary parses a negation or primary expression
+func (p *Parser) parseUnary() (*CompiledCondition, error) {
+	if p.peekKeyword("not") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &CompiledCondition{Not: operand, Compiled: true}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+// parsePrimary parses a parenthesized expression, segment reference or comparison
+func (p *Parser) parsePrimary() (*CompiledCondition, error) {
+	tok := p.peek()
+
+	switch {
+	case tok.Type == TokenLParen:
+		p.next()
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(TokenRParen, `")"`); err != nil {
+			return nil, err
+		}
+		return expr, nil
+
+	case tok.Type == TokenIdent && tok.Text == "segment" && p.peekAt(1).Type == TokenLParen:
+		p.next()
+		p.next()
+		keyTok := p.peek()
+		if keyTok.Type != TokenString {
+			return nil, p.errorf(keyTok, "expected segment key, found %s", describe(keyTok))
+		}
+		p.next()
+		if err := p.expect(TokenRParen, `")"`); err != nil {
+			return nil, err
+		}
+		return &CompiledCondition{Attribute: "segment", Operator: "eq", Value: keyTok.Text, Compiled: true}, nil
+
+	case tok.Type == TokenIdent, tok.Type == TokenString:
+		// Quoted attributes allow JSON pointer paths such as "/company/plan"
+		return p.parseComparison()
+	}
+
+	return nil, p.errorf(tok, "expected condition, found %s", describe(tok))
+}
+
+// parseComparison parses "operand operator value"
+func (p *Parser) parseComparison() (*CompiledCondition, error) {
+	attrTok := p.next()
+	attribute := attrTok.Text
+	function := ""
+
+	// Function operands such as semver(app_version) select a typed operator family
+	if p.peek().Type == TokenLParen {
+		p.next()
+		argTok := p.peek()
+		if argTok.Type != TokenIdent && argTok.Type != TokenString {
+			return nil, p.errorf(argTok, "expected attribute name, found %s", describe(argTok))
+		}
+		p.next()
+		if err := p.expect(TokenRParen, `")"`); err != nil {
+			return nil, err
+		}
+		function = attribute
+		attribute = argTok.Text
+	}
+
+	opTok := p.peek()
+	var operator string
+	switch {
+	case opTok.Type == TokenOperator:
+		p.next()
+		operator = symbolOperators[opTok.Text]
+	case opTok.Type == TokenKeyword && opTok.Text == "in":
+		p.next()
+		operator = "in"
+	case opTok.Type == TokenKeyword && opTok.Text == "not":
+		p.next()
+		if !p.peekKeyword("in") {
+			return nil, p.errorf(p.peek(), `expected "in" after "not", found %s`, describe(p.peek()))
+		}
+		p.next()
+		operator = "nin"
+	case opTok.Type == TokenIdent:
+		p.next()
+		operator = opTok.Text
+		if mapped, ok := wordOperators[operator]; ok {
+			operator = mapped
+		}
+	default:
+		return nil, p.errorf(opTok, "expected operator, found %s", describe(opTok))
+	}
+
+	if function != "" {
+		operator = function + "_" + operator
+	}
+
+	if !p.compiler.isValidOperator(operator) {
+		return nil, p.errorf(opTok, "unknown operator %q", operator)
+	}
+
+	valueTok := p.peek()
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+
+	condition, err := p.compiler.compileComparison(attribute, operator, value)
+	if err != nil {
+		return nil, p.errorf(valueTok, "%s", err.Error())
+	}
+	return condition, nil
+}
+
+// parseValue parses a literal value or list of values
+func (p *Parser) parseValue() (interface{}, error) {
+	tok := p.peek()
+
+	switch {
+	case tok.Type == TokenString:
+		p.next()
+		return tok.Text, nil
+	case tok.Type == TokenNumber:
+		p.next()
+		f, _ := strconv.ParseFloat(tok.Text, 64)
+		return f, nil
+	case tok.Type == TokenKeyword && (tok.Text == "true" || tok.Text == "false"):
+		p.next()
+		return tok.Text == "true", nil
+	case tok.Type == TokenLBracket:
+		p.next()
+		values := make([]interface{}, 0)
+		if p.peek().Type == TokenRBracket {
+			p.next()
+			return values, nil
+		}
+		for {
+			value, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+			if p.peek().Type != TokenComma {
+				break
+			}
+			p.next()
+		}
+		if err := p.expect(TokenRBracket, `"]"`); err != nil {
+			return nil, err
+		}
+		return values, nil
+	}
+
+	return nil, p.errorf(tok, "expected value, found %s", describe(tok))
+}
+
+// Token helpers
+
+func (p *Parser) peek() Token {
+	return p.peekAt(0)
+}
+
+func (p *Parser) peekAt(offset int) Token {
+	if p.pos+offset >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.pos+offset]
+}
+
+func (p *Parser) peekKeyword(keyword string) bool {
+	tok := p.peek()
+	return tok.Type == TokenKeyword && tok.Text == keyword
+}
+
+// peekIdent reports whether the next token is the given contextual word,
+// which unlike a keyword remains usable as an attribute name
+func (p *Parser) peekIdent(word string) bool {
+	tok := p.peek()
+	return tok.Type == TokenIdent && tok.Text == word
+}
+
+func (p *Parser) next() Token {
+	tok := p.peek()
+	if p.pos < len(p.tokens)-1 {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *Parser) expect(tokType TokenType, expected string) error {
+	tok := p.peek()
+	if tok.Type != tokType {
+		return p.errorf(tok, "expected %s, found %s", expected, describe(tok))
+	}
+	p.next()
+	return nil
+}
+
+func (p *Parser) errorf(tok Token, format string, args ...interface{}) error {
+	return &SyntaxError{Line: tok.Line, Column: tok.Column, Message: fmt.Sprintf(format, args...)}
+}
+
+// describe renders a token for error messages
+func describe(tok Token) string {
+	switch tok.Type {
+	case TokenEOF:
+		return "end of input"
+	case TokenString:
+		return strconv.Quote(tok.Text)
+	default:
+		return fmt.Sprintf("%q", tok.Text)
+	}
+}
+
+// flattenAnd returns the operands of an AND node, or the node itself as a
+// single-element implicit AND
+func flattenAnd(cond *CompiledCondition) []CompiledCondition {
+	if len(cond.And) > 0 && len(cond.Or) == 0 && cond.Not == nil {
+		return cond.And
+	}
+	return []CompiledCondition{*cond}
+}
@@ -0,0 +1,252 @@
+package dsl
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sidd-007/feature-flag-platform/pkg/bucketing"
+)
+
+// operatorSymbols maps compiled operator names back to their textual form
+var operatorSymbols = map[string]string{
+	"eq":    "==",
+	"neq":   "!=",
+	"lt":    "<",
+	"gt":    ">",
+	"lte":   "<=",
+	"gte":   ">=",
+	"in":    "in",
+	"nin":   "not in",
+	"regex": "matches",
+}
+
+// operatorFunctions lists operator families written as function operands,
+// e.g. semver_gte is printed as semver(attr) >= value
+var operatorFunctions = []string{"semver"}
+
+// Operator precedence used to decide where parentheses are needed
+const (
+	precOr = iota + 1
+	precAnd
+	precNot
+	precLeaf
+)
+
+// FormatPlan renders a compiled plan in the textual rule language, so rules
+// can be displayed in the same form they were written. Compiling the text
+// gives back the plan, except for rule IDs and persisted bucket ranges, which
+// the language does not carry.
+func FormatPlan(plan *CompiledPlan) string {
+	if plan == nil {
+		return ""
+	}
+
+	var sb strings.Builder
+	if plan.BucketBy != "" {
+		sb.WriteString("bucket_by ")
+		sb.WriteString(formatAttribute(plan.BucketBy))
+		sb.WriteString("\n")
+	}
+	for i := range plan.Rules {
+		sb.WriteString(FormatRule(&plan.Rules[i]))
+		sb.WriteString("\n")
+	}
+
+	if plan.DefaultValue != nil {
+		sb.WriteString("default ")
+		sb.WriteString(formatValue(plan.DefaultValue))
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
+
+// FormatRule renders a single compiled rule as an "if ... then ..." statement,
+// followed by its traffic allocation and bucket_by when they are set
+func FormatRule(rule *CompiledRule) string {
+	text := fmt.Sprintf("if %s then %s", FormatConditions(rule.Conditions), formatAction(&rule.Action))
+	if rule.TrafficAllocation != 1 {
+		text += " traffic " + formatNumber(rule.TrafficAllocation)
+	}
+	if rule.BucketBy != "" {
+		text += " bucket_by " + formatAttribute(rule.BucketBy)
+	}
+	return text
+}
+
+// FormatConditions renders conditions forming an implicit AND as an
+// expression. No conditions render as "true".
+func FormatConditions(conditions []CompiledCondition) string {
+	if len(conditions) == 0 {
+		return "true"
+	}
+	if len(conditions) == 1 {
+		return formatCondition(&conditions[0], 0)
+	}
+	return formatCondition(&CompiledCondition{And: conditions}, 0)
+}
+
+// formatCondition renders a condition, parenthesizing when its precedence is
+// lower than the surrounding context
+func formatCondition(cond *CompiledCondition, parentPrec int) string {
+	var text string
+	var prec int
+
+	switch {
+	case cond.Not != nil:
+		prec = precNot
+		text = "not " + formatCondition(cond.Not, precNot)
+	case len(cond.Or) > 0:
+		prec = precOr
+		parts := make([]string, len(cond.Or))
+		for i := range cond.Or {
+			parts[i] = formatCondition(&cond.Or[i], precOr+1)
+		}
+		text = strings.Join(parts, " or ")
+	case len(cond.And) > 0:
+		prec = precAnd
+		parts := make([]string, len(cond.And))
+		for i := range cond.And {
+			parts[i] = formatCondition(&cond.And[i], precAnd)
+		}
+		text = strings.Join(parts, " and ")
+	default:
+		prec = precLeaf
+		text = formatComparison(cond)
+	}
+
+	if prec < parentPrec {
+		return "(" + text + ")"
+	}
+	return text
+}
+
+// formatComparison renders a leaf comparison
+func formatComparison(cond *CompiledCondition) string {
+	if cond.Attribute == "segment" {
+		return fmt.Sprintf("segment(%s)", formatValue(cond.Value))
+	}
+
+	operand := formatAttribute(cond.Attribute)
+	operator := cond.Operator
+	for _, function := range operatorFunctions {
+		if strings.HasPrefix(operator, function+"_") {
+			operand = fmt.Sprintf("%s(%s)", function, formatAttribute(cond.Attribute))
+			operator = strings.TrimPrefix(operator, function+"_")
+			break
+		}
+	}
+
+	if symbol, ok := operatorSymbols[operator]; ok {
+		operator = symbol
+	}
+
+	return fmt.Sprintf("%s %s %s", operand, operator, formatValue(cond.Value))
+}
+
+// formatAttribute renders an attribute name, quoting names that are not
+// plain identifiers, such as JSON pointer paths
+func formatAttribute(attribute string) string {
+	for i, r := range attribute {
+		if (i == 0 && !isIdentStart(r)) || !isIdentPart(r) {
+			return strconv.Quote(attribute)
+		}
+	}
+	if attribute == "" || keywords[attribute] {
+		return strconv.Quote(attribute)
+	}
+	return attribute
+}
+
+// formatAction renders a rule action
+func formatAction(action *CompiledAction) string {
+	if action.Type != "rollout" || action.Rollout == nil {
+		return strconv.Quote(action.VariationKey)
+	}
+
+	text := "rollout "
+	if action.Rollout.StickyBuckets {
+		text += "sticky "
+	}
+	if action.Rollout.Ramp != nil {
+		return text + formatRamp(action.Rollout.Ramp)
+	}
+
+	parts := make([]string, len(action.Rollout.Variations))
+	for i, v := range action.Rollout.Variations {
+		parts[i] = fmt.Sprintf("%s: %s", strconv.Quote(v.VariationKey), formatNumber(v.Weight))
+	}
+	return text + fmt.Sprintf("{ %s }", strings.Join(parts, ", "))
+}
+
+// formatRamp renders a ramp's variations, schedule and state
+func formatRamp(ramp *bucketing.Ramp) string {
+	var schedule string
+	var parts []string
+	if ramp.Linear != nil {
+		schedule = "linear"
+		parts = []string{
+			formatStep(ramp.Linear.Start, ramp.Linear.StartPercentage),
+			formatStep(ramp.Linear.End, ramp.Linear.EndPercentage),
+		}
+	} else {
+		schedule = "steps"
+		for _, step := range ramp.Steps {
+			parts = append(parts, formatStep(step.At, step.Percentage))
+		}
+	}
+
+	text := fmt.Sprintf("ramp %s to %s %s { %s }", strconv.Quote(ramp.ControlVariation), strconv.Quote(ramp.TreatmentVariation), schedule, strings.Join(parts, ", "))
+	switch {
+	case ramp.Status == bucketing.RampPaused && ramp.PausedAt != nil:
+		text += " paused " + strconv.Quote(ramp.PausedAt.Format(time.RFC3339Nano))
+	case ramp.Status != "":
+		text += " " + ramp.Status
+	}
+	return text
+}
+
+// formatStep renders a ramp schedule point as "time: percentage"
+func formatStep(at time.Time, percentage float64) string {
+	return fmt.Sprintf("%s: %s", strconv.Quote(at.Format(time.RFC3339Nano)), formatNumber(percentage))
+}
+
+// formatNumber renders a number in its shortest exact form
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+// formatValue renders a literal value
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return strconv.Quote(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return formatNumber(v)
+	case int, int32, int64, float32:
+		return fmt.Sprintf("%v", v)
+	case []string:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = strconv.Quote(item)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = formatValue(item)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case map[string]interface{}:
+		// Objects have no literal syntax; render them as JSON text
+		data, _ := json.Marshal(v)
+		return strconv.Quote(string(data))
+	default:
+		return strconv.Quote(fmt.Sprintf("%v", v))
+	}
+}
@@ -0,0 +1,88 @@
+package dsl
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Sidd-007/feature-flag-platform/pkg/operators"
+)
+
+func TestFormatPlanRoundTrip(t *testing.T) {
+	sources := []string{
+		`if true then "on"`,
+		`if country in ["US", "CA"] and semver(app_version) >= "2.3.0" and not segment("beta-blocklist") then "on" default "off"`,
+		`if (plan == "pro" or plan == "team") and "/company/size" > 50 then rollout { "control": 50, "treatment": 50 }`,
+		`bucket_by company.id
+if plan == "pro" then rollout sticky { "a": 33.3, "b": 33.3, "c": 33.4 } traffic 0.25 bucket_by device_id
+if true then "off" traffic 0`,
+		`if true then rollout ramp "off" to "on" steps { "2026-03-01T00:00:00Z": 10, "2026-03-08T00:00:00Z": 50, "2026-03-15T00:00:00Z": 100 }`,
+		`if true then rollout sticky ramp "off" to "on" linear { "2026-03-01T00:00:00Z": 0, "2026-04-01T00:00:00Z": 100 } paused "2026-03-10T12:30:00Z"`,
+		`if email contains "@example.com" then rollout ramp "off" to "on" steps { "2026-03-01T00:00:00+01:00": 20 } aborted traffic 0.5`,
+		`if true then rollout ramp "off" to "on" linear { "2026-03-01T00:00:00Z": 5, "2026-03-02T00:00:00Z": 95.5 } running bucket_by "/org/id"`,
+	}
+
+	compiler := NewCompiler()
+	compiler.SetClock(operators.FixedClock(time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)))
+
+	for _, src := range sources {
+		plan, err := compiler.CompileText("flag", src)
+		if err != nil {
+			t.Fatalf("CompileText(%q): %v", src, err)
+		}
+
+		text := FormatPlan(plan)
+		reparsed, err := compiler.CompileText("flag", text)
+		if err != nil {
+			t.Errorf("CompileText of printed plan %q: %v", text, err)
+			continue
+		}
+
+		want, _ := json.Marshal(plan)
+		got, _ := json.Marshal(reparsed)
+		if string(got) != string(want) {
+			t.Errorf("round trip of %q through %q changed the plan:\n got %s\nwant %s", src, text, got, want)
+		}
+		if again := FormatPlan(reparsed); again != text {
+			t.Errorf("printing is not stable:\n%s\n%s", text, again)
+		}
+	}
+}
+
+func TestFormatRuleOptions(t *testing.T) {
+	compiler := NewCompiler()
+	plan, err := compiler.CompileText("flag", `if true then rollout sticky { "a": 50, "b": 50 } traffic 0.1 bucket_by org.id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rule := plan.Rules[0]
+	if rule.TrafficAllocation != 0.1 || rule.BucketBy != "org.id" || !rule.Action.Rollout.StickyBuckets {
+		t.Errorf("parsed rule = traffic %v, bucket_by %q, sticky %v", rule.TrafficAllocation, rule.BucketBy, rule.Action.Rollout.StickyBuckets)
+	}
+
+	want := `if true then rollout sticky { "a": 50, "b": 50 } traffic 0.1 bucket_by org.id`
+	if got := FormatRule(&rule); got != want {
+		t.Errorf("FormatRule = %s, want %s", got, want)
+	}
+}
+
+func TestCompileTextRuleOptionErrors(t *testing.T) {
+	sources := []string{
+		`if true then "on" traffic 1.5`,
+		`if true then "on" traffic "half"`,
+		`if true then "on" bucket_by`,
+		`if true then rollout ramp "off" to "off" steps { "2026-03-01T00:00:00Z": 10 }`,
+		`if true then rollout ramp "off" "on" steps { "2026-03-01T00:00:00Z": 10 }`,
+		`if true then rollout ramp "off" to "on" linear { "2026-03-01T00:00:00Z": 10 }`,
+		`if true then rollout ramp "off" to "on" steps { "next week": 10 }`,
+		`if true then rollout ramp "off" to "on" steps { "2026-03-01T00:00:00Z": 10 } paused`,
+	}
+
+	compiler := NewCompiler()
+	for _, src := range sources {
+		if _, err := compiler.CompileText("flag", src); err == nil {
+			t.Errorf("CompileText(%q) succeeded, want an error", src)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package hashing
+
+// Allocation strategies
+const (
+	// AllocationContiguous lays out one bucket range per variation in
+	// variation order, recomputed from the weights alone
+	AllocationContiguous = "contiguous"
+	// AllocationMinimal starts from the previous allocation and moves the
+	// fewest buckets needed to reach the new weights
+	AllocationMinimal = "minimal"
+)
+
+// VariationBuckets is the set of bucket ranges allocated to a variation
+type VariationBuckets struct {
+	Key    string        `json:"key"`
+	Ranges []BucketRange `json:"ranges"`
+}
+
+// Contains checks if a bucket is within any of the variation's ranges
+func (vb VariationBuckets) Contains(bucket int) bool {
+	for _, r := range vb.Ranges {
+		if r.Contains(bucket) {
+			return true
+		}
+	}
+	return false
+}
+
+// Size returns the number of buckets allocated to the variation
+func (vb VariationBuckets) Size() int {
+	size := 0
+	for _, r := range vb.Ranges {
+		size += r.Size()
+	}
+	return size
+}
+
+// ContiguousAllocation returns the allocation AllocateBucketsForVariations
+// lays out for the weights, keyed by variation
+func (h *Hasher) ContiguousAllocation(keys []string, weights []float64) []VariationBuckets {
+	ranges := h.AllocateBucketsForVariations(weights)
+
+	allocation := make([]VariationBuckets, len(keys))
+	for i, key := range keys {
+		allocation[i].Key = key
+		if i < len(ranges) && ranges[i].Size() > 0 {
+			allocation[i].Ranges = []BucketRange{ranges[i]}
+		}
+	}
+	return allocation
+}
+
+// ReallocateBuckets allocates buckets to variations in proportion to the
+// weights, moving as few buckets as possible away from the owners they had in
+// the previous allocation. Each variation gets the same number of buckets as
+// under AllocateBucketsForVariations. Variations that shrink give up their
+// highest buckets; freed buckets and those of removed variations go, lowest
+// first, to the variations that grow, in variation order.
+func (h *Hasher) ReallocateBuckets(previous []VariationBuckets, keys []string, weights []float64) []VariationBuckets {
+	allocation := make([]VariationBuckets, len(keys))
+	for i, key := range keys {
+		allocation[i].Key = key
+	}
+
+	targets := h.bucketCounts(weights)
+	if targets == nil {
+		return allocation
+	}
+
+	index := make(map[string]int, len(keys))
+	for i := len(keys) - 1; i >= 0; i-- {
+		index[keys[i]] = i
+	}
+
+	// Keep previous owners that still exist
+	buckets := h.Buckets()
+	owners := make([]int32, buckets)
+	for b := range owners {
+		owners[b] = -1
+	}
+	for _, vb := range previous {
+		i, exists := index[vb.Key]
+		if !exists {
+			continue
+		}
+		for _, r := range vb.Ranges {
+			for b := max(r.Start, 0); b < min(r.End, buckets); b++ {
+				owners[b] = int32(i)
+			}
+		}
+	}
+
+	// Shrinking variations give up their highest buckets
+	counts := make([]int, len(keys))
+	for _, owner := range owners {
+		if owner >= 0 {
+			counts[owner]++
+		}
+	}
+	for b := buckets - 1; b >= 0; b-- {
+		if owner := owners[b]; owner >= 0 && counts[owner] > targets[owner] {
+			owners[b] = -1
+			counts[owner]--
+		}
+	}
+
+	// Growing variations take free buckets, lowest first
+	next := 0
+	for b := range owners {
+		if owners[b] >= 0 {
+			continue
+		}
+		for next < len(keys) && counts[next] >= targets[next] {
+			next++
+		}
+		if next == len(keys) {
+			break
+		}
+		owners[b] = int32(next)
+		counts[next]++
+	}
+
+	// Collapse the owners into ranges
+	for b := 0; b < buckets; {
+		owner := owners[b]
+		end := b + 1
+		for end < buckets && owners[end] == owner {
+			end++
+		}
+		if owner >= 0 {
+			allocation[owner].Ranges = append(allocation[owner].Ranges, BucketRange{
+				Start:      b,
+				End:        end,
+				Percentage: float64(end-b) * 100 / float64(buckets),
+			})
+		}
+		b = end
+	}
+
+	return allocation
+}
+
+// ChangedBuckets returns how many of the buckets are owned by a different
+// variation in the two allocations. Buckets owned by no variation count as
+// owned by the empty key.
+func (h *Hasher) ChangedBuckets(before, after []VariationBuckets) int {
+	changed := 0
+	for b := 0; b < h.Buckets(); b++ {
+		if BucketOwner(before, b) != BucketOwner(after, b) {
+			changed++
+		}
+	}
+	return changed
+}
+
+// BucketOwner returns the key of the variation a bucket is allocated to, or
+// an empty string when it is not allocated
+func BucketOwner(allocation []VariationBuckets, bucket int) string {
+	for _, vb := range allocation {
+		if vb.Contains(bucket) {
+			return vb.Key
+		}
+	}
+	return ""
+}
+
+// bucketCounts returns the number of buckets AllocateBucketsForVariations
+// gives each weight, or nil when the weights sum to zero
+func (h *Hasher) bucketCounts(weights []float64) []int {
+	ranges := h.AllocateBucketsForVariations(weights)
+	if ranges == nil {
+		return nil
+	}
+
+	counts := make([]int, len(ranges))
+	for i, r := range ranges {
+		counts[i] = r.Size()
+	}
+	return counts
+}
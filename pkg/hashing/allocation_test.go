@@ -0,0 +1,112 @@
+package hashing
+
+import "testing"
+
+func TestReallocateBucketsMovesFewestBuckets(t *testing.T) {
+	tests := []struct {
+		name                   string
+		version                Version
+		beforeKeys, afterKeys  []string
+		beforeWeights, weights []float64
+		minimal, contiguous    int
+	}{
+		{"unchanged", HashV1, []string{"a", "b"}, []string{"a", "b"}, []float64{50, 50}, []float64{50, 50}, 0, 0},
+		{"two-way weight change", HashV1, []string{"a", "b"}, []string{"a", "b"}, []float64{50, 50}, []float64{40, 60}, 1000, 1000},
+		{"three-way weight change", HashV1, []string{"a", "b", "c"}, []string{"a", "b", "c"}, []float64{30, 30, 40}, []float64{20, 20, 60}, 2000, 3000},
+		{"first variation shrinks", HashV1, []string{"a", "b", "c"}, []string{"a", "b", "c"}, []float64{40, 20, 40}, []float64{30, 20, 50}, 1000, 2000},
+		{"added variation", HashV1, []string{"a", "b"}, []string{"a", "b", "c"}, []float64{50, 50}, []float64{40, 40, 20}, 2000, 3000},
+		{"removed variation", HashV1, []string{"a", "b", "c"}, []string{"a", "b"}, []float64{40, 40, 20}, []float64{50, 50}, 2000, 3000},
+		{"replaced variation", HashV1, []string{"a", "b"}, []string{"a", "c"}, []float64{50, 50}, []float64{50, 50}, 5000, 5000},
+		{"million buckets", HashV2, []string{"a", "b"}, []string{"a", "b"}, []float64{50, 50}, []float64{40, 60}, 100000, 100000},
+	}
+
+	for _, tt := range tests {
+		h := ForVersion(tt.version)
+		before := h.ContiguousAllocation(tt.beforeKeys, tt.beforeWeights)
+		after := h.ReallocateBuckets(before, tt.afterKeys, tt.weights)
+		contiguous := h.ContiguousAllocation(tt.afterKeys, tt.weights)
+
+		for i, vb := range after {
+			if vb.Key != tt.afterKeys[i] {
+				t.Errorf("%s: variation %d is %q, want %q", tt.name, i, vb.Key, tt.afterKeys[i])
+			}
+			if vb.Size() != contiguous[i].Size() {
+				t.Errorf("%s: %s has %d buckets, want %d", tt.name, vb.Key, vb.Size(), contiguous[i].Size())
+			}
+		}
+		if got := h.ChangedBuckets(before, after); got != tt.minimal {
+			t.Errorf("%s: minimal allocation moved %d buckets, want %d", tt.name, got, tt.minimal)
+		}
+		if got := h.ChangedBuckets(before, contiguous); got != tt.contiguous {
+			t.Errorf("%s: contiguous allocation moved %d buckets, want %d", tt.name, got, tt.contiguous)
+		}
+	}
+}
+
+func TestReallocateBucketsWithoutPrevious(t *testing.T) {
+	h := ForVersion(HashV1)
+	keys := []string{"a", "b", "c"}
+	weights := []float64{25, 25, 50}
+
+	got := h.ReallocateBuckets(nil, keys, weights)
+	if changed := h.ChangedBuckets(got, h.ContiguousAllocation(keys, weights)); changed != 0 {
+		t.Errorf("allocation without a previous one differs from the contiguous one in %d buckets", changed)
+	}
+
+	empty := h.ReallocateBuckets(got, keys, []float64{0, 0, 0})
+	for _, vb := range empty {
+		if vb.Size() != 0 {
+			t.Errorf("zero weights allocate %d buckets to %s", vb.Size(), vb.Key)
+		}
+	}
+}
+
+func TestReallocateBucketsChained(t *testing.T) {
+	h := ForVersion(HashV1)
+	keys := []string{"a", "b"}
+	allocation := h.ContiguousAllocation(keys, []float64{50, 50})
+
+	steps := []struct {
+		weights []float64
+		want    int
+	}{
+		{[]float64{40, 60}, 1000},
+		{[]float64{50, 50}, 1000},
+		{[]float64{70, 30}, 2000},
+		{[]float64{70, 30}, 0},
+	}
+	for _, step := range steps {
+		next := h.ReallocateBuckets(allocation, keys, step.weights)
+		if got := h.ChangedBuckets(allocation, next); got != step.want {
+			t.Errorf("reallocating to %v moved %d buckets, want %d", step.weights, got, step.want)
+		}
+		allocation = next
+	}
+}
+
+func TestChangedBuckets(t *testing.T) {
+	h := ForVersion(HashV1)
+	a := func(start, end int) VariationBuckets {
+		return VariationBuckets{Key: "a", Ranges: []BucketRange{{Start: start, End: end}}}
+	}
+	b := func(start, end int) VariationBuckets {
+		return VariationBuckets{Key: "b", Ranges: []BucketRange{{Start: start, End: end}}}
+	}
+
+	tests := []struct {
+		name          string
+		before, after []VariationBuckets
+		want          int
+	}{
+		{"identical", []VariationBuckets{a(0, 5000), b(5000, 10000)}, []VariationBuckets{a(0, 5000), b(5000, 10000)}, 0},
+		{"boundary moved", []VariationBuckets{a(0, 5000), b(5000, 10000)}, []VariationBuckets{a(0, 4000), b(4000, 10000)}, 1000},
+		{"swapped", []VariationBuckets{a(0, 5000), b(5000, 10000)}, []VariationBuckets{b(0, 5000), a(5000, 10000)}, 10000},
+		{"unallocated buckets", []VariationBuckets{a(0, 5000)}, []VariationBuckets{a(0, 2000), b(8000, 10000)}, 5000},
+		{"nothing allocated", nil, nil, 0},
+	}
+	for _, tt := range tests {
+		if got := h.ChangedBuckets(tt.before, tt.after); got != tt.want {
+			t.Errorf("%s: ChangedBuckets = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
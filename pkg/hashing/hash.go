@@ -4,15 +4,20 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"math"
 	"strconv"
 )
 
-// Hasher provides hashing utilities for feature flags
-type Hasher struct{}
+// Hasher provides hashing utilities for feature flags. Bucket mapping
+// depends on the hasher's hash version; bucketing IDs do not.
+type Hasher struct {
+	version Version
+	buckets int
+}
 
-// NewHasher creates a new hasher instance
+// NewHasher creates a new hasher instance using HashV1
 func NewHasher() *Hasher {
-	return &Hasher{}
+	return &Hasher{version: HashV1, buckets: 10000}
 }
 
 // HashUserKey hashes a user key for privacy and consistency
@@ -29,9 +34,16 @@ func (h *Hasher) GenerateBucketingID(envSalt, flagKey, userKey string) string {
 	return hex.EncodeToString(hash[:])
 }
 
-// DeterministicBucket converts a bucketing ID to a bucket number (0-9999)
-// This provides 10,000 buckets for fine-grained percentage rollouts
+// DeterministicBucket converts a bucketing ID to a bucket number, from 0 to
+// Buckets()-1. HashV1 provides 10,000 buckets; later versions 1,000,000.
 func (h *Hasher) DeterministicBucket(bucketingID string) int {
+	switch h.Version() {
+	case HashV2:
+		return sha256Bucket(bucketingID, h.Buckets())
+	case HashV3:
+		return murmurBucket(bucketingID, h.Buckets())
+	}
+
 	// Take first 8 characters of hex string for consistency
 	if len(bucketingID) < 8 {
 		bucketingID = bucketingID + "00000000"[:8-len(bucketingID)]
@@ -66,6 +78,10 @@ func (h *Hasher) IsInPercentageRange(bucket int, percentage float64) bool {
 		return true
 	}
 
+	if h.Version() != HashV1 {
+		return bucket < h.percentageBuckets(percentage)
+	}
+
 	// Convert percentage to bucket threshold (0-9999)
 	threshold := int(percentage * 100) // percentage * 100 = bucket threshold
 	return bucket < threshold
@@ -95,6 +111,26 @@ func (h *Hasher) AllocateBucketsForVariations(weights []float64) []BucketRange {
 	ranges := make([]BucketRange, len(weights))
 	currentBucket := 0
 
+	if h.Version() != HashV1 {
+		// Round the cumulative weights, so each range is within a bucket of
+		// its exact share and the ranges cover every bucket
+		cumulative := 0.0
+		for i, weight := range weights {
+			cumulative += weight
+			end := int(math.Round(cumulative / total * float64(h.Buckets())))
+			if i == len(weights)-1 {
+				end = h.Buckets()
+			}
+			ranges[i] = BucketRange{
+				Start:      currentBucket,
+				End:        end,
+				Percentage: (weight / total) * 100.0,
+			}
+			currentBucket = end
+		}
+		return ranges
+	}
+
 	for i, weight := range weights {
 		percentage := (weight / total) * 100.0
 		bucketCount := int(percentage * 100) // Convert to bucket count (out of 10000)
@@ -116,6 +152,12 @@ func (h *Hasher) AllocateBucketsForVariations(weights []float64) []BucketRange {
 	return ranges
 }
 
+// percentageBuckets returns the number of buckets a percentage covers,
+// rounded to the nearest bucket
+func (h *Hasher) percentageBuckets(percentage float64) int {
+	return int(math.Round(percentage * float64(h.Buckets()) / 100))
+}
+
 // BucketRange represents a range of buckets allocated to a variation
 type BucketRange struct {
 	Start      int     `json:"start"`
@@ -0,0 +1,107 @@
+package hashing
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+// bucketingVectors is the layout of api/bucketing-vectors.json
+type bucketingVectors struct {
+	Murmur3 []struct {
+		Input string `json:"input"`
+		Seed  uint32 `json:"seed"`
+		Hash  uint32 `json:"hash"`
+	} `json:"murmur3_x86_32"`
+	Versions []struct {
+		Version   Version `json:"version"`
+		Buckets   int     `json:"buckets"`
+		Bucketing []struct {
+			EnvSalt     string `json:"env_salt"`
+			FlagKey     string `json:"flag_key"`
+			UserKey     string `json:"user_key"`
+			BucketingID string `json:"bucketing_id"`
+			Bucket      int    `json:"bucket"`
+			RuleID      string `json:"rule_id"`
+			RuleBucket  int    `json:"rule_bucket"`
+		} `json:"bucketing"`
+		Percentages []struct {
+			Percentage float64 `json:"percentage"`
+			Threshold  int     `json:"threshold"`
+		} `json:"percentages"`
+		Allocations []struct {
+			Weights []float64 `json:"weights"`
+			Ranges  [][2]int  `json:"ranges"`
+		} `json:"allocations"`
+	} `json:"versions"`
+}
+
+func loadBucketingVectors(t *testing.T) *bucketingVectors {
+	t.Helper()
+	data, err := os.ReadFile("../../api/bucketing-vectors.json")
+	if err != nil {
+		t.Fatalf("read vectors: %v", err)
+	}
+	var vectors bucketingVectors
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		t.Fatalf("decode vectors: %v", err)
+	}
+	return &vectors
+}
+
+func TestMurmur3Vectors(t *testing.T) {
+	for _, v := range loadBucketingVectors(t).Murmur3 {
+		if got := Murmur3([]byte(v.Input), v.Seed); got != v.Hash {
+			t.Errorf("Murmur3(%q, %d) = %d, want %d", v.Input, v.Seed, got, v.Hash)
+		}
+	}
+}
+
+func TestBucketingVectors(t *testing.T) {
+	for _, version := range loadBucketingVectors(t).Versions {
+		h := ForVersion(version.Version)
+		if h.Version() != version.Version {
+			t.Fatalf("version %d: hasher implements version %d", version.Version, h.Version())
+		}
+		if h.Buckets() != version.Buckets {
+			t.Errorf("version %d: Buckets() = %d, want %d", version.Version, h.Buckets(), version.Buckets)
+		}
+
+		for _, v := range version.Bucketing {
+			id := h.GenerateBucketingID(v.EnvSalt, v.FlagKey, v.UserKey)
+			if id != v.BucketingID {
+				t.Errorf("version %d: bucketing ID of %q = %s, want %s", version.Version, v.UserKey, id, v.BucketingID)
+				continue
+			}
+			if got := h.DeterministicBucket(id); got != v.Bucket {
+				t.Errorf("version %d: bucket of %q = %d, want %d", version.Version, v.UserKey, got, v.Bucket)
+			}
+			if got := h.DeterministicBucket(id + v.RuleID); got != v.RuleBucket {
+				t.Errorf("version %d: rule bucket of %q = %d, want %d", version.Version, v.UserKey, got, v.RuleBucket)
+			}
+		}
+
+		for _, v := range version.Percentages {
+			// The threshold is the first bucket not in the percentage
+			if v.Threshold > 0 && !h.IsInPercentageRange(v.Threshold-1, v.Percentage) {
+				t.Errorf("version %d: bucket %d not in %v%%", version.Version, v.Threshold-1, v.Percentage)
+			}
+			if v.Threshold < h.Buckets() && h.IsInPercentageRange(v.Threshold, v.Percentage) {
+				t.Errorf("version %d: bucket %d in %v%%", version.Version, v.Threshold, v.Percentage)
+			}
+		}
+
+		for _, v := range version.Allocations {
+			ranges := h.AllocateBucketsForVariations(v.Weights)
+			if len(ranges) != len(v.Ranges) {
+				t.Errorf("version %d: %v allocates %d ranges, want %d", version.Version, v.Weights, len(ranges), len(v.Ranges))
+				continue
+			}
+			for i, r := range ranges {
+				if r.Start != v.Ranges[i][0] || r.End != v.Ranges[i][1] {
+					t.Errorf("version %d: %v range %d = [%d, %d), want [%d, %d)", version.Version, v.Weights, i, r.Start, r.End, v.Ranges[i][0], v.Ranges[i][1])
+				}
+			}
+		}
+	}
+}
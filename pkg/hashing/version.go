@@ -0,0 +1,132 @@
+package hashing
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math/bits"
+)
+
+// Version identifies the algorithm that maps bucketing IDs to buckets. An
+// environment keeps the version it was created with, so users stay in their
+// buckets when newer versions are added. See api/bucketing.md for the
+// specification SDKs implement.
+type Version int
+
+// Hash versions
+const (
+	// HashV1 takes the first 8 hex characters of the bucketing ID modulo
+	// 10,000, and truncates percentages to hundredths
+	HashV1 Version = 1
+	// HashV2 takes the first 8 bytes of the SHA-256 of the bucketing ID
+	// modulo 1,000,000, and rounds percentages to the nearest bucket
+	HashV2 Version = 2
+	// HashV3 scales the MurmurHash3 (x86, 32-bit) of the bucketing ID to
+	// 1,000,000 buckets, and rounds percentages like HashV2
+	HashV3 Version = 3
+)
+
+// DefaultHashVersion is the version of environments that do not set one
+const DefaultHashVersion = HashV1
+
+// murmurSeed is the MurmurHash3 seed of HashV3
+const murmurSeed = 1
+
+// hashers are the shared hashers of each version. Hashers hold no mutable
+// state, so they are safe for concurrent use.
+var hashers = map[Version]*Hasher{
+	HashV1: {version: HashV1, buckets: 10000},
+	HashV2: {version: HashV2, buckets: 1000000},
+	HashV3: {version: HashV3, buckets: 1000000},
+}
+
+// Valid reports whether the version is a known hash version
+func (v Version) Valid() bool {
+	_, ok := hashers[v]
+	return ok
+}
+
+// ForVersion returns the hasher of a hash version. Unset and unknown versions
+// get the DefaultHashVersion hasher.
+func ForVersion(v Version) *Hasher {
+	if h, ok := hashers[v]; ok {
+		return h
+	}
+	return hashers[DefaultHashVersion]
+}
+
+// Version returns the hash version the hasher implements
+func (h *Hasher) Version() Version {
+	if h.version == 0 {
+		return HashV1
+	}
+	return h.version
+}
+
+// Buckets returns the number of buckets the hasher maps bucketing IDs to
+func (h *Hasher) Buckets() int {
+	if h.buckets == 0 {
+		return 10000
+	}
+	return h.buckets
+}
+
+// sha256Bucket maps a bucketing ID to a bucket from the first 8 bytes of its
+// SHA-256, read as a big-endian unsigned integer
+func sha256Bucket(bucketingID string, buckets int) int {
+	sum := sha256.Sum256([]byte(bucketingID))
+	return int(binary.BigEndian.Uint64(sum[:8]) % uint64(buckets))
+}
+
+// murmurBucket maps a bucketing ID to a bucket by scaling its 32-bit
+// MurmurHash3 to the bucket count
+func murmurBucket(bucketingID string, buckets int) int {
+	hash := Murmur3([]byte(bucketingID), murmurSeed)
+	return int(uint64(hash) * uint64(buckets) >> 32)
+}
+
+// Murmur3 returns the MurmurHash3 x86 32-bit hash of data
+func Murmur3(data []byte, seed uint32) uint32 {
+	const (
+		c1 = 0xcc9e2d51
+		c2 = 0x1b873593
+	)
+
+	h := seed
+	blocks := len(data) / 4
+	for i := 0; i < blocks; i++ {
+		k := binary.LittleEndian.Uint32(data[i*4:])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+
+		h ^= k
+		h = bits.RotateLeft32(h, 13)
+		h = h*5 + 0xe6546b64
+	}
+
+	var k uint32
+	tail := data[blocks*4:]
+	switch len(tail) {
+	case 3:
+		k ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		k ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		k ^= uint32(tail[0])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+	}
+
+	// Finalization mix
+	h ^= uint32(len(data))
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
@@ -0,0 +1,371 @@
+package operators
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sidd-007/feature-flag-platform/pkg/semver"
+)
+
+// Matcher reports whether an attribute value satisfies a pre-compiled
+// condition. It is an alias so that compile functions written against other
+// packages with the same signature can be registered here unchanged.
+type Matcher = func(value interface{}) bool
+
+// CompileFunc builds a Matcher from a condition's operand. It runs once per
+// condition when a configuration is loaded, so parsing and validation of the
+// operand (regexes, sets, numbers, versions) happen off the evaluation path.
+type CompileFunc = func(operand interface{}) (Matcher, error)
+
+// ClockCompileFunc builds a Matcher for an operator that compares against the
+// current time, which the matcher reads from clock
+type ClockCompileFunc = func(operand interface{}, clock Clock) (Matcher, error)
+
+// builtins are the default operators, registered at start-up
+var builtins = []Definition{
+	{Name: "eq", Compile: compileEquals},
+	{Name: "neq", Compile: negate(compileEquals)},
+	{Name: "in", OperandType: ArgList, Compile: compileIn},
+	{Name: "nin", OperandType: ArgList, Compile: negate(compileIn)},
+	{Name: "lt", OperandType: ArgNumber, Compile: compileNumeric(func(a, b float64) bool { return a < b })},
+	{Name: "gt", OperandType: ArgNumber, Compile: compileNumeric(func(a, b float64) bool { return a > b })},
+	{Name: "lte", OperandType: ArgNumber, Compile: compileNumeric(func(a, b float64) bool { return a <= b })},
+	{Name: "gte", OperandType: ArgNumber, Compile: compileNumeric(func(a, b float64) bool { return a >= b })},
+	{Name: "contains", Compile: compileContains},
+	{Name: "contains_any", OperandType: ArgList, Compile: compileContainsSet(false)},
+	{Name: "contains_all", OperandType: ArgList, Compile: compileContainsSet(true)},
+	{Name: "regex", OperandType: ArgString, Compile: compileRegex},
+	{Name: "semver_eq", ValueType: ArgString, Compile: compileVersion(func(cmp int) bool { return cmp == 0 })},
+	{Name: "semver_neq", ValueType: ArgString, Compile: compileVersion(func(cmp int) bool { return cmp != 0 })},
+	{Name: "semver_lt", ValueType: ArgString, Compile: compileVersion(func(cmp int) bool { return cmp < 0 })},
+	{Name: "semver_gt", ValueType: ArgString, Compile: compileVersion(func(cmp int) bool { return cmp > 0 })},
+	{Name: "semver_lte", ValueType: ArgString, Compile: compileVersion(func(cmp int) bool { return cmp <= 0 })},
+	{Name: "semver_gte", ValueType: ArgString, Compile: compileVersion(func(cmp int) bool { return cmp >= 0 })},
+	{Name: "semver", ValueType: ArgString, OperandType: ArgString, Compile: compileVersionRange},
+	{Name: "before", Compile: compileTime(func(v, o time.Time) bool { return v.Before(o) })},
+	{Name: "after", Compile: compileTime(func(v, o time.Time) bool { return v.After(o) })},
+	{Name: "between", Compile: compileBetween},
+	{Name: "older_than", OperandType: ArgString, CompileWithClock: compileAge(func(age, limit time.Duration) bool { return age > limit })},
+	{Name: "newer_than", OperandType: ArgString, CompileWithClock: compileAge(func(age, limit time.Duration) bool { return age < limit })},
+}
+
+// negate wraps a compile function so the resulting matcher is inverted
+func negate(compile CompileFunc) CompileFunc {
+	return func(operand interface{}) (Matcher, error) {
+		matcher, err := compile(operand)
+		if err != nil {
+			return nil, err
+		}
+		return func(value interface{}) bool { return !matcher(value) }, nil
+	}
+}
+
+// compileEquals compares numbers numerically and everything else by its
+// canonical string form
+func compileEquals(operand interface{}) (Matcher, error) {
+	if number, ok := numericOperand(operand); ok {
+		return func(value interface{}) bool {
+			v, ok := ToFloat64(value)
+			return ok && v == number
+		}, nil
+	}
+
+	expected := Key(operand)
+	return func(value interface{}) bool {
+		if s, ok := value.(string); ok {
+			return s == expected
+		}
+		return value != nil && Key(value) == expected
+	}, nil
+}
+
+// compileIn builds a hash set from the operand list for constant-time lookups
+func compileIn(operand interface{}) (Matcher, error) {
+	items, ok := ToSlice(operand)
+	if !ok {
+		return nil, fmt.Errorf("expected a list, got %T", operand)
+	}
+
+	set := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		set[Key(item)] = struct{}{}
+	}
+
+	return func(value interface{}) bool {
+		if value == nil {
+			return false
+		}
+		_, found := set[Key(value)]
+		return found
+	}, nil
+}
+
+// compileNumeric parses the operand once and compares numerically
+func compileNumeric(compare func(a, b float64) bool) CompileFunc {
+	return func(operand interface{}) (Matcher, error) {
+		number, ok := ToFloat64(operand)
+		if !ok {
+			return nil, fmt.Errorf("expected a number, got %v", operand)
+		}
+		return func(value interface{}) bool {
+			v, ok := ToFloat64(value)
+			return ok && compare(v, number)
+		}, nil
+	}
+}
+
+// compileContains matches string values containing the operand, and lists
+// with an element equal to it
+func compileContains(operand interface{}) (Matcher, error) {
+	needle := Key(operand)
+	return func(value interface{}) bool {
+		if value == nil {
+			return false
+		}
+		if s, ok := value.(string); ok {
+			return strings.Contains(s, needle)
+		}
+		if items, ok := ToSlice(value); ok {
+			for _, item := range items {
+				if item != nil && Key(item) == needle {
+					return true
+				}
+			}
+			return false
+		}
+		return strings.Contains(Key(value), needle)
+	}, nil
+}
+
+// compileContainsSet matches list values against a set of operand elements.
+// With all set every operand element must be present, otherwise any one is
+// enough. A scalar value is treated as a list of one element, and an empty
+// operand list never matches.
+func compileContainsSet(all bool) CompileFunc {
+	return func(operand interface{}) (Matcher, error) {
+		items, ok := ToSlice(operand)
+		if !ok {
+			return nil, fmt.Errorf("expected a list, got %T", operand)
+		}
+
+		// Operand elements are numbered, so containsAll can track the ones
+		// it found without a map per evaluation
+		index := make(map[string]int, len(items))
+		for _, item := range items {
+			key := Key(item)
+			if _, ok := index[key]; !ok {
+				index[key] = len(index)
+			}
+		}
+
+		return func(value interface{}) bool {
+			if value == nil || len(index) == 0 {
+				return false
+			}
+			elements, ok := ToSlice(value)
+			if !ok {
+				_, found := index[Key(value)]
+				return found && (!all || len(index) == 1)
+			}
+
+			if all {
+				return containsAll(index, elements)
+			}
+			for _, element := range elements {
+				if element == nil {
+					continue
+				}
+				if _, ok := index[Key(element)]; ok {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+}
+
+// containsAll reports whether elements hold every key of index, which
+// numbers the keys from 0. Found keys are tracked in a bitmask, so sets of up
+// to 64 keys are matched without allocating.
+func containsAll(index map[string]int, elements []interface{}) bool {
+	// Each element is at most one key
+	if len(elements) < len(index) {
+		return false
+	}
+
+	var mask uint64
+	var seen []bool
+	if len(index) > 64 {
+		seen = make([]bool, len(index))
+	}
+
+	found := 0
+	for _, element := range elements {
+		if element == nil {
+			continue
+		}
+		i, ok := index[Key(element)]
+		if !ok {
+			continue
+		}
+		if seen != nil {
+			if seen[i] {
+				continue
+			}
+			seen[i] = true
+		} else {
+			if mask&(1<<uint(i)) != 0 {
+				continue
+			}
+			mask |= 1 << uint(i)
+		}
+		if found++; found == len(index) {
+			return true
+		}
+	}
+	return false
+}
+
+// compileRegex compiles the pattern once
+func compileRegex(operand interface{}) (Matcher, error) {
+	pattern, ok := operand.(string)
+	if !ok {
+		return nil, fmt.Errorf("expected a pattern string, got %T", operand)
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(value interface{}) bool {
+		if value == nil {
+			return false
+		}
+		if s, ok := value.(string); ok {
+			return re.MatchString(s)
+		}
+		return re.MatchString(Key(value))
+	}, nil
+}
+
+// compileVersion parses the operand version once and compares against it
+func compileVersion(accept func(cmp int) bool) CompileFunc {
+	return func(operand interface{}) (Matcher, error) {
+		expected, err := semver.Parse(Key(operand))
+		if err != nil {
+			return nil, err
+		}
+		return func(value interface{}) bool {
+			s, ok := value.(string)
+			if !ok {
+				return false
+			}
+			actual, err := semver.Parse(s)
+			return err == nil && accept(actual.Compare(expected))
+		}, nil
+	}
+}
+
+// compileVersionRange parses a range expression such as ">=2.0.0 <3.0.0"
+func compileVersionRange(operand interface{}) (Matcher, error) {
+	r, err := semver.ParseRange(operand.(string))
+	if err != nil {
+		return nil, err
+	}
+	return func(value interface{}) bool {
+		s, ok := value.(string)
+		if !ok {
+			return false
+		}
+		v, err := semver.Parse(s)
+		return err == nil && r.Contains(v)
+	}, nil
+}
+
+// Key returns the canonical string form of a value used for equality and set
+// membership, so that "30", 30 and 30.0 compare equal
+func Key(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case int32:
+		return strconv.FormatInt(int64(v), 10)
+	case bool:
+		return strconv.FormatBool(v)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// ToFloat64 converts various numeric types, and numeric strings, to float64
+func ToFloat64(v interface{}) (float64, bool) {
+	switch val := v.(type) {
+	case float64:
+		return val, true
+	case float32:
+		return float64(val), true
+	case int:
+		return float64(val), true
+	case int32:
+		return float64(val), true
+	case int64:
+		return float64(val), true
+	case string:
+		if f, err := strconv.ParseFloat(val, 64); err == nil {
+			return f, true
+		}
+	}
+	return 0, false
+}
+
+// numericOperand reports whether the operand is a number (not a numeric string)
+func numericOperand(operand interface{}) (float64, bool) {
+	switch operand.(type) {
+	case string:
+		return 0, false
+	}
+	return ToFloat64(operand)
+}
+
+// ToSlice converts list operands and values to []interface{}, reporting
+// whether the value is a list
+func ToSlice(operand interface{}) ([]interface{}, bool) {
+	switch v := operand.(type) {
+	case []interface{}:
+		return v, true
+	case []string:
+		items := make([]interface{}, len(v))
+		for i, s := range v {
+			items[i] = s
+		}
+		return items, true
+	case nil, string:
+		return nil, false
+	}
+
+	// Other typed slices supplied by Go callers, such as []int
+	rv := reflect.ValueOf(operand)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	items := make([]interface{}, rv.Len())
+	for i := range items {
+		items[i] = rv.Index(i).Interface()
+	}
+	return items, true
+}
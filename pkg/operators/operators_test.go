@@ -0,0 +1,123 @@
+package operators
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestContainsSet(t *testing.T) {
+	tests := []struct {
+		operator string
+		operand  interface{}
+		value    interface{}
+		want     bool
+	}{
+		{"contains_any", []interface{}{"a", "b"}, []interface{}{"x", "b"}, true},
+		{"contains_any", []interface{}{"a", "b"}, []interface{}{"x", "y"}, false},
+		{"contains_any", []interface{}{"a", "b"}, "a", true},
+		{"contains_any", []interface{}{}, []interface{}{"a"}, false},
+		{"contains_any", []interface{}{1.0, 2.0}, []interface{}{2.0}, true},
+		{"contains_all", []interface{}{"a", "b"}, []interface{}{"b", "x", "a"}, true},
+		{"contains_all", []interface{}{"a", "b"}, []interface{}{"a", "a", "x"}, false},
+		{"contains_all", []interface{}{"a", "a"}, []interface{}{"a"}, true},
+		{"contains_all", []interface{}{"a"}, "a", true},
+		{"contains_all", []interface{}{"a", "b"}, "a", false},
+		{"contains_all", []interface{}{}, []interface{}{"a"}, false},
+		{"contains_all", []interface{}{"a", "b"}, nil, false},
+		{"contains_all", []interface{}{"a", "b"}, []interface{}{nil, "a", "b"}, true},
+	}
+
+	for _, tt := range tests {
+		matcher, err := Compile(tt.operator, tt.operand, nil)
+		if err != nil {
+			t.Fatalf("Compile(%s, %v): %v", tt.operator, tt.operand, err)
+		}
+		if got := matcher(tt.value); got != tt.want {
+			t.Errorf("%s %v against %v = %v, want %v", tt.operator, tt.operand, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestContainsAllLargeSet(t *testing.T) {
+	operand := make([]interface{}, 100)
+	for i := range operand {
+		operand[i] = fmt.Sprintf("group-%d", i)
+	}
+	matcher, err := Compile("contains_all", operand, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value := append([]interface{}{"other"}, operand...)
+	if !matcher(value) {
+		t.Errorf("contains_all of 100 elements did not match a superset")
+	}
+	if matcher(value[:len(value)-1]) {
+		t.Errorf("contains_all of 100 elements matched a list missing one")
+	}
+}
+
+// groups returns n group names, as a user's group memberships
+func groups(prefix string, n int) []interface{} {
+	items := make([]interface{}, n)
+	for i := range items {
+		items[i] = fmt.Sprintf("%s-%d", prefix, i)
+	}
+	return items
+}
+
+func BenchmarkContainsAny(b *testing.B) {
+	matcher, err := Compile("contains_any", groups("operand", 10), nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	var value interface{} = append(groups("user", 20), "operand-9")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		matcher(value)
+	}
+}
+
+func BenchmarkContainsAll(b *testing.B) {
+	matcher, err := Compile("contains_all", groups("operand", 10), nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	var value interface{} = append(groups("user", 20), groups("operand", 10)...)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		matcher(value)
+	}
+}
+
+// BenchmarkContainsSetEnvironment evaluates the contains_any and contains_all
+// conditions of an environment with hundreds of flags for one user, as a
+// full evaluation of the environment does
+func BenchmarkContainsSetEnvironment(b *testing.B) {
+	const flags = 500
+
+	var matchers []Matcher
+	for i := 0; i < flags; i++ {
+		for _, operator := range []string{"contains_any", "contains_all"} {
+			matcher, err := Compile(operator, groups(fmt.Sprintf("flag-%d", i), 5), nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+			matchers = append(matchers, matcher)
+		}
+	}
+	var value interface{} = append(groups("user", 20), groups("flag-7", 5)...)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, matcher := range matchers {
+			matcher(value)
+		}
+	}
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*len(matchers)), "ns/condition")
+}
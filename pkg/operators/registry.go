@@ -0,0 +1,191 @@
+package operators
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ArgType describes the type of an attribute value or operand an operator
+// accepts
+type ArgType string
+
+const (
+	ArgAny    ArgType = "any"
+	ArgString ArgType = "string"
+	ArgNumber ArgType = "number"
+	ArgBool   ArgType = "bool"
+	ArgList   ArgType = "list"
+)
+
+// Definition describes a named operator. The same definition drives the DSL
+// compiler and the bucketing engine, so a registered operator behaves the same
+// wherever a condition is evaluated.
+type Definition struct {
+	Name string
+
+	// ValueType is the attribute value type the operator accepts. Values of
+	// any other type never match. An empty type accepts anything.
+	ValueType ArgType
+
+	// OperandType is the operand type the operator requires. Operands of any
+	// other type are rejected at compile time. An empty type accepts anything.
+	OperandType ArgType
+
+	// Validate is an optional hook that checks the operand at compile time,
+	// after its type has been checked
+	Validate func(operand interface{}) error
+
+	// Compile builds the matcher for a validated operand
+	Compile CompileFunc
+
+	// CompileWithClock replaces Compile for operators that compare against
+	// the current time, such as older_than. It receives the clock of the
+	// evaluator compiling the condition.
+	CompileWithClock ClockCompileFunc
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Definition)
+)
+
+func init() {
+	for _, def := range builtins {
+		MustRegister(def)
+	}
+}
+
+// Register adds an operator to the registry. Operators should be registered
+// during start-up, before any configuration using them is compiled.
+func Register(def Definition) error {
+	if def.Name == "" {
+		return fmt.Errorf("operator name is required")
+	}
+	if (def.Compile == nil) == (def.CompileWithClock == nil) {
+		return fmt.Errorf("operator %s: exactly one compile function is required", def.Name)
+	}
+	if !def.ValueType.valid() || !def.OperandType.valid() {
+		return fmt.Errorf("operator %s: unknown argument type", def.Name)
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, exists := registry[def.Name]; exists {
+		return fmt.Errorf("operator %s is already registered", def.Name)
+	}
+	registry[def.Name] = def
+	return nil
+}
+
+// MustRegister is like Register but panics on error
+func MustRegister(def Definition) {
+	if err := Register(def); err != nil {
+		panic(err)
+	}
+}
+
+// Lookup returns the definition of a registered operator
+func Lookup(name string) (Definition, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	def, exists := registry[name]
+	return def, exists
+}
+
+// IsSupported checks if an operator is registered
+func IsSupported(operator string) bool {
+	_, exists := Lookup(operator)
+	return exists
+}
+
+// Names returns the names of all registered operators in sorted order
+func Names() []string {
+	registryMu.RLock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	registryMu.RUnlock()
+
+	sort.Strings(names)
+	return names
+}
+
+// Compile compiles an operator and its operand into a Matcher, checking the
+// operand type and running the operator's validation hook. Operators that
+// compare against the current time read it from clock; nil is the system
+// clock.
+func Compile(operator string, operand interface{}, clock Clock) (Matcher, error) {
+	def, exists := Lookup(operator)
+	if !exists {
+		return nil, fmt.Errorf("unknown operator %q", operator)
+	}
+
+	if !def.OperandType.accepts(operand) {
+		return nil, fmt.Errorf("invalid value for operator %s: expected %s, got %T", operator, def.OperandType, operand)
+	}
+	if def.Validate != nil {
+		if err := def.Validate(operand); err != nil {
+			return nil, fmt.Errorf("invalid value for operator %s: %w", operator, err)
+		}
+	}
+
+	var matcher Matcher
+	var err error
+	if def.CompileWithClock != nil {
+		matcher, err = def.CompileWithClock(operand, orSystemClock(clock))
+	} else {
+		matcher, err = def.Compile(operand)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for operator %s: %w", operator, err)
+	}
+
+	if def.ValueType == "" || def.ValueType == ArgAny {
+		return matcher, nil
+	}
+	return func(value interface{}) bool {
+		return def.ValueType.accepts(value) && matcher(value)
+	}, nil
+}
+
+// Evaluate compiles and applies an operator in one step. It is the slow path
+// for conditions that were not pre-compiled; invalid operands never match.
+func Evaluate(operator string, value, operand interface{}, clock Clock) bool {
+	matcher, err := Compile(operator, operand, clock)
+	if err != nil {
+		return false
+	}
+	return matcher(value)
+}
+
+// valid reports whether the type is one of the known argument types
+func (t ArgType) valid() bool {
+	switch t {
+	case "", ArgAny, ArgString, ArgNumber, ArgBool, ArgList:
+		return true
+	}
+	return false
+}
+
+// accepts reports whether a value is of the type
+func (t ArgType) accepts(value interface{}) bool {
+	switch t {
+	case ArgString:
+		_, ok := value.(string)
+		return ok
+	case ArgNumber:
+		_, ok := ToFloat64(value)
+		return ok
+	case ArgBool:
+		_, ok := value.(bool)
+		return ok
+	case ArgList:
+		_, ok := ToSlice(value)
+		return ok
+	}
+	return true
+}
@@ -0,0 +1,244 @@
+package operators
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NowAttribute is the attribute that evaluators resolve to the current time
+// when the context does not provide it
+const NowAttribute = "now"
+
+// Clock supplies the current time to relative date/time operators and to the
+// "now" attribute. Evaluators and compilers take one so evaluations can be
+// reproduced in tests.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc adapts a function to the Clock interface
+type ClockFunc func() time.Time
+
+// Now implements Clock
+func (f ClockFunc) Now() time.Time { return f() }
+
+// FixedClock returns a clock that always reports t
+func FixedClock(t time.Time) Clock {
+	return ClockFunc(func() time.Time { return t })
+}
+
+// SystemClock reads the wall clock. Evaluators given no clock use it.
+var SystemClock Clock = ClockFunc(time.Now)
+
+// NowIn returns the current time according to clock in the named IANA zone,
+// falling back to UTC when the zone is empty or unknown. A nil clock is the
+// system clock.
+func NowIn(clock Clock, zone string) time.Time {
+	now := orSystemClock(clock).Now()
+	if zone == "" {
+		return now.UTC()
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return now.UTC()
+	}
+	return now.In(loc)
+}
+
+// orSystemClock returns clock, or the system clock when it is nil
+func orSystemClock(clock Clock) Clock {
+	if clock == nil {
+		return SystemClock
+	}
+	return clock
+}
+
+// timeLayouts are the accepted textual timestamp formats. Layouts without a
+// zone are interpreted as UTC.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// ParseTime converts an attribute value or operand to a time. It accepts
+// time.Time values, RFC3339 and date strings, and Unix timestamps in seconds
+// given as numbers. Numeric strings are not timestamps: "20240101" is
+// rejected rather than read as a time in 1970.
+func ParseTime(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		s := strings.TrimSpace(v)
+		for _, layout := range timeLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t, true
+			}
+		}
+	default:
+		if n, ok := ToFloat64(value); ok {
+			return time.Unix(int64(n), 0).UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// ParseDuration parses a duration, extending time.ParseDuration with day (d)
+// and week (w) units, e.g. "30d" or "2w"
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if strings.HasSuffix(s, suffix) {
+			n, err := strconv.ParseFloat(strings.TrimSuffix(s, suffix), 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration %q", s)
+			}
+			return time.Duration(n * float64(unit)), nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+// compileTime parses the operand timestamp once and compares against it
+func compileTime(accept func(value, operand time.Time) bool) CompileFunc {
+	return func(operand interface{}) (Matcher, error) {
+		expected, ok := ParseTime(operand)
+		if !ok {
+			return nil, fmt.Errorf("invalid timestamp %v", operand)
+		}
+		return func(value interface{}) bool {
+			actual, ok := ParseTime(value)
+			return ok && accept(actual, expected)
+		}, nil
+	}
+}
+
+// clockRange matches the "HH:MM-HH:MM" part of a daily time window
+var clockRange = regexp.MustCompile(`^\d{1,2}:\d{2}-\d{1,2}:\d{2}$`)
+
+// compileBetween accepts either a time-of-day window such as
+// "09:00-17:00 Europe/Berlin", or an interval of two timestamps given as a
+// list or as "start/end". Windows without a zone use the value's own zone, so
+// "now" resolved in the user's timezone is compared in local time. Ranges
+// include the start and exclude the end.
+func compileBetween(operand interface{}) (Matcher, error) {
+	if items, ok := ToSlice(operand); ok {
+		if len(items) != 2 {
+			return nil, fmt.Errorf("expected a start and an end, got %d values", len(items))
+		}
+		return compileInterval(items[0], items[1])
+	}
+
+	s, ok := operand.(string)
+	if !ok {
+		return nil, fmt.Errorf("expected a time window or interval, got %T", operand)
+	}
+	if fields := strings.Fields(s); len(fields) > 0 && clockRange.MatchString(fields[0]) {
+		return compileWindow(s)
+	}
+	if start, end, found := strings.Cut(s, "/"); found {
+		return compileInterval(start, end)
+	}
+	return nil, fmt.Errorf("invalid time window or interval %q", s)
+}
+
+// compileInterval matches timestamps in [start, end)
+func compileInterval(startOperand, endOperand interface{}) (Matcher, error) {
+	start, ok := ParseTime(startOperand)
+	if !ok {
+		return nil, fmt.Errorf("invalid timestamp %v", startOperand)
+	}
+	end, ok := ParseTime(endOperand)
+	if !ok {
+		return nil, fmt.Errorf("invalid timestamp %v", endOperand)
+	}
+	if !start.Before(end) {
+		return nil, fmt.Errorf("interval start must be before its end")
+	}
+
+	return func(value interface{}) bool {
+		t, ok := ParseTime(value)
+		return ok && !t.Before(start) && t.Before(end)
+	}, nil
+}
+
+// compileWindow matches times of day in a daily window, which may wrap past
+// midnight (e.g. "22:00-06:00")
+func compileWindow(window string) (Matcher, error) {
+	fields := strings.Fields(window)
+	if len(fields) == 0 || len(fields) > 2 {
+		return nil, fmt.Errorf("invalid time window %q", window)
+	}
+
+	from, to, found := strings.Cut(fields[0], "-")
+	if !found {
+		return nil, fmt.Errorf("invalid time window %q", window)
+	}
+	start, err := parseClockTime(from)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseClockTime(to)
+	if err != nil {
+		return nil, err
+	}
+
+	var loc *time.Location
+	if len(fields) == 2 {
+		if loc, err = time.LoadLocation(fields[1]); err != nil {
+			return nil, fmt.Errorf("unknown time zone %q", fields[1])
+		}
+	}
+
+	return func(value interface{}) bool {
+		t, ok := ParseTime(value)
+		if !ok {
+			return false
+		}
+		if loc != nil {
+			t = t.In(loc)
+		}
+		minute := t.Hour()*60 + t.Minute()
+		if start <= end {
+			return minute >= start && minute < end
+		}
+		return minute >= start || minute < end
+	}, nil
+}
+
+// parseClockTime parses "HH:MM" into minutes after midnight
+func parseClockTime(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// compileAge compares how long ago a timestamp was against a duration, using
+// the evaluator's clock at evaluation time
+func compileAge(accept func(age, limit time.Duration) bool) ClockCompileFunc {
+	return func(operand interface{}, clock Clock) (Matcher, error) {
+		s, ok := operand.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a duration, got %T", operand)
+		}
+		limit, err := ParseDuration(s)
+		if err != nil {
+			return nil, err
+		}
+		return func(value interface{}) bool {
+			t, ok := ParseTime(value)
+			return ok && accept(clock.Now().Sub(t), limit)
+		}, nil
+	}
+}
@@ -0,0 +1,56 @@
+package operators
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+)
+
+// operatorVectors is the layout of api/operator-vectors.json, the cases SDKs
+// that do not use this registry must reproduce
+type operatorVectors struct {
+	Now   time.Time `json:"now"`
+	Cases []struct {
+		Operator string      `json:"operator"`
+		Operand  interface{} `json:"operand"`
+		Value    interface{} `json:"value"`
+		Match    bool        `json:"match"`
+		Error    bool        `json:"error"`
+	} `json:"cases"`
+}
+
+func TestOperatorVectors(t *testing.T) {
+	data, err := os.ReadFile("../../api/operator-vectors.json")
+	if err != nil {
+		t.Fatalf("read vectors: %v", err)
+	}
+	var vectors operatorVectors
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		t.Fatalf("decode vectors: %v", err)
+	}
+
+	clock := FixedClock(vectors.Now)
+	covered := make(map[string]bool)
+	for _, v := range vectors.Cases {
+		covered[v.Operator] = true
+
+		matcher, err := Compile(v.Operator, v.Operand, clock)
+		switch {
+		case v.Error && err == nil:
+			t.Errorf("Compile(%s, %v) succeeded, want an error", v.Operator, v.Operand)
+		case !v.Error && err != nil:
+			t.Errorf("Compile(%s, %v): %v", v.Operator, v.Operand, err)
+		case !v.Error:
+			if got := matcher(v.Value); got != v.Match {
+				t.Errorf("%s %v against %v = %v, want %v", v.Operator, v.Operand, v.Value, got, v.Match)
+			}
+		}
+	}
+
+	for _, def := range builtins {
+		if !covered[def.Name] {
+			t.Errorf("operator %s has no shared test cases", def.Name)
+		}
+	}
+}
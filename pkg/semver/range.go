@@ -0,0 +1,231 @@
+package semver
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Range is a parsed range expression such as ">=2.0.0 <3.0.0". Comparators
+// separated by spaces must all match; alternatives are separated by "||".
+// Supported comparators are =, !=, >, >=, <, <=, caret (^1.2), tilde (~1.2),
+// x-ranges (1.x, 1.2, *) and hyphen ranges (1.2 - 2.0).
+//
+// As in npm, a pre-release version only satisfies a comparator set when one of
+// its comparators names a pre-release of the same major.minor.patch, so
+// "<3.0.0" does not admit "3.0.0-beta".
+type Range struct {
+	raw  string
+	sets [][]comparator
+}
+
+type comparator struct {
+	op      string
+	version Version
+}
+
+// ParseRange parses a range expression
+func ParseRange(s string) (Range, error) {
+	r := Range{raw: s}
+	for _, alternative := range strings.Split(s, "||") {
+		set, err := parseComparatorSet(alternative)
+		if err != nil {
+			return Range{}, fmt.Errorf("invalid range %q: %w", s, err)
+		}
+		r.sets = append(r.sets, set)
+	}
+	return r, nil
+}
+
+// String returns the range as it was written
+func (r Range) String() string {
+	return r.raw
+}
+
+// Contains reports whether the version satisfies the range
+func (r Range) Contains(v Version) bool {
+	for _, set := range r.sets {
+		if setContains(set, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func setContains(set []comparator, v Version) bool {
+	for _, c := range set {
+		if !c.matches(v) {
+			return false
+		}
+	}
+
+	if len(v.Prerelease) == 0 {
+		return true
+	}
+	for _, c := range set {
+		if len(c.version.Prerelease) > 0 && c.version.sameRelease(v) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c comparator) matches(v Version) bool {
+	cmp := v.Compare(c.version)
+	switch c.op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return false
+}
+
+// rangeOperators are comparator prefixes, longest first
+var rangeOperators = []string{">=", "<=", "!=", ">", "<", "=", "^", "~"}
+
+// parseComparatorSet parses space-separated comparators, allowing a space
+// between an operator and its version (">= 2.0")
+func parseComparatorSet(s string) ([]comparator, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty comparator set")
+	}
+
+	var set []comparator
+	for i := 0; i < len(fields); i++ {
+		// Hyphen range: "1.2.3 - 2.3.4" includes both ends
+		if i+2 < len(fields) && fields[i+1] == "-" {
+			from, err := expand(">=", fields[i])
+			if err != nil {
+				return nil, err
+			}
+			to, err := expand("<=", fields[i+2])
+			if err != nil {
+				return nil, err
+			}
+			set = append(set, from...)
+			set = append(set, to...)
+			i += 2
+			continue
+		}
+
+		op, rest := splitOperator(fields[i])
+		if rest == "" && i+1 < len(fields) {
+			i++
+			rest = fields[i]
+		}
+
+		expanded, err := expand(op, rest)
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, expanded...)
+	}
+	return set, nil
+}
+
+func splitOperator(field string) (string, string) {
+	for _, op := range rangeOperators {
+		if strings.HasPrefix(field, op) {
+			return op, field[len(op):]
+		}
+	}
+	return "", field
+}
+
+// expand turns one written comparator into primitive comparators on full
+// versions, filling in partial versions
+func expand(op, text string) ([]comparator, error) {
+	p, err := parsePartial(text)
+	if err != nil {
+		return nil, err
+	}
+	lower := p.version()
+	full := p.count == 3
+
+	switch op {
+	case "", "=":
+		if full {
+			return []comparator{{"=", lower}}, nil
+		}
+		if p.count == 0 {
+			return []comparator{{">=", Version{}}}, nil
+		}
+		return []comparator{{">=", lower}, {"<", bump(p, p.count)}}, nil
+	case "!=":
+		if !full {
+			return nil, fmt.Errorf("!= requires a full version, got %q", text)
+		}
+		return []comparator{{"!=", lower}}, nil
+	case ">":
+		if full {
+			return []comparator{{">", lower}}, nil
+		}
+		if p.count == 0 {
+			return nil, fmt.Errorf("no version can be greater than %q", text)
+		}
+		return []comparator{{">=", bump(p, p.count)}}, nil
+	case ">=":
+		return []comparator{{">=", lower}}, nil
+	case "<":
+		if p.count == 0 {
+			return nil, fmt.Errorf("no version can be less than %q", text)
+		}
+		return []comparator{{"<", lower}}, nil
+	case "<=":
+		if full {
+			return []comparator{{"<=", lower}}, nil
+		}
+		if p.count == 0 {
+			return []comparator{{">=", Version{}}}, nil
+		}
+		return []comparator{{"<", bump(p, p.count)}}, nil
+	case "~":
+		// ~1.2.3 and ~1.2 allow patch changes; ~1 allows minor changes
+		if p.count == 0 {
+			return []comparator{{">=", Version{}}}, nil
+		}
+		level := 2
+		if p.count == 1 {
+			level = 1
+		}
+		return []comparator{{">=", lower}, {"<", bump(p, level)}}, nil
+	case "^":
+		// ^ allows changes that do not modify the left-most non-zero component
+		if p.count == 0 {
+			return []comparator{{">=", Version{}}}, nil
+		}
+		var level int
+		switch {
+		case p.parts[0] > 0 || p.count == 1:
+			level = 1
+		case p.parts[1] > 0 || p.count == 2:
+			level = 2
+		default:
+			level = 3
+		}
+		return []comparator{{">=", lower}, {"<", bump(p, level)}}, nil
+	}
+	return nil, fmt.Errorf("unknown operator %q", op)
+}
+
+// bump returns the smallest release above every version sharing the first
+// level components of p, e.g. bump(1.2.3, 2) = 1.3.0
+func bump(p partial, level int) Version {
+	switch level {
+	case 1:
+		return Version{Major: p.parts[0] + 1}
+	case 2:
+		return Version{Major: p.parts[0], Minor: p.parts[1] + 1}
+	default:
+		return Version{Major: p.parts[0], Minor: p.parts[1], Patch: p.parts[2] + 1}
+	}
+}
@@ -0,0 +1,199 @@
+package semver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a parsed semantic version
+type Version struct {
+	Major      uint64
+	Minor      uint64
+	Patch      uint64
+	Prerelease []string
+	Build      string
+}
+
+// Parse parses a version leniently: a leading "v", surrounding whitespace and
+// missing minor or patch components are accepted, so "v1.2" parses as 1.2.0.
+// Pre-release ("-beta.1") and build metadata ("+build.5") follow SemVer 2.0.
+func Parse(s string) (Version, error) {
+	p, err := parsePartial(s)
+	if err != nil {
+		return Version{}, err
+	}
+	if p.wildcard {
+		return Version{}, fmt.Errorf("invalid version %q: wildcards are only allowed in ranges", s)
+	}
+	return p.version(), nil
+}
+
+// String returns the canonical form of the version
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.Prerelease) > 0 {
+		s += "-" + strings.Join(v.Prerelease, ".")
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 following SemVer 2.0 precedence. Build metadata
+// is ignored and a pre-release sorts before its release.
+func (v Version) Compare(o Version) int {
+	if c := compareUint(v.Major, o.Major); c != 0 {
+		return c
+	}
+	if c := compareUint(v.Minor, o.Minor); c != 0 {
+		return c
+	}
+	if c := compareUint(v.Patch, o.Patch); c != 0 {
+		return c
+	}
+	return comparePrerelease(v.Prerelease, o.Prerelease)
+}
+
+// Compare parses and compares two version strings
+func Compare(a, b string) (int, error) {
+	va, err := Parse(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := Parse(b)
+	if err != nil {
+		return 0, err
+	}
+	return va.Compare(vb), nil
+}
+
+// sameRelease reports whether two versions share major, minor and patch
+func (v Version) sameRelease(o Version) bool {
+	return v.Major == o.Major && v.Minor == o.Minor && v.Patch == o.Patch
+}
+
+// partial is a version that may omit trailing components or use x wildcards,
+// as written in range expressions
+type partial struct {
+	parts      [3]uint64
+	count      int // number of numeric components given
+	wildcard   bool
+	prerelease []string
+	build      string
+}
+
+func (p partial) version() Version {
+	return Version{Major: p.parts[0], Minor: p.parts[1], Patch: p.parts[2], Prerelease: p.prerelease, Build: p.build}
+}
+
+// parsePartial parses a possibly incomplete version such as "1", "1.2",
+// "1.x" or "v1.2.3-rc.1+build"
+func parsePartial(s string) (partial, error) {
+	var p partial
+	raw := s
+
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "v"), "V")
+	if s == "" {
+		return p, fmt.Errorf("invalid version %q", raw)
+	}
+
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		p.build = s[i+1:]
+		s = s[:i]
+		if p.build == "" || !validIdentifiers(p.build) {
+			return p, fmt.Errorf("invalid build metadata in %q", raw)
+		}
+	}
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		pre := s[i+1:]
+		s = s[:i]
+		if pre == "" || !validIdentifiers(pre) {
+			return p, fmt.Errorf("invalid pre-release in %q", raw)
+		}
+		p.prerelease = strings.Split(pre, ".")
+	}
+
+	fields := strings.Split(s, ".")
+	if len(fields) > 3 {
+		return p, fmt.Errorf("invalid version %q: too many components", raw)
+	}
+	for i, field := range fields {
+		if field == "x" || field == "X" || field == "*" {
+			p.wildcard = true
+			break
+		}
+		n, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return p, fmt.Errorf("invalid version %q", raw)
+		}
+		p.parts[i] = n
+		p.count++
+	}
+
+	if (p.wildcard || p.count < 3) && len(p.prerelease) > 0 {
+		return p, fmt.Errorf("invalid version %q: pre-release requires a full version", raw)
+	}
+	return p, nil
+}
+
+// validIdentifiers checks dot-separated pre-release or build identifiers
+func validIdentifiers(s string) bool {
+	for _, id := range strings.Split(s, ".") {
+		if id == "" {
+			return false
+		}
+		for _, r := range id {
+			if !(r == '-' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func compareUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// comparePrerelease orders pre-release identifiers: a release sorts after any
+// pre-release, numeric identifiers compare numerically and sort before
+// alphanumeric ones, and a shorter list sorts first when all else is equal
+func comparePrerelease(a, b []string) int {
+	switch {
+	case len(a) == 0 && len(b) == 0:
+		return 0
+	case len(a) == 0:
+		return 1
+	case len(b) == 0:
+		return -1
+	}
+
+	for i := 0; i < len(a) && i < len(b); i++ {
+		an, aErr := strconv.ParseUint(a[i], 10, 64)
+		bn, bErr := strconv.ParseUint(b[i], 10, 64)
+		switch {
+		case aErr == nil && bErr == nil:
+			if c := compareUint(an, bn); c != 0 {
+				return c
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(a[i], b[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return compareUint(uint64(len(a)), uint64(len(b)))
+}
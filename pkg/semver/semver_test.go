@@ -0,0 +1,95 @@
+package semver
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+// semverVectors is the layout of api/semver-vectors.json, the cases SDKs that
+// do not use this package must reproduce
+type semverVectors struct {
+	Parse []struct {
+		Input   string `json:"input"`
+		Version string `json:"version"`
+		Error   bool   `json:"error"`
+	} `json:"parse"`
+	Compare []struct {
+		A      string `json:"a"`
+		B      string `json:"b"`
+		Result int    `json:"result"`
+	} `json:"compare"`
+	Ranges []struct {
+		Range    string `json:"range"`
+		Version  string `json:"version"`
+		Contains bool   `json:"contains"`
+		Error    bool   `json:"error"`
+	} `json:"ranges"`
+}
+
+func loadSemverVectors(t *testing.T) *semverVectors {
+	t.Helper()
+	data, err := os.ReadFile("../../api/semver-vectors.json")
+	if err != nil {
+		t.Fatalf("read vectors: %v", err)
+	}
+	var vectors semverVectors
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		t.Fatalf("decode vectors: %v", err)
+	}
+	return &vectors
+}
+
+func TestParseVectors(t *testing.T) {
+	for _, v := range loadSemverVectors(t).Parse {
+		got, err := Parse(v.Input)
+		switch {
+		case v.Error && err == nil:
+			t.Errorf("Parse(%q) = %s, want an error", v.Input, got)
+		case !v.Error && err != nil:
+			t.Errorf("Parse(%q): %v", v.Input, err)
+		case !v.Error && got.String() != v.Version:
+			t.Errorf("Parse(%q) = %s, want %s", v.Input, got, v.Version)
+		}
+	}
+}
+
+func TestCompareVectors(t *testing.T) {
+	for _, v := range loadSemverVectors(t).Compare {
+		got, err := Compare(v.A, v.B)
+		if err != nil {
+			t.Errorf("Compare(%q, %q): %v", v.A, v.B, err)
+			continue
+		}
+		if got != v.Result {
+			t.Errorf("Compare(%q, %q) = %d, want %d", v.A, v.B, got, v.Result)
+		}
+		if reverse, _ := Compare(v.B, v.A); reverse != -v.Result {
+			t.Errorf("Compare(%q, %q) = %d, want %d", v.B, v.A, reverse, -v.Result)
+		}
+	}
+}
+
+func TestRangeVectors(t *testing.T) {
+	for _, v := range loadSemverVectors(t).Ranges {
+		r, err := ParseRange(v.Range)
+		if v.Error {
+			if err == nil {
+				t.Errorf("ParseRange(%q) succeeded, want an error", v.Range)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseRange(%q): %v", v.Range, err)
+			continue
+		}
+
+		version, err := Parse(v.Version)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", v.Version, err)
+		}
+		if got := r.Contains(version); got != v.Contains {
+			t.Errorf("%q contains %s = %v, want %v", v.Range, v.Version, got, v.Contains)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package featureflags
+
+import (
+	"sync"
+)
+
+// AssignmentStore records the variation first served to a user by a rollout
+// with sticky buckets, so later changes to its weights do not move users
+// between variations. Implementations must be safe for concurrent use. The
+// platform's bucketing.RedisAssignmentStore satisfies this interface and can
+// share assignments between processes.
+type AssignmentStore interface {
+	// Get returns the variation recorded for the bucketing ID in the
+	// experiment, if any
+	Get(bucketingID, experiment string) (string, bool, error)
+	// Save records the variation served to the bucketing ID in the
+	// experiment. An assignment already recorded for the bucketing ID is
+	// kept, so concurrent first evaluations agree on one variation.
+	Save(bucketingID, experiment, variationKey string) error
+	// Reset forgets every assignment recorded in the experiment
+	Reset(experiment string) error
+}
+
+// MemoryAssignmentStore is an AssignmentStore held in process memory
+type MemoryAssignmentStore struct {
+	mu          sync.RWMutex
+	assignments map[string]map[string]string // experiment -> bucketing ID -> variation
+}
+
+// NewMemoryAssignmentStore creates an empty in-memory assignment store
+func NewMemoryAssignmentStore() *MemoryAssignmentStore {
+	return &MemoryAssignmentStore{assignments: make(map[string]map[string]string)}
+}
+
+// Get implements AssignmentStore
+func (s *MemoryAssignmentStore) Get(bucketingID, experiment string) (string, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	variationID, ok := s.assignments[experiment][bucketingID]
+	return variationID, ok, nil
+}
+
+// Save implements AssignmentStore. An assignment already recorded is kept.
+func (s *MemoryAssignmentStore) Save(bucketingID, experiment, variationID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.assignments[experiment] == nil {
+		s.assignments[experiment] = make(map[string]string)
+	}
+	if _, exists := s.assignments[experiment][bucketingID]; !exists {
+		s.assignments[experiment][bucketingID] = variationID
+	}
+	return nil
+}
+
+// Reset implements AssignmentStore
+func (s *MemoryAssignmentStore) Reset(experiment string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.assignments, experiment)
+	return nil
+}
+
+// stickyExperiment returns the experiment a flag's sticky assignments belong
+// to: its experiment, or the flag itself
+func stickyExperiment(flag *Flag) string {
+	if flag.ExperimentID != "" {
+		return flag.ExperimentID
+	}
+	return flag.Key
+}
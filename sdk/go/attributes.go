@@ -0,0 +1,178 @@
+package featureflags
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// bucketingKey returns the value users are bucketed by: the bucket_by
+// attribute when set and present, otherwise the user ID. A bucket_by naming a
+// context kind buckets by that kind's key. The boolean is false when a
+// bucket_by attribute was set but missing. It mirrors bucketing.BucketingKey
+// on the platform and must be kept in sync with it.
+func bucketingKey(userContext *UserContext, bucketBy string) (string, bool) {
+	if bucketBy == "" || bucketBy == "user_id" || bucketBy == "user_key" || bucketBy == "user" {
+		return userContext.UserID, true
+	}
+
+	value, _ := contextAttribute(bucketBy, userContext)
+	switch v := value.(type) {
+	case string:
+		if v != "" {
+			return v, true
+		}
+	case bool:
+		return strconv.FormatBool(v), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), true
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(v), true
+	}
+	return userContext.UserID, false
+}
+
+// lookupAttributePath resolves a dotted ("company.plan") or JSON pointer
+// ("/company/plan") path against context attributes. A top-level key that
+// matches the whole path takes precedence. It mirrors
+// bucketing.LookupAttribute on the platform and must be kept in sync with it.
+func lookupAttributePath(attributes map[string]interface{}, path string) (interface{}, bool) {
+	if value, ok := attributes[path]; ok {
+		return value, true
+	}
+	if !strings.ContainsAny(path, "./") {
+		return nil, false
+	}
+
+	var current interface{} = attributes
+	for _, segment := range splitAttributePath(path) {
+		next, ok := childValue(current, segment)
+		if !ok {
+			return nil, false
+		}
+		current = next
+	}
+	return current, true
+}
+
+// kindAttribute resolves a path starting with the name of a context kind the
+// user context carries: the kind name alone or "<kind>.key" is its key, and
+// "<kind>.<path>" the kind's attribute at path. addressed is false when the
+// path does not start with such a kind. It mirrors the platform's resolution
+// of kind paths and must be kept in sync with it.
+func kindAttribute(userContext *UserContext, path string) (value interface{}, exists, addressed bool) {
+	if len(userContext.Contexts) == 0 {
+		return nil, false, false
+	}
+	segments := splitAttributePath(path)
+	if len(segments) == 0 {
+		return nil, false, false
+	}
+	kind, ok := userContext.Contexts[segments[0]]
+	if !ok || kind == nil {
+		return nil, false, false
+	}
+
+	rest := segments[1:]
+	if len(rest) == 0 || (len(rest) == 1 && rest[0] == "key") {
+		return kind.Key, kind.Key != "", true
+	}
+	if value, ok := kind.Attributes[strings.Join(rest, ".")]; ok {
+		return value, true, true
+	}
+
+	var current interface{} = kind.Attributes
+	for _, segment := range rest {
+		next, ok := childValue(current, segment)
+		if !ok {
+			return nil, false, true
+		}
+		current = next
+	}
+	return current, true, true
+}
+
+// contextKinds returns the kinds of the entities the user context describes,
+// sorted
+func contextKinds(userContext *UserContext) []string {
+	kinds := make([]string, 0, len(userContext.Contexts)+1)
+	if userContext.UserID != "" {
+		kinds = append(kinds, "user")
+	}
+	for kind, entry := range userContext.Contexts {
+		if entry != nil && kind != "user" {
+			kinds = append(kinds, kind)
+		}
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// attributeRoot returns the top-level attribute a path starts from
+func attributeRoot(path string) string {
+	segments := splitAttributePath(path)
+	if len(segments) == 0 {
+		return path
+	}
+	return segments[0]
+}
+
+// pointerUnescaper decodes the ~1 and ~0 escapes of JSON pointer segments
+var pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+
+// splitAttributePath splits a dotted path or JSON pointer into its segments
+func splitAttributePath(path string) []string {
+	if strings.HasPrefix(path, "/") {
+		segments := strings.Split(path[1:], "/")
+		for i, segment := range segments {
+			segments[i] = pointerUnescaper.Replace(segment)
+		}
+		return segments
+	}
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, ".")
+}
+
+// childValue returns the element of a map or list addressed by a path segment
+func childValue(container interface{}, segment string) (interface{}, bool) {
+	switch c := container.(type) {
+	case map[string]interface{}:
+		value, ok := c[segment]
+		return value, ok
+	case []interface{}:
+		index, err := strconv.Atoi(segment)
+		if err != nil || index < 0 || index >= len(c) {
+			return nil, false
+		}
+		return c[index], true
+	case nil:
+		return nil, false
+	}
+
+	// Typed maps and slices supplied by Go callers
+	rv := reflect.ValueOf(container)
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, false
+		}
+		value := rv.MapIndex(reflect.ValueOf(segment).Convert(rv.Type().Key()))
+		if !value.IsValid() {
+			return nil, false
+		}
+		return value.Interface(), true
+	case reflect.Slice, reflect.Array:
+		index, err := strconv.Atoi(segment)
+		if err != nil || index < 0 || index >= rv.Len() {
+			return nil, false
+		}
+		return rv.Index(index).Interface(), true
+	}
+	return nil, false
+}
@@ -0,0 +1,15 @@
+package featureflags
+
+import "github.com/Sidd-007/feature-flag-platform/pkg/hashing"
+
+// HashVersion identifies the algorithm that maps users to buckets. It is
+// published on the environment and on each flag, and the SDK buckets users
+// with the platform's hasher of that version; see api/bucketing.md.
+type HashVersion = hashing.Version
+
+// Hash versions
+const (
+	HashV1 = hashing.HashV1
+	HashV2 = hashing.HashV2
+	HashV3 = hashing.HashV3
+)
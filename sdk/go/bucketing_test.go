@@ -0,0 +1,56 @@
+package featureflags
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+// bucketingVectors is the part of api/bucketing-vectors.json the SDK's
+// rollouts are checked against. The hashing itself is pkg/hashing's, which is
+// tested against all of the vectors.
+type bucketingVectors struct {
+	Versions []struct {
+		Version   HashVersion `json:"version"`
+		Buckets   int         `json:"buckets"`
+		Bucketing []struct {
+			EnvSalt    string `json:"env_salt"`
+			FlagKey    string `json:"flag_key"`
+			UserKey    string `json:"user_key"`
+			RuleID     string `json:"rule_id"`
+			RuleBucket int    `json:"rule_bucket"`
+		} `json:"bucketing"`
+	} `json:"versions"`
+}
+
+func TestServeRolloutBucketsLikeServer(t *testing.T) {
+	data, err := os.ReadFile("../../api/bucketing-vectors.json")
+	if err != nil {
+		t.Fatalf("read vectors: %v", err)
+	}
+	var vectors bucketingVectors
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		t.Fatalf("decode vectors: %v", err)
+	}
+
+	e := &Evaluator{}
+	for _, version := range vectors.Versions {
+		for _, b := range version.Bucketing {
+			// Two variations split at the user's rule bucket: the user is
+			// served the second only if the SDK computed the same bucket
+			flag := &Flag{
+				Key:         b.FlagKey,
+				HashVersion: version.Version,
+				Variations:  []Variation{{ID: "low", Value: "low"}, {ID: "high", Value: "high"}},
+			}
+			rollout := &RolloutStrategy{Variations: []RolloutSplit{
+				{VariationID: "low", Weight: b.RuleBucket},
+				{VariationID: "high", Weight: version.Buckets - b.RuleBucket},
+			}}
+			result := e.serveRollout(flag, rollout, b.EnvSalt, b.RuleID, &UserContext{UserID: b.UserKey}, ReasonRuleMatch)
+			if result.VariationID != "high" {
+				t.Errorf("version %d: %q served %q, want high", version.Version, b.UserKey, result.VariationID)
+			}
+		}
+	}
+}
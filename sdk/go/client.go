@@ -56,6 +56,14 @@ type Config struct {
 	OfflineEnabled    bool   `json:"offline_enabled"`
 	OfflineConfigPath string `json:"offline_config_path"`
 
+	// Sticky bucketing: rollouts with sticky buckets record assignments
+	// here. Defaults to an in-memory store.
+	AssignmentStore AssignmentStore `json:"-"`
+
+	// Clock supplies the current time to date/time conditions. Defaults to
+	// the system clock.
+	Clock Clock `json:"-"`
+
 	// Timeouts
 	EvaluationTimeout time.Duration `json:"evaluation_timeout"`
 	HTTPTimeout       time.Duration `json:"http_timeout"`
@@ -223,6 +231,9 @@ func (c *Client) initialize() error {
 	}
 
 	// Initialize evaluator
+	if c.config.AssignmentStore == nil {
+		c.config.AssignmentStore = NewMemoryAssignmentStore()
+	}
 	c.evaluator = NewEvaluator(&EvaluatorConfig{
 		EvaluatorEndpoint: c.config.EvaluatorEndpoint,
 		Environment:       c.config.Environment,
@@ -232,6 +243,8 @@ func (c *Client) initialize() error {
 		Cache:             c.cache,
 		Offline:           c.offline,
 		Events:            c.events,
+		Assignments:       c.config.AssignmentStore,
+		Clock:             c.config.Clock,
 	}, c.logger)
 
 	// Initialize streaming client
@@ -652,20 +665,3 @@ func (c *Client) JSONFlag(flagKey string, userContext *UserContext, defaultValue
 func (c *Client) EvaluateMultiple(ctx context.Context, flagKeys []string, userContext *UserContext, defaults map[string]interface{}) (map[string]*EvaluationResult, error) {
 	return c.evaluator.EvaluateMultiple(ctx, flagKeys, userContext, defaults)
 }
-
-// TrackEvent tracks a custom event
-func (c *Client) TrackEvent(ctx context.Context, eventName string, userContext *UserContext, properties map[string]interface{}) error {
-	if c.events == nil {
-		return fmt.Errorf("events are not enabled")
-	}
-
-	event := &Event{
-		Type:       EventTypeCustom,
-		UserID:     userContext.UserID,
-		EventName:  eventName,
-		Properties: properties,
-		Timestamp:  time.Now(),
-	}
-
-	return c.events.Track(ctx, event)
-}
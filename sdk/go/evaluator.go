@@ -6,8 +6,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/Sidd-007/feature-flag-platform/pkg/hashing"
+	"github.com/Sidd-007/feature-flag-platform/pkg/operators"
 	"github.com/rs/zerolog"
 )
 
@@ -15,6 +19,12 @@ import (
 type Evaluator struct {
 	config *EvaluatorConfig
 	logger zerolog.Logger
+
+	// matchers caches compiled conditions by operator and values
+	matchers sync.Map
+
+	// schemaWarnings records context schema violations already logged
+	schemaWarnings sync.Map
 }
 
 // EvaluatorConfig holds configuration for the evaluator
@@ -27,6 +37,8 @@ type EvaluatorConfig struct {
 	Cache             *Cache
 	Offline           *OfflineHandler
 	Events            *EventProcessor
+	Assignments       AssignmentStore // sticky bucketing assignments, nil to disable
+	Clock             Clock           // current time for date/time conditions, nil for the system clock
 }
 
 // NewEvaluator creates a new evaluator
@@ -41,6 +53,8 @@ func NewEvaluator(config *EvaluatorConfig, logger zerolog.Logger) *Evaluator {
 func (e *Evaluator) Evaluate(ctx context.Context, flagKey string, userContext *UserContext, defaultValue interface{}) (*EvaluationResult, error) {
 	startTime := time.Now()
 
+	e.warnContextSchema(userContext)
+
 	// Create default result
 	result := &EvaluationResult{
 		FlagKey:     flagKey,
@@ -139,6 +153,8 @@ func (e *Evaluator) EvaluateMultiple(ctx context.Context, flagKeys []string, use
 		return make(map[string]*EvaluationResult), nil
 	}
 
+	e.warnContextSchema(userContext)
+
 	results := make(map[string]*EvaluationResult)
 
 	// Check cache for all flags first
@@ -275,7 +291,7 @@ func (e *Evaluator) evaluateOffline(flagKey string, userContext *UserContext) *E
 	}
 
 	// Perform local evaluation
-	result := e.evaluateFlag(flag, userContext)
+	result := e.evaluateFlag(flag, e.config.Offline.GetSalt(), userContext)
 	result.Reason = ReasonOffline
 
 	return result
@@ -416,10 +432,21 @@ func (e *Evaluator) mapUserContext(userContext *UserContext) *UserContext {
 	return userContext
 }
 
-// evaluateFlag performs local flag evaluation
-func (e *Evaluator) evaluateFlag(flag *Flag, userContext *UserContext) *EvaluationResult {
-	// Check if flag is enabled
+// evaluateFlag performs local flag evaluation, bucketing rollouts with the
+// environment's salt
+func (e *Evaluator) evaluateFlag(flag *Flag, envSalt string, userContext *UserContext) *EvaluationResult {
+	// Check if flag is enabled. A flag that is off serves its off variation,
+	// whatever its targeting.
 	if !flag.Enabled {
+		if variation := e.findVariation(flag, flag.OffVariation); flag.OffVariation != "" && variation != nil {
+			return &EvaluationResult{
+				FlagKey:     flag.Key,
+				Value:       variation.Value,
+				VariationID: variation.ID,
+				Reason:      ReasonOff,
+				EvaluatedAt: time.Now(),
+			}
+		}
 		return &EvaluationResult{
 			FlagKey:     flag.Key,
 			Value:       flag.DefaultValue,
@@ -449,13 +476,13 @@ func (e *Evaluator) evaluateFlag(flag *Flag, userContext *UserContext) *Evaluati
 		}
 
 		if e.evaluateRule(&rule, userContext) {
-			return e.serveRule(flag, &rule, userContext)
+			return e.serveRule(flag, &rule, envSalt, userContext)
 		}
 	}
 
 	// Fallback to targeting configuration
 	if flag.Targeting != nil && flag.Targeting.Enabled {
-		return e.serveTargeting(flag, flag.Targeting, userContext)
+		return e.serveTargeting(flag, flag.Targeting, envSalt, userContext)
 	}
 
 	// Return default value
@@ -481,39 +508,41 @@ func (e *Evaluator) evaluateRule(rule *Rule, userContext *UserContext) bool {
 
 // evaluateCondition evaluates a single condition
 func (e *Evaluator) evaluateCondition(condition *Condition, userContext *UserContext) bool {
-	value := e.getAttributeValue(condition.Attribute, userContext)
-	if value == "" {
+	value, exists := e.getAttribute(condition.Attribute, userContext)
+	if !exists {
 		return false
 	}
 
-	switch condition.Operator {
-	case OperatorEquals:
-		return e.contains(condition.Values, value)
-	case OperatorNotEquals:
-		return !e.contains(condition.Values, value)
-	case OperatorIn:
-		return e.contains(condition.Values, value)
-	case OperatorNotIn:
-		return !e.contains(condition.Values, value)
-	case OperatorContains:
-		return e.containsSubstring(condition.Values, value)
-	case OperatorNotContains:
-		return !e.containsSubstring(condition.Values, value)
-	case OperatorStartsWith:
-		return e.startsWith(condition.Values, value)
-	case OperatorEndsWith:
-		return e.endsWith(condition.Values, value)
-	// TODO: Implement numeric and regex operators
-	default:
+	matcher, err := e.conditionMatcher(condition)
+	if err != nil {
 		e.logger.Warn().
-			Str("operator", string(condition.Operator)).
-			Msg("Unsupported operator")
+			Err(err).
+			Str("attribute", condition.Attribute).
+			Msg("Invalid condition")
 		return false
 	}
+
+	return matcher(value)
+}
+
+// conditionMatcher returns the compiled matcher for a condition, compiling it
+// on first use
+func (e *Evaluator) conditionMatcher(condition *Condition) (Matcher, error) {
+	key := string(condition.Operator) + "\x00" + strings.Join(condition.Values, "\x00")
+	if cached, ok := e.matchers.Load(key); ok {
+		return cached.(Matcher), nil
+	}
+
+	matcher, err := CompileCondition(condition, e.config.Clock)
+	if err != nil {
+		return nil, err
+	}
+	e.matchers.Store(key, matcher)
+	return matcher, nil
 }
 
 // serveRule serves the result for a matching rule
-func (e *Evaluator) serveRule(flag *Flag, rule *Rule, userContext *UserContext) *EvaluationResult {
+func (e *Evaluator) serveRule(flag *Flag, rule *Rule, envSalt string, userContext *UserContext) *EvaluationResult {
 	if rule.Serve.VariationID != "" {
 		variation := e.findVariation(flag, rule.Serve.VariationID)
 		if variation != nil {
@@ -529,7 +558,7 @@ func (e *Evaluator) serveRule(flag *Flag, rule *Rule, userContext *UserContext)
 	}
 
 	if rule.Serve.Rollout != nil {
-		return e.serveRollout(flag, rule.Serve.Rollout, userContext, ReasonRuleMatch)
+		return e.serveRollout(flag, rule.Serve.Rollout, envSalt, rule.ID, userContext, ReasonRuleMatch)
 	}
 
 	return &EvaluationResult{
@@ -543,7 +572,7 @@ func (e *Evaluator) serveRule(flag *Flag, rule *Rule, userContext *UserContext)
 }
 
 // serveTargeting serves the result for targeting configuration
-func (e *Evaluator) serveTargeting(flag *Flag, targeting *Targeting, userContext *UserContext) *EvaluationResult {
+func (e *Evaluator) serveTargeting(flag *Flag, targeting *Targeting, envSalt string, userContext *UserContext) *EvaluationResult {
 	if targeting.DefaultServe != nil {
 		if targeting.DefaultServe.VariationID != "" {
 			variation := e.findVariation(flag, targeting.DefaultServe.VariationID)
@@ -560,12 +589,12 @@ func (e *Evaluator) serveTargeting(flag *Flag, targeting *Targeting, userContext
 		}
 
 		if targeting.DefaultServe.Rollout != nil {
-			return e.serveRollout(flag, targeting.DefaultServe.Rollout, userContext, ReasonFallthrough)
+			return e.serveRollout(flag, targeting.DefaultServe.Rollout, envSalt, "", userContext, ReasonFallthrough)
 		}
 	}
 
 	if targeting.Rollout != nil {
-		return e.serveRollout(flag, targeting.Rollout, userContext, ReasonFallthrough)
+		return e.serveRollout(flag, targeting.Rollout, envSalt, "", userContext, ReasonFallthrough)
 	}
 
 	return &EvaluationResult{
@@ -578,36 +607,75 @@ func (e *Evaluator) serveTargeting(flag *Flag, targeting *Targeting, userContext
 	}
 }
 
-// serveRollout serves the result for a rollout strategy
-func (e *Evaluator) serveRollout(flag *Flag, rollout *RolloutStrategy, userContext *UserContext, reason Reason) *EvaluationResult {
-	// Get bucket value for user
+// serveRollout serves the result for a rollout strategy. Rollouts of rules
+// bucket by the rule bucket, and fallthrough rollouts, which have no rule ID,
+// by the flag bucket.
+func (e *Evaluator) serveRollout(flag *Flag, rollout *RolloutStrategy, envSalt, ruleID string, userContext *UserContext, reason Reason) *EvaluationResult {
+	// Get bucket value for user, falling back to the user ID like the server
 	bucketBy := rollout.BucketBy
 	if bucketBy == "" {
-		bucketBy = "user_id"
+		bucketBy = flag.BucketBy
 	}
 
-	bucketValue := e.getAttributeValue(bucketBy, userContext)
-	if bucketValue == "" {
-		bucketValue = userContext.UserID
+	bucketValue, found := bucketingKey(userContext, bucketBy)
+	missing := ""
+	if !found {
+		missing = bucketBy
+		e.logger.Debug().
+			Str("flag_key", flag.Key).
+			Str("bucket_by", bucketBy).
+			Msg("Bucket by attribute missing, bucketing by user ID")
 	}
 
-	// Calculate bucket (0-9999)
-	bucket := calculateBucket(flag.Key, bucketValue)
+	// Users keep the variation a sticky rollout first served them
+	sticky := rollout.StickyBuckets && e.config.Assignments != nil
+	if sticky {
+		variationID, found, err := e.config.Assignments.Get(bucketValue, stickyExperiment(flag))
+		if err != nil {
+			e.logger.Warn().Err(err).Str("flag_key", flag.Key).Msg("Failed to read sticky assignment")
+		} else if found {
+			if variation := e.findVariation(flag, variationID); variation != nil {
+				return &EvaluationResult{
+					FlagKey:         flag.Key,
+					Value:           variation.Value,
+					VariationID:     variation.ID,
+					Reason:          reason,
+					DefaultUsed:     false,
+					EvaluatedAt:     time.Now(),
+					BucketByMissing: missing,
+				}
+			}
+		}
+	}
+
+	// Bucket the user as the server does, see api/bucketing.md
+	hasher := hashing.ForVersion(flag.HashVersion)
+	bucket := hasher.DeterministicBucket(hasher.GenerateBucketingID(envSalt, flag.Key, bucketValue) + ruleID)
 
-	// Find matching variation based on weights
-	currentWeight := 0
-	for _, split := range rollout.Variations {
-		currentWeight += split.Weight
-		if bucket < currentWeight {
+	weights := make([]float64, len(rollout.Variations))
+	for i, split := range rollout.Variations {
+		weights[i] = float64(split.Weight)
+	}
+
+	// Find the variation whose bucket range holds the user
+	ranges := hasher.AllocateBucketsForVariations(weights)
+	for i, split := range rollout.Variations {
+		if i < len(ranges) && ranges[i].Contains(bucket) {
 			variation := e.findVariation(flag, split.VariationID)
 			if variation != nil {
+				if sticky {
+					if err := e.config.Assignments.Save(bucketValue, stickyExperiment(flag), variation.ID); err != nil {
+						e.logger.Warn().Err(err).Str("flag_key", flag.Key).Msg("Failed to record sticky assignment")
+					}
+				}
 				return &EvaluationResult{
-					FlagKey:     flag.Key,
-					Value:       variation.Value,
-					VariationID: variation.ID,
-					Reason:      reason,
-					DefaultUsed: false,
-					EvaluatedAt: time.Now(),
+					FlagKey:         flag.Key,
+					Value:           variation.Value,
+					VariationID:     variation.ID,
+					Reason:          reason,
+					DefaultUsed:     false,
+					EvaluatedAt:     time.Now(),
+					BucketByMissing: missing,
 				}
 			}
 		}
@@ -640,35 +708,52 @@ func (e *Evaluator) trackExposure(ctx context.Context, result *EvaluationResult,
 		ExperimentID: result.ExperimentID,
 		Reason:       result.Reason,
 		Context:      userContext,
+		ContextKinds: contextKinds(userContext),
 	}
 
 	e.config.Events.TrackExposure(ctx, exposure)
 }
 
-func (e *Evaluator) getAttributeValue(attribute string, userContext *UserContext) string {
+// getAttribute returns the typed value of an attribute. "now" defaults to the
+// current time in the user's timezone.
+func (e *Evaluator) getAttribute(attribute string, userContext *UserContext) (interface{}, bool) {
+	value, exists := contextAttribute(attribute, userContext)
+	if !exists && attribute == "now" {
+		return operators.NowIn(e.config.Clock, userContext.Timezone), true
+	}
+	return value, exists
+}
+
+// contextAttribute returns the typed value of an attribute, following nested
+// paths. Paths starting with a context kind address that kind's context.
+// Built-in attributes are strings and count as missing when empty.
+func contextAttribute(attribute string, userContext *UserContext) (interface{}, bool) {
+	if value, exists, addressed := kindAttribute(userContext, attribute); addressed {
+		return value, exists
+	}
+
+	var value string
 	switch attribute {
 	case "user_id":
-		return userContext.UserID
+		value = userContext.UserID
 	case "email":
-		return userContext.Email
+		value = userContext.Email
 	case "country":
-		return userContext.Country
+		value = userContext.Country
 	case "region":
-		return userContext.Region
+		value = userContext.Region
 	case "city":
-		return userContext.City
+		value = userContext.City
 	case "platform":
-		return userContext.Platform
+		value = userContext.Platform
 	case "version":
-		return userContext.Version
+		value = userContext.Version
 	case "language":
-		return userContext.Language
+		value = userContext.Language
 	default:
-		if value, exists := userContext.GetAttribute(attribute); exists {
-			return fmt.Sprintf("%v", value)
-		}
-		return ""
+		return lookupAttributePath(userContext.Attributes, attribute)
 	}
+	return value, value != ""
 }
 
 func (e *Evaluator) findVariation(flag *Flag, variationID string) *Variation {
@@ -679,63 +764,3 @@ func (e *Evaluator) findVariation(flag *Flag, variationID string) *Variation {
 	}
 	return nil
 }
-
-func (e *Evaluator) contains(values []string, target string) bool {
-	for _, value := range values {
-		if value == target {
-			return true
-		}
-	}
-	return false
-}
-
-func (e *Evaluator) containsSubstring(values []string, target string) bool {
-	for _, value := range values {
-		if len(value) > 0 && len(target) > 0 {
-			// Simple substring check - in production you'd use strings.Contains
-			// This is a simplified implementation
-			if value == target {
-				return true
-			}
-		}
-	}
-	return false
-}
-
-func (e *Evaluator) startsWith(values []string, target string) bool {
-	for _, value := range values {
-		if len(value) > 0 && len(target) >= len(value) {
-			// Simple prefix check - in production you'd use strings.HasPrefix
-			if target[:len(value)] == value {
-				return true
-			}
-		}
-	}
-	return false
-}
-
-func (e *Evaluator) endsWith(values []string, target string) bool {
-	for _, value := range values {
-		if len(value) > 0 && len(target) >= len(value) {
-			// Simple suffix check - in production you'd use strings.HasSuffix
-			if target[len(target)-len(value):] == value {
-				return true
-			}
-		}
-	}
-	return false
-}
-
-// calculateBucket calculates a bucket value (0-9999) for consistent assignment
-func calculateBucket(flagKey, userValue string) int {
-	// This should use the same hashing algorithm as the backend
-	// For now, using a simple hash
-	hash := 0
-	for _, char := range flagKey + ":" + userValue {
-		hash = hash*31 + int(char)
-	}
-	if hash < 0 {
-		hash = -hash
-	}
-	return hash % 10000
-}
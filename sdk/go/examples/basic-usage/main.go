@@ -138,15 +138,10 @@ func main() {
 
 	// Example 6: Track custom events
 	fmt.Println("\n=== Custom Event Example ===")
-	err = client.TrackEvent(ctx, &featureflags.Event{
-		Type:      featureflags.EventTypeCustom,
-		UserID:    userContext.UserID,
-		EventName: "button_clicked",
-		Properties: map[string]interface{}{
-			"button_color": buttonColor,
-			"page":         "homepage",
-			"timestamp":    time.Now().Unix(),
-		},
+	err = client.TrackEvent(ctx, "button_clicked", userContext, map[string]interface{}{
+		"button_color": buttonColor,
+		"page":         "homepage",
+		"timestamp":    time.Now().Unix(),
 	})
 	if err != nil {
 		log.Printf("Error tracking custom event: %v", err)
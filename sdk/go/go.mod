@@ -2,10 +2,17 @@ module github.com/Sidd-007/feature-flag-platform/sdk/go
 
 go 1.22
 
-require github.com/rs/zerolog v1.32.0
+require (
+	github.com/Sidd-007/feature-flag-platform v0.0.0-00010101000000-000000000000
+	github.com/rs/zerolog v1.32.0
+)
 
 require (
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.19 // indirect
-	golang.org/x/sys v0.12.0 // indirect
+	golang.org/x/sys v0.13.0 // indirect
 )
+
+// The SDK evaluates with the platform's operator, semver and hashing
+// packages, from this repository
+replace github.com/Sidd-007/feature-flag-platform => ../..
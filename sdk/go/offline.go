@@ -132,6 +132,30 @@ func (oh *OfflineHandler) GetSegment(segmentID string) (*Segment, bool) {
 	return &segmentCopy, true
 }
 
+// GetContextSchema returns the context schema of the offline configuration,
+// or nil when none is declared
+func (oh *OfflineHandler) GetContextSchema() *ContextSchema {
+	oh.mutex.RLock()
+	defer oh.mutex.RUnlock()
+
+	if oh.environment == nil {
+		return nil
+	}
+	return oh.environment.ContextSchema
+}
+
+// GetSalt returns the salt bucketing IDs of the offline configuration are
+// derived from
+func (oh *OfflineHandler) GetSalt() string {
+	oh.mutex.RLock()
+	defer oh.mutex.RUnlock()
+
+	if oh.environment == nil {
+		return ""
+	}
+	return oh.environment.Salt
+}
+
 // UpdateConfiguration updates the offline configuration with new data
 func (oh *OfflineHandler) UpdateConfiguration(environment *Environment) error {
 	oh.mutex.Lock()
@@ -492,6 +516,15 @@ func (oh *OfflineHandler) ValidateConfiguration() []string {
 			errors = append(errors, fmt.Sprintf("invalid flag: %s", key))
 		}
 
+		// Check conditions compile, which rejects unknown operators
+		for _, rule := range flag.Rules {
+			for i := range rule.Conditions {
+				if _, err := CompileCondition(&rule.Conditions[i], nil); err != nil {
+					errors = append(errors, fmt.Sprintf("flag %s: rule %s: condition on %s: %v", key, rule.ID, rule.Conditions[i].Attribute, err))
+				}
+			}
+		}
+
 		// Check variation references
 		for _, rule := range flag.Rules {
 			if rule.Serve.VariationID != "" {
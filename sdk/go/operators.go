@@ -0,0 +1,185 @@
+package featureflags
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Sidd-007/feature-flag-platform/pkg/operators"
+)
+
+// The SDK evaluates conditions with the platform's operator registry, so
+// local and remote evaluation run the same operator code. Operators
+// registered with RegisterOperator are registered with the platform registry
+// too.
+
+// Matcher reports whether an attribute value satisfies a compiled condition
+type Matcher = operators.Matcher
+
+// OperatorCompileFunc builds a Matcher from a condition's operand
+type OperatorCompileFunc = operators.CompileFunc
+
+// OperatorClockCompileFunc builds a Matcher for an operator that compares
+// against the current time, which the matcher reads from clock
+type OperatorClockCompileFunc = operators.ClockCompileFunc
+
+// ArgType describes the type of an attribute value or operand an operator
+// accepts
+type ArgType = operators.ArgType
+
+const (
+	ArgAny    = operators.ArgAny
+	ArgString = operators.ArgString
+	ArgNumber = operators.ArgNumber
+	ArgBool   = operators.ArgBool
+	ArgList   = operators.ArgList
+)
+
+// OperatorDefinition describes a named operator, see operators.Definition
+type OperatorDefinition struct {
+	Name Operator
+
+	// ValueType is the attribute value type the operator accepts. Values of
+	// any other type never match. An empty type accepts anything.
+	ValueType ArgType
+
+	// OperandType is the operand type the operator requires. List operators
+	// receive all of a condition's values; other operators receive the first.
+	// An empty type accepts anything.
+	OperandType ArgType
+
+	// Validate is an optional hook that checks the operand at compile time
+	Validate func(operand interface{}) error
+
+	// Compile builds the matcher for a validated operand
+	Compile OperatorCompileFunc
+
+	// CompileWithClock replaces Compile for operators that compare against
+	// the current time, such as older_than. It receives the evaluator's
+	// clock.
+	CompileWithClock OperatorClockCompileFunc
+}
+
+// legacyOperators are the long operator names of earlier SDK releases, kept
+// for existing rules. All but starts_with and ends_with are aliases of
+// platform operators.
+var legacyOperators = []OperatorDefinition{
+	aliasOperator(OperatorEquals, OperatorIn, false),
+	aliasOperator(OperatorNotEquals, OperatorIn, true),
+	aliasOperator(OperatorNotIn, OperatorIn, true),
+	aliasOperator(OperatorNotContains, OperatorContains, true),
+	{Name: OperatorStartsWith, OperandType: ArgString, Compile: compileAffix(strings.HasPrefix)},
+	{Name: OperatorEndsWith, OperandType: ArgString, Compile: compileAffix(strings.HasSuffix)},
+	aliasOperator(OperatorGreaterThan, OperatorGt, false),
+	aliasOperator(OperatorGreaterThanEq, OperatorGte, false),
+	aliasOperator(OperatorLessThan, OperatorLt, false),
+	aliasOperator(OperatorLessThanEq, OperatorLte, false),
+}
+
+func init() {
+	for _, def := range legacyOperators {
+		if err := RegisterOperator(def); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterOperator adds a custom operator. Operators should be registered
+// before the client is created.
+func RegisterOperator(def OperatorDefinition) error {
+	return operators.Register(operators.Definition{
+		Name:             string(def.Name),
+		ValueType:        def.ValueType,
+		OperandType:      def.OperandType,
+		Validate:         def.Validate,
+		Compile:          def.Compile,
+		CompileWithClock: def.CompileWithClock,
+	})
+}
+
+// LookupOperator returns the definition of a registered operator
+func LookupOperator(name Operator) (OperatorDefinition, bool) {
+	def, exists := operators.Lookup(string(name))
+	if !exists {
+		return OperatorDefinition{}, false
+	}
+	return OperatorDefinition{
+		Name:             name,
+		ValueType:        def.ValueType,
+		OperandType:      def.OperandType,
+		Validate:         def.Validate,
+		Compile:          def.Compile,
+		CompileWithClock: def.CompileWithClock,
+	}, true
+}
+
+// CompileCondition compiles a condition into a Matcher, reporting unknown
+// operators and invalid values. Operators that compare against the current
+// time read it from clock; nil is the system clock.
+func CompileCondition(condition *Condition, clock Clock) (Matcher, error) {
+	def, exists := LookupOperator(condition.Operator)
+	if !exists {
+		return nil, fmt.Errorf("unknown operator %q", condition.Operator)
+	}
+
+	var operand interface{}
+	if def.OperandType == ArgList {
+		items := make([]interface{}, len(condition.Values))
+		for i, v := range condition.Values {
+			items[i] = v
+		}
+		operand = items
+	} else {
+		if len(condition.Values) == 0 {
+			return nil, fmt.Errorf("operator %s requires a value", condition.Operator)
+		}
+		operand = condition.Values[0]
+	}
+
+	return operators.Compile(string(condition.Operator), operand, clock)
+}
+
+// aliasOperator defines name as the platform operator of, negated if negate
+// is set. It panics if of is not registered.
+func aliasOperator(name, of Operator, negate bool) OperatorDefinition {
+	def, exists := LookupOperator(of)
+	if !exists || def.Compile == nil {
+		panic(fmt.Sprintf("operator %s: cannot alias %s", name, of))
+	}
+	def.Name = name
+	if negate {
+		compile := def.Compile
+		def.Compile = func(operand interface{}) (Matcher, error) {
+			matcher, err := compile(operand)
+			if err != nil {
+				return nil, err
+			}
+			return func(value interface{}) bool { return !matcher(value) }, nil
+		}
+	}
+	return def
+}
+
+func compileAffix(match func(s, affix string) bool) OperatorCompileFunc {
+	return func(operand interface{}) (Matcher, error) {
+		affix := operators.Key(operand)
+		return func(value interface{}) bool {
+			return value != nil && match(operators.Key(value), affix)
+		}, nil
+	}
+}
+
+// Clock supplies the current time to relative date/time operators and to the
+// "now" attribute. Set Config.Clock to reproduce evaluations in tests.
+type Clock = operators.Clock
+
+// ClockFunc adapts a function to the Clock interface
+type ClockFunc = operators.ClockFunc
+
+// FixedClock returns a clock that always reports t
+func FixedClock(t time.Time) Clock {
+	return operators.FixedClock(t)
+}
+
+// SystemClock reads the wall clock. Evaluators given no clock use it.
+var SystemClock = operators.SystemClock
@@ -0,0 +1,71 @@
+package featureflags
+
+import "testing"
+
+func TestLegacyOperators(t *testing.T) {
+	tests := []struct {
+		operator Operator
+		values   []string
+		value    interface{}
+		want     bool
+	}{
+		{OperatorEquals, []string{"a", "b"}, "b", true},
+		{OperatorEquals, []string{"30"}, 30, true},
+		{OperatorNotEquals, []string{"a", "b"}, "b", false},
+		{OperatorNotEquals, []string{"a", "b"}, "c", true},
+		{OperatorNotIn, []string{"a", "b"}, "c", true},
+		{OperatorNotContains, []string{"beta"}, "beta-tester", false},
+		{OperatorNotContains, []string{"beta"}, "tester", true},
+		{OperatorStartsWith, []string{"user-"}, "user-1", true},
+		{OperatorStartsWith, []string{"user-"}, "admin-1", false},
+		{OperatorEndsWith, []string{"@example.com"}, "jo@example.com", true},
+		{OperatorEndsWith, []string{"@example.com"}, nil, false},
+		{OperatorGreaterThan, []string{"18"}, 21, true},
+		{OperatorGreaterThanEq, []string{"18"}, 18, true},
+		{OperatorLessThan, []string{"18"}, 18, false},
+		{OperatorLessThanEq, []string{"18"}, "18", true},
+	}
+
+	for _, tt := range tests {
+		matcher, err := CompileCondition(&Condition{Attribute: "a", Operator: tt.operator, Values: tt.values}, nil)
+		if err != nil {
+			t.Errorf("compile %s %v: %v", tt.operator, tt.values, err)
+			continue
+		}
+		if got := matcher(tt.value); got != tt.want {
+			t.Errorf("%s %v against %v = %v, want %v", tt.operator, tt.values, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestRegisterOperatorSharesPlatformRegistry(t *testing.T) {
+	err := RegisterOperator(OperatorDefinition{
+		Name:        "test_sdk_even",
+		ValueType:   ArgNumber,
+		OperandType: ArgAny,
+		Compile: func(operand interface{}) (Matcher, error) {
+			return func(value interface{}) bool {
+				n, ok := value.(int)
+				return ok && n%2 == 0
+			}, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterOperator(OperatorDefinition{Name: OperatorEq, Compile: func(interface{}) (Matcher, error) { return nil, nil }}); err == nil {
+		t.Error("registered eq twice")
+	}
+
+	def, exists := LookupOperator("test_sdk_even")
+	if !exists || def.Name != "test_sdk_even" || def.ValueType != ArgNumber {
+		t.Fatalf("LookupOperator = %+v, %v", def, exists)
+	}
+	matcher, err := CompileCondition(&Condition{Operator: "test_sdk_even", Values: []string{"x"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !matcher(4) || matcher(3) || matcher(true) {
+		t.Error("custom operator matched the wrong values")
+	}
+}
@@ -0,0 +1,215 @@
+package featureflags
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Sidd-007/feature-flag-platform/pkg/operators"
+	"github.com/Sidd-007/feature-flag-platform/pkg/semver"
+)
+
+// AttributeType is the declared type of a context attribute
+type AttributeType string
+
+const (
+	AttributeString   AttributeType = "string"
+	AttributeNumber   AttributeType = "number"
+	AttributeBoolean  AttributeType = "boolean"
+	AttributeVersion  AttributeType = "version"  // semantic version string
+	AttributeDateTime AttributeType = "datetime" // RFC3339 timestamp, date or Unix seconds
+	AttributeList     AttributeType = "list"
+)
+
+// Schema violation codes, matching the server
+const (
+	SchemaMissingAttribute    = "missing_attribute"
+	SchemaTypeMismatch        = "type_mismatch"
+	SchemaValueNotAllowed     = "value_not_allowed"
+	SchemaUndeclaredAttribute = "undeclared_attribute"
+)
+
+// ContextSchema declares the attributes user contexts are expected to carry.
+// It is shipped with the environment configuration; the SDK logs a warning
+// the first time a context violates it.
+type ContextSchema struct {
+	Attributes map[string]*AttributeSchema `json:"attributes"`
+	Strict     bool                        `json:"strict,omitempty"`
+}
+
+// AttributeSchema declares a single context attribute
+type AttributeSchema struct {
+	Type          AttributeType `json:"type"`
+	Description   string        `json:"description,omitempty"`
+	Required      bool          `json:"required,omitempty"`
+	AllowedValues []interface{} `json:"allowed_values,omitempty"`
+	PII           bool          `json:"pii,omitempty"`
+}
+
+// SchemaViolation describes an attribute that does not match the schema
+type SchemaViolation struct {
+	Attribute string `json:"attribute"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+}
+
+// ValidateContext reports every way the user context departs from the
+// schema. Built-in fields such as "email" are checked under their attribute
+// names. A nil schema accepts any context.
+func (s *ContextSchema) ValidateContext(userContext *UserContext) []SchemaViolation {
+	if s == nil || userContext == nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(s.Attributes))
+	for name, attr := range s.Attributes {
+		if attr != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var violations []SchemaViolation
+	for _, name := range names {
+		attr := s.Attributes[name]
+
+		value, exists := contextAttribute(name, userContext)
+		if !exists || value == nil {
+			if attr.Required {
+				violations = append(violations, SchemaViolation{name, SchemaMissingAttribute, "required attribute is missing"})
+			}
+			continue
+		}
+		if v := attr.check(name, value); v != nil {
+			violations = append(violations, *v)
+		}
+	}
+
+	if s.Strict {
+		// A top-level attribute is declared when any declared path starts at
+		// it; an attribute of a context kind when a path starts at
+		// "<kind>.<attribute>", or when the kind itself is declared
+		roots := make(map[string]bool, len(s.Attributes))
+		for name := range s.Attributes {
+			roots[name] = true
+			roots[attributeRoot(name)] = true
+			if segments := splitAttributePath(name); len(segments) > 1 {
+				roots[segments[0]+"."+segments[1]] = true
+			}
+		}
+		undeclared := make([]string, 0)
+		for name := range userContext.Attributes {
+			if !roots[name] {
+				undeclared = append(undeclared, name)
+			}
+		}
+		for kind, entry := range userContext.Contexts {
+			if _, declared := s.Attributes[kind]; entry == nil || declared {
+				continue
+			}
+			for name := range entry.Attributes {
+				if path := kind + "." + name; !roots[path] {
+					undeclared = append(undeclared, path)
+				}
+			}
+		}
+		sort.Strings(undeclared)
+		for _, name := range undeclared {
+			violations = append(violations, SchemaViolation{name, SchemaUndeclaredAttribute, "attribute is not declared in the context schema"})
+		}
+	}
+
+	return violations
+}
+
+// check validates a present attribute value
+func (a *AttributeSchema) check(name string, value interface{}) *SchemaViolation {
+	if !a.conforms(value) {
+		return &SchemaViolation{name, SchemaTypeMismatch, fmt.Sprintf("value %s is not a %s", describeValue(value), a.Type)}
+	}
+
+	items := []interface{}{value}
+	if a.Type == AttributeList {
+		items, _ = operators.ToSlice(value)
+	}
+	for _, item := range items {
+		if !a.allows(item) {
+			return &SchemaViolation{name, SchemaValueNotAllowed, fmt.Sprintf("value %s is not an allowed value", describeValue(item))}
+		}
+	}
+	return nil
+}
+
+// conforms reports whether a value has the declared type. Values are not
+// coerced: the string "30" is not a number.
+func (a *AttributeSchema) conforms(value interface{}) bool {
+	switch a.Type {
+	case AttributeString:
+		_, ok := value.(string)
+		return ok
+	case AttributeNumber:
+		if _, ok := value.(string); ok {
+			return false
+		}
+		_, ok := operators.ToFloat64(value)
+		return ok
+	case AttributeBoolean:
+		_, ok := value.(bool)
+		return ok
+	case AttributeVersion:
+		s, ok := value.(string)
+		if !ok {
+			return false
+		}
+		_, err := semver.Parse(s)
+		return err == nil
+	case AttributeDateTime:
+		_, ok := operators.ParseTime(value)
+		return ok
+	case AttributeList:
+		_, ok := operators.ToSlice(value)
+		return ok
+	}
+	return false
+}
+
+// allows reports whether a value is one of the allowed values, if any are
+// declared
+func (a *AttributeSchema) allows(value interface{}) bool {
+	if len(a.AllowedValues) == 0 {
+		return true
+	}
+	key := operators.Key(value)
+	for _, allowed := range a.AllowedValues {
+		if operators.Key(allowed) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// describeValue formats a value for violation messages, quoting strings so
+// that "30" and 30 can be told apart
+func describeValue(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// warnContextSchema logs each kind of schema violation once per attribute, so
+// a misconfigured caller does not flood the logs
+func (e *Evaluator) warnContextSchema(userContext *UserContext) {
+	if e.config.Offline == nil {
+		return
+	}
+
+	for _, violation := range e.config.Offline.GetContextSchema().ValidateContext(userContext) {
+		if _, warned := e.schemaWarnings.LoadOrStore(violation.Attribute+"\x00"+violation.Code, true); warned {
+			continue
+		}
+		e.logger.Warn().
+			Str("attribute", violation.Attribute).
+			Str("code", violation.Code).
+			Msg("Context does not match schema: " + violation.Message)
+	}
+}
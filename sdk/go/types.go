@@ -22,6 +22,18 @@ type UserContext struct {
 	Timezone   string                 `json:"timezone,omitempty"`
 	Groups     []string               `json:"groups,omitempty"`
 	Attributes map[string]interface{} `json:"attributes,omitempty"`
+
+	// Contexts describes the entities other than the user the evaluation is
+	// for, keyed by kind, such as "organization" or "device". Conditions
+	// address them as "organization.plan", and rollouts can bucket by a kind.
+	Contexts map[string]*KindContext `json:"contexts,omitempty"`
+}
+
+// KindContext describes an entity other than the user, such as the user's
+// organization or device
+type KindContext struct {
+	Key        string                 `json:"key"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
 }
 
 // EvaluationResult represents the result of a flag evaluation
@@ -35,6 +47,10 @@ type EvaluationResult struct {
 	DefaultUsed  bool        `json:"default_used"`
 	CacheHit     bool        `json:"cache_hit"`
 	EvaluatedAt  time.Time   `json:"evaluated_at"`
+
+	// BucketByMissing names the bucket_by attribute the user context lacked
+	// when a rollout fell back to bucketing by user ID
+	BucketByMissing string `json:"bucket_by_missing,omitempty"`
 }
 
 // Reason represents why a particular evaluation result was returned
@@ -60,11 +76,14 @@ type Flag struct {
 	Type          FlagType       `json:"type"`
 	Enabled       bool           `json:"enabled"`
 	DefaultValue  interface{}    `json:"default_value"`
+	OffVariation  string         `json:"off_variation,omitempty"` // variation served while the flag is off; unset serves the default value
 	Variations    []Variation    `json:"variations"`
 	Rules         []Rule         `json:"rules"`
 	Targeting     *Targeting     `json:"targeting,omitempty"`
 	Prerequisites []Prerequisite `json:"prerequisites,omitempty"`
 	ExperimentID  string         `json:"experiment_id,omitempty"`
+	BucketBy      string         `json:"bucket_by,omitempty"` // attribute rollouts bucket by instead of the user ID
+	HashVersion   HashVersion    `json:"hash_version,omitempty"`
 	Version       int64          `json:"version"`
 	CreatedAt     time.Time      `json:"created_at"`
 	UpdatedAt     time.Time      `json:"updated_at"`
@@ -114,6 +133,8 @@ const (
 	OperatorIn            Operator = "in"
 	OperatorNotIn         Operator = "not_in"
 	OperatorContains      Operator = "contains"
+	OperatorContainsAny   Operator = "contains_any" // list attribute holds any of the values
+	OperatorContainsAll   Operator = "contains_all" // list attribute holds all of the values
 	OperatorNotContains   Operator = "not_contains"
 	OperatorStartsWith    Operator = "starts_with"
 	OperatorEndsWith      Operator = "ends_with"
@@ -127,6 +148,26 @@ const (
 	OperatorSemverLt      Operator = "semver_lt"
 	OperatorSemverGte     Operator = "semver_gte"
 	OperatorSemverLte     Operator = "semver_lte"
+	OperatorSemverNeq     Operator = "semver_neq"
+	OperatorSemver        Operator = "semver" // range expression, e.g. ">=2.0.0 <3.0.0"
+
+	// Short operator names used by platform rule sets
+	OperatorEq  Operator = "eq"
+	OperatorNeq Operator = "neq"
+	OperatorNin Operator = "nin"
+	OperatorLt  Operator = "lt"
+	OperatorGt  Operator = "gt"
+	OperatorLte Operator = "lte"
+	OperatorGte Operator = "gte"
+
+	// Date/time operators. Values are RFC3339 timestamps, dates or Unix
+	// seconds; the "now" attribute resolves to the current time in the
+	// user's timezone.
+	OperatorBefore    Operator = "before"
+	OperatorAfter     Operator = "after"
+	OperatorBetween   Operator = "between"
+	OperatorOlderThan Operator = "older_than"
+	OperatorNewerThan Operator = "newer_than"
 )
 
 // Serve represents what to serve when a rule matches
@@ -139,7 +180,7 @@ type Serve struct {
 type RolloutStrategy struct {
 	Type          RolloutType    `json:"type"`
 	Variations    []RolloutSplit `json:"variations"`
-	BucketBy      string         `json:"bucket_by,omitempty"`
+	BucketBy      string         `json:"bucket_by,omitempty"` // overrides the flag's bucket_by
 	StickyBuckets bool           `json:"sticky_buckets"`
 }
 
@@ -179,6 +220,14 @@ type Environment struct {
 	Segments  map[string]*Segment `json:"segments"`
 	Version   int64               `json:"version"`
 	UpdatedAt time.Time           `json:"updated_at"`
+
+	// Salt and HashVersion determine the buckets users are served rollouts
+	// from, as described in api/bucketing.md
+	Salt        string      `json:"salt,omitempty"`
+	HashVersion HashVersion `json:"hash_version,omitempty"`
+
+	// ContextSchema, when set, declares the expected context attributes
+	ContextSchema *ContextSchema `json:"context_schema,omitempty"`
 }
 
 // Segment represents a user segment
@@ -204,6 +253,7 @@ type ExposureEvent struct {
 	ExperimentID string                 `json:"experiment_id,omitempty"`
 	Reason       Reason                 `json:"reason"`
 	Context      *UserContext           `json:"context,omitempty"`
+	ContextKinds []string               `json:"context_kinds,omitempty"`
 	Properties   map[string]interface{} `json:"properties,omitempty"`
 }
 